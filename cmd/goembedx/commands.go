@@ -3,14 +3,31 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	grpcserver "github.com/ldaidone/goembedx/internal/server/grpc"
+	"github.com/ldaidone/goembedx/internal/server/rest"
 	"github.com/ldaidone/goembedx/pkg/embedx"
+	"github.com/ldaidone/goembedx/pkg/vecio"
+	"github.com/ldaidone/goembedx/vector"
 	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
 )
 
 // dbPath stores the database path specified by the --db flag.
 var dbPath string
 
+// collection stores the collection name specified by the --collection flag.
+var collection string
+
 // Execute runs the CLI command with the given embedx engine.
 // It sets up the root command with subcommands and executes it.
 func Execute(engine *embedx.Embedder) {
@@ -21,15 +38,25 @@ func Execute(engine *embedx.Embedder) {
 It provides CLI tools for adding and searching vector embeddings.`,
 
 		// attach engine to context for subcommands
-		PersistentPreRun: func(cmd *cobra.Command, args []string) {
-			ctx := embedx.WithEngine(cmd.Context(), engine)
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			e := engine
+			if collection != "" {
+				var err error
+				// init creates the collection it is asked to initialize.
+				if e, err = collectionEngine(engine, collection, cmd.Name() == "init"); err != nil {
+					return err
+				}
+			}
+			ctx := embedx.WithEngine(cmd.Context(), e)
 			cmd.SetContext(ctx)
+			return nil
 		},
 	}
 
 	root.PersistentFlags().StringVar(&dbPath, "db", "./data", "database path for persistent storage")
+	root.PersistentFlags().StringVar(&collection, "collection", "", "collection to use instead of the default keyspace of the store")
 
-	root.AddCommand(cmdInit(), cmdAdd(), cmdSearch())
+	root.AddCommand(cmdInit(), cmdAdd(), cmdSearch(), cmdDelete(), cmdImport(), cmdExport(), cmdTrain(), cmdServe())
 
 	if err := root.Execute(); err != nil {
 		panic(err)
@@ -38,20 +65,96 @@ It provides CLI tools for adding and searching vector embeddings.`,
 
 // cmdInit creates the 'init' command for initializing the vector store.
 func cmdInit() *cobra.Command {
-	return &cobra.Command{
+	var (
+		dim          int
+		metric       string
+		model        string
+		modelVersion string
+		precision    string
+	)
+
+	cmd := &cobra.Command{
 		Use:   "init",
-		Short: "Initialize vector store (already done in main)",
-		Long:  `Initialize the vector store. This command confirms that the database path is ready for use.`,
+		Short: "Initialize vector store and its schema",
+		Long: `Initialize the vector store. This command confirms that the database path is ready for use.
+With --dim, --metric, --model, --model-version or --precision it also sets the
+schema of the store: the dimension every vector must have, the similarity
+metric, the embedding model the vectors come from and the precision they are
+stored in. The schema is persisted with the store and checked on every write;
+it can only be changed while the store holds no vectors.`,
+		Args: cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			engine := embedx.EngineFromContext(cmd.Context())
 			if engine == nil {
 				return fmt.Errorf("engine not available")
 			}
 
-			fmt.Println("Store ready at", dbPath)
+			store, ok := engine.Store().(embedx.SchemaStore)
+			flags := cmd.Flags()
+			changed := flags.Changed("dim") || flags.Changed("metric") || flags.Changed("model") ||
+				flags.Changed("model-version") || flags.Changed("precision")
+			if changed && !ok {
+				return fmt.Errorf("store does not support a schema")
+			}
+
+			out := cmd.OutOrStdout()
+			fmt.Fprintln(out, "Store ready at", dbPath)
+			if !ok {
+				return nil
+			}
+
+			schema := store.Schema()
+			if flags.Changed("dim") {
+				schema.Dim = dim
+			}
+			if flags.Changed("metric") {
+				m, err := vector.ParseMetric(metric)
+				if err != nil {
+					return err
+				}
+				schema.Metric = m
+			}
+			if flags.Changed("model") {
+				schema.Model = model
+			}
+			if flags.Changed("model-version") {
+				schema.ModelVersion = modelVersion
+			}
+			if flags.Changed("precision") {
+				p, err := vector.ParsePrecision(precision)
+				if err != nil {
+					return err
+				}
+				schema.Precision = p
+			}
+			if changed {
+				if err := store.SetSchema(schema); err != nil {
+					return err
+				}
+			}
+
+			dimText := "any"
+			if schema.Dim > 0 {
+				dimText = strconv.Itoa(schema.Dim)
+			}
+			fmt.Fprintf(out, "Schema: dim=%s metric=%s precision=%s", dimText, schema.Metric, schema.Precision)
+			if schema.Model != "" {
+				fmt.Fprintf(out, " model=%s", schema.Model)
+				if schema.ModelVersion != "" {
+					fmt.Fprintf(out, "@%s", schema.ModelVersion)
+				}
+			}
+			fmt.Fprintln(out)
 			return nil
 		},
 	}
+
+	cmd.Flags().IntVar(&dim, "dim", 0, "dimension every vector must have (0 for any)")
+	cmd.Flags().StringVar(&metric, "metric", "cosine", "similarity metric: cosine, dot, euclidean, manhattan or hamming")
+	cmd.Flags().StringVar(&model, "model", "", "name of the embedding model the vectors come from")
+	cmd.Flags().StringVar(&modelVersion, "model-version", "", "version of the embedding model")
+	cmd.Flags().StringVar(&precision, "precision", "float32", "storage precision of vector components: float32, float16 or bfloat16")
+	return cmd
 }
 
 // cmdAdd creates the 'add' command for adding vectors to the store.
@@ -90,7 +193,9 @@ func cmdSearch() *cobra.Command {
 		Use:   "search [v1 v2 v3 ...]",
 		Short: "Search vectors",
 		Long: `Search for vectors similar to the given query vector.
-The query vector components should be provided as separate arguments.`,
+The query vector components should be provided as separate arguments.
+Stores that search themselves answer the query with their own index,
+quantized codes and metric, so that 'train' takes effect.`,
 		Args: cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			engine := embedx.FromContext(cmd.Context())
@@ -103,20 +208,436 @@ The query vector components should be provided as separate arguments.`,
 				return err
 			}
 
-			res, err := engine.Search(vec, 5) // default k=5 for now
-			if err != nil {
+			const k = 5 // default k=5 for now
+			var res []embedx.Result
+			if store, ok := engine.Store().(embedx.Store); ok {
+				hits, err := store.SearchContext(cmd.Context(), vec, k)
+				if err != nil {
+					return err
+				}
+				for _, h := range hits {
+					res = append(res, embedx.Result{ID: h.ID, Score: h.Score})
+				}
+			} else if res, err = engine.SearchContext(cmd.Context(), vec, k); err != nil {
 				return err
 			}
 
-			fmt.Println("Results:")
+			out := cmd.OutOrStdout()
+			fmt.Fprintln(out, "Results:")
 			for _, r := range res {
-				fmt.Printf("%s -> %.4f\n", r.ID, r.Score)
+				fmt.Fprintf(out, "%s -> %.4f\n", r.ID, r.Score)
+			}
+			return nil
+		},
+	}
+}
+
+// cmdDelete creates the 'delete' command for removing vectors from the store.
+func cmdDelete() *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete [id...]",
+		Short: "Delete vectors",
+		Long: `Delete the vectors with the given IDs from the store.
+IDs that are not stored are skipped.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			engine := embedx.FromContext(cmd.Context())
+			if engine == nil {
+				return fmt.Errorf("engine not initialized")
+			}
+
+			n, err := engine.DeleteMany(args)
+			if err != nil {
+				return err
 			}
+
+			fmt.Printf("Deleted %d of %d vectors\n", n, len(args))
 			return nil
 		},
 	}
 }
 
+// cmdImport creates the 'import' command for bulk loading vectors from a file.
+func cmdImport() *cobra.Command {
+	var (
+		format    string
+		batchSize int
+		idPrefix  string
+		idsPath   string
+		mode      string
+		quiet     bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "import [file]",
+		Short: "Import vectors from a file",
+		Long: `Import vectors from a JSON Lines, CSV, NumPy (.npy/.npz), .fvecs or .ivecs file.
+The format is inferred from the file extension unless --format is given.
+Records are streamed and written in batches; records that cannot be read or
+stored are reported on stderr and skipped instead of aborting the import.
+Formats without IDs use --ids (one ID per line), the <file>.ids sidecar of an
+exported .npy file, or --id-prefix plus the row number.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			engine := embedx.FromContext(cmd.Context())
+			if engine == nil {
+				return fmt.Errorf("engine not initialized")
+			}
+			store, ok := engine.Store().(embedx.Store)
+			if !ok {
+				return fmt.Errorf("store does not support metadata")
+			}
+
+			f, err := vecio.FormatFromPath(args[0])
+			if format != "" {
+				f, err = vecio.ParseFormat(format)
+			}
+			if err != nil {
+				return err
+			}
+			m, err := parseUpsertMode(mode)
+			if err != nil {
+				return err
+			}
+
+			in, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer in.Close()
+
+			opts := vecio.ReadOptions{IDPrefix: idPrefix}
+			if idsPath == "" && f == vecio.FormatNPY {
+				// Pick up the sidecar written by 'goembedx export'.
+				if _, err := os.Stat(args[0] + ".ids"); err == nil {
+					idsPath = args[0] + ".ids"
+				}
+			}
+			if idsPath != "" {
+				ids, err := os.Open(idsPath)
+				if err != nil {
+					return err
+				}
+				defer ids.Close()
+				opts.IDs = ids
+			}
+
+			r, err := vecio.NewReader(f, in, opts)
+			if err != nil {
+				return err
+			}
+
+			stderr := cmd.ErrOrStderr()
+			stats, err := vecio.Import(store, r, vecio.ImportOptions{
+				BatchSize: batchSize,
+				Mode:      m,
+				Progress: func(s vecio.ImportStats) {
+					if !quiet {
+						fmt.Fprintf(stderr, "read %d, imported %d, failed %d\n", s.Read, s.Imported, s.Failed)
+					}
+				},
+				OnError: func(e *vecio.RecordError) {
+					fmt.Fprintln(stderr, "skipped", e)
+				},
+			})
+			fmt.Printf("Imported %d of %d vectors (%d failed)\n", stats.Imported, stats.Read, stats.Failed)
+			return err
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "", "input format: jsonl, csv, npy, npz, fvecs or ivecs (default: from extension)")
+	cmd.Flags().IntVar(&batchSize, "batch-size", vecio.DefaultBatchSize, "number of records written per batch")
+	cmd.Flags().StringVar(&idPrefix, "id-prefix", "", "prefix of generated IDs for formats without IDs")
+	cmd.Flags().StringVar(&idsPath, "ids", "", "file with one ID per line for formats without IDs")
+	cmd.Flags().StringVar(&mode, "mode", "upsert", "how to treat existing IDs: upsert, insert or update")
+	cmd.Flags().BoolVar(&quiet, "quiet", false, "do not report progress after every batch")
+	return cmd
+}
+
+// cmdExport creates the 'export' command for streaming the store to a file.
+func cmdExport() *cobra.Command {
+	var (
+		format  string
+		idsPath string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "export [file]",
+		Short: "Export vectors to a file",
+		Long: `Export every vector with its norm and metadata to a file, or to stdout for "-".
+Supported formats are JSON Lines, .npy and goembedx snapshots (.snap); the
+format is inferred from the file extension unless --format is given.
+Snapshots are versioned and checksummed, and restore into any store with
+'goembedx import'. For .npy, IDs are written one per line to --ids, which
+defaults to the output path with an .ids suffix; metadata is not exported.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			engine := embedx.FromContext(cmd.Context())
+			if engine == nil {
+				return fmt.Errorf("engine not initialized")
+			}
+			src, ok := engine.Store().(embedx.Scanner)
+			if !ok {
+				return fmt.Errorf("store does not support scanning")
+			}
+
+			path := args[0]
+			var (
+				f   vecio.Format
+				err error
+			)
+			switch {
+			case format != "":
+				f, err = vecio.ParseFormat(format)
+			case path == "-":
+				f = vecio.FormatJSONL
+			default:
+				f, err = vecio.FormatFromPath(path)
+			}
+			if err != nil {
+				return err
+			}
+
+			var opts vecio.WriteOptions
+			if sp, ok := engine.Store().(embedx.StatsProvider); ok {
+				stats, err := sp.Stats()
+				if err != nil {
+					return err
+				}
+				opts.Snapshot = vecio.SnapshotHeader{Metric: stats.Metric, Dim: stats.Dim}
+			}
+
+			out, summary := os.Stdout, cmd.OutOrStdout()
+			if path == "-" {
+				summary = cmd.ErrOrStderr()
+			} else {
+				if out, err = os.Create(path); err != nil {
+					return err
+				}
+				defer out.Close()
+			}
+
+			if f == vecio.FormatNPY {
+				if idsPath == "" && path != "-" {
+					idsPath = path + ".ids"
+				}
+				if idsPath != "" {
+					ids, err := os.Create(idsPath)
+					if err != nil {
+						return err
+					}
+					defer ids.Close()
+					opts.IDs = ids
+				}
+			}
+
+			w, err := vecio.NewWriter(f, out, opts)
+			if err != nil {
+				return err
+			}
+			n, err := vecio.Export(src, w)
+			if err != nil {
+				return err
+			}
+			if path != "-" {
+				if err := out.Close(); err != nil {
+					return err
+				}
+			}
+			fmt.Fprintf(summary, "Exported %d vectors to %s\n", n, path)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "", "output format: jsonl, npy or snapshot (default: from extension)")
+	cmd.Flags().StringVar(&idsPath, "ids", "", "file to write IDs to for .npy output (default: <file>.ids)")
+	return cmd
+}
+
+// cmdTrain creates the 'train' command for quantizing the stored vectors.
+func cmdTrain() *cobra.Command {
+	var (
+		method      string
+		calibration string
+		pq          = vector.DefaultPQConfig
+		sample      int
+		rescore     int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "train",
+		Short: "Train a quantizer on the stored vectors",
+		Long: `Train a quantizer on the stored vectors and quantize every vector.
+With --method pq (the default), each vector is split into --subspaces
+sub-vectors whose codebooks of up to --centroids centroids are trained with
+k-means on a random sample of --sample vectors (all vectors if 0); vectors
+are then stored as one byte per subspace and searched with lookup tables.
+With --method int8, every component is quantized to one byte.
+With --method binary, every vector is also stored as the signs of its
+components, one bit per dimension, and searched by Hamming distance.
+Searches rescore the best k*--rescore candidates at full precision when
+--rescore is positive. The quantizer is persisted with the store.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			engine := embedx.FromContext(cmd.Context())
+			if engine == nil {
+				return fmt.Errorf("engine not initialized")
+			}
+			store, ok := engine.Store().(embedx.Quantizable)
+			if !ok {
+				return fmt.Errorf("store does not support quantization")
+			}
+
+			cfg := embedx.QuantizationConfig{Rescore: rescore, Sample: sample}
+			switch calibration {
+			case "per-dimension":
+				cfg.Calibration = vector.CalibratePerDimension
+			case "global":
+				cfg.Calibration = vector.CalibrateGlobal
+			default:
+				return fmt.Errorf("invalid calibration %q: want per-dimension or global", calibration)
+			}
+			switch method {
+			case "pq":
+				cfg.Product = &pq
+			case "int8":
+			case "binary":
+				cfg.Binary = true
+			default:
+				return fmt.Errorf("invalid method %q: want pq, int8 or binary", method)
+			}
+
+			if err := store.Quantize(cfg); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Trained %s quantizer\n", method)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&method, "method", "pq", "quantization method: pq, int8 or binary")
+	cmd.Flags().IntVar(&pq.Subspaces, "subspaces", pq.Subspaces, "number of PQ subspaces, which must divide the dimension")
+	cmd.Flags().IntVar(&pq.Centroids, "centroids", pq.Centroids, "number of centroids per PQ codebook, at most 256")
+	cmd.Flags().IntVar(&pq.Iterations, "iterations", pq.Iterations, "maximum number of k-means iterations")
+	cmd.Flags().Int64Var(&pq.Seed, "seed", pq.Seed, "seed of the random sampling and centroid initialization")
+	cmd.Flags().IntVar(&sample, "sample", 0, "number of vectors to train on (0 for all)")
+	cmd.Flags().IntVar(&rescore, "rescore", 0, "rescore k*N candidates at full precision (0 to disable)")
+	cmd.Flags().StringVar(&calibration, "calibration", "per-dimension", "int8 calibration: per-dimension or global")
+	return cmd
+}
+
+// cmdServe creates the 'serve' command for exposing the store over HTTP/JSON.
+func cmdServe() *cobra.Command {
+	var (
+		listen          []string
+		grpcListen      string
+		maxBodyBytes    int64
+		shutdownTimeout time.Duration
+		requestTimeout  time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve the store over HTTP/JSON and gRPC",
+		Long: `Serve the vector store over a REST API with JSON bodies.
+The server listens on every --listen address and shuts down gracefully on
+SIGINT or SIGTERM, giving in-flight requests --shutdown-timeout to finish.
+With --request-timeout, REST requests that take longer are stopped and
+answered with 503 Service Unavailable.
+With --grpc-listen, the gRPC API is served on that address as well.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			engine := embedx.FromContext(cmd.Context())
+			if engine == nil {
+				return fmt.Errorf("engine not initialized")
+			}
+			store, ok := engine.Store().(embedx.Store)
+			if !ok {
+				return fmt.Errorf("store does not support metadata and search")
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			// Stopping either server stops the other.
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+
+			var grpcErr chan error
+			if grpcListen != "" {
+				ln, err := net.Listen("tcp", grpcListen)
+				if err != nil {
+					return fmt.Errorf("grpc: listen on %s: %w", grpcListen, err)
+				}
+				grpcErr = make(chan error, 1)
+				go func() {
+					err := grpcserver.NewServer(store).Serve(ctx, ln, grpc.MaxRecvMsgSize(int(maxBodyBytes)))
+					cancel()
+					grpcErr <- err
+				}()
+				fmt.Println("Serving gRPC on", grpcListen)
+			}
+
+			srv := rest.NewServer(store,
+				rest.WithMaxBodyBytes(maxBodyBytes),
+				rest.WithShutdownTimeout(shutdownTimeout),
+				rest.WithRequestTimeout(requestTimeout),
+			)
+			fmt.Println("Serving on", listen)
+			err := srv.ListenAndServe(ctx, listen...)
+			cancel()
+			if grpcErr != nil {
+				if gerr := <-grpcErr; err == nil {
+					err = gerr
+				}
+			}
+			return err
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&listen, "listen", []string{"127.0.0.1:8080"}, "addresses to listen on (repeatable)")
+	cmd.Flags().StringVar(&grpcListen, "grpc-listen", "", "address to serve the gRPC API on (disabled if empty)")
+	cmd.Flags().Int64Var(&maxBodyBytes, "max-body-bytes", rest.DefaultMaxBodyBytes, "maximum request or message size in bytes")
+	cmd.Flags().DurationVar(&shutdownTimeout, "shutdown-timeout", rest.DefaultShutdownTimeout, "time allowed for in-flight requests on shutdown")
+	cmd.Flags().DurationVar(&requestTimeout, "request-timeout", 0, "time allowed for each REST request (unlimited if 0)")
+	return cmd
+}
+
+// collectionEngine returns an engine over the named collection of the store
+// of engine, creating the collection with an empty schema if it does not
+// exist and create is set.
+func collectionEngine(engine *embedx.Embedder, name string, create bool) (*embedx.Embedder, error) {
+	cs, ok := engine.Store().(embedx.CollectionStore)
+	if !ok {
+		return nil, fmt.Errorf("store does not support collections")
+	}
+	store, err := cs.OpenCollection(name)
+	if errors.Is(err, embedx.ErrNotFound) && create {
+		store, err = cs.CreateCollection(name, embedx.Schema{})
+	}
+	if err != nil {
+		return nil, err
+	}
+	vs, ok := store.(embedx.VectorStore)
+	if !ok {
+		return nil, fmt.Errorf("collection %s cannot back an engine", name)
+	}
+	return embedx.New(vs), nil
+}
+
+// parseUpsertMode converts the --mode flag to an embedx.UpsertMode.
+func parseUpsertMode(mode string) (embedx.UpsertMode, error) {
+	switch mode {
+	case "", "upsert":
+		return embedx.UpsertAny, nil
+	case "insert":
+		return embedx.InsertOnly, nil
+	case "update":
+		return embedx.UpdateOnly, nil
+	default:
+		return 0, fmt.Errorf("invalid mode %q: want upsert, insert or update", mode)
+	}
+}
+
 // parseFloat32Vec converts a slice of string representations to a slice of float32 values.
 // It returns an error if any string cannot be parsed as a float32.
 func parseFloat32Vec(strs []string) ([]float32, error) {
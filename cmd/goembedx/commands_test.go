@@ -2,10 +2,17 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/ldaidone/goembedx/internal/store/badger"
 	"github.com/ldaidone/goembedx/pkg/embedx"
+	"github.com/ldaidone/goembedx/vector"
 )
 
 // mockVectorStore implements VectorStore interface for testing
@@ -46,10 +53,79 @@ func (m *mockVectorStore) GetAllVectors() (map[string][]float32, error) {
 	return m.data, nil
 }
 
+func (m *mockVectorStore) UpsertVector(id string, vec []float32, mode embedx.UpsertMode) error {
+	_, exists := m.data[id]
+	if err := embedx.CheckUpsertMode(id, mode, exists); err != nil {
+		return err
+	}
+	return m.SaveVector(id, vec)
+}
+
+func (m *mockVectorStore) Delete(id string) error {
+	if _, exists := m.data[id]; !exists {
+		return embedx.ErrNotFound
+	}
+	delete(m.data, id)
+	return nil
+}
+
+func (m *mockVectorStore) DeleteMany(ids []string) (int, error) {
+	n := 0
+	for _, id := range ids {
+		if _, exists := m.data[id]; exists {
+			delete(m.data, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
 func (m *mockVectorStore) Close() error {
 	return m.closeErr
 }
 
+func (m *mockVectorStore) SaveVectorContext(ctx context.Context, id string, vec []float32) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return m.SaveVector(id, vec)
+}
+
+func (m *mockVectorStore) GetVectorContext(ctx context.Context, id string) ([]float32, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.GetVector(id)
+}
+
+func (m *mockVectorStore) GetAllVectorsContext(ctx context.Context) (map[string][]float32, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.GetAllVectors()
+}
+
+func (m *mockVectorStore) UpsertVectorContext(ctx context.Context, id string, vec []float32, mode embedx.UpsertMode) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return m.UpsertVector(id, vec, mode)
+}
+
+func (m *mockVectorStore) DeleteContext(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return m.Delete(id)
+}
+
+func (m *mockVectorStore) DeleteManyContext(ctx context.Context, ids []string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return m.DeleteMany(ids)
+}
+
 func TestCmdInit(t *testing.T) {
 	cmd := cmdInit()
 
@@ -57,6 +133,56 @@ func TestCmdInit(t *testing.T) {
 	if cmd.Use != "init" {
 		t.Errorf("Expected Use to be 'init', got '%s'", cmd.Use)
 	}
+
+	// A store without a schema can only be initialized without schema flags.
+	cmd.SetOut(io.Discard)
+	cmd.SetContext(embedx.WithEngine(context.Background(), embedx.New(&mockVectorStore{})))
+	if err := cmd.RunE(cmd, nil); err != nil {
+		t.Fatalf("init failed: %v", err)
+	}
+	if err := cmd.Flags().Set("dim", "3"); err != nil {
+		t.Fatalf("setting --dim failed: %v", err)
+	}
+	if err := cmd.RunE(cmd, nil); err == nil {
+		t.Error("Expected error setting the schema of a store without one, got nil")
+	}
+
+	store, err := badger.NewBadgerStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewBadgerStore failed: %v", err)
+	}
+	defer store.Close()
+	cmd.SetContext(embedx.WithEngine(context.Background(), embedx.New(store)))
+	for flag, value := range map[string]string{"metric": "dot", "model": "minilm", "model-version": "v2", "precision": "fp16"} {
+		if err := cmd.Flags().Set(flag, value); err != nil {
+			t.Fatalf("setting --%s failed: %v", flag, err)
+		}
+	}
+	if err := cmd.RunE(cmd, nil); err != nil {
+		t.Fatalf("init failed: %v", err)
+	}
+	want := embedx.Schema{Dim: 3, Metric: vector.MetricDot, Model: "minilm", ModelVersion: "v2", Precision: vector.PrecisionFloat16}
+	if got := store.Schema(); got != want {
+		t.Errorf("Expected schema %+v, got %+v", want, got)
+	}
+
+	// The schema is fixed once the store holds vectors.
+	if err := store.Add("a", []float32{1, 2, 3}, nil); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := cmd.Flags().Set("dim", "4"); err != nil {
+		t.Fatalf("setting --dim failed: %v", err)
+	}
+	var schemaErr *embedx.SchemaError
+	if err := cmd.RunE(cmd, nil); !errors.As(err, &schemaErr) {
+		t.Errorf("Expected a SchemaError, got %v", err)
+	}
+	if err := cmd.Flags().Set("metric", "jaccard"); err != nil {
+		t.Fatalf("setting --metric failed: %v", err)
+	}
+	if err := cmd.RunE(cmd, nil); err == nil {
+		t.Error("Expected error for an invalid metric, got nil")
+	}
 }
 
 func TestCmdAdd(t *testing.T) {
@@ -67,12 +193,248 @@ func TestCmdAdd(t *testing.T) {
 	}
 }
 
+// searchStore is a memory store that counts its own searches.
+type searchStore struct {
+	*embedx.MemoryStore
+	searches int
+}
+
+func (s *searchStore) SearchContext(ctx context.Context, query []float32, k int) ([]embedx.SearchResult, error) {
+	s.searches++
+	return s.MemoryStore.SearchContext(ctx, query, k)
+}
+
 func TestCmdSearch(t *testing.T) {
 	cmd := cmdSearch()
 
 	if cmd.Use != "search [v1 v2 v3 ...]" {
 		t.Errorf("Expected Use to be 'search [v1 v2 v3 ...]', got '%s'", cmd.Use)
 	}
+
+	// Stores that search themselves answer with their quantized codes.
+	store := &searchStore{MemoryStore: embedx.NewMemoryStore()}
+	_ = store.Add("a", []float32{1, 0}, nil)
+	_ = store.Add("b", []float32{0, 1}, nil)
+	if err := store.Quantize(embedx.QuantizationConfig{}); err != nil {
+		t.Fatalf("Quantize failed: %v", err)
+	}
+	var out strings.Builder
+	cmd.SetOut(&out)
+	cmd.SetContext(embedx.WithEngine(context.Background(), embedx.New(store)))
+	if err := cmd.RunE(cmd, []string{"1", "0"}); err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if store.searches != 1 {
+		t.Errorf("Expected the store to answer the search, got %d searches", store.searches)
+	}
+	if !strings.HasPrefix(out.String(), "Results:\na -> ") {
+		t.Errorf("Expected a first, got %q", out.String())
+	}
+
+	// Other stores are scanned by the engine.
+	out.Reset()
+	cmd.SetContext(embedx.WithEngine(context.Background(), embedx.New(&mockVectorStore{data: map[string][]float32{"v": {1, 0}}})))
+	if err := cmd.RunE(cmd, []string{"1", "0"}); err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if want := "Results:\nv -> 1.0000\n"; out.String() != want {
+		t.Errorf("Expected output %q, got %q", want, out.String())
+	}
+}
+
+func TestCmdSearchMetric(t *testing.T) {
+	store, err := badger.NewBadgerStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewBadgerStore failed: %v", err)
+	}
+	defer store.Close()
+	engine := embedx.New(store)
+	ce, err := collectionEngine(engine, "c", true)
+	if err != nil {
+		t.Fatalf("creating collection failed: %v", err)
+	}
+
+	// Searches of the store and of a collection score with the metric set by init.
+	for name, e := range map[string]*embedx.Embedder{"store": engine, "collection": ce} {
+		ctx := embedx.WithEngine(context.Background(), e)
+		ic := cmdInit()
+		ic.SetOut(io.Discard)
+		ic.SetContext(ctx)
+		if err := ic.Flags().Set("metric", "dot"); err != nil {
+			t.Fatalf("setting --metric failed: %v", err)
+		}
+		if err := ic.RunE(ic, nil); err != nil {
+			t.Fatalf("%s: init failed: %v", name, err)
+		}
+		for id, vec := range map[string][]float32{"a": {1, 0}, "b": {10, 1}} {
+			if err := e.Add(id, vec); err != nil {
+				t.Fatalf("%s: Add failed: %v", name, err)
+			}
+		}
+
+		search := cmdSearch()
+		var out strings.Builder
+		search.SetOut(&out)
+		search.SetContext(ctx)
+		if err := search.RunE(search, []string{"1", "0"}); err != nil {
+			t.Fatalf("%s: search failed: %v", name, err)
+		}
+		// Under cosine a would rank first with a score of 1.
+		if want := "Results:\nb -> 10.0000\na -> 1.0000\n"; out.String() != want {
+			t.Errorf("%s: expected output %q, got %q", name, want, out.String())
+		}
+	}
+}
+
+func TestCmdDelete(t *testing.T) {
+	cmd := cmdDelete()
+
+	if cmd.Use != "delete [id...]" {
+		t.Errorf("Expected Use to be 'delete [id...]', got '%s'", cmd.Use)
+	}
+
+	store := &mockVectorStore{data: map[string][]float32{"a": {1}, "b": {2}}}
+	cmd.SetContext(embedx.WithEngine(context.Background(), embedx.New(store)))
+	if err := cmd.RunE(cmd, []string{"a", "missing"}); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if _, exists := store.data["a"]; exists {
+		t.Error("Expected vector a to be deleted")
+	}
+	if _, exists := store.data["b"]; !exists {
+		t.Error("Expected vector b to be kept")
+	}
+}
+
+func TestCmdImport(t *testing.T) {
+	cmd := cmdImport()
+
+	if cmd.Use != "import [file]" {
+		t.Errorf("Expected Use to be 'import [file]', got '%s'", cmd.Use)
+	}
+
+	path := filepath.Join(t.TempDir(), "vectors.jsonl")
+	data := `{"id":"a","vector":[1,0],"meta":{"tenant":"x"}}
+{"id":"b","vector":[1,2,3]}
+{"id":"c","vector":[0,1]}
+`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	store := embedx.NewMemoryStoreWithDim(2)
+	cmd.SetContext(embedx.WithEngine(context.Background(), embedx.New(store)))
+	cmd.SetErr(io.Discard)
+	if err := cmd.RunE(cmd, []string{path}); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if _, _, meta, err := store.Get("a"); err != nil || meta["tenant"] != "x" {
+		t.Errorf("Expected a with metadata, got %v, %v", meta, err)
+	}
+	if _, err := store.GetVector("b"); err == nil {
+		t.Error("Expected b to be skipped for its dimension")
+	}
+	if _, err := store.GetVector("c"); err != nil {
+		t.Errorf("Expected c to be imported after the bad record, got %v", err)
+	}
+
+	if err := cmd.Flags().Set("mode", "merge"); err != nil {
+		t.Fatalf("setting --mode failed: %v", err)
+	}
+	if err := cmd.RunE(cmd, []string{path}); err == nil {
+		t.Error("Expected error for invalid mode, got nil")
+	}
+}
+
+func TestCmdExport(t *testing.T) {
+	cmd := cmdExport()
+
+	if cmd.Use != "export [file]" {
+		t.Errorf("Expected Use to be 'export [file]', got '%s'", cmd.Use)
+	}
+
+	// A store that cannot be scanned cannot be exported.
+	cmd.SetContext(embedx.WithEngine(context.Background(), embedx.New(&mockVectorStore{})))
+	if err := cmd.RunE(cmd, []string{"-"}); err == nil {
+		t.Error("Expected error for a store without Scan, got nil")
+	}
+
+	src := embedx.NewMemoryStore()
+	_ = src.Add("a", []float32{1, 0}, map[string]any{"tenant": "x"})
+	_ = src.Add("b", []float32{0, 1}, nil)
+	cmd.SetContext(embedx.WithEngine(context.Background(), embedx.New(src)))
+	cmd.SetOut(io.Discard)
+
+	dir := t.TempDir()
+	for _, name := range []string{"backup.snap", "vectors.npy"} {
+		path := filepath.Join(dir, name)
+		if err := cmd.RunE(cmd, []string{path}); err != nil {
+			t.Fatalf("export to %s failed: %v", name, err)
+		}
+
+		// Restore into a fresh store with the import command.
+		dst := embedx.NewMemoryStore()
+		imp := cmdImport()
+		imp.SetContext(embedx.WithEngine(context.Background(), embedx.New(dst)))
+		imp.SetErr(io.Discard)
+		if err := imp.RunE(imp, []string{path}); err != nil {
+			t.Fatalf("import of %s failed: %v", name, err)
+		}
+		if vec, err := dst.GetVector("b"); err != nil || vec[1] != 1 {
+			t.Errorf("%s: expected b to be restored, got %v, %v", name, vec, err)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "vectors.npy.ids")); err != nil {
+		t.Errorf("Expected IDs sidecar next to the .npy file: %v", err)
+	}
+}
+
+func TestCmdTrain(t *testing.T) {
+	cmd := cmdTrain()
+
+	if cmd.Use != "train" {
+		t.Errorf("Expected Use to be 'train', got '%s'", cmd.Use)
+	}
+
+	// A store without quantization support cannot be trained.
+	cmd.SetContext(embedx.WithEngine(context.Background(), embedx.New(&mockVectorStore{})))
+	if err := cmd.RunE(cmd, nil); err == nil {
+		t.Error("Expected error for a store without Quantize, got nil")
+	}
+
+	store := embedx.NewMemoryStore()
+	_ = store.Add("a", []float32{1, 0}, nil)
+	_ = store.Add("b", []float32{0, 1}, nil)
+	cmd.SetContext(embedx.WithEngine(context.Background(), embedx.New(store)))
+	cmd.SetOut(io.Discard)
+
+	// The in-memory store only supports int8 and binary quantization.
+	if err := cmd.RunE(cmd, nil); err == nil {
+		t.Error("Expected error for product quantization of a memory store, got nil")
+	}
+	if err := cmd.Flags().Set("method", "binary"); err != nil {
+		t.Fatalf("setting --method failed: %v", err)
+	}
+	if err := cmd.RunE(cmd, nil); err != nil {
+		t.Fatalf("train failed: %v", err)
+	}
+	if err := cmd.Flags().Set("method", "int8"); err != nil {
+		t.Fatalf("setting --method failed: %v", err)
+	}
+	if err := cmd.RunE(cmd, nil); err != nil {
+		t.Fatalf("train failed: %v", err)
+	}
+	if stats, _ := store.Stats(); !stats.Quantized {
+		t.Error("Expected store to be quantized")
+	}
+
+	if err := cmd.Flags().Set("method", "opq"); err != nil {
+		t.Fatalf("setting --method failed: %v", err)
+	}
+	if err := cmd.RunE(cmd, nil); err == nil {
+		t.Error("Expected error for invalid method, got nil")
+	}
 }
 
 func TestParseFloat32Vec(t *testing.T) {
@@ -105,6 +467,40 @@ func TestParseFloat32Vec(t *testing.T) {
 	}
 }
 
+func TestCollectionEngine(t *testing.T) {
+	if _, err := collectionEngine(embedx.New(&mockVectorStore{}), "c", true); err == nil {
+		t.Error("Expected error for a store without collections, got nil")
+	}
+
+	store, err := badger.NewBadgerStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewBadgerStore failed: %v", err)
+	}
+	defer store.Close()
+	engine := embedx.New(store)
+	if _, err := collectionEngine(engine, "c", false); !errors.Is(err, embedx.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a missing collection, got %v", err)
+	}
+
+	// init creates the collection, and later commands write to it.
+	if _, err := collectionEngine(engine, "c", true); err != nil {
+		t.Fatalf("creating collection failed: %v", err)
+	}
+	ce, err := collectionEngine(engine, "c", false)
+	if err != nil {
+		t.Fatalf("opening collection failed: %v", err)
+	}
+	if err := ce.Add("a", []float32{1, 0}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if results, _ := ce.Search([]float32{1, 0}, 1); len(results) != 1 || results[0].ID != "a" {
+		t.Errorf("Expected the collection's vector, got %v", results)
+	}
+	if _, err := store.GetVector("a"); !errors.Is(err, embedx.ErrNotFound) {
+		t.Errorf("Expected the store itself to be empty, got %v", err)
+	}
+}
+
 func TestCmdContext(t *testing.T) {
 	// Test that commands properly retrieve engine from context
 	mockStore := &mockVectorStore{}
@@ -128,3 +524,31 @@ func TestCmdContext(t *testing.T) {
 		t.Error("EngineFromContext with nil context should return nil")
 	}
 }
+
+func TestCmdServe(t *testing.T) {
+	cmd := cmdServe()
+
+	if cmd.Use != "serve" {
+		t.Errorf("Expected Use to be 'serve', got '%s'", cmd.Use)
+	}
+
+	// A store without metadata and search support cannot be served.
+	cmd.SetContext(embedx.WithEngine(context.Background(), embedx.New(&mockVectorStore{})))
+	if err := cmd.RunE(cmd, nil); err == nil {
+		t.Error("Expected error for a plain VectorStore, got nil")
+	}
+
+	// A cancelled context shuts the server down right away.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cmd.SetContext(embedx.WithEngine(ctx, embedx.New(embedx.NewMemoryStore())))
+	if err := cmd.Flags().Set("listen", "127.0.0.1:0"); err != nil {
+		t.Fatalf("setting --listen failed: %v", err)
+	}
+	if err := cmd.Flags().Set("grpc-listen", "127.0.0.1:0"); err != nil {
+		t.Fatalf("setting --grpc-listen failed: %v", err)
+	}
+	if err := cmd.RunE(cmd, nil); err != nil {
+		t.Errorf("serve failed: %v", err)
+	}
+}
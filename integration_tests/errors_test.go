@@ -0,0 +1,131 @@
+package integration_tests
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ldaidone/goembedx/internal/store/badger"
+	"github.com/ldaidone/goembedx/internal/store/memory"
+	"github.com/ldaidone/goembedx/pkg/embedx"
+	"github.com/ldaidone/goembedx/pkg/index/flat"
+)
+
+// errorStore is the part of the store API whose errors every store must
+// report with the same types.
+type errorStore interface {
+	Upsert(id string, vec []float32, meta map[string]any, mode embedx.UpsertMode) error
+	AddBatch(records []embedx.Record, opts embedx.BatchOptions) error
+	Delete(id string) error
+	Search(query []float32, k int) ([]embedx.SearchResult, error)
+	Quantize(cfg embedx.QuantizationConfig) error
+}
+
+// errorStores returns a new empty store with dimension 3 of every kind.
+func errorStores(t *testing.T) map[string]errorStore {
+	b, err := badger.NewBadgerStore(t.TempDir(), badger.WithDim(3))
+	if err != nil {
+		t.Fatalf("NewBadgerStore failed: %v", err)
+	}
+	t.Cleanup(func() { b.Close() })
+	return map[string]errorStore{
+		"embedx":   embedx.NewMemoryStoreWithDim(3),
+		"internal": memory.NewMemoryStore(3),
+		"badger":   b,
+	}
+}
+
+func TestStoreErrors(t *testing.T) {
+	for name, store := range errorStores(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := store.Quantize(embedx.QuantizationConfig{}); !errors.Is(err, embedx.ErrEmptyStore) {
+				t.Errorf("Expected ErrEmptyStore quantizing an empty store, got %v", err)
+			}
+			if err := store.Delete("missing"); !errors.Is(err, embedx.ErrNotFound) {
+				t.Errorf("Expected ErrNotFound, got %v", err)
+			}
+			if err := store.Upsert("", []float32{1, 2, 3}, nil, embedx.UpsertAny); !errors.Is(err, embedx.ErrInvalidID) {
+				t.Errorf("Expected ErrInvalidID, got %v", err)
+			}
+
+			var dimErr *embedx.DimensionError
+			err := store.Upsert("a", []float32{1, 2}, nil, embedx.UpsertAny)
+			if !errors.Is(err, embedx.ErrDimensionMismatch) || !errors.As(err, &dimErr) || dimErr.Expected != 3 || dimErr.Actual != 2 {
+				t.Errorf("Expected a DimensionError for the vector, got %v", err)
+			}
+
+			if err := store.Upsert("a", []float32{1, 2, 3}, nil, embedx.UpsertAny); err != nil {
+				t.Fatalf("Upsert failed: %v", err)
+			}
+			if err := store.Upsert("a", []float32{1, 2, 3}, nil, embedx.InsertOnly); !errors.Is(err, embedx.ErrAlreadyExists) {
+				t.Errorf("Expected ErrAlreadyExists, got %v", err)
+			}
+			if err := store.Upsert("b", []float32{1, 2, 3}, nil, embedx.UpdateOnly); !errors.Is(err, embedx.ErrNotFound) {
+				t.Errorf("Expected ErrNotFound, got %v", err)
+			}
+			_, err = store.Search([]float32{1, 2, 3, 4}, 1)
+			if !errors.As(err, &dimErr) || dimErr.Expected != 3 || dimErr.Actual != 4 {
+				t.Errorf("Expected a DimensionError for the query, got %v", err)
+			}
+
+			// Batches report the error of each rejected record.
+			err = store.AddBatch([]embedx.Record{
+				{ID: "", Vector: []float32{1, 2, 3}},
+				{ID: "c", Vector: []float32{1}},
+				{ID: "d", Vector: []float32{1, 2, 3}},
+			}, embedx.BatchOptions{})
+			var batchErr *embedx.BatchError
+			if !errors.As(err, &batchErr) || len(batchErr.Items) != 2 || batchErr.Written != 1 {
+				t.Fatalf("Expected a BatchError with 2 items, got %v", err)
+			}
+			if !errors.Is(batchErr.Items[0], embedx.ErrInvalidID) || !errors.Is(batchErr.Items[1], embedx.ErrDimensionMismatch) {
+				t.Errorf("Unexpected item errors: %v", batchErr.Items)
+			}
+		})
+	}
+}
+
+func TestEmbedderErrors(t *testing.T) {
+	b, err := badger.NewBadgerStore(t.TempDir(), badger.WithDim(3))
+	if err != nil {
+		t.Fatalf("NewBadgerStore failed: %v", err)
+	}
+	defer b.Close()
+
+	for name, store := range map[string]embedx.VectorStore{"embedx": embedx.NewMemoryStoreWithDim(3), "badger": b} {
+		t.Run(name, func(t *testing.T) {
+			e := embedx.New(store)
+			if _, err := e.Search([]float32{1, 2, 3}, 1); !errors.Is(err, embedx.ErrEmptyStore) {
+				t.Errorf("Expected ErrEmptyStore, got %v", err)
+			}
+			if err := e.Delete("missing"); !errors.Is(err, embedx.ErrNotFound) {
+				t.Errorf("Expected ErrNotFound, got %v", err)
+			}
+			if err := e.Add("a", []float32{1, 2}); !errors.Is(err, embedx.ErrDimensionMismatch) {
+				t.Errorf("Expected ErrDimensionMismatch, got %v", err)
+			}
+		})
+	}
+}
+
+func TestEmbedderIndexDimensionMismatch(t *testing.T) {
+	// The store fixes no dimension, so only the index rejects the vector.
+	store := embedx.NewMemoryStore()
+	e := embedx.New(store, embedx.WithIndex(flat.New(flat.Config{})))
+	if err := e.Add("a", []float32{1, 2, 3}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := e.Add("b", []float32{1, 2}); !errors.Is(err, embedx.ErrDimensionMismatch) {
+		t.Errorf("Expected ErrDimensionMismatch, got %v", err)
+	}
+	if err := e.Upsert("a", []float32{1, 2}, embedx.UpsertAny); !errors.Is(err, embedx.ErrDimensionMismatch) {
+		t.Errorf("Expected ErrDimensionMismatch, got %v", err)
+	}
+	all, err := store.GetAllVectors()
+	if err != nil || len(all) != 1 || len(all["a"]) != 3 {
+		t.Errorf("Expected the store to keep only a with dimension 3, got %v, %v", all, err)
+	}
+	results, err := e.Search([]float32{1, 2, 3}, 0)
+	if err != nil || len(results) != 1 || results[0].ID != "a" {
+		t.Errorf("Expected [a], got %v, %v", results, err)
+	}
+}
@@ -1,8 +0,0 @@
-package integration_tests
-
-import "testing"
-
-func TestPlaceholder(t *testing.T) {
-	// Integration tests will go here.
-	t.Skip("integration tests coming soon")
-}
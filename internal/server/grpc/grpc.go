@@ -0,0 +1,276 @@
+// Package grpc implements the goembedx gRPC API on top of an embedx.Store.
+// The service definition lives in proto/goembedx/v1 and the generated client
+// and message types in pkg/api/goembedx/v1.
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+
+	goembedxv1 "github.com/ldaidone/goembedx/pkg/api/goembedx/v1"
+	"github.com/ldaidone/goembedx/pkg/embedx"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// defaultK is the number of results returned when a search does not set k.
+const defaultK = 10
+
+// Server implements goembedxv1.EmbedxServiceServer on top of an embedx.Store.
+type Server struct {
+	goembedxv1.UnimplementedEmbedxServiceServer
+
+	// store holds the vectors served by the API.
+	store embedx.Store
+}
+
+// Compile-time interface check
+var _ goembedxv1.EmbedxServiceServer = (*Server)(nil)
+
+// NewServer creates a gRPC service implementation for store.
+// BulkExport requires the store to implement embedx.Scanner.
+func NewServer(store embedx.Store) *Server {
+	return &Server{store: store}
+}
+
+// Register creates a grpc.Server with opts and registers the service on it.
+func (s *Server) Register(opts ...grpc.ServerOption) *grpc.Server {
+	gs := grpc.NewServer(opts...)
+	goembedxv1.RegisterEmbedxServiceServer(gs, s)
+	return gs
+}
+
+// Serve serves the service on ln until ctx is cancelled, then stops
+// gracefully, waiting for in-flight calls to finish.
+func (s *Server) Serve(ctx context.Context, ln net.Listener, opts ...grpc.ServerOption) error {
+	gs := s.Register(opts...)
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- gs.Serve(ln) }()
+
+	select {
+	case <-ctx.Done():
+		gs.GracefulStop()
+		<-errCh
+		return nil
+	case err := <-errCh:
+		return err
+	}
+}
+
+// Upsert stores a vector according to the requested mode.
+func (s *Server) Upsert(ctx context.Context, req *goembedxv1.UpsertRequest) (*goembedxv1.UpsertResponse, error) {
+	if err := s.upsert(ctx, req.GetVector(), req.GetMode()); err != nil {
+		return nil, toStatus(err)
+	}
+	return &goembedxv1.UpsertResponse{}, nil
+}
+
+// Get returns a stored vector with its norm and metadata.
+func (s *Server) Get(ctx context.Context, req *goembedxv1.GetRequest) (*goembedxv1.GetResponse, error) {
+	vec, norm, meta, err := s.store.GetContext(ctx, req.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	pv, err := toProtoVector(embedx.Record{ID: req.GetId(), Vector: vec, Norm: norm, Meta: meta})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &goembedxv1.GetResponse{Vector: pv}, nil
+}
+
+// Delete removes a stored vector.
+func (s *Server) Delete(ctx context.Context, req *goembedxv1.DeleteRequest) (*goembedxv1.DeleteResponse, error) {
+	if err := s.store.DeleteContext(ctx, req.GetId()); err != nil {
+		return nil, toStatus(err)
+	}
+	return &goembedxv1.DeleteResponse{}, nil
+}
+
+// Search returns the vectors most similar to the query, optionally filtered by metadata.
+func (s *Server) Search(ctx context.Context, req *goembedxv1.SearchRequest) (*goembedxv1.SearchResponse, error) {
+	if len(req.GetVector()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "query vector is empty")
+	}
+	k := int(req.GetK())
+	if k <= 0 {
+		k = defaultK
+	}
+
+	var filter embedx.Filter
+	if doc := req.GetFilter().AsMap(); len(doc) > 0 {
+		f, err := embedx.ParseFilter(doc)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		filter = f
+	}
+
+	results, err := s.store.SearchWithFilterContext(ctx, req.GetVector(), k, filter)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	resp := &goembedxv1.SearchResponse{Results: make([]*goembedxv1.SearchHit, len(results))}
+	for i, r := range results {
+		meta, err := toStruct(r.Meta)
+		if err != nil {
+			return nil, toStatus(err)
+		}
+		resp.Results[i] = &goembedxv1.SearchHit{Id: r.ID, Score: r.Score, Meta: meta}
+	}
+	return resp, nil
+}
+
+// BulkExport streams every stored vector in ascending ID order.
+func (s *Server) BulkExport(req *goembedxv1.BulkExportRequest, stream goembedxv1.EmbedxService_BulkExportServer) error {
+	sc, ok := s.store.(embedx.Scanner)
+	if !ok {
+		return status.Error(codes.Unimplemented, "store does not support scanning")
+	}
+	err := sc.Scan(func(r embedx.Record) error {
+		if err := stream.Context().Err(); err != nil {
+			return err
+		}
+		pv, err := toProtoVector(r)
+		if err != nil {
+			return err
+		}
+		return stream.Send(&goembedxv1.BulkExportResponse{Vector: pv})
+	})
+	if err != nil {
+		return toStatus(err)
+	}
+	return nil
+}
+
+// BulkImport stores a stream of vectors. Records that cannot be stored are
+// reported in the response and do not abort the stream; the stream is
+// aborted once its context is done.
+func (s *Server) BulkImport(stream goembedxv1.EmbedxService_BulkImportServer) error {
+	resp := &goembedxv1.BulkImportResponse{}
+	for index := int64(0); ; index++ {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return stream.SendAndClose(resp)
+		}
+		if err != nil {
+			return err
+		}
+		if err := s.upsert(stream.Context(), req.GetVector(), req.GetMode()); err != nil {
+			if cerr := stream.Context().Err(); cerr != nil {
+				return toStatus(cerr)
+			}
+			resp.Errors = append(resp.Errors, &goembedxv1.ImportError{
+				Index:   index,
+				Id:      req.GetVector().GetId(),
+				Message: err.Error(),
+			})
+			continue
+		}
+		resp.Imported++
+	}
+}
+
+// invalidArgumentError marks errors caused by an invalid request.
+type invalidArgumentError struct {
+	err error
+}
+
+func (e invalidArgumentError) Error() string { return e.err.Error() }
+func (e invalidArgumentError) Unwrap() error { return e.err }
+
+// upsert validates and stores a single vector.
+func (s *Server) upsert(ctx context.Context, v *goembedxv1.Vector, mode goembedxv1.UpsertMode) error {
+	if v.GetId() == "" {
+		return invalidArgumentError{errors.New("id is empty")}
+	}
+	if len(v.GetValues()) == 0 {
+		return invalidArgumentError{errors.New("vector is empty")}
+	}
+	m, err := fromProtoMode(mode)
+	if err != nil {
+		return err
+	}
+	var meta map[string]any
+	if v.GetMeta() != nil {
+		meta = v.GetMeta().AsMap()
+	}
+	return s.store.UpsertContext(ctx, v.GetId(), v.GetValues(), meta, m)
+}
+
+// fromProtoMode converts a wire upsert mode to an embedx.UpsertMode.
+func fromProtoMode(mode goembedxv1.UpsertMode) (embedx.UpsertMode, error) {
+	switch mode {
+	case goembedxv1.UpsertMode_UPSERT_MODE_UNSPECIFIED:
+		return embedx.UpsertAny, nil
+	case goembedxv1.UpsertMode_UPSERT_MODE_INSERT_ONLY:
+		return embedx.InsertOnly, nil
+	case goembedxv1.UpsertMode_UPSERT_MODE_UPDATE_ONLY:
+		return embedx.UpdateOnly, nil
+	default:
+		return 0, invalidArgumentError{fmt.Errorf("invalid upsert mode %d", mode)}
+	}
+}
+
+// toProtoVector converts a stored record to its wire representation.
+func toProtoVector(r embedx.Record) (*goembedxv1.Vector, error) {
+	meta, err := toStruct(r.Meta)
+	if err != nil {
+		return nil, err
+	}
+	return &goembedxv1.Vector{Id: r.ID, Values: r.Vector, Meta: meta, Norm: r.Norm}, nil
+}
+
+// toStruct converts metadata to a protobuf Struct. Values that structpb does
+// not support directly, such as typed slices, are converted through their JSON
+// representation. Returns nil for empty metadata.
+func toStruct(meta map[string]any) (*structpb.Struct, error) {
+	if len(meta) == 0 {
+		return nil, nil
+	}
+	if st, err := structpb.NewStruct(meta); err == nil {
+		return st, nil
+	}
+
+	b, err := json.Marshal(meta)
+	if err != nil {
+		return nil, fmt.Errorf("metadata is not JSON-compatible: %w", err)
+	}
+	var generic map[string]any
+	if err := json.Unmarshal(b, &generic); err != nil {
+		return nil, err
+	}
+	return structpb.NewStruct(generic)
+}
+
+// toStatus maps an error to a gRPC status error.
+func toStatus(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	var invalid invalidArgumentError
+	var schemaErr *embedx.SchemaError
+	switch {
+	case errors.As(err, &invalid), errors.Is(err, embedx.ErrDimensionMismatch), errors.Is(err, embedx.ErrInvalidID):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.As(err, &schemaErr), errors.Is(err, embedx.ErrEmptyStore):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, embedx.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, embedx.ErrAlreadyExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
@@ -0,0 +1,249 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/ldaidone/goembedx/internal/store/badger"
+	goembedxv1 "github.com/ldaidone/goembedx/pkg/api/goembedx/v1"
+	"github.com/ldaidone/goembedx/pkg/embedx"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// newTestClient serves store over an in-process bufconn listener and returns a client for it.
+func newTestClient(t *testing.T, store embedx.Store) goembedxv1.EmbedxServiceClient {
+	t.Helper()
+
+	ln := bufconn.Listen(1 << 20)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- NewServer(store).Serve(ctx, ln) }()
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return ln.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	t.Cleanup(func() {
+		_ = conn.Close()
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Serve returned error: %v", err)
+		}
+	})
+	return goembedxv1.NewEmbedxServiceClient(conn)
+}
+
+func mustStruct(t *testing.T, m map[string]any) *structpb.Struct {
+	t.Helper()
+	s, err := structpb.NewStruct(m)
+	if err != nil {
+		t.Fatalf("NewStruct failed: %v", err)
+	}
+	return s
+}
+
+func TestServerUnaryCalls(t *testing.T) {
+	client := newTestClient(t, embedx.NewMemoryStore())
+	ctx := context.Background()
+
+	for id, v := range map[string][]float32{"a": {1, 0}, "b": {0.9, 0.1}, "c": {0, 1}} {
+		tenant := "x"
+		if id == "b" {
+			tenant = "y"
+		}
+		_, err := client.Upsert(ctx, &goembedxv1.UpsertRequest{
+			Vector: &goembedxv1.Vector{Id: id, Values: v, Meta: mustStruct(t, map[string]any{"tenant": tenant})},
+		})
+		if err != nil {
+			t.Fatalf("Upsert %s failed: %v", id, err)
+		}
+	}
+
+	_, err := client.Upsert(ctx, &goembedxv1.UpsertRequest{
+		Vector: &goembedxv1.Vector{Id: "a", Values: []float32{1, 1}},
+		Mode:   goembedxv1.UpsertMode_UPSERT_MODE_INSERT_ONLY,
+	})
+	if status.Code(err) != codes.AlreadyExists {
+		t.Errorf("Expected AlreadyExists, got %v", err)
+	}
+	_, err = client.Upsert(ctx, &goembedxv1.UpsertRequest{Vector: &goembedxv1.Vector{Id: "a"}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for empty vector, got %v", err)
+	}
+
+	got, err := client.Get(ctx, &goembedxv1.GetRequest{Id: "a"})
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Vector.Id != "a" || got.Vector.Norm != 1 || got.Vector.Meta.AsMap()["tenant"] != "x" {
+		t.Errorf("Unexpected Get response: %v", got)
+	}
+
+	res, err := client.Search(ctx, &goembedxv1.SearchRequest{
+		Vector: []float32{1, 0},
+		K:      5,
+		Filter: mustStruct(t, map[string]any{"tenant": "x"}),
+	})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(res.Results) != 2 || res.Results[0].Id != "a" || res.Results[1].Id != "c" {
+		t.Errorf("Expected [a c], got %v", res.Results)
+	}
+	_, err = client.Search(ctx, &goembedxv1.SearchRequest{
+		Vector: []float32{1, 0},
+		Filter: mustStruct(t, map[string]any{"tenant": map[string]any{"$bad": 1}}),
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for invalid filter, got %v", err)
+	}
+
+	if _, err := client.Delete(ctx, &goembedxv1.DeleteRequest{Id: "a"}); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := client.Get(ctx, &goembedxv1.GetRequest{Id: "a"}); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound after delete, got %v", err)
+	}
+	if _, err := client.Delete(ctx, &goembedxv1.DeleteRequest{Id: "a"}); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound for second delete, got %v", err)
+	}
+}
+
+func TestServerBulkImportExport(t *testing.T) {
+	client := newTestClient(t, embedx.NewMemoryStoreWithDim(2))
+	ctx := context.Background()
+
+	imp, err := client.BulkImport(ctx)
+	if err != nil {
+		t.Fatalf("BulkImport failed: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		v := &goembedxv1.Vector{Id: fmt.Sprintf("v%d", i), Values: []float32{float32(i), 1}}
+		if i == 3 {
+			v.Values = []float32{1, 2, 3} // wrong dimension
+		}
+		if i == 4 {
+			v.Meta = mustStruct(t, map[string]any{"n": 4})
+		}
+		if err := imp.Send(&goembedxv1.BulkImportRequest{Vector: v}); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+	summary, err := imp.CloseAndRecv()
+	if err != nil {
+		t.Fatalf("CloseAndRecv failed: %v", err)
+	}
+	if summary.Imported != 4 || len(summary.Errors) != 1 || summary.Errors[0].Index != 3 || summary.Errors[0].Id != "v3" {
+		t.Fatalf("Expected 4 imported and an error for v3, got %v", summary)
+	}
+
+	exp, err := client.BulkExport(ctx, &goembedxv1.BulkExportRequest{})
+	if err != nil {
+		t.Fatalf("BulkExport failed: %v", err)
+	}
+	var ids []string
+	for {
+		msg, err := exp.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv failed: %v", err)
+		}
+		ids = append(ids, msg.Vector.Id)
+		if msg.Vector.Id == "v4" && msg.Vector.Meta.AsMap()["n"] != float64(4) {
+			t.Errorf("Expected metadata of v4 to be exported, got %v", msg.Vector.Meta)
+		}
+	}
+	if fmt.Sprint(ids) != "[v0 v1 v2 v4]" {
+		t.Errorf("Expected [v0 v1 v2 v4] in ID order, got %v", ids)
+	}
+}
+
+func TestServerBadgerStore(t *testing.T) {
+	store, err := badger.NewBadgerStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewBadgerStore failed: %v", err)
+	}
+	defer store.Close()
+	client := newTestClient(t, store)
+	ctx := context.Background()
+
+	// structpb.AsMap decodes lists and nested structs to []any and map[string]any.
+	meta := map[string]any{"tags": []any{"a", "b"}, "author": map[string]any{"name": "ann", "ids": []any{1.0, 2.0}}}
+	_, err = client.Upsert(ctx, &goembedxv1.UpsertRequest{
+		Vector: &goembedxv1.Vector{Id: "a", Values: []float32{1, 0}, Meta: mustStruct(t, meta)},
+	})
+	if err != nil {
+		t.Fatalf("Upsert with list metadata failed: %v", err)
+	}
+
+	got, err := client.Get(ctx, &goembedxv1.GetRequest{Id: "a"})
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if !reflect.DeepEqual(got.Vector.Meta.AsMap(), meta) {
+		t.Errorf("Expected metadata %v, got %v", meta, got.Vector.Meta.AsMap())
+	}
+	res, err := client.Search(ctx, &goembedxv1.SearchRequest{Vector: []float32{1, 0}, K: 1})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(res.Results) != 1 || !reflect.DeepEqual(res.Results[0].Meta.AsMap(), meta) {
+		t.Errorf("Expected a with its metadata, got %v", res.Results)
+	}
+}
+
+func TestToStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want codes.Code
+	}{
+		{fmt.Errorf("get: %w", embedx.ErrNotFound), codes.NotFound},
+		{&embedx.DimensionError{Expected: 3, Actual: 2}, codes.InvalidArgument},
+		{&embedx.SchemaError{Field: "metric", Stored: "cosine", Requested: "dot"}, codes.FailedPrecondition},
+		{fmt.Errorf("add: %w", embedx.ErrInvalidID), codes.InvalidArgument},
+		{fmt.Errorf("quantize: %w", embedx.ErrEmptyStore), codes.FailedPrecondition},
+		{fmt.Errorf("search: %w", context.Canceled), codes.Canceled},
+		{fmt.Errorf("search: %w", context.DeadlineExceeded), codes.DeadlineExceeded},
+		{errors.New("disk full"), codes.Internal},
+	}
+	for _, tt := range tests {
+		if got := status.Code(toStatus(tt.err)); got != tt.want {
+			t.Errorf("toStatus(%v) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestToStruct(t *testing.T) {
+	st, err := toStruct(map[string]any{"tags": []string{"a", "b"}, "n": int64(3)})
+	if err != nil {
+		t.Fatalf("toStruct failed: %v", err)
+	}
+	m := st.AsMap()
+	if tags, ok := m["tags"].([]any); !ok || len(tags) != 2 || m["n"] != float64(3) {
+		t.Errorf("Unexpected conversion: %v", m)
+	}
+	if st, err := toStruct(nil); st != nil || err != nil {
+		t.Errorf("Expected nil struct for empty metadata, got %v, %v", st, err)
+	}
+	if _, err := toStruct(map[string]any{"ch": make(chan int)}); err == nil {
+		t.Error("Expected error for non-JSON metadata, got nil")
+	}
+}
@@ -0,0 +1,516 @@
+// Package rest exposes an embedx.Store over HTTP with JSON request and response bodies.
+// It lets services written in other languages share a single local store.
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ldaidone/goembedx/pkg/embedx"
+)
+
+const (
+	// DefaultMaxBodyBytes is the default limit on the size of request bodies.
+	DefaultMaxBodyBytes = 32 << 20
+	// DefaultShutdownTimeout is the default time in-flight requests are given to
+	// finish once the server is asked to stop.
+	DefaultShutdownTimeout = 10 * time.Second
+	// defaultK is the number of results returned when a search does not set k.
+	defaultK = 10
+)
+
+// Server serves the REST API on top of an embedx.Store.
+//
+// Routes:
+//
+//	POST   /v1/vectors        add or upsert one vector
+//	POST   /v1/vectors/batch  add or upsert many vectors
+//	GET    /v1/vectors/{id}   get a vector with its norm and metadata
+//	DELETE /v1/vectors/{id}   delete a vector
+//	POST   /v1/search         search with optional k and metadata filter
+//	GET    /v1/stats          store statistics
+//	GET    /healthz           liveness probe
+type Server struct {
+	// store holds the vectors served by the API.
+	store embedx.Store
+	// maxBody limits the size of request bodies in bytes.
+	maxBody int64
+	// shutdownTimeout bounds the graceful shutdown in Serve.
+	shutdownTimeout time.Duration
+	// requestTimeout bounds the time spent on each request, or is 0.
+	requestTimeout time.Duration
+	// mux routes requests to the handlers.
+	mux *http.ServeMux
+}
+
+// Option configures optional Server behavior in NewServer.
+type Option func(*Server)
+
+// WithMaxBodyBytes limits request bodies to n bytes. Larger requests are
+// rejected with 413 Request Entity Too Large. Values <= 0 are ignored.
+func WithMaxBodyBytes(n int64) Option {
+	return func(s *Server) {
+		if n > 0 {
+			s.maxBody = n
+		}
+	}
+}
+
+// WithShutdownTimeout sets how long Serve waits for in-flight requests
+// to finish after its context is cancelled. Values <= 0 are ignored.
+func WithShutdownTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		if d > 0 {
+			s.shutdownTimeout = d
+		}
+	}
+}
+
+// WithRequestTimeout bounds the time the store may spend on each request.
+// Once it elapses, the store stops the search or write in progress and the
+// request fails with 503 Service Unavailable. Values <= 0 disable the limit.
+func WithRequestTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.requestTimeout = max(d, 0)
+	}
+}
+
+// NewServer creates a REST server for store.
+// Handlers pass the request context to the store, so requests whose client
+// goes away or whose timeout elapses stop their work.
+func NewServer(store embedx.Store, opts ...Option) *Server {
+	s := &Server{
+		store:           store,
+		maxBody:         DefaultMaxBodyBytes,
+		shutdownTimeout: DefaultShutdownTimeout,
+		mux:             http.NewServeMux(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.mux.HandleFunc("POST /v1/vectors", s.handleAdd)
+	s.mux.HandleFunc("POST /v1/vectors/batch", s.handleBatchAdd)
+	s.mux.HandleFunc("GET /v1/vectors/{id}", s.handleGet)
+	s.mux.HandleFunc("DELETE /v1/vectors/{id}", s.handleDelete)
+	s.mux.HandleFunc("POST /v1/search", s.handleSearch)
+	s.mux.HandleFunc("GET /v1/stats", s.handleStats)
+	s.mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	return s
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxBody)
+	if s.requestTimeout > 0 {
+		ctx, cancel := context.WithTimeout(r.Context(), s.requestTimeout)
+		defer cancel()
+		r = r.WithContext(ctx)
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// ListenAndServe listens on every address in addrs and serves the API until
+// ctx is cancelled, then shuts down gracefully.
+// Returns an error if any address cannot be bound or a listener fails.
+func (s *Server) ListenAndServe(ctx context.Context, addrs ...string) error {
+	if len(addrs) == 0 {
+		return errors.New("rest: no listen address")
+	}
+
+	listeners := make([]net.Listener, 0, len(addrs))
+	for _, addr := range addrs {
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			for _, l := range listeners {
+				_ = l.Close()
+			}
+			return fmt.Errorf("rest: listen on %s: %w", addr, err)
+		}
+		listeners = append(listeners, ln)
+	}
+	return s.Serve(ctx, listeners...)
+}
+
+// Serve serves the API on the given listeners until ctx is cancelled or a
+// listener fails. In-flight requests are then given the shutdown timeout to
+// finish before the listeners are closed.
+func (s *Server) Serve(ctx context.Context, listeners ...net.Listener) error {
+	srv := &http.Server{
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errCh := make(chan error, len(listeners))
+	var wg sync.WaitGroup
+	for _, ln := range listeners {
+		wg.Add(1)
+		go func(ln net.Listener) {
+			defer wg.Done()
+			if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				errCh <- err
+			}
+		}(ln)
+	}
+
+	var serveErr error
+	select {
+	case <-ctx.Done():
+	case serveErr = <-errCh:
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+	err := srv.Shutdown(shutdownCtx)
+	wg.Wait()
+
+	if serveErr != nil {
+		return serveErr
+	}
+	return err
+}
+
+// vectorRequest is the body of an add request and an element of a batch add.
+type vectorRequest struct {
+	ID     string         `json:"id"`
+	Vector []float32      `json:"vector"`
+	Meta   map[string]any `json:"meta,omitempty"`
+	// Mode is "upsert" (the default), "insert" or "update".
+	Mode string `json:"mode,omitempty"`
+}
+
+// batchRequest is the body of a batch add request.
+type batchRequest struct {
+	Vectors []vectorRequest `json:"vectors"`
+	// Mode is the mode of every vector of the batch: "upsert" (the default),
+	// "insert" or "update". Vectors may repeat it but not set another one.
+	Mode string `json:"mode,omitempty"`
+	// Atomic makes the batch all-or-nothing. Otherwise every valid vector
+	// is stored and the rejected ones are reported.
+	Atomic bool `json:"atomic,omitempty"`
+}
+
+// batchResponse reports how many vectors a batch add stored and which
+// vectors it rejected.
+type batchResponse struct {
+	Added  int              `json:"added"`
+	Errors []batchItemError `json:"errors,omitempty"`
+	// Error describes why an atomic batch stored nothing.
+	Error string `json:"error,omitempty"`
+}
+
+// batchItemError describes a vector rejected by a batch add.
+type batchItemError struct {
+	// Index is the position of the vector in the batch.
+	Index int    `json:"index"`
+	ID    string `json:"id"`
+	Error string `json:"error"`
+	// Status is the status code a single add of the vector would have failed with.
+	Status int `json:"status"`
+}
+
+// vectorResponse is the body of a get response.
+type vectorResponse struct {
+	ID     string         `json:"id"`
+	Vector []float32      `json:"vector"`
+	Norm   float32        `json:"norm"`
+	Meta   map[string]any `json:"meta,omitempty"`
+}
+
+// searchRequest is the body of a search request.
+type searchRequest struct {
+	Vector []float32 `json:"vector"`
+	K      int       `json:"k,omitempty"`
+	// Filter is a filter document in the syntax accepted by embedx.ParseFilter.
+	Filter map[string]any `json:"filter,omitempty"`
+}
+
+// searchHit is a single search result.
+type searchHit struct {
+	ID    string         `json:"id"`
+	Score float32        `json:"score"`
+	Meta  map[string]any `json:"meta,omitempty"`
+}
+
+// searchResponse is the body of a search response.
+type searchResponse struct {
+	Results []searchHit `json:"results"`
+}
+
+// statsResponse is the body of a stats response.
+type statsResponse struct {
+	Count     int    `json:"count"`
+	Dim       int    `json:"dim"`
+	Metric    string `json:"metric"`
+	Precision string `json:"precision"`
+	Model     string `json:"model,omitempty"`
+	Indexed   bool   `json:"indexed"`
+	Quantized bool   `json:"quantized"`
+}
+
+// errorResponse is the body of every error response.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// badRequestError marks errors caused by an invalid request.
+type badRequestError struct {
+	err error
+}
+
+func (e badRequestError) Error() string { return e.err.Error() }
+func (e badRequestError) Unwrap() error { return e.err }
+
+// badRequest wraps a formatted error as a badRequestError.
+func badRequest(format string, args ...any) error {
+	return badRequestError{fmt.Errorf(format, args...)}
+}
+
+// handleAdd stores a single vector.
+func (s *Server) handleAdd(w http.ResponseWriter, r *http.Request) {
+	var req vectorRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.upsert(r.Context(), req); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]string{"id": req.ID})
+}
+
+// handleBatchAdd stores many vectors with a single Store.AddBatch.
+// Malformed requests store nothing and fail with 400 Bad Request.
+// A best-effort batch stores every valid vector and answers 200 OK with the
+// rejected ones in Errors. An atomic batch with a rejected vector stores
+// nothing and fails with the status code of the first rejection.
+func (s *Server) handleBatchAdd(w http.ResponseWriter, r *http.Request) {
+	var req batchRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	mode, err := parseMode(req.Mode)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	records := make([]embedx.Record, len(req.Vectors))
+	for i, v := range req.Vectors {
+		if err := validateVector(v); err != nil {
+			writeError(w, fmt.Errorf("vector %d (%s): %w", i, v.ID, err))
+			return
+		}
+		if v.Mode != "" {
+			if m, err := parseMode(v.Mode); err != nil || m != mode {
+				writeError(w, badRequest("vector %d (%s): mode %q differs from the batch mode", i, v.ID, v.Mode))
+				return
+			}
+		}
+		records[i] = embedx.Record{ID: v.ID, Vector: v.Vector, Meta: v.Meta}
+	}
+
+	err = s.store.AddBatchContext(r.Context(), records, embedx.BatchOptions{Mode: mode, Atomic: req.Atomic})
+	var batchErr *embedx.BatchError
+	if err != nil && !errors.As(err, &batchErr) {
+		writeError(w, err)
+		return
+	}
+	if batchErr == nil {
+		writeJSON(w, http.StatusOK, batchResponse{Added: len(records)})
+		return
+	}
+
+	resp := batchResponse{Added: batchErr.Written, Errors: make([]batchItemError, len(batchErr.Items))}
+	for i, item := range batchErr.Items {
+		resp.Errors[i] = batchItemError{Index: item.Index, ID: item.ID, Error: item.Err.Error(), Status: statusCode(item.Err)}
+	}
+	if !req.Atomic {
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+	resp.Error = batchErr.Error()
+	writeJSON(w, resp.Errors[0].Status, resp)
+}
+
+// handleGet returns a vector with its norm and metadata.
+func (s *Server) handleGet(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	vec, norm, meta, err := s.store.GetContext(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, vectorResponse{ID: id, Vector: vec, Norm: norm, Meta: meta})
+}
+
+// handleDelete removes a vector.
+func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
+	if err := s.store.DeleteContext(r.Context(), r.PathValue("id")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleSearch runs a similarity search with an optional metadata filter.
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	var req searchRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if len(req.Vector) == 0 {
+		writeError(w, badRequest("query vector is empty"))
+		return
+	}
+	if req.K <= 0 {
+		req.K = defaultK
+	}
+
+	var filter embedx.Filter
+	if len(req.Filter) > 0 {
+		f, err := embedx.ParseFilter(req.Filter)
+		if err != nil {
+			writeError(w, badRequestError{err})
+			return
+		}
+		filter = f
+	}
+
+	// An empty store has no results rather than failing the search.
+	results, err := s.store.SearchWithFilterContext(r.Context(), req.Vector, req.K, filter)
+	if err != nil && !errors.Is(err, embedx.ErrEmptyStore) {
+		writeError(w, err)
+		return
+	}
+
+	resp := searchResponse{Results: make([]searchHit, len(results))}
+	for i, res := range results {
+		resp.Results[i] = searchHit{ID: res.ID, Score: res.Score, Meta: res.Meta}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleStats reports store statistics. Stores that do not implement
+// embedx.StatsProvider are answered with 501 Not Implemented.
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	sp, ok := s.store.(embedx.StatsProvider)
+	if !ok {
+		writeJSON(w, http.StatusNotImplemented, errorResponse{Error: "store does not report statistics"})
+		return
+	}
+	stats, err := sp.Stats()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, statsResponse{
+		Count:     stats.Count,
+		Dim:       stats.Dim,
+		Metric:    stats.Metric.String(),
+		Precision: stats.Precision.String(),
+		Model:     stats.Model,
+		Indexed:   stats.Indexed,
+		Quantized: stats.Quantized,
+	})
+}
+
+// upsert validates and stores a single vector.
+func (s *Server) upsert(ctx context.Context, req vectorRequest) error {
+	if err := validateVector(req); err != nil {
+		return err
+	}
+	mode, err := parseMode(req.Mode)
+	if err != nil {
+		return err
+	}
+	return s.store.UpsertContext(ctx, req.ID, req.Vector, req.Meta, mode)
+}
+
+// validateVector rejects vector requests without an ID or components.
+func validateVector(req vectorRequest) error {
+	if req.ID == "" {
+		return badRequest("id is empty")
+	}
+	if len(req.Vector) == 0 {
+		return badRequest("vector is empty")
+	}
+	return nil
+}
+
+// parseMode converts the mode of a vector request to an embedx.UpsertMode.
+func parseMode(mode string) (embedx.UpsertMode, error) {
+	switch mode {
+	case "", "upsert":
+		return embedx.UpsertAny, nil
+	case "insert":
+		return embedx.InsertOnly, nil
+	case "update":
+		return embedx.UpdateOnly, nil
+	default:
+		return 0, badRequest("invalid mode %q", mode)
+	}
+}
+
+// decodeJSON decodes the request body into v, rejecting unknown fields
+// and trailing data.
+func decodeJSON(r *http.Request, v any) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return err
+		}
+		return badRequest("invalid JSON body: %v", err)
+	}
+	if dec.More() {
+		return badRequest("invalid JSON body: unexpected data after value")
+	}
+	return nil
+}
+
+// writeJSON writes v as a JSON response with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes err as a JSON error response with a status code derived from it.
+func writeError(w http.ResponseWriter, err error) {
+	writeJSON(w, statusCode(err), errorResponse{Error: err.Error()})
+}
+
+// statusCode maps an error to an HTTP status code.
+func statusCode(err error) int {
+	var tooLarge *http.MaxBytesError
+	var bad badRequestError
+	var schemaErr *embedx.SchemaError
+	switch {
+	case errors.As(err, &tooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.As(err, &bad), errors.Is(err, embedx.ErrDimensionMismatch), errors.Is(err, embedx.ErrInvalidID):
+		return http.StatusBadRequest
+	case errors.As(err, &schemaErr), errors.Is(err, embedx.ErrEmptyStore):
+		return http.StatusConflict
+	case errors.Is(err, embedx.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, embedx.ErrAlreadyExists):
+		return http.StatusConflict
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
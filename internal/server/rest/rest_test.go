@@ -0,0 +1,359 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/goembedx/internal/store/badger"
+	"github.com/ldaidone/goembedx/pkg/embedx"
+)
+
+// do sends a request with an optional JSON body to h and decodes the JSON response into out.
+func do(t *testing.T, h http.Handler, method, path, body string, out any) int {
+	t.Helper()
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, path, r)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: invalid JSON response %q: %v", method, path, rec.Body.String(), err)
+		}
+	}
+	return rec.Code
+}
+
+func TestServerVectorLifecycle(t *testing.T) {
+	srv := NewServer(embedx.NewMemoryStore())
+
+	if code := do(t, srv, "POST", "/v1/vectors", `{"id":"a","vector":[1,0],"meta":{"tenant":"x"}}`, nil); code != http.StatusCreated {
+		t.Fatalf("add: expected 201, got %d", code)
+	}
+	var errResp errorResponse
+	if code := do(t, srv, "POST", "/v1/vectors", `{"id":"a","vector":[0,1],"mode":"insert"}`, &errResp); code != http.StatusConflict {
+		t.Fatalf("insert-only add of existing id: expected 409, got %d (%s)", code, errResp.Error)
+	}
+	if code := do(t, srv, "POST", "/v1/vectors", `{"id":"b","vector":[0,1],"mode":"update"}`, nil); code != http.StatusNotFound {
+		t.Fatalf("update-only add of missing id: expected 404, got %d", code)
+	}
+
+	var batch batchResponse
+	body := `{"vectors":[{"id":"b","vector":[0.9,0.1],"meta":{"tenant":"y"}},{"id":"c","vector":[0,1],"meta":{"tenant":"x"}}]}`
+	if code := do(t, srv, "POST", "/v1/vectors/batch", body, &batch); code != http.StatusOK || batch.Added != 2 {
+		t.Fatalf("batch add: expected 200 with 2 added, got %d %+v", code, batch)
+	}
+
+	var got vectorResponse
+	if code := do(t, srv, "GET", "/v1/vectors/a", "", &got); code != http.StatusOK {
+		t.Fatalf("get: expected 200, got %d", code)
+	}
+	if got.ID != "a" || got.Norm != 1 || got.Meta["tenant"] != "x" || len(got.Vector) != 2 {
+		t.Errorf("get: unexpected response %+v", got)
+	}
+
+	var search searchResponse
+	if code := do(t, srv, "POST", "/v1/search", `{"vector":[1,0],"k":2}`, &search); code != http.StatusOK {
+		t.Fatalf("search: expected 200, got %d", code)
+	}
+	if len(search.Results) != 2 || search.Results[0].ID != "a" || search.Results[1].ID != "b" {
+		t.Errorf("search: expected [a b], got %+v", search.Results)
+	}
+	if code := do(t, srv, "POST", "/v1/search", `{"vector":[1,0],"filter":{"tenant":"x"}}`, &search); code != http.StatusOK {
+		t.Fatalf("filtered search: expected 200, got %d", code)
+	}
+	if len(search.Results) != 2 || search.Results[0].ID != "a" || search.Results[1].ID != "c" {
+		t.Errorf("filtered search: expected [a c], got %+v", search.Results)
+	}
+
+	var stats statsResponse
+	if code := do(t, srv, "GET", "/v1/stats", "", &stats); code != http.StatusOK {
+		t.Fatalf("stats: expected 200, got %d", code)
+	}
+	if stats.Count != 3 || stats.Dim != 2 || stats.Metric != "cosine" || stats.Precision != "float32" || stats.Indexed || stats.Quantized {
+		t.Errorf("stats: unexpected response %+v", stats)
+	}
+
+	if code := do(t, srv, "DELETE", "/v1/vectors/a", "", nil); code != http.StatusNoContent {
+		t.Fatalf("delete: expected 204, got %d", code)
+	}
+	if code := do(t, srv, "DELETE", "/v1/vectors/a", "", nil); code != http.StatusNotFound {
+		t.Fatalf("second delete: expected 404, got %d", code)
+	}
+	if code := do(t, srv, "GET", "/v1/vectors/a", "", nil); code != http.StatusNotFound {
+		t.Fatalf("get deleted: expected 404, got %d", code)
+	}
+}
+
+func TestServerBadRequests(t *testing.T) {
+	srv := NewServer(embedx.NewMemoryStore())
+
+	tests := []struct {
+		name, method, path, body string
+		want                     int
+	}{
+		{"invalid json", "POST", "/v1/vectors", `{"id":`, http.StatusBadRequest},
+		{"unknown field", "POST", "/v1/vectors", `{"id":"a","vector":[1],"extra":1}`, http.StatusBadRequest},
+		{"trailing data", "POST", "/v1/vectors", `{"id":"a","vector":[1]} {}`, http.StatusBadRequest},
+		{"empty id", "POST", "/v1/vectors", `{"vector":[1]}`, http.StatusBadRequest},
+		{"empty vector", "POST", "/v1/vectors", `{"id":"a"}`, http.StatusBadRequest},
+		{"invalid mode", "POST", "/v1/vectors", `{"id":"a","vector":[1],"mode":"merge"}`, http.StatusBadRequest},
+		{"batch with invalid vector", "POST", "/v1/vectors/batch", `{"vectors":[{"id":""}]}`, http.StatusBadRequest},
+		{"batch with invalid mode", "POST", "/v1/vectors/batch", `{"vectors":[],"mode":"merge"}`, http.StatusBadRequest},
+		{"batch with mixed modes", "POST", "/v1/vectors/batch", `{"vectors":[{"id":"a","vector":[1],"mode":"insert"}]}`, http.StatusBadRequest},
+		{"empty query", "POST", "/v1/search", `{"k":3}`, http.StatusBadRequest},
+		{"invalid filter", "POST", "/v1/search", `{"vector":[1],"filter":{"a":{"$regex":"x"}}}`, http.StatusBadRequest},
+		{"wrong method", "PUT", "/v1/search", `{}`, http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := do(t, srv, tt.method, tt.path, tt.body, nil); code != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, code)
+			}
+		})
+	}
+}
+
+func TestServerBatchAdd(t *testing.T) {
+	store := embedx.NewMemoryStoreWithDim(2)
+	srv := NewServer(store)
+	if err := store.Add("a", []float32{1, 0}, nil); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	// An atomic batch with a rejected vector stores nothing.
+	body := `{"vectors":[{"id":"b","vector":[0,1]},{"id":"a","vector":[1,1]},{"id":"c","vector":[1,2,3]}],"mode":"insert","atomic":true}`
+	var resp batchResponse
+	if code := do(t, srv, "POST", "/v1/vectors/batch", body, &resp); code != http.StatusConflict {
+		t.Fatalf("atomic batch: expected 409, got %d %+v", code, resp)
+	}
+	want := []batchItemError{
+		{Index: 1, ID: "a", Status: http.StatusConflict},
+		{Index: 2, ID: "c", Status: http.StatusBadRequest},
+	}
+	checkItems := func(name string, resp batchResponse, added int) {
+		t.Helper()
+		if resp.Added != added || len(resp.Errors) != len(want) {
+			t.Fatalf("%s: expected %d added and %d errors, got %+v", name, added, len(want), resp)
+		}
+		for i, item := range resp.Errors {
+			if item.Index != want[i].Index || item.ID != want[i].ID || item.Status != want[i].Status || item.Error == "" {
+				t.Errorf("%s: error %d: expected %+v, got %+v", name, i, want[i], item)
+			}
+		}
+	}
+	checkItems("atomic batch", resp, 0)
+	if resp.Error == "" {
+		t.Error("atomic batch: expected an error message")
+	}
+	if _, err := store.GetVector("b"); !errors.Is(err, embedx.ErrNotFound) {
+		t.Errorf("atomic batch: expected b not to be stored, got %v", err)
+	}
+
+	// A best-effort batch stores the valid vectors and reports the others.
+	body = strings.Replace(body, `"atomic":true`, `"atomic":false`, 1)
+	resp = batchResponse{}
+	if code := do(t, srv, "POST", "/v1/vectors/batch", body, &resp); code != http.StatusOK {
+		t.Fatalf("best-effort batch: expected 200, got %d %+v", code, resp)
+	}
+	checkItems("best-effort batch", resp, 1)
+	if resp.Error != "" {
+		t.Errorf("best-effort batch: expected no error message, got %q", resp.Error)
+	}
+	if _, err := store.GetVector("b"); err != nil {
+		t.Errorf("best-effort batch: expected b to be stored, got %v", err)
+	}
+}
+
+func TestServerSearchEmptyStore(t *testing.T) {
+	srv := NewServer(embedx.NewMemoryStore())
+
+	var search searchResponse
+	if code := do(t, srv, "POST", "/v1/search", `{"vector":[1,0]}`, &search); code != http.StatusOK {
+		t.Fatalf("search of an empty store: expected 200, got %d", code)
+	}
+	if search.Results == nil || len(search.Results) != 0 {
+		t.Errorf("search of an empty store: expected empty results, got %+v", search.Results)
+	}
+}
+
+func TestServerBadgerStore(t *testing.T) {
+	store, err := badger.NewBadgerStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewBadgerStore failed: %v", err)
+	}
+	defer store.Close()
+	srv := NewServer(store)
+
+	var search searchResponse
+	if code := do(t, srv, "POST", "/v1/search", `{"vector":[1,0]}`, &search); code != http.StatusOK || len(search.Results) != 0 {
+		t.Fatalf("search of an empty store: expected 200 with no results, got %d %+v", code, search)
+	}
+
+	// Arrays and nested objects in metadata are stored and returned as sent.
+	meta := `{"tags":["a","b"],"author":{"name":"ann","ids":[1,2]}}`
+	if code := do(t, srv, "POST", "/v1/vectors", `{"id":"a","vector":[1,0],"meta":`+meta+`}`, nil); code != http.StatusCreated {
+		t.Fatalf("add: expected 201, got %d", code)
+	}
+	body := `{"vectors":[{"id":"b","vector":[0,1],"meta":{"tags":[]}},{"id":"a","vector":[1,1]}],"mode":"insert"}`
+	var batch batchResponse
+	if code := do(t, srv, "POST", "/v1/vectors/batch", body, &batch); code != http.StatusOK || batch.Added != 1 || len(batch.Errors) != 1 {
+		t.Fatalf("batch add: expected 200 with 1 added and 1 error, got %d %+v", code, batch)
+	}
+
+	var want map[string]any
+	_ = json.Unmarshal([]byte(meta), &want)
+	var got vectorResponse
+	if code := do(t, srv, "GET", "/v1/vectors/a", "", &got); code != http.StatusOK {
+		t.Fatalf("get: expected 200, got %d", code)
+	}
+	if !reflect.DeepEqual(got.Meta, want) {
+		t.Errorf("get: expected metadata %v, got %v", want, got.Meta)
+	}
+	if code := do(t, srv, "POST", "/v1/search", `{"vector":[1,0],"k":2,"filter":{"author":{"$exists":true}}}`, &search); code != http.StatusOK {
+		t.Fatalf("filtered search: expected 200, got %d", code)
+	}
+	if len(search.Results) != 1 || search.Results[0].ID != "a" || !reflect.DeepEqual(search.Results[0].Meta, want) {
+		t.Errorf("filtered search: expected a with its metadata, got %+v", search.Results)
+	}
+}
+
+func TestStatusCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{fmt.Errorf("add: %w", embedx.ErrNotFound), http.StatusNotFound},
+		{&embedx.DimensionError{Expected: 3, Actual: 2}, http.StatusBadRequest},
+		{&embedx.SchemaError{Field: "dim", Stored: "3", Requested: "4"}, http.StatusConflict},
+		{fmt.Errorf("add: %w", embedx.ErrInvalidID), http.StatusBadRequest},
+		{fmt.Errorf("quantize: %w", embedx.ErrEmptyStore), http.StatusConflict},
+		{context.DeadlineExceeded, http.StatusServiceUnavailable},
+		{context.Canceled, http.StatusServiceUnavailable},
+		{errors.New("disk full"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if got := statusCode(tt.err); got != tt.want {
+			t.Errorf("statusCode(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestServerMaxBodyBytes(t *testing.T) {
+	srv := NewServer(embedx.NewMemoryStore(), WithMaxBodyBytes(64))
+
+	body := `{"id":"a","vector":[` + strings.Repeat("1,", 100) + `1]}`
+	var errResp errorResponse
+	if code := do(t, srv, "POST", "/v1/vectors", body, &errResp); code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413, got %d (%s)", code, errResp.Error)
+	}
+	if code := do(t, srv, "POST", "/v1/vectors", `{"id":"a","vector":[1,2]}`, nil); code != http.StatusCreated {
+		t.Fatalf("small request: expected 201, got %d", code)
+	}
+}
+
+// blockingStore is a store whose searches wait until their context is done.
+type blockingStore struct {
+	embedx.Store
+}
+
+func (blockingStore) SearchWithFilterContext(ctx context.Context, query []float32, k int, filter embedx.Filter) ([]embedx.SearchResult, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestServerRequestTimeout(t *testing.T) {
+	srv := NewServer(blockingStore{embedx.NewMemoryStore()}, WithRequestTimeout(10*time.Millisecond))
+
+	var errResp errorResponse
+	if code := do(t, srv, "POST", "/v1/search", `{"vector":[1,0]}`, &errResp); code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d (%s)", code, errResp.Error)
+	}
+	if !strings.Contains(errResp.Error, "deadline exceeded") {
+		t.Errorf("Expected a deadline error, got %q", errResp.Error)
+	}
+}
+
+func TestServerPassesRequestContext(t *testing.T) {
+	store := embedx.NewMemoryStore()
+	if err := store.Add("a", []float32{1, 0}, nil); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	srv := NewServer(store)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest("POST", "/v1/search", strings.NewReader(`{"vector":[1,0]}`)).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 for a cancelled request, got %d (%s)", rec.Code, rec.Body.String())
+	}
+}
+
+func TestServerServeShutsDownGracefully(t *testing.T) {
+	srv := NewServer(embedx.NewMemoryStore(), WithShutdownTimeout(time.Second))
+
+	ln1, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	ln2, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ctx, ln1, ln2) }()
+
+	for _, ln := range []net.Listener{ln1, ln2} {
+		resp, err := http.Post("http://"+ln.Addr().String()+"/v1/vectors", "application/json",
+			bytes.NewBufferString(`{"id":"`+ln.Addr().String()+`","vector":[1,2]}`))
+		if err != nil {
+			t.Fatalf("request to %s failed: %v", ln.Addr(), err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("expected 201 from %s, got %d", ln.Addr(), resp.StatusCode)
+		}
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Serve returned error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return after cancellation")
+	}
+
+	if _, err := http.Get("http://" + ln1.Addr().String() + "/healthz"); err == nil {
+		t.Error("Expected listener to be closed after shutdown")
+	}
+}
+
+func TestListenAndServeInvalidAddress(t *testing.T) {
+	srv := NewServer(embedx.NewMemoryStore())
+	if err := srv.ListenAndServe(context.Background(), "127.0.0.1:0", "invalid:address:1"); err == nil {
+		t.Error("Expected error for invalid address, got nil")
+	}
+	if err := srv.ListenAndServe(context.Background()); err == nil {
+		t.Error("Expected error without addresses, got nil")
+	}
+}
@@ -4,39 +4,180 @@ package badger
 
 import (
 	"bytes"
+	"context"
 	"encoding/gob"
+	"errors"
 	"fmt"
 	"github.com/dgraph-io/badger/v4"
 	"github.com/ldaidone/goembedx/pkg/embedx" // only for the interface
+	"github.com/ldaidone/goembedx/pkg/index/hnsw"
+	"github.com/ldaidone/goembedx/vector"
 	"math"
-	"sort"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 // BadgerStore implements the embedx stores using BadgerDB as the persistent backend.
 // It stores vectors with precomputed norms for efficient similarity calculations.
+// Methods with a Context suffix return the context's error once it is done:
+// scans check it every embedx.ContextCheckInterval records, and writes check
+// it before each transaction they commit.
 type BadgerStore struct {
 	// db is the underlying BadgerDB database instance.
 	db *badger.DB
+	// prefix starts every key of the store. It is empty for the store
+	// opened by NewBadgerStore and set for its collections.
+	prefix string
+	// collections tracks the open collections of the database. It is
+	// shared by the store opened by NewBadgerStore and its collections.
+	collections *collectionSet
+	// schema is the schema recorded in the store header. Its metric scores
+	// vectors in searches, both in scans and in the HNSW graph, and its
+	// precision encodes the vector components.
+	schema embedx.Schema
+	// opts holds the schema settings requested with options.
+	opts schemaOptions
+	// graphCfg holds the HNSW configuration requested with WithIndex, or nil.
+	graphCfg *hnsw.Config
+	// graph is the HNSW index persisted under graphPrefix, or nil if disabled.
+	// It is swapped atomically when the graph is reloaded after a failed write.
+	graph atomic.Pointer[hnsw.Graph]
+	// graphOnDisk reports whether the database holds a persisted graph that
+	// must be invalidated by writes made while the graph is disabled.
+	graphOnDisk bool
+	// quant is the quantization configuration set by Quantize, or nil.
+	quant atomic.Pointer[quantState]
+	// ivf is the IVF index trained by TrainIVF, or nil.
+	ivf atomic.Pointer[ivfState]
+	// mu serializes writes so that the in-memory graph and the database
+	// always commit the same changes in the same order.
+	mu sync.Mutex
 }
 
 // Compile-time interface checks
 var _ embedx.VectorStore = (*BadgerStore)(nil)
 var _ embedx.Store = (*BadgerStore)(nil)
+var _ embedx.IndexedStore = (*BadgerStore)(nil)
+var _ embedx.StatsProvider = (*BadgerStore)(nil)
+var _ embedx.Scanner = (*BadgerStore)(nil)
+var _ embedx.Quantizable = (*BadgerStore)(nil)
+var _ embedx.SchemaStore = (*BadgerStore)(nil)
+var _ batchWriter = (*badger.Txn)(nil)
+var _ batchWriter = (*badger.WriteBatch)(nil)
+
+// Option configures optional BadgerStore behavior in NewBadgerStore.
+type Option func(*BadgerStore)
+
+// WithMetric sets the similarity metric used to rank search results.
+// The metric is part of the store schema; see WithDim.
+// The default is vector.MetricCosine.
+func WithMetric(m vector.Metric) Option {
+	return func(s *BadgerStore) {
+		s.opts.metric = &m
+	}
+}
+
+// WithPrecision sets the precision the vector components are stored in.
+// Half-precision stores take half the space of float32 stores: vectors are
+// rounded when they are written, and Get returns the rounded values.
+// The precision is part of the store schema; see WithDim.
+// The default is vector.PrecisionFloat32.
+func WithPrecision(p vector.Precision) Option {
+	return func(s *BadgerStore) {
+		s.opts.precision = &p
+	}
+}
+
+// WithDim fixes the dimension of the stored vectors. Writes of vectors and
+// searches with queries of another dimension fail with a
+// *embedx.DimensionError. By default, vectors of any dimension are accepted.
+//
+// The dimension, metric, precision and model form the store schema, which is
+// recorded in the store header when the store is created. Later opens use the
+// recorded schema: options that are omitted take their value from it, and
+// options that conflict with it make NewBadgerStore fail with a
+// *embedx.SchemaError. See also SetSchema.
+func WithDim(dim int) Option {
+	return func(s *BadgerStore) {
+		s.opts.dim = &dim
+	}
+}
+
+// WithModel records the name and version of the embedding model the vectors
+// come from in the store schema; see WithDim. Either may be empty.
+func WithModel(name, version string) Option {
+	return func(s *BadgerStore) {
+		s.opts.model = &[2]string{name, version}
+	}
+}
+
+// WithIndex enables an HNSW index that is persisted in the same database as
+// the vectors. Graph updates commit in the same transaction as the vector
+// writes, and the graph is loaded on open instead of being rebuilt.
+// The graph always uses the store's metric; cfg.Metric is ignored.
+func WithIndex(cfg hnsw.Config) Option {
+	return func(s *BadgerStore) {
+		s.graphCfg = &cfg
+	}
+}
 
 // NewBadgerStore creates a new BadgerStore instance backed by BadgerDB.
 // The path parameter specifies the directory where the database files will be stored.
-// Returns an error if the database cannot be opened or initialized.
-func NewBadgerStore(path string) (*BadgerStore, error) {
-	opts := badger.DefaultOptions(path).WithLogger(nil)
-	db, err := badger.Open(opts)
+// Returns an error if the database cannot be opened or initialized, or if the
+// options request a negative dimension or an unknown metric or precision.
+// Returns a *embedx.SchemaError if the options conflict with the schema the
+// store was created with.
+func NewBadgerStore(path string, opts ...Option) (*BadgerStore, error) {
+	bopts := badger.DefaultOptions(path).WithLogger(nil)
+	db, err := badger.Open(bopts)
 	if err != nil {
 		return nil, err
 	}
-	return &BadgerStore{db: db}, nil
+
+	s := &BadgerStore{db: db}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.collections = newCollectionSet(s.graphCfg)
+	if err := s.open(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// open validates the requested schema against the stored one and loads the
+// indexes and quantizer of the store.
+func (s *BadgerStore) open() error {
+	if err := validateSchema(s.opts.apply(embedx.Schema{})); err != nil {
+		return err
+	}
+	if err := s.loadSchema(); err != nil {
+		return err
+	}
+	if s.graphCfg != nil {
+		s.graphCfg.Metric = s.schema.Metric
+	}
+
+	if err := s.openGraph(); err != nil {
+		return err
+	}
+	if err := s.loadQuantizer(); err != nil {
+		return err
+	}
+	return s.loadIVF()
 }
 
 // VectorStore interface methods
 func (s *BadgerStore) SaveVector(id string, vec []float32) error {
+	return s.SaveVectorContext(context.Background(), id, vec)
+}
+
+// SaveVectorContext is like SaveVector but returns ctx.Err() if ctx is done
+// before the vector is written.
+func (s *BadgerStore) SaveVectorContext(ctx context.Context, id string, vec []float32) error {
 	// Use the same data structure as Add to maintain consistency
 	var norm float32
 	for _, val := range vec {
@@ -50,112 +191,97 @@ func (s *BadgerStore) SaveVector(id string, vec []float32) error {
 		Meta:   nil, // No metadata for basic SaveVector
 	}
 
-	var buf bytes.Buffer
-	if err := gob.NewEncoder(&buf).Encode(data); err != nil {
-		return err
-	}
-
-	return s.db.Update(func(txn *badger.Txn) error {
-		return txn.Set([]byte(id), buf.Bytes())
-	})
+	return s.putVectorData(ctx, id, data, embedx.UpsertAny)
 }
 
 func (s *BadgerStore) GetVector(id string) ([]float32, error) {
+	return s.GetVectorContext(context.Background(), id)
+}
+
+// GetVectorContext is like GetVector but returns ctx.Err() if ctx is done.
+func (s *BadgerStore) GetVectorContext(ctx context.Context, id string) ([]float32, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	data, err := s.getVectorData(id)
+	if err != nil {
+		return nil, err
+	}
+	return data.Vector, nil
+}
+
+// getVectorData reads and decodes the record stored under id.
+// Returns an error wrapping embedx.ErrNotFound if the ID is not stored.
+func (s *BadgerStore) getVectorData(id string) (vectorData, error) {
 	var data vectorData
+	if strings.HasPrefix(id, internalPrefix) {
+		return data, fmt.Errorf("%w: %s", embedx.ErrNotFound, id)
+	}
 
 	err := s.db.View(func(txn *badger.Txn) error {
-		item, err := txn.Get([]byte(id))
+		item, err := txn.Get(s.key(id))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return fmt.Errorf("%w: %s", embedx.ErrNotFound, id)
+		}
 		if err != nil {
 			return err
 		}
 		return item.Value(func(v []byte) error {
-			// First try to decode as the new vectorData struct
-			dec := gob.NewDecoder(bytes.NewReader(v))
-			err := dec.Decode(&data)
-			if err != nil {
-				// If that fails, try to decode as the old []float32 format
-				var oldVec []float32
-				decOld := gob.NewDecoder(bytes.NewReader(v))
-				oldErr := decOld.Decode(&oldVec)
-				if oldErr != nil {
-					return fmt.Errorf("failed to decode vector data: %w", err)
-				}
-				// Convert to new format with computed norm
-				var norm float32
-				for _, val := range oldVec {
-					norm += val * val
-				}
-				norm = float32(math.Sqrt(float64(norm)))
-				data = vectorData{
-					Vector: oldVec,
-					Norm:   norm,
-					Meta:   nil,
-				}
-				// Optionally update the stored record to new format
-				_ = s.updateToNewFormat(id, &data) // Don't fail if this fails
-			}
-			return nil
+			var err error
+			data, err = s.decodeVectorData(id, v)
+			return err
 		})
 	})
-
-	if err != nil {
-		return nil, err
-	}
-
-	return data.Vector, nil
+	return data, err
 }
 
-// updateToNewFormat updates an existing record from old format to new format
-func (s *BadgerStore) updateToNewFormat(id string, data *vectorData) error {
-	var buf bytes.Buffer
-	if err := gob.NewEncoder(&buf).Encode(data); err != nil {
+// migrateRecord rewrites the legacy record old of id in the binary layout.
+// The record is only replaced if it still holds old, so that a concurrent
+// write is never overwritten with stale data.
+func (s *BadgerStore) migrateRecord(id string, old []byte, data vectorData) error {
+	v, err := encodeRecord(data, s.schema.Precision)
+	if err != nil {
 		return err
 	}
 
 	return s.db.Update(func(txn *badger.Txn) error {
-		return txn.Set([]byte(id), buf.Bytes())
+		item, err := txn.Get(s.key(id))
+		if err != nil {
+			return err
+		}
+		current, err := item.ValueCopy(nil)
+		if err != nil || !bytes.Equal(current, old) {
+			return err
+		}
+		return txn.Set(s.key(id), v)
 	})
 }
 
 func (s *BadgerStore) GetAllVectors() (map[string][]float32, error) {
+	return s.GetAllVectorsContext(context.Background())
+}
+
+// GetAllVectorsContext is like GetAllVectors but stops reading and returns
+// ctx.Err() once ctx is done.
+func (s *BadgerStore) GetAllVectorsContext(ctx context.Context) (map[string][]float32, error) {
 	vectors := make(map[string][]float32)
 
 	err := s.db.View(func(txn *badger.Txn) error {
-		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		it := s.newVectorIterator(txn, badger.DefaultIteratorOptions)
 		defer it.Close()
 
-		for it.Rewind(); it.Valid(); it.Next() {
+		for it.Seek(s.key(firstVectorKey)); it.Valid(); it.Next() {
+			if err := embedx.CheckContext(ctx, len(vectors)); err != nil {
+				return err
+			}
 			item := it.Item()
-			key := string(item.Key())
+			key := s.id(item.Key())
 
 			var data vectorData
 			err := item.Value(func(v []byte) error {
-				// First try to decode as the new vectorData struct
-				dec := gob.NewDecoder(bytes.NewReader(v))
-				err := dec.Decode(&data)
-				if err != nil {
-					// If that fails, try to decode as the old []float32 format
-					var oldVec []float32
-					decOld := gob.NewDecoder(bytes.NewReader(v))
-					oldErr := decOld.Decode(&oldVec)
-					if oldErr != nil {
-						return fmt.Errorf("failed to decode vector %s: %w", key, err)
-					}
-					// Convert to new format with computed norm
-					var norm float32
-					for _, val := range oldVec {
-						norm += val * val
-					}
-					norm = float32(math.Sqrt(float64(norm)))
-					data = vectorData{
-						Vector: oldVec,
-						Norm:   norm,
-						Meta:   nil,
-					}
-					// Optionally update the stored record to new format
-					_ = s.updateToNewFormat(key, &data) // Don't fail if this fails
-				}
-				return nil
+				var err error
+				data, err = s.decodeVectorData(key, v)
+				return err
 			})
 			if err != nil {
 				return err
@@ -166,10 +292,86 @@ func (s *BadgerStore) GetAllVectors() (map[string][]float32, error) {
 		return nil
 	})
 
-	return vectors, err
+	if err != nil {
+		return nil, err
+	}
+	return vectors, nil
 }
 
+// Scan calls fn for every stored record in ascending ID order, decoding one
+// record at a time inside a single read transaction.
+func (s *BadgerStore) Scan(fn func(embedx.Record) error) error {
+	return s.db.View(func(txn *badger.Txn) error {
+		it := s.newVectorIterator(txn, badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Seek(s.key(firstVectorKey)); it.Valid(); it.Next() {
+			item := it.Item()
+			id := s.id(item.Key())
+
+			var data vectorData
+			err := item.Value(func(v []byte) error {
+				var err error
+				data, err = s.decodeVectorData(id, v)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+
+			if err := fn(embedx.Record{ID: id, Vector: data.Vector, Norm: data.Norm, Meta: data.Meta}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Stats counts the stored vectors without decoding them and reports the
+// dimension of the first stored vector, the store's metric and whether
+// the HNSW or IVF index is enabled and the store is quantized.
+func (s *BadgerStore) Stats() (embedx.StoreStats, error) {
+	stats := embedx.StoreStats{
+		Dim:       s.schema.Dim,
+		Metric:    s.schema.Metric,
+		Precision: s.schema.Precision,
+		Model:     s.schema.Model,
+		Indexed:   s.graph.Load() != nil || s.ivf.Load() != nil,
+		Quantized: s.quant.Load() != nil,
+	}
+
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := s.newVectorIterator(txn, opts)
+		defer it.Close()
+
+		for it.Seek(s.key(firstVectorKey)); it.Valid(); it.Next() {
+			if stats.Count == 0 {
+				item := it.Item()
+				err := item.Value(func(v []byte) error {
+					r, err := s.viewRecord(item.Key(), v)
+					stats.Dim = r.dim
+					return err
+				})
+				if err != nil {
+					return err
+				}
+			}
+			stats.Count++
+		}
+		return nil
+	})
+	return stats, err
+}
+
+// Close closes the database. Closing a collection does nothing: the database
+// is closed with the store returned by NewBadgerStore, which makes its
+// collections unusable.
 func (s *BadgerStore) Close() error {
+	if s.prefix != "" {
+		return nil
+	}
 	return s.db.Close()
 }
 
@@ -188,6 +390,12 @@ type vectorData struct {
 // It precomputes the L2 norm of the vector for faster similarity calculations.
 // Returns an error if the operation fails.
 func (s *BadgerStore) Add(id string, vec []float32, meta map[string]any) error {
+	return s.AddContext(context.Background(), id, vec, meta)
+}
+
+// AddContext is like Add but returns ctx.Err() if ctx is done before the
+// vector is written.
+func (s *BadgerStore) AddContext(ctx context.Context, id string, vec []float32, meta map[string]any) error {
 	// Precompute norm for faster similarity calculations
 	var norm float32
 	for _, val := range vec {
@@ -201,131 +409,759 @@ func (s *BadgerStore) Add(id string, vec []float32, meta map[string]any) error {
 		Meta:   meta,
 	}
 
-	var buf bytes.Buffer
-	if err := gob.NewEncoder(&buf).Encode(data); err != nil {
+	return s.putVectorData(ctx, id, data, embedx.UpsertAny)
+}
+
+// UpsertVector stores a vector without metadata according to mode.
+// Returns an error wrapping embedx.ErrAlreadyExists or embedx.ErrNotFound when mode forbids the write.
+func (s *BadgerStore) UpsertVector(id string, vec []float32, mode embedx.UpsertMode) error {
+	return s.UpsertContext(context.Background(), id, vec, nil, mode)
+}
+
+// UpsertVectorContext is like UpsertVector but returns ctx.Err() if ctx is
+// done before the vector is written.
+func (s *BadgerStore) UpsertVectorContext(ctx context.Context, id string, vec []float32, mode embedx.UpsertMode) error {
+	return s.UpsertContext(ctx, id, vec, nil, mode)
+}
+
+// Upsert stores a vector with the given ID and associated metadata according to mode.
+// The existence check and the write happen in the same transaction.
+// Returns an error wrapping embedx.ErrAlreadyExists or embedx.ErrNotFound when mode forbids the write.
+func (s *BadgerStore) Upsert(id string, vec []float32, meta map[string]any, mode embedx.UpsertMode) error {
+	return s.UpsertContext(context.Background(), id, vec, meta, mode)
+}
+
+// UpsertContext is like Upsert but returns ctx.Err() if ctx is done before
+// the vector is written.
+func (s *BadgerStore) UpsertContext(ctx context.Context, id string, vec []float32, meta map[string]any, mode embedx.UpsertMode) error {
+	data := vectorData{
+		Vector: vec,
+		Norm:   s.computeNorm(vec),
+		Meta:   meta,
+	}
+	return s.putVectorData(ctx, id, data, mode)
+}
+
+// Delete removes the vector with the given ID. When the HNSW index is enabled,
+// the vector is tombstoned in the graph in the same transaction.
+// Returns an error wrapping embedx.ErrNotFound if the ID is not stored.
+func (s *BadgerStore) Delete(id string) error {
+	return s.DeleteContext(context.Background(), id)
+}
+
+// DeleteContext is like Delete but returns ctx.Err() if ctx is done before
+// the vector is deleted.
+func (s *BadgerStore) DeleteContext(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
 		return err
 	}
 
-	return s.db.Update(func(txn *badger.Txn) error {
-		return txn.Set([]byte(id), buf.Bytes())
+	return s.updateGraph(func(txn *badger.Txn) error {
+		deleted, err := s.deleteInTxn(txn, id)
+		if err != nil {
+			return err
+		}
+		if !deleted {
+			return fmt.Errorf("%w: %s", embedx.ErrNotFound, id)
+		}
+		return nil
 	})
 }
 
-// Get retrieves a vector by its ID along with its precomputed norm and metadata.
-// It handles backward compatibility with older data formats.
-// Returns the vector, its norm, metadata, and any error that occurred.
-func (s *BadgerStore) Get(id string) ([]float32, float32, map[string]any, error) {
-	var data vectorData
+// DeleteMany removes the vectors with the given IDs, skipping IDs that are not stored.
+// IDs are deleted in transactions of up to deleteBatchSize IDs.
+// Returns the number of vectors removed.
+func (s *BadgerStore) DeleteMany(ids []string) (int, error) {
+	return s.DeleteManyContext(context.Background(), ids)
+}
 
-	err := s.db.View(func(txn *badger.Txn) error {
-		item, err := txn.Get([]byte(id))
+// DeleteManyContext is like DeleteMany but returns ctx.Err(), with the number
+// of vectors removed so far, if ctx is done before a transaction starts.
+// Vectors deleted by earlier transactions stay deleted.
+func (s *BadgerStore) DeleteManyContext(ctx context.Context, ids []string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	total := 0
+	for start := 0; start < len(ids); start += deleteBatchSize {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+		chunk := ids[start:min(start+deleteBatchSize, len(ids))]
+		n := 0
+		err := s.updateGraph(func(txn *badger.Txn) error {
+			for _, id := range chunk {
+				deleted, err := s.deleteInTxn(txn, id)
+				if err != nil {
+					return err
+				}
+				if deleted {
+					n++
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return total, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// deleteBatchSize bounds the number of IDs deleted per transaction by DeleteMany.
+const deleteBatchSize = 1000
+
+// deleteInTxn deletes the record of id and tombstones it in the graph.
+// It reports whether the record existed.
+func (s *BadgerStore) deleteInTxn(txn *badger.Txn, id string) (bool, error) {
+	if strings.HasPrefix(id, internalPrefix) {
+		return false, nil
+	}
+	_, err := txn.Get(s.key(id))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := txn.Delete(s.key(id)); err != nil {
+		return false, err
+	}
+	if s.quant.Load() != nil {
+		if err := txn.Delete(s.quantCodeKey(id)); err != nil {
+			return false, err
+		}
+	}
+	if s.ivf.Load() != nil {
+		list, err := s.readIVFList(txn, id)
 		if err != nil {
+			return false, err
+		}
+		if list >= 0 {
+			if err := applyWrites(txn, []keyWrite{{key: s.ivfPostingKey(list, id)}, {key: s.ivfAssignKey(id)}}); err != nil {
+				return false, err
+			}
+		}
+	}
+	if g := s.graph.Load(); g != nil {
+		if err := g.Delete(id); err != nil && !errors.Is(err, embedx.ErrNotFound) {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// errEmptyVector is returned for writes of vectors without components.
+var errEmptyVector = errors.New("vector cannot be empty")
+
+// checkID returns an error wrapping embedx.ErrInvalidID if id is empty or
+// starts with the reserved internalPrefix.
+func checkID(id string) error {
+	switch {
+	case id == "":
+		return fmt.Errorf("%w: id cannot be empty", embedx.ErrInvalidID)
+	case strings.HasPrefix(id, internalPrefix):
+		return fmt.Errorf("%w: id cannot start with a reserved NUL byte", embedx.ErrInvalidID)
+	}
+	return nil
+}
+
+// putVectorData encodes and writes a vector record after checking mode
+// against the existing record in the same transaction. When the HNSW index is
+// enabled, the vector is inserted into the graph and the modified graph nodes
+// are written in the same transaction as the record, and so are the codes of
+// the vector when the store is quantized and its IVF posting when the store
+// has an IVF index. It returns ctx.Err() if ctx is done once the write lock
+// is acquired.
+func (s *BadgerStore) putVectorData(ctx context.Context, id string, data vectorData, mode embedx.UpsertMode) error {
+	if err := checkID(id); err != nil {
+		return err
+	}
+	if len(data.Vector) == 0 {
+		return errEmptyVector
+	}
+	if err := s.checkDim(data.Vector); err != nil {
+		return err
+	}
+
+	if s.schema.Precision != vector.PrecisionFloat32 {
+		data.Vector = s.schema.Precision.Round(nil, data.Vector)
+		data.Norm = s.computeNorm(data.Vector)
+	}
+	v, err := encodeRecord(data, s.schema.Precision)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var extra []keyWrite
+	if qs := s.quant.Load(); qs != nil {
+		if len(data.Vector) != qs.dim() {
+			return &embedx.DimensionError{Expected: qs.dim(), Actual: len(data.Vector)}
+		}
+		extra = append(extra, keyWrite{key: s.quantCodeKey(id), value: qs.encode(data.Vector, data.Norm)})
+	}
+	st := s.ivf.Load()
+	if st != nil && len(data.Vector) != st.centroids.Dim() {
+		return &embedx.DimensionError{Expected: st.centroids.Dim(), Actual: len(data.Vector)}
+	}
+
+	return s.updateGraph(func(txn *badger.Txn) error {
+		if mode != embedx.UpsertAny {
+			_, err := txn.Get(s.key(id))
+			if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+				return err
+			}
+			if err := embedx.CheckUpsertMode(id, mode, err == nil); err != nil {
+				return err
+			}
+		}
+		if err := txn.Set(s.key(id), v); err != nil {
 			return err
 		}
-		return item.Value(func(v []byte) error {
-			// First try to decode as the new vectorData struct
-			dec := gob.NewDecoder(bytes.NewReader(v))
-			err := dec.Decode(&data)
+		if err := applyWrites(txn, extra); err != nil {
+			return err
+		}
+		if st != nil {
+			old, err := s.readIVFList(txn, id)
 			if err != nil {
-				// If that fails, try to decode as the old []float32 format
-				var oldVec []float32
-				decOld := gob.NewDecoder(bytes.NewReader(v))
-				oldErr := decOld.Decode(&oldVec)
-				if oldErr != nil {
-					return fmt.Errorf("failed to decode vector data: %w", err)
-				}
-				// Convert to new format with computed norm
-				var norm float32
-				for _, val := range oldVec {
-					norm += val * val
+				return err
+			}
+			_, writes := s.ivfWrites(st, id, data.Vector, data.Norm, old)
+			if err := applyWrites(txn, writes); err != nil {
+				return err
+			}
+		}
+		g := s.graph.Load()
+		if g == nil {
+			return nil
+		}
+		return g.Add(id, data.Vector)
+	})
+}
+
+// errBatchRejected aborts the transaction of an atomic batch with rejected records.
+var errBatchRejected = errors.New("batch rejected")
+
+// AddBatch stores many vectors with their metadata. Records are validated,
+// encoded and checked against opts.Mode up front, reading existing IDs in a
+// single transaction, and a record may refer to an ID stored earlier in the
+// same batch.
+//
+// Best-effort batches are written with a badger.WriteBatch, which commits in
+// as few transactions as possible. When the HNSW index is enabled, the
+// persisted graph is invalidated while the batch is written and saved again
+// once it is complete, so that a crash mid-batch makes the next open rebuild
+// the graph rather than load one that is missing vectors.
+//
+// Atomic batches are written in a single transaction together with their graph
+// changes. They are bounded by Badger's transaction size and fail with
+// badger.ErrTxnTooBig, writing nothing, when they exceed it.
+//
+// Returns a *embedx.BatchError listing the rejected records.
+func (s *BadgerStore) AddBatch(records []embedx.Record, opts embedx.BatchOptions) error {
+	return s.AddBatchContext(context.Background(), records, opts)
+}
+
+// AddBatchContext is like AddBatch but returns ctx.Err() once ctx is done.
+// Nothing is written if ctx is done while the records are validated, and an
+// atomic batch writes nothing if ctx is done at all. A best-effort batch
+// cancelled while it is written may leave some of its records written.
+func (s *BadgerStore) AddBatchContext(ctx context.Context, records []embedx.Record, opts embedx.BatchOptions) error {
+	if s.schema.Precision != vector.PrecisionFloat32 {
+		// Index and codes are built from the vectors as they are stored.
+		records = slices.Clone(records)
+		for i := range records {
+			records[i].Vector = s.schema.Precision.Round(nil, records[i].Vector)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	qs, st := s.quant.Load(), s.ivf.Load()
+	errs := make([]error, len(records))
+	encoded := make([][]byte, len(records))
+	extra := make([][]keyWrite, len(records))
+	err := s.db.View(func(txn *badger.Txn) error {
+		pending := make(map[string]bool, len(records))
+		// lists holds the IVF list of the records pending in the batch.
+		lists := make(map[string]int)
+		for i, r := range records {
+			if err := embedx.CheckContext(ctx, i); err != nil {
+				return err
+			}
+			if errs[i] = checkID(r.ID); errs[i] != nil {
+				continue
+			}
+			switch {
+			case len(r.Vector) == 0:
+				errs[i] = errEmptyVector
+				continue
+			case qs != nil && len(r.Vector) != qs.dim():
+				errs[i] = &embedx.DimensionError{Expected: qs.dim(), Actual: len(r.Vector)}
+				continue
+			case st != nil && len(r.Vector) != st.centroids.Dim():
+				errs[i] = &embedx.DimensionError{Expected: st.centroids.Dim(), Actual: len(r.Vector)}
+				continue
+			}
+			if errs[i] = s.checkDim(r.Vector); errs[i] != nil {
+				continue
+			}
+
+			exists := pending[r.ID]
+			if !exists && opts.Mode != embedx.UpsertAny {
+				_, err := txn.Get(s.key(r.ID))
+				if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+					return err
 				}
-				norm = float32(math.Sqrt(float64(norm)))
-				data = vectorData{
-					Vector: oldVec,
-					Norm:   norm,
-					Meta:   nil,
+				exists = err == nil
+			}
+			if errs[i] = embedx.CheckUpsertMode(r.ID, opts.Mode, exists); errs[i] != nil {
+				continue
+			}
+
+			data := vectorData{Vector: r.Vector, Norm: s.computeNorm(r.Vector), Meta: r.Meta}
+			if encoded[i], errs[i] = encodeRecord(data, s.schema.Precision); errs[i] != nil {
+				continue
+			}
+			if qs != nil {
+				extra[i] = append(extra[i], keyWrite{key: s.quantCodeKey(r.ID), value: qs.encode(r.Vector, data.Norm)})
+			}
+			if st != nil {
+				old, ok := lists[r.ID]
+				if !ok {
+					var err error
+					if old, err = s.readIVFList(txn, r.ID); err != nil {
+						return err
+					}
 				}
-				// Optionally update the stored record to new format
-				_ = s.updateToNewFormat(id, &data) // Don't fail if this fails
+				list, writes := s.ivfWrites(st, r.ID, r.Vector, data.Norm, old)
+				extra[i] = append(extra[i], writes...)
+				lists[r.ID] = list
 			}
-			return nil
-		})
+			pending[r.ID] = true
+		}
+		return nil
 	})
+	if err != nil {
+		return err
+	}
+
+	if opts.Atomic {
+		if err := embedx.NewBatchError(batchItems(records, errs), 0); err != nil {
+			return err
+		}
+		err := s.updateGraph(func(txn *badger.Txn) error {
+			return s.writeBatch(ctx, records, encoded, extra, errs, txn)
+		})
+		if errors.Is(err, errBatchRejected) {
+			return embedx.NewBatchError(batchItems(records, errs), 0)
+		}
+		return err
+	}
 
+	written, err := s.writeBatchBestEffort(ctx, records, encoded, extra, errs)
 	if err != nil {
-		return nil, 0, nil, err
+		return err
+	}
+	return embedx.NewBatchError(batchItems(records, errs), written)
+}
+
+// writeBatch writes the encoded records and the keys that accompany them with
+// w and inserts them into the graph. Records rejected by the graph are
+// recorded in errs; in that case errBatchRejected is returned after every
+// record was tried. It returns ctx.Err() once ctx is done.
+func (s *BadgerStore) writeBatch(ctx context.Context, records []embedx.Record, encoded [][]byte, extra [][]keyWrite, errs []error, w batchWriter) error {
+	g := s.graph.Load()
+	rejected := false
+	for i, r := range records {
+		if err := embedx.CheckContext(ctx, i); err != nil {
+			return err
+		}
+		if encoded[i] == nil {
+			continue
+		}
+		if g != nil {
+			if errs[i] = g.Add(r.ID, r.Vector); errs[i] != nil {
+				rejected = true
+				continue
+			}
+		}
+		if err := w.Set(s.key(r.ID), encoded[i]); err != nil {
+			return err
+		}
+		if err := applyWrites(w, extra[i]); err != nil {
+			return err
+		}
+	}
+	if rejected {
+		return errBatchRejected
+	}
+	return nil
+}
+
+// writeBatchBestEffort writes the encoded records with a badger.WriteBatch and
+// returns the number written. The caller must hold s.mu.
+func (s *BadgerStore) writeBatchBestEffort(ctx context.Context, records []embedx.Record, encoded [][]byte, extra [][]keyWrite, errs []error) (int, error) {
+	g := s.graph.Load()
+	if g != nil || s.graphOnDisk {
+		err := s.db.Update(func(txn *badger.Txn) error {
+			return txn.Delete(s.key(graphEntryKey))
+		})
+		if err != nil {
+			return 0, err
+		}
+		s.graphOnDisk = false
+	}
+
+	wb := s.db.NewWriteBatch()
+	defer wb.Cancel()
+
+	err := s.writeBatch(ctx, records, encoded, extra, errs, wb)
+	if errors.Is(err, errBatchRejected) {
+		err = nil
+	}
+	if err == nil && g != nil {
+		for _, idx := range g.TakeDirty() {
+			if err = wb.Set(s.graphNodeKey(idx), encodeGraphNode(g.Node(idx))); err != nil {
+				break
+			}
+		}
+	}
+	if err == nil {
+		err = wb.Flush()
+	}
+	if err == nil && g != nil {
+		err = s.db.Update(func(txn *badger.Txn) error {
+			return txn.Set(s.key(graphEntryKey), encodeGraphEntry(g.EntryPoint()))
+		})
+	}
+	if err != nil {
+		if g != nil {
+			// Without an entry key, loading rebuilds the graph from the
+			// records that were committed.
+			return 0, errors.Join(err, s.loadGraph())
+		}
+		return 0, err
 	}
 
+	written := 0
+	for i := range records {
+		if errs[i] == nil {
+			written++
+		}
+	}
+	return written, nil
+}
+
+// keyWrite is a write of a key that accompanies a vector record, such as its
+// quantized codes or its IVF posting. A nil value deletes the key.
+type keyWrite struct {
+	key, value []byte
+}
+
+// batchWriter is implemented by both badger.Txn and badger.WriteBatch.
+type batchWriter interface {
+	Set(key, value []byte) error
+	Delete(key []byte) error
+}
+
+// applyWrites applies writes with w in order.
+func applyWrites(w batchWriter, writes []keyWrite) error {
+	for _, kw := range writes {
+		var err error
+		if kw.value == nil {
+			err = w.Delete(kw.key)
+		} else {
+			err = w.Set(kw.key, kw.value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// batchItems lists the records with a non-nil error in errs.
+func batchItems(records []embedx.Record, errs []error) []embedx.ItemError {
+	var items []embedx.ItemError
+	for i, err := range errs {
+		if err != nil {
+			items = append(items, embedx.ItemError{Index: i, ID: records[i].ID, Err: err})
+		}
+	}
+	return items
+}
+
+// Get retrieves a vector by its ID along with its precomputed norm and metadata.
+// It handles backward compatibility with older data formats.
+// Returns the vector, its norm, metadata, and any error that occurred.
+func (s *BadgerStore) Get(id string) ([]float32, float32, map[string]any, error) {
+	return s.GetContext(context.Background(), id)
+}
+
+// GetContext is like Get but returns ctx.Err() if ctx is done.
+func (s *BadgerStore) GetContext(ctx context.Context, id string) ([]float32, float32, map[string]any, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, nil, err
+	}
+	data, err := s.getVectorData(id)
+	if err != nil {
+		return nil, 0, nil, err
+	}
 	return data.Vector, data.Norm, data.Meta, nil
 }
 
 func (s *BadgerStore) Search(query []float32, k int) ([]embedx.SearchResult, error) {
-	results := make([]embedx.SearchResult, 0)
+	return s.SearchWithFilterContext(context.Background(), query, k, nil)
+}
+
+// SearchContext is like Search but stops and returns ctx.Err() once ctx is done.
+func (s *BadgerStore) SearchContext(ctx context.Context, query []float32, k int) ([]embedx.SearchResult, error) {
+	return s.SearchWithFilterContext(ctx, query, k, nil)
+}
+
+// SearchWithFilter performs similarity search restricted to vectors whose
+// metadata matches filter. The filter is evaluated on each record inside the
+// scan, after the vector is scored, and only if the score can make the top k.
+// A nil filter matches every vector.
+// Filtered searches always scan the store, bypassing the HNSW index, so that
+// selective filters cannot starve the approximate candidate list.
+// Unfiltered searches of a store with an IVF index and no HNSW index only
+// read the posting lists closest to the query; see TrainIVF. Unfiltered
+// searches of a quantized store without either index scan the codes of the
+// vectors instead of the records; see Quantize.
+// Returns a *embedx.DimensionError if the store schema fixes a dimension
+// other than the dimension of the query.
+func (s *BadgerStore) SearchWithFilter(query []float32, k int, filter embedx.Filter) ([]embedx.SearchResult, error) {
+	return s.SearchWithFilterContext(context.Background(), query, k, filter)
+}
+
+// SearchWithFilterContext is like SearchWithFilter but stops and returns
+// ctx.Err() once ctx is done.
+func (s *BadgerStore) SearchWithFilterContext(ctx context.Context, query []float32, k int, filter embedx.Filter) ([]embedx.SearchResult, error) {
+	if err := s.checkDim(query); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if filter == nil && s.graph.Load() != nil {
+		return s.searchGraph(ctx, query, k)
+	}
+	if st := s.ivf.Load(); filter == nil && st != nil {
+		return s.searchIVF(ctx, st, query, k)
+	}
+	if qs := s.quant.Load(); filter == nil && qs != nil {
+		return s.searchQuantized(ctx, qs, query, k)
+	}
 
-	queryNorm := s.computeNorm(query)
+	var results []embedx.SearchResult
+	top := vector.NewTopK(k)
+	sc := newScorer(s.schema.Metric, query)
 
 	err := s.db.View(func(txn *badger.Txn) error {
-		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		it := s.newVectorIterator(txn, badger.DefaultIteratorOptions)
 		defer it.Close()
 
-		for it.Rewind(); it.Valid(); it.Next() {
+		// Every vector is scored straight from the value, and only vectors
+		// that can make the top k get their ID copied, so the scan rarely
+		// allocates. With a filter, the metadata is decoded and matched
+		// first so that rejected vectors are never scored.
+		n := 0
+		for it.Seek(s.key(firstVectorKey)); it.Valid(); it.Next() {
+			if err := embedx.CheckContext(ctx, n); err != nil {
+				return err
+			}
+			n++
 			item := it.Item()
-			id := string(item.Key())
+			key := item.Key()
 
-			var data vectorData
 			err := item.Value(func(v []byte) error {
-				// First try to decode as the new vectorData struct
-				dec := gob.NewDecoder(bytes.NewReader(v))
-				err := dec.Decode(&data)
+				r, err := s.viewRecord(key, v)
 				if err != nil {
-					// If that fails, try to decode as the old []float32 format
-					var oldVec []float32
-					decOld := gob.NewDecoder(bytes.NewReader(v))
-					oldErr := decOld.Decode(&oldVec)
-					if oldErr != nil {
-						return fmt.Errorf("failed to decode vector %s: %w", id, err)
-					}
-					// Convert to new format with computed norm
-					var norm float32
-					for _, val := range oldVec {
-						norm += val * val
+					return err
+				}
+				if r.dim != len(query) {
+					return nil
+				}
+				// Cosine scores use the precomputed norm
+				if s.schema.Metric == vector.MetricCosine && (sc.norm == 0 || r.norm == 0) {
+					return nil
+				}
+				if filter != nil {
+					meta, err := r.metadata()
+					if err != nil {
+						return fmt.Errorf("vector %s: %w", s.id(key), err)
 					}
-					norm = float32(math.Sqrt(float64(norm)))
-					data = vectorData{
-						Vector: oldVec,
-						Norm:   norm,
-						Meta:   nil,
+					if !embedx.MatchFilter(filter, meta) {
+						return nil
 					}
-					// Optionally update the stored record to new format
-					_ = s.updateToNewFormat(id, &data) // Don't fail if this fails
 				}
+				score := sc.score(r.precision, r.payload, r.norm)
+				if !top.Admits(score) {
+					return nil
+				}
+				top.Push(vector.Candidate{ID: s.id(key), Score: score})
 				return nil
 			})
 			if err != nil {
 				return err
 			}
+		}
+		var err error
+		results, err = s.loadResults(ctx, txn, top.Results())
+		return err
+	})
 
-			if len(data.Vector) != len(query) {
-				continue
-			}
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
 
-			// Calculate cosine similarity using precomputed norm
-			var dotProduct float32
-			for i := range query {
-				dotProduct += query[i] * data.Vector[i]
+// loadResults returns the search results of hits with the metadata of their
+// records, read in txn.
+func (s *BadgerStore) loadResults(ctx context.Context, txn *badger.Txn, hits []vector.Candidate) ([]embedx.SearchResult, error) {
+	results := make([]embedx.SearchResult, len(hits))
+	for i, hit := range hits {
+		if err := embedx.CheckContext(ctx, i); err != nil {
+			return nil, err
+		}
+		item, err := txn.Get(s.key(hit.ID))
+		if err != nil {
+			return nil, err
+		}
+		err = item.Value(func(v []byte) error {
+			r, err := s.viewRecord(item.Key(), v)
+			if err != nil {
+				return err
 			}
+			results[i] = embedx.SearchResult{ID: hit.ID, Score: hit.Score}
+			results[i].Meta, err = r.metadata()
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
 
-			if queryNorm == 0 || data.Norm == 0 {
-				continue
+// SearchBatch performs Search for many queries at once and returns their
+// results in query order. A brute-force batch reads every record once for the
+// whole batch, decodes its vector, and scores it against blocks of queries
+// of its dimension with vector.QueryBatch, so a batch is much faster than as
+// many calls to Search. Stores with an HNSW or IVF index, or quantized
+// stores, search the queries one by one.
+// Returns an error wrapping a *embedx.DimensionError if the store schema fixes
+// a dimension other than the dimension of a query.
+func (s *BadgerStore) SearchBatch(queries [][]float32, k int) ([][]embedx.SearchResult, error) {
+	return s.SearchBatchContext(context.Background(), queries, k)
+}
+
+// SearchBatchContext is like SearchBatch but stops and returns ctx.Err() once
+// ctx is done.
+func (s *BadgerStore) SearchBatchContext(ctx context.Context, queries [][]float32, k int) ([][]embedx.SearchResult, error) {
+	for i, q := range queries {
+		if err := s.checkDim(q); err != nil {
+			return nil, fmt.Errorf("query %d: %w", i, err)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	results := make([][]embedx.SearchResult, len(queries))
+	if len(queries) == 0 {
+		return results, nil
+	}
+	if s.graph.Load() != nil || s.ivf.Load() != nil || s.quant.Load() != nil {
+		for i, q := range queries {
+			r, err := s.SearchContext(ctx, q, k)
+			if err != nil {
+				return nil, err
 			}
+			results[i] = r
+		}
+		return results, nil
+	}
+
+	groups := embedx.GroupQueries(queries)
+	batches := make(map[int]*vector.QueryBatch, len(groups))
+	for _, group := range groups {
+		dim := len(queries[group[0]])
+		if dim == 0 {
+			continue
+		}
+		qs := make([][]float32, len(group))
+		for j, i := range group {
+			qs[j] = queries[i]
+		}
+		batches[dim] = vector.NewQueryBatch(qs, k, s.schema.Metric)
+	}
+
+	err := s.db.View(func(txn *badger.Txn) error {
+		it := s.newVectorIterator(txn, badger.DefaultIteratorOptions)
+		defer it.Close()
 
-			score := dotProduct / (queryNorm * data.Norm)
+		// Every record is decoded into the same buffer, which the batch of
+		// its dimension copies.
+		var buf []float32
+		n := 0
+		for it.Seek(s.key(firstVectorKey)); it.Valid(); it.Next() {
+			if err := embedx.CheckContext(ctx, n); err != nil {
+				return err
+			}
+			n++
+			item := it.Item()
+			key := item.Key()
 
-			results = append(results, embedx.SearchResult{
-				ID:    id,
-				Score: score,
-				Meta:  data.Meta,
+			err := item.Value(func(v []byte) error {
+				r, err := s.viewRecord(key, v)
+				if err != nil {
+					return err
+				}
+				batch := batches[r.dim]
+				if batch == nil {
+					return nil
+				}
+				buf = r.vector(buf)
+				batch.Push(s.id(key), buf, r.norm)
+				return nil
 			})
+			if err != nil {
+				return err
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		for _, group := range groups {
+			batch := batches[len(queries[group[0]])]
+			if batch == nil {
+				for _, i := range group {
+					results[i] = []embedx.SearchResult{}
+				}
+				continue
+			}
+			for j, hits := range batch.Results() {
+				r, err := s.loadResults(ctx, txn, hits)
+				if err != nil {
+					return err
+				}
+				results[group[j]] = r
+			}
 		}
 		return nil
 	})
@@ -333,38 +1169,95 @@ func (s *BadgerStore) Search(query []float32, k int) ([]embedx.SearchResult, err
 	if err != nil {
 		return nil, err
 	}
-
-	// Sort by score descending
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].Score > results[j].Score
-	})
-
-	// Return top-k results
-	if k > 0 && len(results) > k {
-		results = results[:k]
-	}
-
 	return results, nil
 }
 
 // ImportVectors imports multiple vectors from a map of ID to vector data.
-// It stores each vector with its corresponding ID in the BadgerDB store.
-// Returns an error if any vector fails to be imported.
+// The vectors are written as a best-effort batch with AddBatch.
+// Returns a *embedx.BatchError listing the vectors that could not be imported.
 func (s *BadgerStore) ImportVectors(vectors map[string][]float32) error {
+	records := make([]embedx.Record, 0, len(vectors))
 	for id, vec := range vectors {
-		if err := s.SaveVector(id, vec); err != nil {
-			return fmt.Errorf("failed to import vector %s: %w", id, err)
-		}
+		records = append(records, embedx.Record{ID: id, Vector: vec})
 	}
-	return nil
+	return s.AddBatch(records, embedx.BatchOptions{})
 }
 
 // ExportVectors exports all stored vectors to a map of ID to vector data.
 // Returns a map of all vectors stored in the database and any error that occurred.
+//
+// Deprecated: ExportVectors loads every vector into memory and drops metadata.
+// Use Scan, or vecio.Export to stream records to a file.
 func (s *BadgerStore) ExportVectors() (map[string][]float32, error) {
 	return s.GetAllVectors()
 }
 
+// decodeVectorData decodes a stored vector record. The result does not alias v.
+// Records written in the legacy gob formats, either vectorData or a plain
+// []float32 that is given a computed norm, are converted and rewritten in the
+// binary layout.
+func (s *BadgerStore) decodeVectorData(id string, v []byte) (vectorData, error) {
+	if isBinaryRecord(v) {
+		data, err := decodeRecord(v)
+		if err != nil {
+			return vectorData{}, fmt.Errorf("failed to decode vector %s: %w", id, err)
+		}
+		return data, nil
+	}
+
+	data, err := decodeLegacyRecord(v)
+	if err != nil {
+		return vectorData{}, fmt.Errorf("failed to decode vector %s: %w", id, err)
+	}
+	_ = s.migrateRecord(id, v, data) // Don't fail if this fails
+	return data, nil
+}
+
+// decodeLegacyRecord decodes a record written before the binary layout:
+// a gob-encoded vectorData, or a gob-encoded []float32 whose norm is computed.
+func decodeLegacyRecord(v []byte) (vectorData, error) {
+	// First try to decode as the gob-encoded vectorData struct
+	var data vectorData
+	dec := gob.NewDecoder(bytes.NewReader(v))
+	err := dec.Decode(&data)
+	if err == nil {
+		return data, nil
+	}
+
+	// If that fails, try to decode as the old []float32 format
+	var oldVec []float32
+	decOld := gob.NewDecoder(bytes.NewReader(v))
+	if oldErr := decOld.Decode(&oldVec); oldErr != nil {
+		return vectorData{}, err
+	}
+
+	// Convert to new format with computed norm
+	return vectorData{
+		Vector: oldVec,
+		Norm:   vector.Norm(oldVec),
+		Meta:   nil,
+	}, nil
+}
+
+// viewRecord returns a view of the stored record v. Binary records are
+// viewed in place; legacy records are decoded, migrated and re-encoded.
+func (s *BadgerStore) viewRecord(key, v []byte) (recordView, error) {
+	if !isBinaryRecord(v) {
+		data, err := s.decodeVectorData(s.id(key), v)
+		if err != nil {
+			return recordView{}, err
+		}
+		if v, err = encodeRecord(data, s.schema.Precision); err != nil {
+			return recordView{}, err
+		}
+	}
+	r, err := parseRecord(v)
+	if err != nil {
+		return recordView{}, fmt.Errorf("failed to decode vector %s: %w", s.id(key), err)
+	}
+	return r, nil
+}
+
 // computeNorm computes the L2 norm of a vector
 func (s *BadgerStore) computeNorm(vec []float32) float32 {
 	var norm float32
@@ -1,10 +1,19 @@
 package badger
 
 import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
+	badgerdb "github.com/dgraph-io/badger/v4"
 	"github.com/ldaidone/goembedx/pkg/embedx"
+	"github.com/ldaidone/goembedx/pkg/embedx/embedxtest"
+	"github.com/ldaidone/goembedx/pkg/index/hnsw"
+	"github.com/ldaidone/goembedx/vector"
 )
 
 func TestNewBadgerStore(t *testing.T) {
@@ -59,6 +68,15 @@ func TestBadgerStoreAddGet(t *testing.T) {
 	if !reflect.DeepEqual(meta, retrievedMeta) {
 		t.Errorf("Expected metadata %v, got %v", meta, retrievedMeta)
 	}
+
+	// Arrays and nested objects round-trip like scalar values.
+	nested := map[string]any{"tags": []any{"a", 2.0}, "author": map[string]any{"name": "ann", "ids": []any{1.0}}}
+	if err := store.Add("nested", []float32{1, 0, 0}, nested); err != nil {
+		t.Fatalf("Add with nested metadata failed: %v", err)
+	}
+	if _, _, got, err := store.Get("nested"); err != nil || !reflect.DeepEqual(got, nested) {
+		t.Errorf("Expected metadata %v, got %v (%v)", nested, got, err)
+	}
 }
 
 func TestBadgerStoreSaveVectorGetVector(t *testing.T) {
@@ -162,6 +180,47 @@ func TestBadgerStoreSearch(t *testing.T) {
 	}
 }
 
+func TestBadgerStoreSearchBatch(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewBadgerStore failed: %v", err)
+	}
+	defer store.Close()
+
+	// Enough vectors for several blocks of a vector.QueryBatch, and vectors
+	// of a second dimension.
+	records := make([]embedx.Record, 2500)
+	for i := range records {
+		records[i] = embedx.Record{
+			ID:     fmt.Sprintf("v%04d", i),
+			Vector: []float32{float32(i%7) - 3, float32(i%11) - 5, float32(i % 13), 1},
+			Meta:   map[string]any{"i": i},
+		}
+	}
+	records = append(records, embedx.Record{ID: "short", Vector: []float32{1, 2}})
+	if err := store.AddBatch(records, embedx.BatchOptions{}); err != nil {
+		t.Fatalf("AddBatch failed: %v", err)
+	}
+
+	queries := [][]float32{{1, 0, 0, 0}, {2, 1}, {0, -1, 2, 0}, {1, 2, 3}}
+	batch, err := store.SearchBatch(queries, 5)
+	if err != nil {
+		t.Fatalf("SearchBatch failed: %v", err)
+	}
+	for i, q := range queries {
+		want, err := store.Search(q, 5)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if !reflect.DeepEqual(batch[i], want) {
+			t.Errorf("Query %d: expected %v, got %v", i, want, batch[i])
+		}
+	}
+	if len(batch[1]) != 1 || batch[1][0].ID != "short" || len(batch[3]) != 0 {
+		t.Errorf("Expected queries to match only vectors of their dimension, got %v and %v", batch[1], batch[3])
+	}
+}
+
 func TestBadgerStoreImportExport(t *testing.T) {
 	tempDir := t.TempDir()
 	store, err := NewBadgerStore(tempDir)
@@ -224,6 +283,365 @@ func TestBackwardCompatibility(t *testing.T) {
 	}
 	defer store.Close()
 
-	// This test ensures that old data format can be read and automatically upgraded
-	// We'll test by adding data with the old format and ensuring it can be read
+	// Write records in both legacy gob formats behind the store's back.
+	gobBytes := func(v any) []byte {
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+			t.Fatalf("gob encode failed: %v", err)
+		}
+		return buf.Bytes()
+	}
+	legacy := map[string][]byte{
+		"struct": gobBytes(vectorData{Vector: []float32{3, 4}, Norm: 5, Meta: map[string]any{"n": 7}}),
+		"slice":  gobBytes([]float32{0, 2}),
+	}
+	err = store.db.Update(func(txn *badgerdb.Txn) error {
+		for id, v := range legacy {
+			if err := txn.Set([]byte(id), v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("writing legacy records failed: %v", err)
+	}
+
+	vec, norm, meta, err := store.Get("struct")
+	if err != nil || norm != 5 || !reflect.DeepEqual(vec, []float32{3, 4}) || meta["n"] != 7 {
+		t.Errorf("Unexpected legacy struct record: %v %v %v %v", vec, norm, meta, err)
+	}
+	results, err := store.SearchWithFilter([]float32{0, 1}, 1, embedx.Eq("n", 7))
+	if err != nil || len(results) != 1 || results[0].ID != "struct" {
+		t.Errorf("Expected legacy metadata to be filterable, got %v, %v", results, err)
+	}
+	if _, norm, _, err := store.Get("slice"); err != nil || norm != 2 {
+		t.Errorf("Expected legacy slice record with computed norm 2, got %v, %v", norm, err)
+	}
+
+	// Reading migrated both records to the binary layout.
+	err = store.db.View(func(txn *badgerdb.Txn) error {
+		for id := range legacy {
+			item, err := txn.Get([]byte(id))
+			if err != nil {
+				return err
+			}
+			v, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			if !isBinaryRecord(v) {
+				t.Errorf("Expected %s to be migrated to the binary layout", id)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("reading migrated records failed: %v", err)
+	}
+}
+
+func TestBadgerStoreSearchWithFilter(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithIndex(hnsw.DefaultConfig)}} {
+		store, err := NewBadgerStore(t.TempDir(), opts...)
+		if err != nil {
+			t.Fatalf("NewBadgerStore failed: %v", err)
+		}
+
+		_ = store.Add("a", []float32{1, 0, 0}, map[string]any{"tenant": "acme", "type": "pdf", "date": 20240110, "created": time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)})
+		_ = store.Add("b", []float32{0.9, 0.1, 0}, map[string]any{"tenant": "acme", "type": "doc", "date": 20240220, "created": "2024-02-20T00:00:00Z"})
+		_ = store.Add("c", []float32{0.8, 0.2, 0}, map[string]any{"tenant": "other", "type": "pdf", "date": 20240115, "created": "2024-01-15T09:30:00+01:00"})
+		_ = store.SaveVector("d", []float32{1, 0, 0})
+
+		filter := embedx.And(
+			embedx.Eq("tenant", "acme"),
+			embedx.In("type", "pdf", "doc"),
+			embedx.Range("date", 20240101, 20240131),
+		)
+		results, err := store.SearchWithFilter([]float32{1, 0, 0}, 5, filter)
+		if err != nil {
+			t.Fatalf("SearchWithFilter failed: %v", err)
+		}
+		if len(results) != 1 || results[0].ID != "a" {
+			t.Errorf("Expected [a], got %v", results)
+		}
+
+		// Vectors that score higher but do not match leave room for those that do.
+		results, _ = store.SearchWithFilter([]float32{1, 0, 0}, 1, embedx.Eq("tenant", "other"))
+		if len(results) != 1 || results[0].ID != "c" {
+			t.Errorf("Expected [c], got %v", results)
+		}
+
+		// Dates stored as time.Time values or RFC 3339 strings.
+		january := embedx.TimeRange("created", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC))
+		results, _ = store.SearchWithFilter([]float32{1, 0, 0}, 5, january)
+		if len(results) != 2 || results[0].ID != "a" || results[1].ID != "c" {
+			t.Errorf("Expected [a c], got %v", results)
+		}
+		parsed, err := embedx.ParseFilter(map[string]any{"created": map[string]any{"$gte": "2024-02-01T00:00:00Z"}})
+		if err != nil {
+			t.Fatalf("ParseFilter failed: %v", err)
+		}
+		results, _ = store.SearchWithFilter([]float32{1, 0, 0}, 5, parsed)
+		if len(results) != 1 || results[0].ID != "b" {
+			t.Errorf("Expected [b], got %v", results)
+		}
+
+		results, _ = store.SearchWithFilter([]float32{1, 0, 0}, 5, embedx.Not(embedx.Exists("tenant")))
+		if len(results) != 1 || results[0].ID != "d" {
+			t.Errorf("Expected [d], got %v", results)
+		}
+
+		results, _ = store.SearchWithFilter([]float32{1, 0, 0}, 0, nil)
+		if len(results) != 4 {
+			t.Errorf("Expected 4 unfiltered results, got %d", len(results))
+		}
+
+		_ = store.Close()
+	}
+}
+
+func TestBadgerStoreDeleteUpsert(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewBadgerStore failed: %v", err)
+	}
+	defer store.Close()
+
+	_ = store.Add("a", []float32{1, 0}, map[string]any{"k": "v"})
+	_ = store.SaveVector("b", []float32{0, 1})
+	_ = store.SaveVector("c", []float32{1, 1})
+
+	if err := store.Delete("a"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := store.GetVector("a"); err == nil {
+		t.Error("Expected deleted vector to be gone")
+	}
+	if err := store.Delete("a"); !errors.Is(err, embedx.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	n, err := store.DeleteMany([]string{"b", "missing", "c"})
+	if err != nil || n != 2 {
+		t.Errorf("Expected 2 deletions, got %d, %v", n, err)
+	}
+
+	if err := store.Upsert("x", []float32{1, 2}, nil, embedx.UpdateOnly); !errors.Is(err, embedx.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if err := store.Upsert("x", []float32{1, 2}, nil, embedx.InsertOnly); err != nil {
+		t.Fatalf("insert-only Upsert failed: %v", err)
+	}
+	if err := store.UpsertVector("x", []float32{3, 4}, embedx.InsertOnly); !errors.Is(err, embedx.ErrAlreadyExists) {
+		t.Errorf("Expected ErrAlreadyExists, got %v", err)
+	}
+	if err := store.Upsert("x", []float32{3, 4}, map[string]any{"n": 1}, embedx.UpdateOnly); err != nil {
+		t.Fatalf("update-only Upsert failed: %v", err)
+	}
+	vec, norm, meta, _ := store.Get("x")
+	if !reflect.DeepEqual(vec, []float32{3, 4}) || norm != 5 || meta["n"] != 1 {
+		t.Errorf("Expected updated record, got %v %v %v", vec, norm, meta)
+	}
+}
+
+func TestBadgerStoreDeleteWithIndex(t *testing.T) {
+	tempDir := t.TempDir()
+	store, err := NewBadgerStore(tempDir, WithIndex(hnsw.DefaultConfig))
+	if err != nil {
+		t.Fatalf("NewBadgerStore failed: %v", err)
+	}
+
+	_ = store.SaveVector("a", []float32{1, 0, 0})
+	_ = store.SaveVector("b", []float32{0.9, 0.1, 0})
+	_ = store.SaveVector("c", []float32{0, 1, 0})
+	if _, err := store.DeleteMany([]string{"a", "c"}); err != nil {
+		t.Fatalf("DeleteMany failed: %v", err)
+	}
+	_ = store.Close()
+
+	store, err = NewBadgerStore(tempDir, WithIndex(hnsw.DefaultConfig))
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer store.Close()
+
+	if store.Index().Len() != 1 {
+		t.Errorf("Expected 1 live indexed vector, got %d", store.Index().Len())
+	}
+	res, err := store.Search([]float32{1, 0, 0}, 3)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(res) != 1 || res[0].ID != "b" {
+		t.Errorf("Expected only b, got %v", res)
+	}
+}
+
+func TestBadgerStoreStatsAndNotFound(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir(), WithIndex(hnsw.DefaultConfig))
+	if err != nil {
+		t.Fatalf("NewBadgerStore failed: %v", err)
+	}
+	defer store.Close()
+
+	stats, err := store.Stats()
+	if err != nil || stats.Count != 0 || stats.Dim != 0 || !stats.Indexed {
+		t.Fatalf("Expected empty indexed stats, got %+v, %v", stats, err)
+	}
+
+	_ = store.SaveVector("a", []float32{1, 2, 3})
+	_ = store.SaveVector("b", []float32{4, 5, 6})
+	stats, err = store.Stats()
+	if err != nil || stats.Count != 2 || stats.Dim != 3 {
+		t.Errorf("Expected 2 vectors of dimension 3, got %+v, %v", stats, err)
+	}
+
+	if _, err := store.GetVector("missing"); !errors.Is(err, embedx.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound from GetVector, got %v", err)
+	}
+	if _, _, _, err := store.Get("\x00hnsw/entry"); !errors.Is(err, embedx.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a reserved key, got %v", err)
+	}
+}
+
+func TestBadgerStoreScan(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir(), WithIndex(hnsw.DefaultConfig))
+	if err != nil {
+		t.Fatalf("NewBadgerStore failed: %v", err)
+	}
+	defer store.Close()
+
+	_ = store.Add("b", []float32{3, 4}, map[string]any{"k": "v"})
+	_ = store.SaveVector("a", []float32{1, 0})
+
+	var records []embedx.Record
+	if err := store.Scan(func(r embedx.Record) error {
+		records = append(records, r)
+		return nil
+	}); err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	// Graph keys must not show up as records.
+	if len(records) != 2 || records[0].ID != "a" || records[1].ID != "b" {
+		t.Fatalf("Expected [a b], got %v", records)
+	}
+	if records[1].Norm != 5 || records[1].Meta["k"] != "v" {
+		t.Errorf("Expected norm and metadata of b, got %+v", records[1])
+	}
+}
+
+func TestBadgerStoreAddBatch(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewBadgerStore failed: %v", err)
+	}
+	defer store.Close()
+	_ = store.SaveVector("existing", []float32{1, 1})
+
+	batch := []embedx.Record{
+		{ID: "a", Vector: []float32{1, 0}, Meta: map[string]any{"tenant": "x"}},
+		{ID: "\x00bad", Vector: []float32{1, 0}},
+		{ID: "existing", Vector: []float32{0, 1}},
+		{ID: "a", Vector: []float32{0, 1}},
+	}
+
+	err = store.AddBatch(batch, embedx.BatchOptions{Mode: embedx.InsertOnly, Atomic: true})
+	var be *embedx.BatchError
+	if !errors.As(err, &be) || be.Written != 0 || len(be.Items) != 3 {
+		t.Fatalf("Expected 3 rejected items and nothing written, got %v", err)
+	}
+	if _, err := store.GetVector("a"); !errors.Is(err, embedx.ErrNotFound) {
+		t.Errorf("Expected atomic batch to write nothing, got %v", err)
+	}
+
+	err = store.AddBatch(batch, embedx.BatchOptions{Mode: embedx.InsertOnly})
+	if !errors.As(err, &be) || be.Written != 1 || !errors.Is(err, embedx.ErrAlreadyExists) {
+		t.Fatalf("Expected one record written and ErrAlreadyExists, got %v", err)
+	}
+	if _, _, meta, err := store.Get("a"); err != nil || meta["tenant"] != "x" {
+		t.Errorf("Expected a with metadata, got %v, %v", meta, err)
+	}
+
+	if err := store.AddBatch(batch[3:], embedx.BatchOptions{Atomic: true}); err != nil {
+		t.Fatalf("atomic AddBatch failed: %v", err)
+	}
+	if vec, _ := store.GetVector("a"); !reflect.DeepEqual(vec, []float32{0, 1}) {
+		t.Errorf("Expected a to be replaced, got %v", vec)
+	}
+}
+
+// BenchmarkBadgerStoreWrites compares one transaction per vector with AddBatch.
+func BenchmarkBadgerStoreWrites(b *testing.B) {
+	const n = 1000
+	records := make([]embedx.Record, n)
+	for i := range records {
+		vec := make([]float32, 128)
+		for j := range vec {
+			vec[j] = float32(i*j%97) / 97
+		}
+		records[i] = embedx.Record{ID: fmt.Sprintf("v%05d", i), Vector: vec}
+	}
+
+	b.Run("SaveVector", func(b *testing.B) {
+		store, err := NewBadgerStore(b.TempDir())
+		if err != nil {
+			b.Fatalf("NewBadgerStore failed: %v", err)
+		}
+		defer store.Close()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			for _, r := range records {
+				if err := store.SaveVector(r.ID, r.Vector); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+
+	b.Run("AddBatch", func(b *testing.B) {
+		store, err := NewBadgerStore(b.TempDir())
+		if err != nil {
+			b.Fatalf("NewBadgerStore failed: %v", err)
+		}
+		defer store.Close()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if err := store.AddBatch(records, embedx.BatchOptions{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestBadgerStoreConformance(t *testing.T) {
+	// open returns a function opening a new store with opts.
+	open := func(opts ...Option) func(t *testing.T) *BadgerStore {
+		return func(t *testing.T) *BadgerStore {
+			store, err := NewBadgerStore(t.TempDir(), append(opts, WithDim(embedxtest.Dim))...)
+			if err != nil {
+				t.Fatalf("NewBadgerStore failed: %v", err)
+			}
+			return store
+		}
+	}
+	stores := []struct {
+		name string
+		open func(t *testing.T) *BadgerStore
+	}{
+		{"Scan", open()},
+		{"Index", open(WithIndex(hnsw.DefaultConfig))},
+		{"Float16", open(WithPrecision(vector.PrecisionFloat16))},
+		{"Collection", func(t *testing.T) *BadgerStore {
+			store := open()(t)
+			t.Cleanup(func() { store.Close() })
+			return collection(t)(store.CreateCollection("c", embedx.Schema{Dim: embedxtest.Dim}))
+		}},
+	}
+	for _, s := range stores {
+		t.Run(s.name, func(t *testing.T) {
+			embedxtest.TestStore(t, func(t *testing.T) embedx.Store { return s.open(t) })
+			embedxtest.TestVectorStore(t, func(t *testing.T) embedx.VectorStore { return s.open(t) })
+		})
+	}
 }
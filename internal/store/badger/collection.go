@@ -0,0 +1,220 @@
+package badger
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/ldaidone/goembedx/pkg/embedx"
+	"github.com/ldaidone/goembedx/pkg/index/hnsw"
+)
+
+// Collection key layout.
+//
+// A collection keeps its records, header, graph, codes and IVF lists under
+// collectionPrefix followed by its name and a slash, in the key layout of a
+// store. Names cannot contain a slash, so the prefix of one collection never
+// starts another. The name of every collection is registered under
+// collectionNamePrefix. A collection is created by registering its name and
+// writing its header in one transaction, and dropped by deleting its keys
+// before its name, so that an interrupted drop leaves an empty collection.
+const (
+	// collectionPrefix is followed by the name of a collection and a slash.
+	collectionPrefix = internalPrefix + "c/"
+	// collectionNamePrefix is followed by the name of a collection.
+	collectionNamePrefix = internalPrefix + "collections/"
+	// maxCollectionName bounds the length of collection names.
+	maxCollectionName = 128
+)
+
+var _ embedx.CollectionStore = (*BadgerStore)(nil)
+
+// collectionSet tracks the open collections of a database, so that every
+// OpenCollection of a name returns the same store and its in-memory indexes
+// see every write.
+type collectionSet struct {
+	// index is the HNSW configuration requested with WithIndex, or nil.
+	// Every collection gets a graph with this configuration.
+	index *hnsw.Config
+	mu    sync.Mutex
+	open  map[string]*BadgerStore
+}
+
+// newCollectionSet returns an empty set whose collections are indexed with
+// index, or are not indexed if it is nil.
+func newCollectionSet(index *hnsw.Config) *collectionSet {
+	cs := &collectionSet{open: make(map[string]*BadgerStore)}
+	if index != nil {
+		cfg := *index
+		cs.index = &cfg
+	}
+	return cs
+}
+
+// collectionNameKey returns the key registering the collection name.
+func collectionNameKey(name string) []byte {
+	return []byte(collectionNamePrefix + name)
+}
+
+// validateCollectionName returns an error unless name is 1 to
+// maxCollectionName letters, digits, '-', '_' or '.'.
+func validateCollectionName(name string) error {
+	if name == "" || len(name) > maxCollectionName {
+		return fmt.Errorf("invalid collection name %q: must be 1 to %d characters", name, maxCollectionName)
+	}
+	for _, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			return fmt.Errorf("invalid collection name %q: only letters, digits, '-', '_' and '.' are allowed", name)
+		}
+	}
+	return nil
+}
+
+// collection returns a store for the collection name that is not opened yet.
+func (s *BadgerStore) collection(name string) *BadgerStore {
+	c := &BadgerStore{db: s.db, prefix: collectionPrefix + name + "/", collections: s.collections}
+	if index := s.collections.index; index != nil {
+		cfg := *index
+		c.graphCfg = &cfg
+	}
+	return c
+}
+
+// collectionExists reports whether the collection name is registered.
+func (s *BadgerStore) collectionExists(name string) (bool, error) {
+	err := s.db.View(func(txn *badger.Txn) error {
+		_, err := txn.Get(collectionNameKey(name))
+		return err
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// CreateCollection creates an empty collection with the given schema in the
+// database of the store and opens it. The collection has its own records,
+// schema, quantizer and IVF index, and an HNSW index when the database was
+// opened with WithIndex. Collections belong to the database, so any of its
+// stores can create, open and drop them.
+// Returns an error wrapping embedx.ErrAlreadyExists if the collection exists,
+// or an error if the name is invalid or schema has invalid settings.
+func (s *BadgerStore) CreateCollection(name string, schema embedx.Schema) (embedx.Store, error) {
+	if err := validateCollectionName(name); err != nil {
+		return nil, err
+	}
+	if err := validateSchema(schema); err != nil {
+		return nil, err
+	}
+	cs := s.collections
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	exists, err := s.collectionExists(name)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, fmt.Errorf("%w: collection %s", embedx.ErrAlreadyExists, name)
+	}
+
+	// Keys written by a handle used after its collection was dropped must
+	// not leak into the new collection.
+	c := s.collection(name)
+	if err := s.db.DropPrefix([]byte(c.prefix)); err != nil {
+		return nil, err
+	}
+	err = s.db.Update(func(txn *badger.Txn) error {
+		if err := txn.Set(collectionNameKey(name), nil); err != nil {
+			return err
+		}
+		return txn.Set(c.key(headerKey), marshalSchema(schema))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := c.open(); err != nil {
+		return nil, err
+	}
+	cs.open[name] = c
+	return c, nil
+}
+
+// OpenCollection returns the collection with the given name, opening it on
+// first use. Every call for a name returns the same store until the
+// collection is dropped.
+// Returns an error wrapping embedx.ErrNotFound if the collection does not exist.
+func (s *BadgerStore) OpenCollection(name string) (embedx.Store, error) {
+	if err := validateCollectionName(name); err != nil {
+		return nil, err
+	}
+	cs := s.collections
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if c, ok := cs.open[name]; ok {
+		return c, nil
+	}
+	exists, err := s.collectionExists(name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("%w: collection %s", embedx.ErrNotFound, name)
+	}
+	c := s.collection(name)
+	if err := c.open(); err != nil {
+		return nil, err
+	}
+	cs.open[name] = c
+	return c, nil
+}
+
+// DropCollection deletes the collection with the given name, its vectors and
+// its indexes. Stores returned for the collection must not be used afterwards.
+// Returns an error wrapping embedx.ErrNotFound if the collection does not exist.
+func (s *BadgerStore) DropCollection(name string) error {
+	if err := validateCollectionName(name); err != nil {
+		return err
+	}
+	cs := s.collections
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	exists, err := s.collectionExists(name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("%w: collection %s", embedx.ErrNotFound, name)
+	}
+	delete(cs.open, name)
+	if err := s.db.DropPrefix([]byte(s.collection(name).prefix)); err != nil {
+		return err
+	}
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(collectionNameKey(name))
+	})
+}
+
+// ListCollections returns the names of the collections in the database of the
+// store in ascending order.
+func (s *BadgerStore) ListCollections() ([]string, error) {
+	names := make([]string, 0)
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = []byte(collectionNamePrefix)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			names = append(names, string(it.Item().Key()[len(opts.Prefix):]))
+		}
+		return nil
+	})
+	return names, err
+}
@@ -0,0 +1,173 @@
+package badger
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ldaidone/goembedx/pkg/embedx"
+	"github.com/ldaidone/goembedx/pkg/index/hnsw"
+	"github.com/ldaidone/goembedx/pkg/index/ivf"
+	"github.com/ldaidone/goembedx/vector"
+)
+
+// collection returns a function that fails the test on err and returns the
+// collection store.
+func collection(t *testing.T) func(embedx.Store, error) *BadgerStore {
+	return func(s embedx.Store, err error) *BadgerStore {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("opening collection failed: %v", err)
+		}
+		return s.(*BadgerStore)
+	}
+}
+
+func TestBadgerStoreCollections(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewBadgerStore(dir)
+	if err != nil {
+		t.Fatalf("NewBadgerStore failed: %v", err)
+	}
+
+	products := collection(t)(store.CreateCollection("products", embedx.Schema{Dim: 2, Metric: vector.MetricDot}))
+	docs := collection(t)(store.CreateCollection("docs.v1", embedx.Schema{Dim: 3, Model: "minilm"}))
+	if _, err := store.CreateCollection("products", embedx.Schema{}); !errors.Is(err, embedx.ErrAlreadyExists) {
+		t.Errorf("Expected ErrAlreadyExists, got %v", err)
+	}
+	for _, name := range []string{"", "a/b", "a b", "\x00"} {
+		if _, err := store.CreateCollection(name, embedx.Schema{}); err == nil {
+			t.Errorf("Expected error for collection name %q, got nil", name)
+		}
+	}
+	if _, err := store.OpenCollection("missing"); !errors.Is(err, embedx.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	// The same ID lives independently in the store and each collection.
+	_ = store.Add("x", []float32{1, 0, 0, 0}, nil)
+	_ = products.Add("x", []float32{2, 0}, map[string]any{"kind": "product"})
+	_ = products.Add("y", []float32{0, 1}, nil)
+	_ = docs.Add("x", []float32{0, 0, 1}, nil)
+	var dimErr *embedx.DimensionError
+	if err := products.Add("z", []float32{1, 2, 3}, nil); !errors.As(err, &dimErr) {
+		t.Errorf("Expected the collection schema to reject the vector, got %v", err)
+	}
+
+	if vec, _ := store.GetVector("x"); len(vec) != 4 {
+		t.Errorf("Expected the store's own vector, got %v", vec)
+	}
+	results, err := products.Search([]float32{1, 0}, 5)
+	if err != nil || len(results) != 2 || results[0].ID != "x" || results[0].Score != 2 || results[0].Meta["kind"] != "product" {
+		t.Errorf("Unexpected collection results: %v, %v", results, err)
+	}
+	if stats, _ := store.Stats(); stats.Count != 1 {
+		t.Errorf("Expected the store to count only its own vectors, got %d", stats.Count)
+	}
+	if stats, _ := docs.Stats(); stats.Count != 1 || stats.Dim != 3 || stats.Model != "minilm" {
+		t.Errorf("Unexpected collection stats: %+v", stats)
+	}
+	if n, _ := products.DeleteMany([]string{"x", "y"}); n != 2 {
+		t.Errorf("Expected 2 deletions, got %d", n)
+	}
+	if _, err := store.GetVector("x"); err != nil {
+		t.Errorf("Expected deleting from a collection to keep the store's vector, got %v", err)
+	}
+
+	if names, _ := store.ListCollections(); fmt.Sprint(names) != "[docs.v1 products]" {
+		t.Errorf("Expected [docs.v1 products], got %v", names)
+	}
+	if err := docs.Close(); err != nil {
+		t.Errorf("Closing a collection failed: %v", err)
+	}
+	if vec, err := docs.GetVector("x"); err != nil || len(vec) != 3 {
+		t.Errorf("Expected the database to stay open, got %v, %v", vec, err)
+	}
+	store.Close()
+
+	// Collections and their schemas persist with the database.
+	store, err = NewBadgerStore(dir)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer store.Close()
+	docs = collection(t)(store.OpenCollection("docs.v1"))
+	if again, _ := store.OpenCollection("docs.v1"); again != embedx.Store(docs) {
+		t.Error("Expected every open of a collection to return the same store")
+	}
+	if schema := docs.Schema(); schema.Dim != 3 || schema.Model != "minilm" {
+		t.Errorf("Unexpected schema after reopen: %+v", schema)
+	}
+	if vec, err := docs.GetVector("x"); err != nil || vec[2] != 1 {
+		t.Errorf("Expected the collection's vector after reopen, got %v, %v", vec, err)
+	}
+
+	// Dropping a collection deletes its vectors only.
+	if err := store.DropCollection("docs.v1"); err != nil {
+		t.Fatalf("DropCollection failed: %v", err)
+	}
+	if err := store.DropCollection("docs.v1"); !errors.Is(err, embedx.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound dropping twice, got %v", err)
+	}
+	if names, _ := store.ListCollections(); fmt.Sprint(names) != "[products]" {
+		t.Errorf("Expected [products], got %v", names)
+	}
+	docs = collection(t)(store.CreateCollection("docs.v1", embedx.Schema{}))
+	if stats, _ := docs.Stats(); stats.Count != 0 || stats.Model != "" {
+		t.Errorf("Expected a new empty collection, got %+v", stats)
+	}
+	if _, err := store.GetVector("x"); err != nil {
+		t.Errorf("Expected dropping a collection to keep the store's vector, got %v", err)
+	}
+}
+
+func TestBadgerStoreCollectionIndexes(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir(), WithIndex(hnsw.DefaultConfig))
+	if err != nil {
+		t.Fatalf("NewBadgerStore failed: %v", err)
+	}
+	defer store.Close()
+
+	coll := collection(t)(store.CreateCollection("c", embedx.Schema{Metric: vector.MetricEuclidean}))
+	for i := 0; i < 50; i++ {
+		_ = store.Add(fmt.Sprint(i), []float32{float32(i), 1}, nil)
+		_ = coll.Add(fmt.Sprint(i), []float32{float32(i), 0}, nil)
+	}
+
+	// The collection has its own graph, in its own metric.
+	if coll.Index() == nil || coll.graphCfg.Metric != vector.MetricEuclidean || store.graphCfg.Metric != vector.MetricCosine {
+		t.Fatal("Expected the collection to have its own graph")
+	}
+	if n := coll.Index().Len(); n != 50 {
+		t.Errorf("Expected 50 vectors in the collection graph, got %d", n)
+	}
+	if results, _ := coll.Search([]float32{10, 0}, 1); len(results) != 1 || results[0].ID != "10" || results[0].Score != 1 {
+		t.Errorf("Unexpected graph results: %v", results)
+	}
+
+	// Quantization and IVF are per collection.
+	if err := coll.Quantize(embedx.QuantizationConfig{Rescore: 4}); err != nil {
+		t.Fatalf("Quantize failed: %v", err)
+	}
+	if err := coll.TrainIVF(ivf.Config{Lists: 4, NProbe: 4}); err != nil {
+		t.Fatalf("TrainIVF failed: %v", err)
+	}
+	if stats, _ := store.Stats(); stats.Quantized {
+		t.Error("Expected quantizing a collection to leave the store unquantized")
+	}
+	if sizes, _ := coll.IVFListSizes(); sum(sizes) != 50 {
+		t.Errorf("Expected 50 postings, got %v", sizes)
+	}
+	if sizes, _ := store.IVFListSizes(); sizes != nil {
+		t.Errorf("Expected the store to have no IVF index, got %v", sizes)
+	}
+	if err := store.DropCollection("c"); err != nil {
+		t.Fatalf("DropCollection failed: %v", err)
+	}
+	if n := store.Index().Len(); n != 50 {
+		t.Errorf("Expected the store graph to keep 50 vectors, got %d", n)
+	}
+	if results, _ := store.Search([]float32{10, 1}, 1); len(results) != 1 {
+		t.Errorf("Expected the store to stay searchable, got %v", results)
+	}
+}
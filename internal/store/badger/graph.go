@@ -0,0 +1,516 @@
+package badger
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/ldaidone/goembedx/pkg/embedx"
+	"github.com/ldaidone/goembedx/pkg/index/hnsw"
+)
+
+// Key layout.
+//
+// Vector records are stored under their raw ID. Internal records live under
+// keys starting with a NUL byte, which sort before every vector key and are
+// rejected as vector IDs, so vector scans start at firstVectorKey.
+// Collections use the same layout under the prefix of the collection, which
+// itself starts with a NUL byte; see collection.go. Every key of a store is
+// built with key.
+const (
+	// internalPrefix marks keys that do not hold vector records.
+	internalPrefix = "\x00"
+	// graphPrefix groups every key of the persisted HNSW graph.
+	graphPrefix = internalPrefix + "hnsw/"
+	// graphNodePrefix is followed by the big-endian node index.
+	graphNodePrefix = graphPrefix + "n/"
+	// graphEntryKey holds the entry point and the top level of the graph.
+	graphEntryKey = graphPrefix + "entry"
+	// graphNodeVersion is the encoding version of persisted graph nodes.
+	graphNodeVersion = 1
+	// maxGraphLevel bounds the level accepted when decoding a graph node.
+	maxGraphLevel = 64
+)
+
+// firstVectorKey is the smallest possible vector key.
+const firstVectorKey = "\x01"
+
+// key returns the database key of k, a vector ID or an internal key, in the
+// keyspace of the store.
+func (s *BadgerStore) key(k string) []byte {
+	return []byte(s.prefix + k)
+}
+
+// id returns the vector ID stored under the database key k.
+func (s *BadgerStore) id(k []byte) string {
+	return string(k[len(s.prefix):])
+}
+
+// newVectorIterator returns an iterator with opts over the keys of the store,
+// which starts at its vector records when seeking s.key(firstVectorKey).
+func (s *BadgerStore) newVectorIterator(txn *badger.Txn, opts badger.IteratorOptions) *badger.Iterator {
+	opts.Prefix = []byte(s.prefix)
+	return txn.NewIterator(opts)
+}
+
+// openGraph loads the persisted graph when the index is enabled, building it
+// from the stored vectors if the database holds no valid graph yet.
+// When the index is disabled, it only records whether a graph is on disk.
+func (s *BadgerStore) openGraph() error {
+	if s.graphCfg == nil {
+		return s.db.View(func(txn *badger.Txn) error {
+			_, err := txn.Get(s.key(graphEntryKey))
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			s.graphOnDisk = true
+			return nil
+		})
+	}
+	return s.loadGraph()
+}
+
+// loadGraph restores the graph from the database, or rebuilds it when no
+// graph has been persisted.
+func (s *BadgerStore) loadGraph() error {
+	var (
+		nodes    []hnsw.NodeState
+		entry    uint32
+		maxLevel int
+		found    bool
+	)
+
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(s.key(graphEntryKey))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		found = true
+
+		err = item.Value(func(v []byte) error {
+			entry, maxLevel, err = decodeGraphEntry(v)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = s.key(graphNodePrefix)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			idx := binary.BigEndian.Uint32(item.Key()[len(opts.Prefix):])
+			if int(idx) != len(nodes) {
+				return fmt.Errorf("persisted graph is missing node %d", len(nodes))
+			}
+
+			var ns hnsw.NodeState
+			err := item.Value(func(v []byte) error {
+				var err error
+				ns, err = decodeGraphNode(v)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("failed to decode graph node %d: %w", idx, err)
+			}
+
+			// Live nodes share their vector with the vector record.
+			if !ns.Deleted {
+				rec, err := txn.Get(s.key(ns.ID))
+				if err != nil {
+					return fmt.Errorf("failed to load vector %s for graph node %d: %w", ns.ID, idx, err)
+				}
+				err = rec.Value(func(v []byte) error {
+					data, err := s.decodeVectorData(ns.ID, v)
+					ns.Vector = data.Vector
+					return err
+				})
+				if err != nil {
+					return err
+				}
+			}
+			nodes = append(nodes, ns)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		return s.rebuildGraph()
+	}
+
+	g, err := hnsw.Restore(*s.graphCfg, nodes, entry, maxLevel)
+	if err != nil {
+		return err
+	}
+	g.TrackChanges()
+	s.graph.Store(g)
+	return nil
+}
+
+// rebuildGraph discards any stale graph keys, indexes every stored vector
+// and persists the resulting graph.
+func (s *BadgerStore) rebuildGraph() error {
+	if err := s.db.DropPrefix(s.key(graphPrefix)); err != nil {
+		return err
+	}
+
+	vectors, err := s.GetAllVectors()
+	if err != nil {
+		return err
+	}
+	ids := make([]string, 0, len(vectors))
+	for id := range vectors {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	g := hnsw.New(*s.graphCfg)
+	g.TrackChanges()
+	for _, id := range ids {
+		if err := g.Add(id, vectors[id]); err != nil {
+			return fmt.Errorf("failed to index vector %s: %w", id, err)
+		}
+	}
+
+	wb := s.db.NewWriteBatch()
+	defer wb.Cancel()
+	for _, idx := range g.TakeDirty() {
+		if err := wb.Set(s.graphNodeKey(idx), encodeGraphNode(g.Node(idx))); err != nil {
+			return err
+		}
+	}
+	if err := wb.Set(s.key(graphEntryKey), encodeGraphEntry(g.EntryPoint())); err != nil {
+		return err
+	}
+	if err := wb.Flush(); err != nil {
+		return err
+	}
+
+	s.graph.Store(g)
+	return nil
+}
+
+// CompactIndex rebuilds the persisted HNSW graph from the stored vectors,
+// dropping the tombstones that deletes and updates leave in it. Until then,
+// tombstoned nodes keep their vector on disk and slow searches down.
+// Writes wait for the rebuild; searches use the old graph until it is done.
+// It is a no-op if the store was opened without WithIndex.
+func (s *BadgerStore) CompactIndex() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g := s.graph.Load()
+	if g == nil || g.Tombstones() == 0 {
+		return nil
+	}
+	return s.rebuildGraph()
+}
+
+// updateGraph runs fn in a read-write transaction together with the graph
+// changes it causes. If the transaction fails after the in-memory graph was
+// modified, the graph is reloaded so it never runs ahead of the database.
+// While the index is disabled, the first write invalidates any persisted
+// graph so it is rebuilt the next time the index is enabled.
+// The caller must hold s.mu.
+func (s *BadgerStore) updateGraph(fn func(txn *badger.Txn) error) error {
+	g := s.graph.Load()
+	changed := false
+
+	err := s.db.Update(func(txn *badger.Txn) error {
+		fnErr := fn(txn)
+		if g == nil {
+			if fnErr != nil || !s.graphOnDisk {
+				return fnErr
+			}
+			return txn.Delete(s.key(graphEntryKey))
+		}
+
+		dirty := g.TakeDirty()
+		changed = len(dirty) > 0
+		if fnErr != nil {
+			return fnErr
+		}
+		for _, idx := range dirty {
+			if err := txn.Set(s.graphNodeKey(idx), encodeGraphNode(g.Node(idx))); err != nil {
+				return err
+			}
+		}
+		if !changed {
+			return nil
+		}
+		return txn.Set(s.key(graphEntryKey), encodeGraphEntry(g.EntryPoint()))
+	})
+
+	if err == nil {
+		if g == nil {
+			s.graphOnDisk = false
+		}
+		return nil
+	}
+	if changed {
+		if reloadErr := s.loadGraph(); reloadErr != nil {
+			return errors.Join(err, reloadErr)
+		}
+	}
+	return err
+}
+
+// Index returns the persisted HNSW index, or nil if the store was opened
+// without WithIndex. An Embedder over this store uses it automatically.
+func (s *BadgerStore) Index() embedx.Index {
+	if s.graphCfg == nil {
+		return nil
+	}
+	return graphIndex{s: s}
+}
+
+// searchGraph answers a query from the HNSW index and loads the metadata of
+// each hit from its vector record. If k <= 0, every live vector is returned.
+// Hits whose record was deleted after the graph was searched are skipped.
+// The graph search itself runs to completion; ctx is checked while the
+// records of the hits are loaded.
+func (s *BadgerStore) searchGraph(ctx context.Context, query []float32, k int) ([]embedx.SearchResult, error) {
+	g := s.graph.Load()
+	if k <= 0 {
+		k = g.Len()
+	}
+
+	hits, err := g.Search(query, k)
+	if err != nil {
+		return nil, err
+	}
+
+	results := hits[:0]
+	err = s.db.View(func(txn *badger.Txn) error {
+		for i, hit := range hits {
+			if err := embedx.CheckContext(ctx, i); err != nil {
+				return err
+			}
+			item, err := txn.Get(s.key(hit.ID))
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			err = item.Value(func(v []byte) error {
+				data, err := s.decodeVectorData(hit.ID, v)
+				hit.Meta = data.Meta
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			results = append(results, hit)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// graphIndex exposes the persisted graph of a BadgerStore as an embedx.Index.
+// Writes go through the store so they are persisted together with the vectors.
+type graphIndex struct {
+	s *BadgerStore
+}
+
+// Add stores the vector like SaveVector, which also indexes it.
+func (gi graphIndex) Add(id string, vec []float32) error {
+	return gi.s.SaveVector(id, vec)
+}
+
+// Delete tombstones the vector in the persisted graph. The vector record
+// itself is kept.
+func (gi graphIndex) Delete(id string) error {
+	gi.s.mu.Lock()
+	defer gi.s.mu.Unlock()
+
+	return gi.s.updateGraph(func(txn *badger.Txn) error {
+		return gi.s.graph.Load().Delete(id)
+	})
+}
+
+// Search returns the approximate top-k most similar vectors to the query.
+func (gi graphIndex) Search(query []float32, k int) ([]embedx.SearchResult, error) {
+	return gi.s.graph.Load().Search(query, k)
+}
+
+// Len returns the number of live vectors in the graph.
+func (gi graphIndex) Len() int {
+	return gi.s.graph.Load().Len()
+}
+
+// graphNodeKey returns the key of the graph node at idx.
+func (s *BadgerStore) graphNodeKey(idx uint32) []byte {
+	return binary.BigEndian.AppendUint32(s.key(graphNodePrefix), idx)
+}
+
+// encodeGraphEntry encodes the entry point and top level of the graph.
+func encodeGraphEntry(entry uint32, maxLevel int) []byte {
+	b := binary.LittleEndian.AppendUint32(nil, entry)
+	return binary.LittleEndian.AppendUint32(b, uint32(int32(maxLevel)))
+}
+
+// decodeGraphEntry decodes a record written by encodeGraphEntry.
+func decodeGraphEntry(b []byte) (uint32, int, error) {
+	if len(b) != 8 {
+		return 0, 0, fmt.Errorf("invalid graph entry record of %d bytes", len(b))
+	}
+	entry := binary.LittleEndian.Uint32(b)
+	maxLevel := int(int32(binary.LittleEndian.Uint32(b[4:])))
+	return entry, maxLevel, nil
+}
+
+// encodeGraphNode encodes a graph node as
+// version, flags, level, id, per-layer links and, for tombstoned nodes only,
+// the vector. Live nodes share the vector of their vector record.
+func encodeGraphNode(ns hnsw.NodeState) []byte {
+	b := []byte{graphNodeVersion, 0}
+	if ns.Deleted {
+		b[1] = 1
+	}
+	b = binary.AppendUvarint(b, uint64(ns.Level))
+	b = binary.AppendUvarint(b, uint64(len(ns.ID)))
+	b = append(b, ns.ID...)
+	for _, links := range ns.Links {
+		b = binary.AppendUvarint(b, uint64(len(links)))
+		for _, nb := range links {
+			b = binary.LittleEndian.AppendUint32(b, nb)
+		}
+	}
+	if ns.Deleted {
+		b = binary.AppendUvarint(b, uint64(len(ns.Vector)))
+		for _, f := range ns.Vector {
+			b = binary.LittleEndian.AppendUint32(b, math.Float32bits(f))
+		}
+	}
+	return b
+}
+
+// decodeGraphNode decodes a record written by encodeGraphNode.
+func decodeGraphNode(b []byte) (hnsw.NodeState, error) {
+	var ns hnsw.NodeState
+	r := byteReader{b: b}
+
+	if v := r.byte(); v != graphNodeVersion {
+		return ns, fmt.Errorf("unsupported graph node version %d", v)
+	}
+	ns.Deleted = r.byte()&1 != 0
+	level := r.uvarint()
+	if level > maxGraphLevel {
+		return ns, fmt.Errorf("invalid graph node level %d", level)
+	}
+	ns.Level = int(level)
+	ns.ID = string(r.bytes(r.count(1)))
+
+	// Every layer takes at least the byte of its link count. Lengths are
+	// checked against the rest of the record before they are allocated.
+	if r.err == nil && ns.Level >= len(r.b) {
+		r.err = errShortRecord
+	}
+	if r.err != nil {
+		return ns, r.err
+	}
+	ns.Links = make([][]uint32, ns.Level+1)
+	for l := range ns.Links {
+		n := r.count(4)
+		links := make([]uint32, 0, n)
+		for i := 0; i < n && r.err == nil; i++ {
+			links = append(links, r.uint32())
+		}
+		ns.Links[l] = links
+	}
+
+	if ns.Deleted {
+		n := r.count(4)
+		vec := make([]float32, 0, n)
+		for i := 0; i < n && r.err == nil; i++ {
+			vec = append(vec, math.Float32frombits(r.uint32()))
+		}
+		ns.Vector = vec
+	}
+	return ns, r.err
+}
+
+// byteReader decodes little-endian values from a byte slice and records the
+// first out-of-bounds read in err.
+type byteReader struct {
+	b   []byte
+	err error
+}
+
+// byte reads a single byte.
+func (r *byteReader) byte() byte {
+	p := r.bytes(1)
+	if p == nil {
+		return 0
+	}
+	return p[0]
+}
+
+// uint32 reads a little-endian uint32.
+func (r *byteReader) uint32() uint32 {
+	p := r.bytes(4)
+	if p == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(p)
+}
+
+// uvarint reads an unsigned varint.
+func (r *byteReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.b)
+	if n <= 0 {
+		r.err = errors.New("truncated record")
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+// count reads an element count as an unsigned varint and checks that the
+// rest of the record can hold that many elements of size bytes each.
+func (r *byteReader) count(size int) int {
+	n := r.uvarint()
+	if r.err == nil && n > uint64(len(r.b)/size) {
+		r.err = errShortRecord
+		return 0
+	}
+	return int(n)
+}
+
+// bytes reads the next n bytes, returning nil if fewer are available.
+func (r *byteReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.b) {
+		r.err = errors.New("truncated record")
+		return nil
+	}
+	p := r.b[:n]
+	r.b = r.b[n:]
+	return p
+}
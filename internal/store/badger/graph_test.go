@@ -0,0 +1,396 @@
+package badger
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/ldaidone/goembedx/pkg/embedx"
+	"github.com/ldaidone/goembedx/pkg/index/hnsw"
+	"github.com/ldaidone/goembedx/vector"
+)
+
+func randomVec(r *rand.Rand, dim int) []float32 {
+	v := make([]float32, dim)
+	for i := range v {
+		v[i] = r.Float32()*2 - 1
+	}
+	return v
+}
+
+func TestBadgerStoreIndexPersistsAcrossReopen(t *testing.T) {
+	tempDir := t.TempDir()
+	store, err := NewBadgerStore(tempDir, WithIndex(hnsw.DefaultConfig))
+	if err != nil {
+		t.Fatalf("NewBadgerStore failed: %v", err)
+	}
+
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		if err := store.Add(fmt.Sprintf("v%d", i), randomVec(r, 8), map[string]any{"n": i}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+
+	g := store.graph.Load()
+	before := make([]hnsw.NodeState, g.NodeCount())
+	for i := range before {
+		before[i] = g.Node(uint32(i))
+	}
+	entry, level := g.EntryPoint()
+
+	query := randomVec(r, 8)
+	want, err := store.Search(query, 5)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	store, err = NewBadgerStore(tempDir, WithIndex(hnsw.DefaultConfig))
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer store.Close()
+
+	g = store.graph.Load()
+	if g.NodeCount() != len(before) {
+		t.Fatalf("Expected %d restored nodes, got %d", len(before), g.NodeCount())
+	}
+	for i, ns := range before {
+		if got := g.Node(uint32(i)); !reflect.DeepEqual(got, ns) {
+			t.Fatalf("node %d differs after reopen: got %+v, want %+v", i, got, ns)
+		}
+	}
+	if e, l := g.EntryPoint(); e != entry || l != level {
+		t.Errorf("Expected entry point (%d, %d), got (%d, %d)", entry, level, e, l)
+	}
+
+	got, err := store.Search(query, 5)
+	if err != nil {
+		t.Fatalf("Search after reopen failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Search results differ after reopen: got %v, want %v", got, want)
+	}
+	if got[0].Meta == nil {
+		t.Error("Expected graph search results to carry metadata")
+	}
+}
+
+func TestBadgerStoreIndexBuildsFromExistingVectors(t *testing.T) {
+	tempDir := t.TempDir()
+	store, err := NewBadgerStore(tempDir)
+	if err != nil {
+		t.Fatalf("NewBadgerStore failed: %v", err)
+	}
+	_ = store.SaveVector("vec1", []float32{1, 0, 0})
+	_ = store.SaveVector("vec2", []float32{0, 1, 0})
+	_ = store.Close()
+
+	store, err = NewBadgerStore(tempDir, WithIndex(hnsw.DefaultConfig))
+	if err != nil {
+		t.Fatalf("NewBadgerStore with index failed: %v", err)
+	}
+	if store.Index().Len() != 2 {
+		t.Fatalf("Expected 2 indexed vectors, got %d", store.Index().Len())
+	}
+	_ = store.Close()
+
+	// Writing without the index invalidates the persisted graph.
+	store, err = NewBadgerStore(tempDir)
+	if err != nil {
+		t.Fatalf("NewBadgerStore failed: %v", err)
+	}
+	if store.Index() != nil {
+		t.Error("Expected no index without WithIndex")
+	}
+	_ = store.SaveVector("vec3", []float32{0, 0, 1})
+	_ = store.Close()
+
+	store, err = NewBadgerStore(tempDir, WithIndex(hnsw.DefaultConfig))
+	if err != nil {
+		t.Fatalf("NewBadgerStore with index failed: %v", err)
+	}
+	defer store.Close()
+
+	res, err := store.Search([]float32{0, 0, 1}, 1)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(res) != 1 || res[0].ID != "vec3" {
+		t.Errorf("Expected rebuilt graph to contain vec3, got %v", res)
+	}
+}
+
+func TestBadgerStoreIndexWriteIsAtomic(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir(), WithIndex(hnsw.DefaultConfig))
+	if err != nil {
+		t.Fatalf("NewBadgerStore failed: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.Add("a", []float32{1, 0}, nil); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	// A graph insert failure must roll back the vector write.
+	if err := store.Add("b", []float32{1, 0, 0}, nil); err == nil {
+		t.Fatal("Expected dimension mismatch error, got nil")
+	}
+	if _, err := store.GetVector("b"); err == nil {
+		t.Error("Vector was written even though the graph update failed")
+	}
+
+	if err := store.SaveVector("\x00bad", []float32{1, 0}); err == nil {
+		t.Error("Expected error for reserved id prefix, got nil")
+	}
+
+	all, err := store.GetAllVectors()
+	if err != nil {
+		t.Fatalf("GetAllVectors failed: %v", err)
+	}
+	if len(all) != 1 {
+		t.Errorf("Expected graph keys to be excluded from vector scans, got %v", all)
+	}
+}
+
+func TestBadgerStoreIndexWithEmbedder(t *testing.T) {
+	tempDir := t.TempDir()
+	store, err := NewBadgerStore(tempDir, WithIndex(hnsw.DefaultConfig))
+	if err != nil {
+		t.Fatalf("NewBadgerStore failed: %v", err)
+	}
+
+	engine := embedx.New(store)
+	_ = engine.Add("x", []float32{1, 0, 0})
+	_ = engine.Add("y", []float32{0, 1, 0})
+	if err := engine.BuildIndex(); err != nil {
+		t.Errorf("BuildIndex on a store-managed index should be a no-op: %v", err)
+	}
+
+	// Tombstones written through the index survive a reopen.
+	if err := store.Index().Delete("x"); err != nil {
+		t.Fatalf("Index Delete failed: %v", err)
+	}
+	_ = store.Close()
+
+	store, err = NewBadgerStore(tempDir, WithIndex(hnsw.DefaultConfig))
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer store.Close()
+
+	res, err := embedx.New(store).Search([]float32{1, 0, 0}, 2)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(res) != 1 || res[0].ID != "y" {
+		t.Errorf("Expected only y after tombstoning x, got %v", res)
+	}
+	if !reflect.DeepEqual(res[0].Vector, []float32{0, 1, 0}) {
+		t.Errorf("Expected vector of y, got %v", res[0].Vector)
+	}
+}
+
+func TestGraphNodeEncoding(t *testing.T) {
+	nodes := []hnsw.NodeState{
+		{ID: "live", Level: 1, Links: [][]uint32{{1, 2, 3}, {4}}},
+		{ID: "gone", Level: 0, Links: [][]uint32{{}}, Deleted: true, Vector: []float32{0.5, -1}},
+	}
+	for _, ns := range nodes {
+		got, err := decodeGraphNode(encodeGraphNode(ns))
+		if err != nil {
+			t.Fatalf("decodeGraphNode failed: %v", err)
+		}
+		if !reflect.DeepEqual(got, ns) {
+			t.Errorf("Expected %+v, got %+v", ns, got)
+		}
+	}
+
+	b := encodeGraphNode(nodes[0])
+	if _, err := decodeGraphNode(b[:len(b)-2]); err == nil {
+		t.Error("Expected error for truncated node, got nil")
+	}
+
+	// Lengths beyond the end of the record fail before they are allocated.
+	huge := binary.AppendUvarint(nil, math.MaxUint64)
+	malformed := map[string][]byte{
+		"level overflowing int": append([]byte{graphNodeVersion, 0}, huge...),
+		"level beyond record":   {graphNodeVersion, 0, maxGraphLevel, 0},
+		"id beyond record":      append([]byte{graphNodeVersion, 0, 0}, huge...),
+		"links beyond record":   append([]byte{graphNodeVersion, 0, 0, 0}, binary.AppendUvarint(nil, 1<<40)...),
+		"vector beyond record":  append([]byte{graphNodeVersion, 1, 0, 0, 0}, huge...),
+	}
+	for name, b := range malformed {
+		if _, err := decodeGraphNode(b); err == nil {
+			t.Errorf("%s: expected error, got nil", name)
+		}
+	}
+
+	entry, level, err := decodeGraphEntry(encodeGraphEntry(7, -1))
+	if err != nil || entry != 7 || level != -1 {
+		t.Errorf("Expected (7, -1), got (%d, %d, %v)", entry, level, err)
+	}
+}
+
+func TestBadgerStoreCompactIndex(t *testing.T) {
+	tempDir := t.TempDir()
+	store, err := NewBadgerStore(tempDir, WithIndex(hnsw.DefaultConfig))
+	if err != nil {
+		t.Fatalf("NewBadgerStore failed: %v", err)
+	}
+
+	r := rand.New(rand.NewSource(3))
+	for i := 0; i < 100; i++ {
+		_ = store.SaveVector(fmt.Sprintf("v%d", i), randomVec(r, 8))
+	}
+	for i := 0; i < 100; i += 2 {
+		_ = store.Delete(fmt.Sprintf("v%d", i))
+	}
+	// An update tombstones the old node too.
+	_ = store.SaveVector("v1", randomVec(r, 8))
+	if g := store.graph.Load(); g.Tombstones() != 51 {
+		t.Fatalf("Expected 51 tombstones, got %d", g.Tombstones())
+	}
+
+	if err := store.CompactIndex(); err != nil {
+		t.Fatalf("CompactIndex failed: %v", err)
+	}
+	if g := store.graph.Load(); g.Tombstones() != 0 || g.NodeCount() != 50 {
+		t.Fatalf("Expected 50 nodes without tombstones, got %d with %d tombstones", g.NodeCount(), g.Tombstones())
+	}
+	query := randomVec(r, 8)
+	want, err := store.Search(query, 5)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// The compacted graph is what was persisted.
+	store, err = NewBadgerStore(tempDir, WithIndex(hnsw.DefaultConfig))
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer store.Close()
+	if g := store.graph.Load(); g.Tombstones() != 0 || g.NodeCount() != 50 {
+		t.Fatalf("Expected 50 persisted nodes without tombstones, got %d with %d tombstones", g.NodeCount(), g.Tombstones())
+	}
+	if got, err := store.Search(query, 5); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("Search results differ after reopen: got %v, want %v (%v)", got, want, err)
+	}
+
+	if err := store.CompactIndex(); err != nil {
+		t.Errorf("CompactIndex without tombstones failed: %v", err)
+	}
+	plain, err := NewBadgerStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewBadgerStore failed: %v", err)
+	}
+	defer plain.Close()
+	if err := plain.CompactIndex(); err != nil {
+		t.Errorf("CompactIndex without an index failed: %v", err)
+	}
+}
+
+func TestBadgerStoreWithMetric(t *testing.T) {
+	for _, indexed := range []bool{false, true} {
+		t.Run(fmt.Sprintf("indexed=%v", indexed), func(t *testing.T) {
+			opts := []Option{WithMetric(vector.MetricEuclidean)}
+			if indexed {
+				opts = append(opts, WithIndex(hnsw.DefaultConfig))
+			}
+			store, err := NewBadgerStore(t.TempDir(), opts...)
+			if err != nil {
+				t.Fatalf("NewBadgerStore failed: %v", err)
+			}
+			defer store.Close()
+
+			_ = store.SaveVector("origin", []float32{0, 0})
+			_ = store.SaveVector("near", []float32{1, 1})
+			_ = store.SaveVector("far", []float32{3, 4})
+
+			res, err := store.Search([]float32{0, 0}, 3)
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			if len(res) != 3 || res[0].ID != "origin" || res[2].ID != "far" {
+				t.Fatalf("Expected [origin near far], got %v", res)
+			}
+			if res[0].Score != 1 || res[2].Score != 1.0/6 {
+				t.Errorf("Expected scores 1 and 1/6, got %v and %v", res[0].Score, res[2].Score)
+			}
+		})
+	}
+
+	if _, err := NewBadgerStore(t.TempDir(), WithMetric(vector.Metric(99))); err == nil {
+		t.Error("Expected error for invalid metric, got nil")
+	}
+}
+
+func TestBadgerStoreAddBatchWithIndex(t *testing.T) {
+	tempDir := t.TempDir()
+	store, err := NewBadgerStore(tempDir, WithIndex(hnsw.DefaultConfig))
+	if err != nil {
+		t.Fatalf("NewBadgerStore failed: %v", err)
+	}
+
+	r := rand.New(rand.NewSource(2))
+	records := make([]embedx.Record, 100)
+	for i := range records {
+		records[i] = embedx.Record{ID: fmt.Sprintf("v%d", i), Vector: randomVec(r, 8)}
+	}
+	records[50].Vector = randomVec(r, 3) // rejected by the graph
+
+	err = store.AddBatch(records, embedx.BatchOptions{})
+	var be *embedx.BatchError
+	if !errors.As(err, &be) || len(be.Items) != 1 || be.Items[0].ID != "v50" || be.Written != 99 {
+		t.Fatalf("Expected only v50 to be rejected, got %v", err)
+	}
+	if _, err := store.GetVector("v50"); err == nil {
+		t.Error("Expected v50 not to be written")
+	}
+	if store.Index().Len() != 99 {
+		t.Errorf("Expected 99 indexed vectors, got %d", store.Index().Len())
+	}
+
+	// An atomic batch rejected by the graph leaves both untouched.
+	extra := []embedx.Record{{ID: "x1", Vector: randomVec(r, 8)}, {ID: "x2", Vector: randomVec(r, 2)}}
+	if err := store.AddBatch(extra, embedx.BatchOptions{Atomic: true}); !errors.As(err, &be) {
+		t.Fatalf("Expected atomic batch to be rejected, got %v", err)
+	}
+	if _, err := store.GetVector("x1"); err == nil || store.Index().Len() != 99 {
+		t.Errorf("Expected rejected atomic batch to write nothing, index has %d vectors", store.Index().Len())
+	}
+
+	query := records[10].Vector
+	want, err := store.Search(query, 5)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if want[0].ID != "v10" {
+		t.Errorf("Expected v10 to be its own nearest neighbor, got %v", want)
+	}
+	_ = store.Close()
+
+	// The graph saved after the batch is loaded instead of rebuilt.
+	store, err = NewBadgerStore(tempDir, WithIndex(hnsw.DefaultConfig))
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer store.Close()
+	got, err := store.Search(query, 5)
+	if err != nil {
+		t.Fatalf("Search after reopen failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Search results differ after reopen: got %v, want %v", got, want)
+	}
+}
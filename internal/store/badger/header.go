@@ -0,0 +1,302 @@
+package badger
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/ldaidone/goembedx/pkg/embedx"
+	"github.com/ldaidone/goembedx/vector"
+)
+
+// Store header.
+//
+// The header records the schema a store was created with, so that a
+// database opened later knows how its records are encoded and which vectors
+// it accepts:
+//
+//	offset  size  field
+//	0       1     headerVersion
+//	1       1     precision of the vector components (vector.Precision)
+//	2       1     metric (vector.Metric)
+//	3       var   dimension (uvarint), 0 if not fixed
+//	        var   model name (uvarint length and bytes)
+//	        var   model version (uvarint length and bytes)
+//
+// Version 1 headers hold only the precision; they are rewritten in the current
+// version, like databases created before the header existed, which hold
+// float32 records and get a header the first time they are opened.
+const (
+	// headerKey holds the store header.
+	headerKey = internalPrefix + "header"
+	// headerVersion is the encoding version of the store header.
+	headerVersion = 2
+)
+
+// schemaOptions holds the schema settings requested with options.
+// Nil fields were not requested.
+type schemaOptions struct {
+	dim       *int
+	metric    *vector.Metric
+	precision *vector.Precision
+	model     *[2]string
+}
+
+// marshalSchema encodes schema as a store header.
+func marshalSchema(schema embedx.Schema) []byte {
+	b := []byte{headerVersion, byte(schema.Precision), byte(schema.Metric)}
+	b = binary.AppendUvarint(b, uint64(schema.Dim))
+	b = binary.AppendUvarint(b, uint64(len(schema.Model)))
+	b = append(b, schema.Model...)
+	b = binary.AppendUvarint(b, uint64(len(schema.ModelVersion)))
+	return append(b, schema.ModelVersion...)
+}
+
+// unmarshalSchema decodes a store header. It reports whether the header
+// holds a complete schema; version 1 headers only set the precision.
+func unmarshalSchema(b []byte) (embedx.Schema, bool, error) {
+	var schema embedx.Schema
+	if len(b) < 2 {
+		return schema, false, errors.New("store header is truncated")
+	}
+	version := b[0]
+	if version != 1 && version != headerVersion {
+		return schema, false, fmt.Errorf("unsupported store header version %d", version)
+	}
+	schema.Precision = vector.Precision(b[1])
+	if !schema.Precision.Valid() {
+		return schema, false, fmt.Errorf("invalid precision in store header: %s", schema.Precision)
+	}
+	if version == 1 {
+		return schema, false, nil
+	}
+
+	r := byteReader{b: b[2:]}
+	schema.Metric = vector.Metric(r.byte())
+	schema.Dim = int(r.uvarint())
+	schema.Model = string(r.bytes(int(r.uvarint())))
+	schema.ModelVersion = string(r.bytes(int(r.uvarint())))
+	if r.err != nil {
+		return schema, false, errors.New("store header is truncated")
+	}
+	if !schema.Metric.Valid() {
+		return schema, false, fmt.Errorf("invalid metric in store header: %s", schema.Metric)
+	}
+	return schema, true, nil
+}
+
+// checkSchema compares the settings requested with options against the
+// stored schema, returning a *embedx.SchemaError for the first conflict.
+func (o schemaOptions) checkSchema(stored embedx.Schema) error {
+	switch {
+	case o.dim != nil && *o.dim != stored.Dim:
+		return &embedx.SchemaError{Field: "dim", Stored: strconv.Itoa(stored.Dim), Requested: strconv.Itoa(*o.dim)}
+	case o.metric != nil && *o.metric != stored.Metric:
+		return &embedx.SchemaError{Field: "metric", Stored: stored.Metric.String(), Requested: o.metric.String()}
+	case o.precision != nil && *o.precision != stored.Precision:
+		return &embedx.SchemaError{Field: "precision", Stored: stored.Precision.String(), Requested: o.precision.String()}
+	case o.model != nil && (o.model[0] != stored.Model || o.model[1] != stored.ModelVersion):
+		return &embedx.SchemaError{
+			Field:     "model",
+			Stored:    modelString(stored.Model, stored.ModelVersion),
+			Requested: modelString(o.model[0], o.model[1]),
+		}
+	}
+	return nil
+}
+
+// apply returns schema with the requested settings.
+func (o schemaOptions) apply(schema embedx.Schema) embedx.Schema {
+	if o.dim != nil {
+		schema.Dim = *o.dim
+	}
+	if o.metric != nil {
+		schema.Metric = *o.metric
+	}
+	if o.precision != nil {
+		schema.Precision = *o.precision
+	}
+	if o.model != nil {
+		schema.Model, schema.ModelVersion = o.model[0], o.model[1]
+	}
+	return schema
+}
+
+// modelString formats a model name and version for error messages.
+func modelString(name, version string) string {
+	switch {
+	case name == "" && version == "":
+		return `""`
+	case version == "":
+		return strconv.Quote(name)
+	default:
+		return strconv.Quote(name + "@" + version)
+	}
+}
+
+// loadSchema reads the store header and applies its schema, writing a header
+// with the requested settings when the database has none.
+// A store's schema is fixed when it is created: opening an existing store
+// with options that conflict with its schema returns a *embedx.SchemaError.
+// Databases without a header or with a version 1 header get their missing
+// settings from the options, but a precision other than their own or a
+// dimension that their vectors do not have is rejected.
+func (s *BadgerStore) loadSchema() error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		schema, complete := embedx.Schema{}, false
+		item, err := txn.Get(s.key(headerKey))
+		switch {
+		case err == nil:
+			err = item.Value(func(v []byte) error {
+				schema, complete, err = unmarshalSchema(v)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+		case !errors.Is(err, badger.ErrKeyNotFound):
+			return err
+		}
+
+		if complete {
+			if err := s.opts.checkSchema(schema); err != nil {
+				return err
+			}
+			s.schema = schema
+			return nil
+		}
+
+		// Upgrade: only the precision of the records is known.
+		if s.opts.precision != nil && *s.opts.precision != schema.Precision {
+			if err := s.checkEmpty(txn); err != nil {
+				return &embedx.SchemaError{Field: "precision", Stored: schema.Precision.String(), Requested: s.opts.precision.String()}
+			}
+		}
+		schema = s.opts.apply(schema)
+		if schema.Dim != 0 {
+			if err := s.checkDims(txn, schema.Dim); err != nil {
+				return err
+			}
+		}
+		s.schema = schema
+		return txn.Set(s.key(headerKey), marshalSchema(schema))
+	})
+}
+
+// checkEmpty returns an error if the database holds any vector record.
+func (s *BadgerStore) checkEmpty(txn *badger.Txn) error {
+	it := s.newVectorIterator(txn, badger.IteratorOptions{})
+	defer it.Close()
+	if it.Seek(s.key(firstVectorKey)); it.Valid() {
+		return errors.New("store is not empty")
+	}
+	return nil
+}
+
+// checkDims returns a *embedx.DimensionError if a stored vector does not
+// have dimension dim.
+func (s *BadgerStore) checkDims(txn *badger.Txn, dim int) error {
+	it := s.newVectorIterator(txn, badger.DefaultIteratorOptions)
+	defer it.Close()
+	for it.Seek(s.key(firstVectorKey)); it.Valid(); it.Next() {
+		item := it.Item()
+		err := item.Value(func(v []byte) error {
+			n := 0
+			if isBinaryRecord(v) {
+				r, err := parseRecord(v)
+				if err != nil {
+					return fmt.Errorf("failed to decode vector %s: %w", s.id(item.Key()), err)
+				}
+				n = r.dim
+			} else {
+				data, err := decodeLegacyRecord(v)
+				if err != nil {
+					return fmt.Errorf("failed to decode vector %s: %w", s.id(item.Key()), err)
+				}
+				n = len(data.Vector)
+			}
+			if n != dim {
+				return fmt.Errorf("vector %s: %w", s.id(item.Key()), &embedx.DimensionError{Expected: dim, Actual: n})
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Schema returns the schema of the store.
+func (s *BadgerStore) Schema() embedx.Schema {
+	return s.schema
+}
+
+// SetSchema replaces the schema of a store that holds no vectors, and of
+// its HNSW index if it is enabled. It is meant to initialize a new store and
+// must not be called concurrently with other methods.
+// It succeeds without changes if schema equals the current schema, and
+// returns a *embedx.SchemaError for any other change to a store that holds
+// vectors, quantized codes or an IVF index. It returns an error if schema
+// has a negative dimension or an unknown metric or precision.
+func (s *BadgerStore) SetSchema(schema embedx.Schema) error {
+	if err := validateSchema(schema); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if schema == s.schema {
+		return nil
+	}
+	opts := schemaOptions{
+		dim:       &schema.Dim,
+		metric:    &schema.Metric,
+		precision: &schema.Precision,
+		model:     &[2]string{schema.Model, schema.ModelVersion},
+	}
+	conflict := opts.checkSchema(s.schema)
+	if s.quant.Load() != nil || s.ivf.Load() != nil {
+		return conflict
+	}
+	err := s.db.Update(func(txn *badger.Txn) error {
+		if s.checkEmpty(txn) != nil {
+			return conflict
+		}
+		return txn.Set(s.key(headerKey), marshalSchema(schema))
+	})
+	if err != nil {
+		return err
+	}
+
+	s.schema = schema
+	if s.graphCfg != nil && s.graphCfg.Metric != schema.Metric {
+		s.graphCfg.Metric = schema.Metric
+		return s.rebuildGraph()
+	}
+	return nil
+}
+
+// validateSchema returns an error if schema has invalid settings.
+func validateSchema(schema embedx.Schema) error {
+	switch {
+	case schema.Dim < 0:
+		return fmt.Errorf("invalid dimension: %d", schema.Dim)
+	case !schema.Metric.Valid():
+		return fmt.Errorf("invalid metric: %s", schema.Metric)
+	case !schema.Precision.Valid():
+		return fmt.Errorf("invalid precision: %s", schema.Precision)
+	}
+	return nil
+}
+
+// checkDim returns a *embedx.DimensionError if the schema fixes a dimension
+// other than the dimension of vec.
+func (s *BadgerStore) checkDim(vec []float32) error {
+	if s.schema.Dim != 0 && len(vec) != s.schema.Dim {
+		return &embedx.DimensionError{Expected: s.schema.Dim, Actual: len(vec)}
+	}
+	return nil
+}
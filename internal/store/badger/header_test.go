@@ -0,0 +1,279 @@
+package badger
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+	"testing"
+
+	badgerdb "github.com/dgraph-io/badger/v4"
+	"github.com/ldaidone/goembedx/pkg/embedx"
+	"github.com/ldaidone/goembedx/pkg/index/hnsw"
+	"github.com/ldaidone/goembedx/pkg/index/ivf"
+	"github.com/ldaidone/goembedx/vector"
+)
+
+func TestBadgerStorePrecision(t *testing.T) {
+	for _, p := range []vector.Precision{vector.PrecisionFloat16, vector.PrecisionBFloat16} {
+		t.Run(p.String(), func(t *testing.T) {
+			dir := t.TempDir()
+			store, err := NewBadgerStore(dir, WithPrecision(p))
+			if err != nil {
+				t.Fatalf("NewBadgerStore failed: %v", err)
+			}
+
+			r := rand.New(rand.NewSource(1))
+			records := make([]embedx.Record, 300)
+			for i := range records {
+				records[i] = embedx.Record{ID: fmt.Sprint(i), Vector: randomVec(r, 32)}
+			}
+			if err := store.AddBatch(records, embedx.BatchOptions{}); err != nil {
+				t.Fatalf("AddBatch failed: %v", err)
+			}
+			_ = store.Add("single", records[0].Vector, map[string]any{"k": "v"})
+
+			// Records take two bytes per component and read back rounded.
+			err = store.db.View(func(txn *badgerdb.Txn) error {
+				item, err := txn.Get([]byte("7"))
+				if err != nil {
+					return err
+				}
+				if want := int64(recordHeaderLen + 2*32); item.ValueSize() != want {
+					t.Errorf("Expected a %d-byte record, got %d", want, item.ValueSize())
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("reading record failed: %v", err)
+			}
+			vec, norm, meta, err := store.Get("single")
+			if err != nil || !slices.Equal(vec, p.Round(nil, records[0].Vector)) || meta["k"] != "v" {
+				t.Fatalf("Expected the rounded vector, got %v, %v, %v", vec, meta, err)
+			}
+			if norm != vector.Norm(vec) {
+				t.Errorf("Expected the norm of the rounded vector, got %v", norm)
+			}
+
+			// Searches score the stored vectors, with or without an IVF index.
+			results, err := store.Search(records[42].Vector, 1)
+			if err != nil || len(results) != 1 || results[0].ID != "42" || results[0].Score < 0.999 {
+				t.Errorf("Expected 42 to match, got %v, %v", results, err)
+			}
+			if err := store.TrainIVF(ivf.Config{Lists: 4, NProbe: 4, Seed: 1}); err != nil {
+				t.Fatalf("TrainIVF failed: %v", err)
+			}
+			if results, _ := store.Search(records[42].Vector, 1); len(results) != 1 || results[0].ID != "42" || results[0].Score < 0.999 {
+				t.Errorf("Expected 42 to match through the IVF index, got %v", results)
+			}
+			store.Close()
+
+			// The precision is recorded in the header and cannot change.
+			store, err = NewBadgerStore(dir)
+			if err != nil {
+				t.Fatalf("reopen failed: %v", err)
+			}
+			if stats, _ := store.Stats(); stats.Precision != p || stats.Count != 301 {
+				t.Errorf("Unexpected stats after reopen: %+v", stats)
+			}
+			if results, _ := store.Search(records[42].Vector, 1); len(results) != 1 || results[0].ID != "42" {
+				t.Errorf("Expected 42 to match after reopen, got %v", results)
+			}
+			store.Close()
+			if _, err := NewBadgerStore(dir, WithPrecision(vector.PrecisionFloat32)); err == nil {
+				t.Error("Expected error reopening with another precision, got nil")
+			}
+		})
+	}
+}
+
+func TestBadgerStoreSchema(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewBadgerStore(dir, WithDim(3), WithMetric(vector.MetricDot), WithModel("minilm", "v2"))
+	if err != nil {
+		t.Fatalf("NewBadgerStore failed: %v", err)
+	}
+	want := embedx.Schema{Dim: 3, Metric: vector.MetricDot, Model: "minilm", ModelVersion: "v2"}
+	if got := store.Schema(); got != want {
+		t.Errorf("Expected schema %+v, got %+v", want, got)
+	}
+
+	// Every write and search is validated against the dimension.
+	var dimErr *embedx.DimensionError
+	if err := store.Add("a", []float32{1, 2}, nil); !errors.As(err, &dimErr) || dimErr.Expected != 3 || dimErr.Actual != 2 {
+		t.Errorf("Expected a DimensionError, got %v", err)
+	}
+	err = store.AddBatch([]embedx.Record{{ID: "b", Vector: []float32{1, 2, 3}}, {ID: "c", Vector: []float32{1}}}, embedx.BatchOptions{})
+	var batchErr *embedx.BatchError
+	if !errors.As(err, &batchErr) || len(batchErr.Items) != 1 || !errors.As(batchErr.Items[0].Err, &dimErr) {
+		t.Errorf("Expected the mismatched record to be rejected, got %v", err)
+	}
+	if _, err := store.Search([]float32{1}, 1); !errors.As(err, &dimErr) {
+		t.Errorf("Expected a DimensionError for the query, got %v", err)
+	}
+	if results, _ := store.Search([]float32{0, 0, 2}, 1); len(results) != 1 || results[0].Score != 6 {
+		t.Errorf("Expected a dot product score, got %v", results)
+	}
+	if stats, _ := store.Stats(); stats.Dim != 3 || stats.Model != "minilm" || stats.Metric != vector.MetricDot {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	// The schema of a store holding vectors cannot change.
+	var schemaErr *embedx.SchemaError
+	if err := store.SetSchema(embedx.Schema{Dim: 4}); !errors.As(err, &schemaErr) || schemaErr.Field != "dim" {
+		t.Errorf("Expected a SchemaError, got %v", err)
+	}
+	if err := store.SetSchema(want); err != nil {
+		t.Errorf("Expected setting the same schema to succeed, got %v", err)
+	}
+	store.Close()
+
+	// Later opens take the schema from the header and reject conflicts.
+	store, err = NewBadgerStore(dir)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	if got := store.Schema(); got != want {
+		t.Errorf("Expected schema %+v after reopen, got %+v", want, got)
+	}
+	store.Close()
+	for name, opt := range map[string]Option{
+		"dim":       WithDim(4),
+		"metric":    WithMetric(vector.MetricCosine),
+		"precision": WithPrecision(vector.PrecisionFloat16),
+		"model":     WithModel("minilm", "v3"),
+	} {
+		_, err := NewBadgerStore(dir, opt)
+		if !errors.As(err, &schemaErr) || schemaErr.Field != name {
+			t.Errorf("%s: expected a SchemaError, got %v", name, err)
+		}
+	}
+	if store, err = NewBadgerStore(dir, WithDim(3), WithModel("minilm", "v2")); err != nil {
+		t.Fatalf("Expected matching options to be accepted, got %v", err)
+	}
+	store.Close()
+
+	if _, err := NewBadgerStore(t.TempDir(), WithDim(-1)); err == nil {
+		t.Error("Expected error for a negative dimension, got nil")
+	}
+	if _, err := NewBadgerStore(t.TempDir(), WithPrecision(vector.Precision(9))); err == nil {
+		t.Error("Expected error for an invalid precision, got nil")
+	}
+}
+
+func TestBadgerStoreSetSchema(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir(), WithIndex(hnsw.DefaultConfig))
+	if err != nil {
+		t.Fatalf("NewBadgerStore failed: %v", err)
+	}
+	defer store.Close()
+
+	// An empty store can be reconfigured, including its graph.
+	schema := embedx.Schema{Dim: 2, Metric: vector.MetricEuclidean, Precision: vector.PrecisionBFloat16, Model: "m"}
+	if err := store.SetSchema(schema); err != nil {
+		t.Fatalf("SetSchema failed: %v", err)
+	}
+	if store.graphCfg.Metric != vector.MetricEuclidean {
+		t.Errorf("Expected the graph to use the new metric, got %s", store.graphCfg.Metric)
+	}
+	_ = store.Add("a", []float32{1, 1}, nil)
+	if results, _ := store.Search([]float32{1, 1}, 1); len(results) != 1 || results[0].Score != 1 {
+		t.Errorf("Expected a Euclidean score of 1, got %v", results)
+	}
+	if err := store.SetSchema(embedx.Schema{Dim: 2}); err == nil {
+		t.Error("Expected error reconfiguring a store with vectors, got nil")
+	}
+	if err := store.SetSchema(embedx.Schema{Metric: vector.Metric(42)}); err == nil {
+		t.Error("Expected error for an invalid metric, got nil")
+	}
+}
+
+func TestBadgerStoreHeaderUpgrade(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewBadgerStore(dir)
+	if err != nil {
+		t.Fatalf("NewBadgerStore failed: %v", err)
+	}
+	_ = store.Add("a", []float32{0.1, 0.2}, nil)
+
+	// Stores without a header hold float32 records of any dimension.
+	err = store.db.Update(func(txn *badgerdb.Txn) error {
+		return txn.Delete([]byte(headerKey))
+	})
+	if err != nil {
+		t.Fatalf("deleting header failed: %v", err)
+	}
+	store.Close()
+	if _, err := NewBadgerStore(dir, WithPrecision(vector.PrecisionFloat16)); err == nil {
+		t.Error("Expected error opening a float32 store in float16, got nil")
+	}
+	var dimErr *embedx.DimensionError
+	if _, err := NewBadgerStore(dir, WithDim(3)); !errors.As(err, &dimErr) {
+		t.Errorf("Expected a DimensionError for vectors of another dimension, got %v", err)
+	}
+
+	// A version 1 header only records the precision.
+	store, err = NewBadgerStore(dir)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	err = store.db.Update(func(txn *badgerdb.Txn) error {
+		return txn.Set([]byte(headerKey), []byte{1, byte(vector.PrecisionFloat32)})
+	})
+	if err != nil {
+		t.Fatalf("writing header failed: %v", err)
+	}
+	store.Close()
+	store, err = NewBadgerStore(dir, WithDim(2), WithMetric(vector.MetricDot))
+	if err != nil {
+		t.Fatalf("upgrade failed: %v", err)
+	}
+	defer store.Close()
+	if got := store.Schema(); got.Dim != 2 || got.Metric != vector.MetricDot {
+		t.Errorf("Expected the options to complete the schema, got %+v", got)
+	}
+	if vec, _ := store.GetVector("a"); vec[0] != 0.1 {
+		t.Errorf("Expected the float32 vector, got %v", vec)
+	}
+
+	for _, b := range [][]byte{nil, {headerVersion + 1, 0}, {headerVersion, 9}, {headerVersion, 0, 0, 5}, {headerVersion, 0, 42, 0, 0, 0}} {
+		if _, _, err := unmarshalSchema(b); err == nil {
+			t.Errorf("Expected error decoding header %v, got nil", b)
+		}
+	}
+	schema := embedx.Schema{Dim: 768, Metric: vector.MetricHamming, Model: "m", ModelVersion: "1", Precision: vector.PrecisionFloat16}
+	if got, complete, err := unmarshalSchema(marshalSchema(schema)); err != nil || !complete || got != schema {
+		t.Errorf("Round trip of %+v gave %+v, %v", schema, got, err)
+	}
+}
+
+// BenchmarkBadgerStorePrecisionSearch scans 2000 768-dimensional vectors
+// stored in each precision.
+func BenchmarkBadgerStorePrecisionSearch(b *testing.B) {
+	for _, p := range []vector.Precision{vector.PrecisionFloat32, vector.PrecisionFloat16, vector.PrecisionBFloat16} {
+		b.Run(p.String(), func(b *testing.B) {
+			store, err := NewBadgerStore(b.TempDir(), WithPrecision(p))
+			if err != nil {
+				b.Fatalf("NewBadgerStore failed: %v", err)
+			}
+			defer store.Close()
+
+			r := rand.New(rand.NewSource(1))
+			records := make([]embedx.Record, 2000)
+			for i := range records {
+				records[i] = embedx.Record{ID: fmt.Sprint(i), Vector: randomVec(r, 768)}
+			}
+			if err := store.AddBatch(records, embedx.BatchOptions{}); err != nil {
+				b.Fatal(err)
+			}
+			query := randomVec(r, 768)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := store.Search(query, 10); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
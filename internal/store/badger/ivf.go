@@ -0,0 +1,403 @@
+package badger
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/ldaidone/goembedx/pkg/embedx"
+	"github.com/ldaidone/goembedx/pkg/index/ivf"
+	"github.com/ldaidone/goembedx/vector"
+)
+
+// IVF key layout.
+//
+// Once TrainIVF has run, every vector is also stored in the posting list of
+// its closest centroid, under ivfListPrefix followed by the big-endian list
+// number and the vector ID, with the little-endian float32 norm and the
+// vector, in the precision of the store, as value. A search iterates only the prefixes of the lists it probes. The list
+// of each vector is recorded under ivfAssignPrefix so that a write can remove
+// the vector from its previous list. The configuration and centroids are
+// stored under ivfConfigKey, which is written last when the lists are built:
+// a store without it has no IVF index and any posting left over is ignored.
+const (
+	// ivfPrefix groups every key of the IVF index.
+	ivfPrefix = internalPrefix + "ivf/"
+	// ivfListPrefix is followed by the big-endian list number and the vector ID.
+	ivfListPrefix = ivfPrefix + "l/"
+	// ivfAssignPrefix is followed by the vector ID and holds its list number.
+	ivfAssignPrefix = ivfPrefix + "a/"
+	// ivfConfigKey holds the index configuration and the encoded centroids.
+	ivfConfigKey = ivfPrefix + "config"
+	// ivfConfigVersion is the encoding version of ivfConfigKey.
+	ivfConfigVersion = 1
+)
+
+// ivfState is the IVF configuration of a store.
+type ivfState struct {
+	// cfg holds the parameters the index was trained with.
+	cfg ivf.Config
+	// centroids assigns vectors to posting lists.
+	centroids *ivf.Centroids
+	// precision encodes the vectors of the postings, like their records.
+	precision vector.Precision
+}
+
+// ivfListKey returns the prefix of posting list list.
+func (s *BadgerStore) ivfListKey(list int) []byte {
+	return binary.BigEndian.AppendUint32(s.key(ivfListPrefix), uint32(list))
+}
+
+// ivfPostingKey returns the key of id in posting list list.
+func (s *BadgerStore) ivfPostingKey(list int, id string) []byte {
+	return append(s.ivfListKey(list), id...)
+}
+
+// ivfAssignKey returns the key holding the list of id.
+func (s *BadgerStore) ivfAssignKey(id string) []byte {
+	return s.key(ivfAssignPrefix + id)
+}
+
+// ivfWrites returns the list of vec in st and the writes that move id from
+// list old, or from no list if old is negative, to that list.
+func (s *BadgerStore) ivfWrites(st *ivfState, id string, vec []float32, norm float32, old int) (int, []keyWrite) {
+	list := st.centroids.Assign(vec)
+	posting := appendComponents(float32ToBytes(norm), vec, st.precision)
+	writes := []keyWrite{
+		{key: s.ivfPostingKey(list, id), value: posting},
+		{key: s.ivfAssignKey(id), value: binary.BigEndian.AppendUint32(nil, uint32(list))},
+	}
+	if old >= 0 && old != list {
+		writes = append(writes, keyWrite{key: s.ivfPostingKey(old, id)})
+	}
+	return list, writes
+}
+
+// readIVFList returns the list id is stored in, or -1 if it is in none.
+func (s *BadgerStore) readIVFList(txn *badger.Txn, id string) (int, error) {
+	item, err := txn.Get(s.ivfAssignKey(id))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return -1, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	list := -1
+	err = item.Value(func(v []byte) error {
+		if len(v) != 4 {
+			return fmt.Errorf("invalid IVF list of %s", id)
+		}
+		list = int(binary.BigEndian.Uint32(v))
+		return nil
+	})
+	return list, err
+}
+
+// encodeIVFConfig encodes st for ivfConfigKey.
+func encodeIVFConfig(st *ivfState) ([]byte, error) {
+	c, err := st.centroids.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	b := []byte{ivfConfigVersion}
+	b = binary.AppendUvarint(b, uint64(st.cfg.NProbe))
+	b = binary.AppendUvarint(b, uint64(st.cfg.Iterations))
+	b = binary.AppendUvarint(b, uint64(st.cfg.Sample))
+	b = binary.AppendVarint(b, st.cfg.Seed)
+	return append(b, c...), nil
+}
+
+// decodeIVFConfig decodes a configuration encoded by encodeIVFConfig.
+func decodeIVFConfig(b []byte, m vector.Metric) (*ivfState, error) {
+	r := byteReader{b: b}
+	if v := r.byte(); v != ivfConfigVersion {
+		return nil, fmt.Errorf("unsupported IVF config version %d", v)
+	}
+	st := &ivfState{centroids: new(ivf.Centroids)}
+	st.cfg.NProbe = int(r.uvarint())
+	st.cfg.Iterations = int(r.uvarint())
+	st.cfg.Sample = int(r.uvarint())
+	seed, n := binary.Varint(r.b)
+	if r.err != nil || n <= 0 {
+		return nil, errors.New("truncated IVF config")
+	}
+	st.cfg.Seed = seed
+	st.cfg.Metric = m
+	if err := st.centroids.UnmarshalBinary(r.b[n:]); err != nil {
+		return nil, err
+	}
+	st.cfg.Lists = st.centroids.Len()
+	return st, nil
+}
+
+// loadIVF restores the IVF configuration persisted by TrainIVF.
+func (s *BadgerStore) loadIVF() error {
+	return s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(s.key(ivfConfigKey))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return item.Value(func(v []byte) error {
+			st, err := decodeIVFConfig(v, s.schema.Metric)
+			if err != nil {
+				return fmt.Errorf("failed to decode IVF index: %w", err)
+			}
+			st.precision = s.schema.Precision
+			s.ivf.Store(st)
+			return nil
+		})
+	})
+}
+
+// TrainIVF trains the centroids of an IVF index with k-means on a sample of
+// cfg.Sample stored vectors and writes every vector to the posting list of
+// its closest centroid. From then on, unfiltered searches of a store without
+// an HNSW index only read the cfg.NProbe lists closest to the query, and
+// writes keep the lists in sync in the same transaction as the record.
+// The index and its configuration are persisted with the store.
+//
+// Training a store that already has an IVF index replaces it. The index
+// always uses the store's metric; cfg.Metric is ignored. Zero fields in cfg
+// are replaced by the values of ivf.DefaultConfig, except Sample, for which
+// 0 means every vector.
+//
+// Returns an error wrapping embedx.ErrEmptyStore if the store is empty, and an
+// error if the stored vectors have different dimensions.
+func (s *BadgerStore) TrainIVF(cfg ivf.Config) error {
+	cfg = ivf.New(cfg).Config()
+	cfg.Metric = s.schema.Metric
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sample, err := s.sampleVectors(cfg.Sample, cfg.Seed)
+	if err != nil {
+		return fmt.Errorf("cannot train IVF index: %w", err)
+	}
+	centroids, err := ivf.TrainCentroids(sample, cfg)
+	if err != nil {
+		return err
+	}
+	cfg.Lists = centroids.Len()
+	return s.buildIVF(&ivfState{cfg: cfg, centroids: centroids, precision: s.schema.Precision})
+}
+
+// RebalanceIVF refines the centroids of the IVF index on a new sample of the
+// stored vectors, starting from the current centroids and reseeding lists
+// that have become empty from the largest ones, and rewrites the posting
+// lists. It restores the balance of lists that grew uneven as the data
+// drifted from the sample the index was trained on, at a lower cost than
+// TrainIVF.
+//
+// Returns an error if the store has no IVF index or is empty.
+func (s *BadgerStore) RebalanceIVF() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := s.ivf.Load()
+	if st == nil {
+		return errors.New("store has no IVF index")
+	}
+	sample, err := s.sampleVectors(st.cfg.Sample, st.cfg.Seed)
+	if err != nil {
+		return err
+	}
+	centroids, err := st.centroids.Refine(sample, st.cfg.Iterations, st.cfg.Seed)
+	if err != nil {
+		return err
+	}
+	return s.buildIVF(&ivfState{cfg: st.cfg, centroids: centroids, precision: s.schema.Precision})
+}
+
+// SetNProbe changes the number of posting lists read by IVF searches until
+// the store is closed. Values less than or equal to 0 are ignored.
+func (s *BadgerStore) SetNProbe(n int) {
+	if n <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if st := s.ivf.Load(); st != nil {
+		next := *st
+		next.cfg.NProbe = n
+		s.ivf.Store(&next)
+	}
+}
+
+// IVFListSizes returns the number of vectors in each posting list of the IVF
+// index, or nil if the store has none.
+func (s *BadgerStore) IVFListSizes() ([]int, error) {
+	st := s.ivf.Load()
+	if st == nil {
+		return nil, nil
+	}
+	sizes := make([]int, st.centroids.Len())
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = s.key(ivfListPrefix)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			list := int(binary.BigEndian.Uint32(it.Item().Key()[len(opts.Prefix):]))
+			if list < len(sizes) {
+				sizes[list]++
+			}
+		}
+		return nil
+	})
+	return sizes, err
+}
+
+// sampleVectors draws up to n stored vectors at random, or every vector if
+// n <= 0. Returns embedx.ErrEmptyStore if the store is empty.
+func (s *BadgerStore) sampleVectors(n int, seed int64) ([][]float32, error) {
+	sample := vector.NewReservoir(n, seed)
+	err := s.Scan(func(r embedx.Record) error {
+		sample.Add(r.Vector)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(sample.Sample()) == 0 {
+		return nil, embedx.ErrEmptyStore
+	}
+	return sample.Sample(), nil
+}
+
+// buildIVF replaces the IVF index with st, writing every stored vector to its
+// posting list. The caller must hold s.mu.
+func (s *BadgerStore) buildIVF(st *ivfState) error {
+	// Drop the previous configuration before the lists, so that a crash
+	// leaves a store without an index rather than half-built lists.
+	s.ivf.Store(nil)
+	if err := s.db.DropPrefix(s.key(ivfPrefix)); err != nil {
+		return err
+	}
+
+	wb := s.db.NewWriteBatch()
+	defer wb.Cancel()
+	dim := st.centroids.Dim()
+	err := s.Scan(func(r embedx.Record) error {
+		if len(r.Vector) != dim {
+			return fmt.Errorf("cannot index vector %s of dimension %d, want %d", r.ID, len(r.Vector), dim)
+		}
+		_, writes := s.ivfWrites(st, r.ID, r.Vector, r.Norm, -1)
+		return applyWrites(wb, writes)
+	})
+	if err != nil {
+		return err
+	}
+	if err := wb.Flush(); err != nil {
+		return err
+	}
+
+	config, err := encodeIVFConfig(st)
+	if err != nil {
+		return err
+	}
+	err = s.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(s.key(ivfConfigKey), config)
+	})
+	if err != nil {
+		return err
+	}
+	s.ivf.Store(st)
+	return nil
+}
+
+// searchIVF scores the vectors of the posting lists closest to the query,
+// keeps the best k and loads their metadata from their records.
+// If k <= 0, every list is read and every vector returned.
+// It returns ctx.Err() once ctx is done.
+func (s *BadgerStore) searchIVF(ctx context.Context, st *ivfState, query []float32, k int) ([]embedx.SearchResult, error) {
+	results := make([]embedx.SearchResult, 0)
+	if len(query) != st.centroids.Dim() {
+		return results, nil
+	}
+	nprobe := st.cfg.NProbe
+	if k <= 0 {
+		nprobe = 0
+	}
+
+	top := vector.NewTopK(k)
+	sc := newScorer(s.schema.Metric, query)
+	err := s.db.View(func(txn *badger.Txn) error {
+		n := 0
+		for _, list := range st.centroids.Probe(query, nprobe) {
+			opts := badger.DefaultIteratorOptions
+			opts.Prefix = s.ivfListKey(list)
+			it := txn.NewIterator(opts)
+
+			for it.Rewind(); it.Valid(); it.Next() {
+				if err := embedx.CheckContext(ctx, n); err != nil {
+					it.Close()
+					return err
+				}
+				n++
+				item := it.Item()
+				err := item.Value(func(v []byte) error {
+					if len(v) != 4+st.precision.Size()*len(query) {
+						return fmt.Errorf("invalid IVF posting %q", item.Key())
+					}
+					norm := bytesToFloat32(v)
+					if s.schema.Metric == vector.MetricCosine && (sc.norm == 0 || norm == 0) {
+						return nil
+					}
+					score := sc.score(st.precision, v[4:], norm)
+					if top.Admits(score) {
+						top.Push(vector.Candidate{ID: string(item.Key()[len(opts.Prefix):]), Score: score})
+					}
+					return nil
+				})
+				if err != nil {
+					it.Close()
+					return err
+				}
+			}
+			it.Close()
+		}
+
+		// Load the metadata of the results. Postings whose record is
+		// missing, which an interrupted best-effort batch can leave behind,
+		// are dropped.
+		for i, hit := range top.Results() {
+			if err := embedx.CheckContext(ctx, i); err != nil {
+				return err
+			}
+			item, err := txn.Get(s.key(hit.ID))
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			res := embedx.SearchResult{ID: hit.ID, Score: hit.Score}
+			err = item.Value(func(v []byte) error {
+				r, err := s.viewRecord(item.Key(), v)
+				if err != nil {
+					return err
+				}
+				res.Meta, err = r.metadata()
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			results = append(results, res)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
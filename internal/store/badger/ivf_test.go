@@ -0,0 +1,148 @@
+package badger
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	badgerdb "github.com/dgraph-io/badger/v4"
+	"github.com/ldaidone/goembedx/pkg/embedx"
+	"github.com/ldaidone/goembedx/pkg/index/ivf"
+)
+
+func TestBadgerStoreIVF(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewBadgerStore(dir)
+	if err != nil {
+		t.Fatalf("NewBadgerStore failed: %v", err)
+	}
+
+	if err := store.TrainIVF(ivf.Config{}); err == nil {
+		t.Error("Expected error training an empty store, got nil")
+	}
+	if err := store.RebalanceIVF(); err == nil {
+		t.Error("Expected error rebalancing a store without an IVF index, got nil")
+	}
+
+	r := rand.New(rand.NewSource(1))
+	records := make([]embedx.Record, 500)
+	for i := range records {
+		records[i] = embedx.Record{ID: fmt.Sprint(i), Vector: randomVec(r, 8), Meta: map[string]any{"i": i}}
+	}
+	if err := store.AddBatch(records, embedx.BatchOptions{}); err != nil {
+		t.Fatalf("AddBatch failed: %v", err)
+	}
+	if err := store.TrainIVF(ivf.Config{Lists: 16, NProbe: 16, Sample: 200, Seed: 1}); err != nil {
+		t.Fatalf("TrainIVF failed: %v", err)
+	}
+
+	sizes, err := store.IVFListSizes()
+	if err != nil || len(sizes) != 16 || sum(sizes) != 500 {
+		t.Fatalf("Expected 500 vectors in 16 lists, got %v, %v", sizes, err)
+	}
+	if stats, _ := store.Stats(); !stats.Indexed {
+		t.Error("Expected stats to report the IVF index")
+	}
+
+	// Probing every list returns the exact results with their metadata.
+	results, err := store.Search(records[42].Vector, 3)
+	if err != nil || len(results) != 3 || results[0].ID != "42" || fmt.Sprint(results[0].Meta["i"]) != "42" {
+		t.Fatalf("Unexpected IVF results: %v, %v", results, err)
+	}
+
+	// Updates move vectors between lists, and deletes remove them.
+	target := []float32{9, -9, 9, -9, 9, -9, 9, -9}
+	if err := store.Add("42", target, nil); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := store.AddBatch([]embedx.Record{{ID: "43", Vector: target}, {ID: "new", Vector: target}, {ID: "bad", Vector: []float32{1}}}, embedx.BatchOptions{}); err == nil {
+		t.Error("Expected the mismatched record to be rejected, got nil")
+	}
+	_ = store.Delete("7")
+	sizes, _ = store.IVFListSizes()
+	if sum(sizes) != 500 {
+		t.Errorf("Expected 500 postings after writes, got %d", sum(sizes))
+	}
+	results, _ = store.Search(target, 3)
+	if len(results) != 3 || results[0].Score < 0.9999 || results[2].Score < 0.9999 {
+		t.Errorf("Expected the three updated vectors first, got %v", results)
+	}
+
+	// A single probed list reads a fraction of the postings.
+	store.SetNProbe(1)
+	if results, _ := store.Search(records[100].Vector, 0); len(results) != 500 {
+		t.Errorf("Expected k <= 0 to read every list, got %d results", len(results))
+	}
+	store.Close()
+
+	// The index persists across reopen and can be rebalanced.
+	store, err = NewBadgerStore(dir)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer store.Close()
+	if st := store.ivf.Load(); st == nil || st.cfg.NProbe != 16 || st.cfg.Lists != 16 {
+		t.Fatalf("Expected the IVF index to be restored, got %+v", st)
+	}
+	if err := store.RebalanceIVF(); err != nil {
+		t.Fatalf("RebalanceIVF failed: %v", err)
+	}
+	if sizes, _ := store.IVFListSizes(); sum(sizes) != 500 {
+		t.Errorf("Expected 500 postings after rebalancing, got %v", sizes)
+	}
+	err = store.db.View(func(txn *badgerdb.Txn) error {
+		list, err := store.readIVFList(txn, "7")
+		if list != -1 {
+			t.Errorf("Expected deleted vector to have no list, got %d", list)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// sum returns the sum of sizes.
+func sum(sizes []int) int {
+	n := 0
+	for _, s := range sizes {
+		n += s
+	}
+	return n
+}
+
+// BenchmarkBadgerStoreIVFSearch compares scanning 5000 128-dimensional
+// vectors with probing 8 of the 64 lists of an IVF index.
+func BenchmarkBadgerStoreIVFSearch(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	for _, name := range []string{"scan", "ivf"} {
+		b.Run(name, func(b *testing.B) {
+			store, err := NewBadgerStore(b.TempDir())
+			if err != nil {
+				b.Fatalf("NewBadgerStore failed: %v", err)
+			}
+			defer store.Close()
+
+			records := make([]embedx.Record, 5000)
+			for i := range records {
+				records[i] = embedx.Record{ID: fmt.Sprint(i), Vector: randomVec(r, 128)}
+			}
+			if err := store.AddBatch(records, embedx.BatchOptions{}); err != nil {
+				b.Fatal(err)
+			}
+			if name == "ivf" {
+				if err := store.TrainIVF(ivf.Config{Lists: 64, NProbe: 8, Seed: 1}); err != nil {
+					b.Fatal(err)
+				}
+			}
+			query := randomVec(r, 128)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := store.Search(query, 10); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,406 @@
+package badger
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/ldaidone/goembedx/pkg/embedx"
+	"github.com/ldaidone/goembedx/vector"
+)
+
+// Quantization key layout.
+//
+// A quantized store keeps the code of every vector under quantCodePrefix
+// followed by the vector ID, next to the full-precision record. Each code
+// value is the little-endian float32 norm of the vector followed by the code:
+// one int8 per component for scalar quantization, one centroid index per
+// subspace for product quantization, or the little-endian words of the bit
+// vector for binary quantization. The kind of quantizer, the rescoring
+// factor and the encoded quantizer or codebooks are stored under
+// quantConfigKey, which is written last when a store is quantized: a store
+// without it is not quantized and any codes left over are ignored.
+const (
+	// quantPrefix groups every key of the quantized vectors.
+	quantPrefix = internalPrefix + "sq/"
+	// quantCodePrefix is followed by the vector ID.
+	quantCodePrefix = quantPrefix + "c/"
+	// quantConfigKey holds the quantizer kind, the rescoring factor and the
+	// encoded quantizer.
+	quantConfigKey = quantPrefix + "config"
+
+	// quantScalar, quantProduct and quantBinary identify the kind of quantizer.
+	quantScalar  = 's'
+	quantProduct = 'p'
+	quantBinary  = 'b'
+)
+
+// quantState is the quantization configuration of a store. Exactly one of
+// sq, pq and bits is set.
+type quantState struct {
+	// sq is the int8 scalar quantizer.
+	sq *vector.ScalarQuantizer
+	// pq is the product quantizer and its codebooks.
+	pq *vector.ProductQuantizer
+	// bits is the dimension of binary-quantized vectors, or 0.
+	bits int
+	// rescore is embedx.QuantizationConfig.Rescore.
+	rescore int
+}
+
+// dim returns the dimension of the vectors the quantizer was trained on.
+func (qs *quantState) dim() int {
+	switch {
+	case qs.pq != nil:
+		return qs.pq.Dim()
+	case qs.bits > 0:
+		return qs.bits
+	default:
+		return qs.sq.Dim()
+	}
+}
+
+// codeLen returns the length of a code in bytes.
+func (qs *quantState) codeLen() int {
+	switch {
+	case qs.pq != nil:
+		return qs.pq.Subspaces()
+	case qs.bits > 0:
+		return 8 * vector.BitWords(qs.bits)
+	default:
+		return qs.sq.Dim()
+	}
+}
+
+// encode quantizes vec and encodes its code together with its norm.
+func (qs *quantState) encode(vec []float32, norm float32) []byte {
+	b := binary.LittleEndian.AppendUint32(make([]byte, 0, 4+qs.codeLen()), math.Float32bits(norm))
+	if qs.pq != nil {
+		return append(b, qs.pq.Encode(nil, vec)...)
+	}
+	if qs.bits > 0 {
+		for _, w := range vector.Binarize(nil, vec) {
+			b = binary.LittleEndian.AppendUint64(b, w)
+		}
+		return b
+	}
+	for _, c := range qs.sq.Encode(nil, vec) {
+		b = append(b, byte(c))
+	}
+	return b
+}
+
+// scorer returns a function scoring codes against query under m. Product codes
+// are scored with an ADC lookup table, and binary codes by the Hamming
+// similarity of the bit vectors, whatever the metric. The function reuses
+// internal buffers and must not be used concurrently.
+func (qs *quantState) scorer(m vector.Metric, query []float32) func(code []byte, norm float32) float32 {
+	if qs.pq != nil {
+		return qs.pq.Table(m, query).Score
+	}
+	if qs.bits > 0 {
+		q := vector.Binarize(nil, query)
+		words := make(vector.BitVector, len(q))
+		return func(code []byte, _ float32) float32 {
+			for i := range words {
+				words[i] = binary.LittleEndian.Uint64(code[8*i:])
+			}
+			return vector.HammingBitsSimilarity(q, words, qs.bits)
+		}
+	}
+	s := qs.sq.Scorer(m, query)
+	codes := make([]int8, qs.sq.Dim())
+	return func(code []byte, norm float32) float32 {
+		for i, b := range code {
+			codes[i] = int8(b)
+		}
+		return s.Score(codes, norm)
+	}
+}
+
+// marshal encodes the configuration for quantConfigKey.
+func (qs *quantState) marshal() ([]byte, error) {
+	var (
+		kind byte = quantScalar
+		b    []byte
+		err  error
+	)
+	switch {
+	case qs.pq != nil:
+		kind = quantProduct
+		b, err = qs.pq.MarshalBinary()
+	case qs.bits > 0:
+		kind = quantBinary
+		b = binary.AppendUvarint(nil, uint64(qs.bits))
+	default:
+		b, err = qs.sq.MarshalBinary()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append(binary.AppendUvarint([]byte{kind}, uint64(qs.rescore)), b...), nil
+}
+
+// unmarshalQuantState decodes a configuration encoded by marshal.
+func unmarshalQuantState(v []byte) (*quantState, error) {
+	if len(v) == 0 {
+		return nil, errShortRecord
+	}
+	rescore, n := binary.Uvarint(v[1:])
+	if n <= 0 {
+		return nil, errShortRecord
+	}
+	qs := &quantState{rescore: int(rescore)}
+	b := v[1+n:]
+	switch v[0] {
+	case quantScalar:
+		qs.sq = new(vector.ScalarQuantizer)
+		return qs, qs.sq.UnmarshalBinary(b)
+	case quantProduct:
+		qs.pq = new(vector.ProductQuantizer)
+		return qs, qs.pq.UnmarshalBinary(b)
+	case quantBinary:
+		dim, n := binary.Uvarint(b)
+		if n <= 0 || dim == 0 {
+			return nil, errShortRecord
+		}
+		qs.bits = int(dim)
+		return qs, nil
+	default:
+		return nil, fmt.Errorf("unknown quantizer kind %q", v[0])
+	}
+}
+
+// quantCodeKey returns the key of the codes of id.
+func (s *BadgerStore) quantCodeKey(id string) []byte {
+	return s.key(quantCodePrefix + id)
+}
+
+// parseCode splits a code value into the norm and the code.
+func parseCode(v []byte) (float32, []byte, error) {
+	if len(v) < 4 {
+		return 0, nil, errShortRecord
+	}
+	return bytesToFloat32(v), v[4:], nil
+}
+
+// loadQuantizer restores the quantization configuration persisted by Quantize.
+func (s *BadgerStore) loadQuantizer() error {
+	return s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(s.key(quantConfigKey))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return item.Value(func(v []byte) error {
+			qs, err := unmarshalQuantState(v)
+			if err != nil {
+				return fmt.Errorf("failed to decode quantizer: %w", err)
+			}
+			s.quant.Store(qs)
+			return nil
+		})
+	})
+}
+
+// Quantize trains a quantizer on the stored vectors, writes the code of every
+// vector and persists the quantizer, so that the store is still quantized
+// when it is reopened. Later writes quantize their vector in the same
+// transaction as the record. The quantizer is int8 scalar quantization,
+// product quantization when cfg.Product is set, in which case the codebooks
+// are trained with k-means on up to cfg.Sample vectors, or binary
+// quantization when cfg.Binary is set, which needs no training.
+//
+// Unfiltered searches of a store without an HNSW index then scan the codes,
+// a fraction of the size of the records, and rescore the best k*cfg.Rescore
+// candidates against the full-precision vectors when cfg.Rescore is positive.
+// Filtered searches still scan the full-precision records. The records are
+// always kept, so Get returns the original vectors.
+//
+// Returns an error wrapping embedx.ErrEmptyStore if the store is empty, and an
+// error if the stored vectors have different dimensions or training fails.
+func (s *BadgerStore) Quantize(cfg embedx.QuantizationConfig) error {
+	if cfg.Rescore < 0 || cfg.Sample < 0 {
+		return errors.New("rescore and sample cannot be negative")
+	}
+	if cfg.Binary && cfg.Product != nil {
+		return errors.New("binary and product quantization are exclusive")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var seed int64 = 1
+	if cfg.Product != nil {
+		seed = cfg.Product.Seed
+	}
+	n := cfg.Sample
+	if cfg.Binary {
+		// Binary quantization only needs the dimension.
+		n = 1
+	}
+	sample, err := s.sampleVectors(n, seed)
+	if err != nil {
+		return fmt.Errorf("cannot quantize: %w", err)
+	}
+
+	qs := &quantState{rescore: cfg.Rescore}
+	switch {
+	case cfg.Binary:
+		qs.bits = len(sample[0])
+		if qs.bits == 0 {
+			err = errors.New("cannot quantize empty vectors")
+		}
+	case cfg.Product != nil:
+		qs.pq, err = vector.TrainProductQuantizer(sample, *cfg.Product)
+	default:
+		qs.sq, err = vector.TrainScalarQuantizer(sample, cfg.Calibration)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Drop the previous configuration before the codes, so that a crash
+	// leaves an unquantized store rather than codes from two quantizers.
+	s.quant.Store(nil)
+	if err := s.db.DropPrefix(s.key(quantPrefix)); err != nil {
+		return err
+	}
+
+	wb := s.db.NewWriteBatch()
+	defer wb.Cancel()
+	err = s.Scan(func(r embedx.Record) error {
+		if len(r.Vector) != qs.dim() {
+			return fmt.Errorf("cannot quantize vector %s of dimension %d, want %d", r.ID, len(r.Vector), qs.dim())
+		}
+		return wb.Set(s.quantCodeKey(r.ID), qs.encode(r.Vector, r.Norm))
+	})
+	if err != nil {
+		return err
+	}
+	if err := wb.Flush(); err != nil {
+		return err
+	}
+
+	config, err := qs.marshal()
+	if err != nil {
+		return err
+	}
+	err = s.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(s.key(quantConfigKey), config)
+	})
+	if err != nil {
+		return err
+	}
+	s.quant.Store(qs)
+	return nil
+}
+
+// searchQuantized scores the codes of every vector against the query, keeps
+// the best k candidates, or k*rescore when rescoring is enabled, and loads
+// their records to rescore them and to read their metadata. It returns
+// ctx.Err() once ctx is done.
+func (s *BadgerStore) searchQuantized(ctx context.Context, qs *quantState, query []float32, k int) ([]embedx.SearchResult, error) {
+	results := make([]embedx.SearchResult, 0)
+	if len(query) != qs.dim() {
+		return results, nil
+	}
+	candidates := k
+	if qs.rescore > 0 {
+		candidates = k * qs.rescore
+	}
+	top := vector.NewTopK(candidates)
+
+	queryNorm := s.computeNorm(query)
+	score := qs.scorer(s.schema.Metric, query)
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = s.key(quantCodePrefix)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		scanned := 0
+		for it.Rewind(); it.Valid(); it.Next() {
+			if err := embedx.CheckContext(ctx, scanned); err != nil {
+				return err
+			}
+			scanned++
+			item := it.Item()
+			err := item.Value(func(v []byte) error {
+				norm, code, err := parseCode(v)
+				if err == nil && len(code) != qs.codeLen() {
+					err = errShortRecord
+				}
+				if err != nil {
+					return fmt.Errorf("failed to decode code of %s: %w", item.Key(), err)
+				}
+				if s.schema.Metric == vector.MetricCosine && (queryNorm == 0 || norm == 0) {
+					return nil
+				}
+				if sc := score(code, norm); top.Admits(sc) {
+					top.Push(vector.Candidate{ID: string(item.Key()[len(opts.Prefix):]), Score: sc})
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		// Rescore the candidates against their records and load their
+		// metadata. Codes whose record is missing, which an interrupted
+		// best-effort batch can leave behind, are dropped.
+		sc := newScorer(s.schema.Metric, query)
+		for i, hit := range top.Results() {
+			if err := embedx.CheckContext(ctx, i); err != nil {
+				return err
+			}
+			item, err := txn.Get(s.key(hit.ID))
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			res := embedx.SearchResult{ID: hit.ID, Score: hit.Score}
+			err = item.Value(func(v []byte) error {
+				r, err := s.viewRecord(item.Key(), v)
+				if err != nil {
+					return err
+				}
+				if qs.rescore > 0 && r.dim == len(query) {
+					res.Score = sc.score(r.precision, r.payload, r.norm)
+				}
+				res.Meta, err = r.metadata()
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			results = append(results, res)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if qs.rescore > 0 {
+		// Keep the best k of the rescored candidates.
+		rescored := vector.NewTopK(k)
+		for i, res := range results {
+			rescored.Push(vector.Candidate{ID: res.ID, Score: res.Score, Pos: i})
+		}
+		hits := rescored.Results()
+		best := make([]embedx.SearchResult, len(hits))
+		for i, hit := range hits {
+			best[i] = results[hit.Pos]
+		}
+		results = best
+	}
+	return results, nil
+}
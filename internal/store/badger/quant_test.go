@@ -0,0 +1,277 @@
+package badger
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	badgerdb "github.com/dgraph-io/badger/v4"
+	"github.com/ldaidone/goembedx/pkg/embedx"
+	"github.com/ldaidone/goembedx/vector"
+)
+
+func TestBadgerStoreQuantize(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewBadgerStore(dir)
+	if err != nil {
+		t.Fatalf("NewBadgerStore failed: %v", err)
+	}
+
+	if err := store.Quantize(embedx.QuantizationConfig{}); err == nil {
+		t.Error("Expected error quantizing an empty store, got nil")
+	}
+	_ = store.Add("a", []float32{1, 0}, map[string]any{"k": "a"})
+	_ = store.Add("b", []float32{0, 1}, nil)
+	_ = store.Add("c", []float32{0.6, 0.8}, nil)
+	if err := store.Quantize(embedx.QuantizationConfig{}); err != nil {
+		t.Fatalf("Quantize failed: %v", err)
+	}
+
+	results, err := store.Search([]float32{1, 0.1}, 2)
+	if err != nil || len(results) != 2 || results[0].ID != "a" || results[1].ID != "c" || results[0].Meta["k"] != "a" {
+		t.Errorf("Unexpected quantized results: %v, %v", results, err)
+	}
+
+	// Writes keep the codes in sync and must match the quantizer.
+	if err := store.Add("d", []float32{1, 2, 3}, nil); err == nil {
+		t.Error("Expected dimension mismatch after quantizing, got nil")
+	}
+	_ = store.Add("d", []float32{0.1, 0.9}, nil)
+	if err := store.AddBatch([]embedx.Record{{ID: "e", Vector: []float32{-1, 0}}, {ID: "f", Vector: []float32{1}}}, embedx.BatchOptions{}); err == nil {
+		t.Error("Expected the mismatched record to be rejected, got nil")
+	}
+	_ = store.Delete("b")
+	if results, _ := store.Search([]float32{0, 1}, 1); len(results) != 1 || results[0].ID != "d" {
+		t.Errorf("Unexpected results after writes: %v", results)
+	}
+	if results, _ := store.Search([]float32{-1, 0}, 1); len(results) != 1 || results[0].ID != "e" {
+		t.Errorf("Expected batch records to be quantized, got %v", results)
+	}
+	if vec, _ := store.GetVector("c"); vec[0] != 0.6 {
+		t.Errorf("Expected full-precision vector from Get, got %v", vec)
+	}
+	store.Close()
+
+	// The quantizer persists across reopen.
+	store, err = NewBadgerStore(dir)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer store.Close()
+	if stats, _ := store.Stats(); !stats.Quantized || stats.Count != 4 {
+		t.Errorf("Unexpected stats after reopen: %+v", stats)
+	}
+
+	// Recalibrating with rescoring returns exact scores.
+	if err := store.Quantize(embedx.QuantizationConfig{Rescore: 2}); err != nil {
+		t.Fatalf("Quantize failed: %v", err)
+	}
+	results, _ = store.Search([]float32{0.6, 0.8}, 1)
+	if len(results) != 1 || results[0].ID != "c" || results[0].Score < 0.9999 {
+		t.Errorf("Expected rescored exact score, got %v", results)
+	}
+	err = store.db.View(func(txn *badgerdb.Txn) error {
+		_, err := txn.Get(store.quantCodeKey("b"))
+		return err
+	})
+	if err == nil {
+		t.Error("Expected the codes of deleted vectors to be removed")
+	}
+}
+
+func TestBadgerStoreProductQuantize(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewBadgerStore(dir)
+	if err != nil {
+		t.Fatalf("NewBadgerStore failed: %v", err)
+	}
+
+	r := rand.New(rand.NewSource(1))
+	records := make([]embedx.Record, 200)
+	for i := range records {
+		records[i] = embedx.Record{ID: fmt.Sprint(i), Vector: randomVec(r, 16)}
+	}
+	if err := store.AddBatch(records, embedx.BatchOptions{}); err != nil {
+		t.Fatalf("AddBatch failed: %v", err)
+	}
+
+	cfg := vector.PQConfig{Subspaces: 4, Centroids: 16, Iterations: 10, Seed: 1}
+	bad := cfg
+	bad.Subspaces = 5
+	if err := store.Quantize(embedx.QuantizationConfig{Product: &bad}); err == nil {
+		t.Error("Expected error for subspaces not dividing the dimension, got nil")
+	}
+	if err := store.Quantize(embedx.QuantizationConfig{Product: &cfg, Sample: 100}); err != nil {
+		t.Fatalf("Quantize failed: %v", err)
+	}
+
+	// Every vector is stored as one byte per subspace after its norm.
+	err = store.db.View(func(txn *badgerdb.Txn) error {
+		item, err := txn.Get(store.quantCodeKey("7"))
+		if err != nil {
+			return err
+		}
+		if item.ValueSize() != int64(4+cfg.Subspaces) {
+			t.Errorf("Expected a %d-byte code value, got %d", 4+cfg.Subspaces, item.ValueSize())
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("reading code failed: %v", err)
+	}
+
+	// A stored vector ranks among the best candidates of its own query.
+	results, err := store.Search(records[7].Vector, 5)
+	if err != nil || len(results) != 5 {
+		t.Fatalf("Unexpected results: %v, %v", results, err)
+	}
+	if !containsID(results, "7") {
+		t.Errorf("Expected 7 among the approximate results, got %v", results)
+	}
+
+	// Writes are encoded with the codebooks.
+	_ = store.Add("new", []float32{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1}, nil)
+	if err := store.Add("short", []float32{1, 2}, nil); err == nil {
+		t.Error("Expected dimension mismatch after quantizing, got nil")
+	}
+	store.Close()
+
+	// The codebooks persist across reopen, and rescoring returns exact scores.
+	store, err = NewBadgerStore(dir)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer store.Close()
+	if qs := store.quant.Load(); qs == nil || qs.pq == nil {
+		t.Fatal("Expected the product quantizer to be restored")
+	}
+	if err := store.Quantize(embedx.QuantizationConfig{Product: &cfg, Rescore: 10}); err != nil {
+		t.Fatalf("Quantize failed: %v", err)
+	}
+	results, _ = store.Search([]float32{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1}, 1)
+	if len(results) != 1 || results[0].ID != "new" || results[0].Score < 0.9999 {
+		t.Errorf("Expected rescored exact match, got %v", results)
+	}
+}
+
+func TestBadgerStoreBinaryQuantize(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewBadgerStore(dir)
+	if err != nil {
+		t.Fatalf("NewBadgerStore failed: %v", err)
+	}
+
+	r := rand.New(rand.NewSource(1))
+	records := make([]embedx.Record, 100)
+	for i := range records {
+		records[i] = embedx.Record{ID: fmt.Sprint(i), Vector: randomVec(r, 100)}
+	}
+	if err := store.AddBatch(records, embedx.BatchOptions{}); err != nil {
+		t.Fatalf("AddBatch failed: %v", err)
+	}
+	if err := store.Quantize(embedx.QuantizationConfig{Binary: true, Product: &vector.DefaultPQConfig}); err == nil {
+		t.Error("Expected error combining binary and product quantization, got nil")
+	}
+	if err := store.Quantize(embedx.QuantizationConfig{Binary: true}); err != nil {
+		t.Fatalf("Quantize failed: %v", err)
+	}
+
+	// Every vector is stored as one bit per dimension after its norm.
+	err = store.db.View(func(txn *badgerdb.Txn) error {
+		item, err := txn.Get(store.quantCodeKey("7"))
+		if err != nil {
+			return err
+		}
+		if want := int64(4 + 8*vector.BitWords(100)); item.ValueSize() != want {
+			t.Errorf("Expected a %d-byte code value, got %d", want, item.ValueSize())
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("reading code failed: %v", err)
+	}
+
+	// A stored vector agrees with itself on every bit.
+	results, err := store.Search(records[7].Vector, 1)
+	if err != nil || len(results) != 1 || results[0].ID != "7" || results[0].Score != 1 {
+		t.Errorf("Expected 7 to match on every bit, got %v, %v", results, err)
+	}
+	if err := store.Add("short", []float32{1, 2}, nil); err == nil {
+		t.Error("Expected dimension mismatch after quantizing, got nil")
+	}
+	store.Close()
+
+	// The mode persists across reopen, and rescoring returns exact scores.
+	store, err = NewBadgerStore(dir)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer store.Close()
+	if qs := store.quant.Load(); qs == nil || qs.bits != 100 {
+		t.Fatal("Expected the binary mode to be restored")
+	}
+	if err := store.Quantize(embedx.QuantizationConfig{Binary: true, Rescore: 10}); err != nil {
+		t.Fatalf("Quantize failed: %v", err)
+	}
+	results, _ = store.Search(records[42].Vector, 1)
+	if len(results) != 1 || results[0].ID != "42" || results[0].Score < 0.9999 {
+		t.Errorf("Expected rescored exact match, got %v", results)
+	}
+}
+
+// containsID reports whether results contain id.
+func containsID(results []embedx.SearchResult, id string) bool {
+	for _, r := range results {
+		if r.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// BenchmarkBadgerStoreQuantizedSearch compares scanning the records of 2000
+// 768-dimensional vectors with scanning their int8, product and binary codes.
+func BenchmarkBadgerStoreQuantizedSearch(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	pq := vector.PQConfig{Subspaces: 96, Centroids: 256, Iterations: 10, Seed: 1}
+	for _, name := range []string{"float32", "int8", "int8-rescore4", "pq", "pq-rescore4", "binary-rescore10"} {
+		b.Run(name, func(b *testing.B) {
+			store, err := NewBadgerStore(b.TempDir())
+			if err != nil {
+				b.Fatalf("NewBadgerStore failed: %v", err)
+			}
+			defer store.Close()
+
+			records := make([]embedx.Record, 2000)
+			for i := range records {
+				records[i] = embedx.Record{ID: fmt.Sprint(i), Vector: randomVec(r, 768)}
+			}
+			if err := store.AddBatch(records, embedx.BatchOptions{}); err != nil {
+				b.Fatal(err)
+			}
+			switch name {
+			case "int8":
+				err = store.Quantize(embedx.QuantizationConfig{})
+			case "int8-rescore4":
+				err = store.Quantize(embedx.QuantizationConfig{Rescore: 4})
+			case "pq":
+				err = store.Quantize(embedx.QuantizationConfig{Product: &pq})
+			case "pq-rescore4":
+				err = store.Quantize(embedx.QuantizationConfig{Product: &pq, Rescore: 4})
+			case "binary-rescore10":
+				err = store.Quantize(embedx.QuantizationConfig{Binary: true, Rescore: 10})
+			}
+			if err != nil {
+				b.Fatal(err)
+			}
+			query := randomVec(r, 768)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := store.Search(query, 10); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package badger
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/ldaidone/goembedx/vector"
+)
+
+// Record layout.
+//
+// Vector records are encoded in a versioned little-endian binary layout:
+//
+//	offset  size  field
+//	0       1     recordMagic
+//	1       1     recordVersion
+//	2       2     flags (recordHasMeta, recordFloat16, recordBFloat16)
+//	4       4     dimension n (uint32)
+//	8       4     norm (float32)
+//	12      sn    vector components: float32 (s=4), or float16 or bfloat16
+//	              (s=2) if the matching flag is set
+//	12+sn   rest  metadata as a gob-encoded map[string]any, if recordHasMeta
+//
+// The vector can be read straight from the value returned by badger without
+// decoding the metadata. Metadata stays gob-encoded so that its Go types
+// (int, float64, string, ...) survive a round trip as they did before. The
+// composite types that JSON and protobuf Struct metadata decode to, and the
+// common slice types and time.Time, are registered with gob so that arrays,
+// nested objects and dates can be stored too.
+// Records written before this layout are gob-encoded vectorData or []float32
+// values; they never start with recordMagic, which is not a valid first byte
+// of a gob stream, and are rewritten in this layout when read.
+// Every record of a store uses the precision recorded in its header, but
+// records carry their own precision flag so that they decode on their own.
+const (
+	// recordMagic marks binary records.
+	recordMagic = 0xE6
+	// recordVersion is the version of the binary record layout.
+	recordVersion = 1
+	// recordHeaderLen is the length of the fixed-size record header.
+	recordHeaderLen = 12
+	// recordHasMeta is set when metadata follows the vector.
+	recordHasMeta = 1 << 0
+	// recordFloat16 is set when the components are float16 values.
+	recordFloat16 = 1 << 1
+	// recordBFloat16 is set when the components are bfloat16 values.
+	recordBFloat16 = 1 << 2
+	// recordKnownFlags holds every flag this version understands.
+	recordKnownFlags = recordHasMeta | recordFloat16 | recordBFloat16
+)
+
+func init() {
+	// gob encodes values held in interfaces only if their type is registered.
+	for _, v := range []any{
+		[]any(nil), map[string]any(nil),
+		[]string(nil), []int(nil), []int64(nil), []float32(nil), []float64(nil), []bool(nil),
+		time.Time{},
+	} {
+		gob.Register(v)
+	}
+}
+
+// errShortRecord is returned for records that end before their header says.
+var errShortRecord = errors.New("record is truncated")
+
+// isBinaryRecord reports whether v is encoded in the binary record layout.
+func isBinaryRecord(v []byte) bool {
+	return len(v) > 0 && v[0] == recordMagic
+}
+
+// encodeRecord encodes data in the binary record layout, with components in precision p.
+func encodeRecord(data vectorData, p vector.Precision) ([]byte, error) {
+	var flags uint16
+	if len(data.Meta) > 0 {
+		flags |= recordHasMeta
+	}
+	switch p {
+	case vector.PrecisionFloat16:
+		flags |= recordFloat16
+	case vector.PrecisionBFloat16:
+		flags |= recordBFloat16
+	}
+
+	b := make([]byte, recordHeaderLen, recordHeaderLen+p.Size()*len(data.Vector))
+	b[0] = recordMagic
+	b[1] = recordVersion
+	binary.LittleEndian.PutUint16(b[2:], flags)
+	binary.LittleEndian.PutUint32(b[4:], uint32(len(data.Vector)))
+	binary.LittleEndian.PutUint32(b[8:], math.Float32bits(data.Norm))
+	b = appendComponents(b, data.Vector, p)
+
+	if flags&recordHasMeta == 0 {
+		return b, nil
+	}
+	buf := bytes.NewBuffer(b)
+	if err := gob.NewEncoder(buf).Encode(data.Meta); err != nil {
+		return nil, fmt.Errorf("failed to encode metadata: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// recordView gives access to the fields of a binary record without copying.
+// It aliases the encoded value and is only valid as long as the value is.
+type recordView struct {
+	// dim is the number of vector components.
+	dim int
+	// norm is the precomputed L2 norm of the vector.
+	norm float32
+	// precision is the encoding of the vector components.
+	precision vector.Precision
+	// payload holds the encoded vector components.
+	payload []byte
+	// meta holds the encoded metadata, or nil.
+	meta []byte
+}
+
+// parseRecord validates the header of the binary record v and returns a view of it.
+func parseRecord(v []byte) (recordView, error) {
+	if len(v) < recordHeaderLen {
+		return recordView{}, errShortRecord
+	}
+	if !isBinaryRecord(v) {
+		return recordView{}, errors.New("not a binary record")
+	}
+	if v[1] != recordVersion {
+		return recordView{}, fmt.Errorf("unsupported record version %d", v[1])
+	}
+
+	flags := binary.LittleEndian.Uint16(v[2:])
+	if flags&^recordKnownFlags != 0 || flags&recordFloat16 != 0 && flags&recordBFloat16 != 0 {
+		return recordView{}, fmt.Errorf("invalid record flags %#x", flags)
+	}
+	p := vector.PrecisionFloat32
+	switch {
+	case flags&recordFloat16 != 0:
+		p = vector.PrecisionFloat16
+	case flags&recordBFloat16 != 0:
+		p = vector.PrecisionBFloat16
+	}
+	dim := binary.LittleEndian.Uint32(v[4:])
+	if uint64(dim)*uint64(p.Size()) > uint64(len(v)-recordHeaderLen) {
+		return recordView{}, errShortRecord
+	}
+	end := recordHeaderLen + p.Size()*int(dim)
+
+	r := recordView{
+		dim:       int(dim),
+		norm:      math.Float32frombits(binary.LittleEndian.Uint32(v[8:])),
+		precision: p,
+		payload:   v[recordHeaderLen:end],
+	}
+	if flags&recordHasMeta != 0 {
+		r.meta = v[end:]
+	}
+	return r, nil
+}
+
+// vector decodes the vector into dst, growing it if needed, and returns it.
+// Passing the previous result as dst lets a scan decode every record into the
+// same buffer.
+func (r recordView) vector(dst []float32) []float32 {
+	if cap(dst) < r.dim {
+		dst = make([]float32, r.dim)
+	}
+	return readComponents(dst[:r.dim], r.payload, r.precision)
+}
+
+// metadata decodes the metadata of the record, or returns nil if it has none.
+func (r recordView) metadata() (map[string]any, error) {
+	if r.meta == nil {
+		return nil, nil
+	}
+	var meta map[string]any
+	if err := gob.NewDecoder(bytes.NewReader(r.meta)).Decode(&meta); err != nil {
+		return nil, fmt.Errorf("failed to decode metadata: %w", err)
+	}
+	return meta, nil
+}
+
+// decodeRecord decodes the binary record v into a vectorData that does not alias v.
+func decodeRecord(v []byte) (vectorData, error) {
+	r, err := parseRecord(v)
+	if err != nil {
+		return vectorData{}, err
+	}
+	meta, err := r.metadata()
+	if err != nil {
+		return vectorData{}, err
+	}
+	return vectorData{Vector: r.vector(nil), Norm: r.norm, Meta: meta}, nil
+}
+
+// scorer scores encoded vectors against a query under a metric, decoding
+// every vector into the same buffer. Decoding half-precision components and
+// scoring them with the float32 kernels is faster than the mixed-precision
+// kernels of the vector package, which would need the components copied out
+// of the value first.
+//
+// A scorer must not be used concurrently.
+type scorer struct {
+	metric vector.Metric
+	query  []float32
+	norm   float32
+	buf    []float32
+}
+
+// newScorer returns a scorer of vectors against query under m.
+func newScorer(m vector.Metric, query []float32) *scorer {
+	return &scorer{metric: m, query: query, norm: vector.Norm(query)}
+}
+
+// score returns the similarity of the query and the vector of len(query)
+// components encoded in precision p in payload, whose L2 norm is norm.
+// Cosine scores involving a zero-magnitude vector are 0.
+func (sc *scorer) score(p vector.Precision, payload []byte, norm float32) float32 {
+	if cap(sc.buf) < len(sc.query) {
+		sc.buf = make([]float32, len(sc.query))
+	}
+	sc.buf = readComponents(sc.buf[:len(sc.query)], payload, p)
+	return sc.metric.ScoreNorms(sc.query, sc.buf, sc.norm, norm)
+}
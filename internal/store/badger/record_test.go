@@ -0,0 +1,215 @@
+package badger
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"math"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ldaidone/goembedx/vector"
+)
+
+func TestRecordEncoding(t *testing.T) {
+	tests := []vectorData{
+		{Vector: []float32{1, -2.5, 3}, Norm: 4},
+		{Vector: []float32{0.5}, Norm: 0.5, Meta: map[string]any{"tenant": "acme", "date": 20240110, "score": 0.75, "ok": true}},
+		{Vector: []float32{}},
+		// Arrays and nested objects, as decoded from JSON or a protobuf Struct.
+		{Vector: []float32{1}, Norm: 1, Meta: map[string]any{
+			"tags":   []any{"a", 1.5, true, nil},
+			"author": map[string]any{"name": "ann", "ids": []any{1.0, 2.0}, "org": map[string]any{"id": "x"}},
+			"ints":   []int{1, 2},
+			"names":  []string{"b", "c"},
+		}},
+		{Vector: []float32{1}, Norm: 1, Meta: map[string]any{"created": time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)}},
+	}
+
+	for _, data := range tests {
+		v, err := encodeRecord(data, vector.PrecisionFloat32)
+		if err != nil {
+			t.Fatalf("encodeRecord failed: %v", err)
+		}
+		if !isBinaryRecord(v) {
+			t.Fatal("Expected encoded record to be recognized as binary")
+		}
+		got, err := decodeRecord(v)
+		if err != nil {
+			t.Fatalf("decodeRecord failed: %v", err)
+		}
+		if len(data.Meta) == 0 {
+			data.Meta = nil
+		}
+		if !slices.Equal(got.Vector, data.Vector) || got.Norm != data.Norm || !reflect.DeepEqual(got.Meta, data.Meta) {
+			t.Errorf("Round trip changed the record: got %+v, want %+v", got, data)
+		}
+	}
+}
+
+func TestRecordViewReusesBuffer(t *testing.T) {
+	v, _ := encodeRecord(vectorData{Vector: []float32{1, 2, 3}, Norm: 1, Meta: map[string]any{"k": "v"}}, vector.PrecisionFloat32)
+	r, err := parseRecord(v)
+	if err != nil {
+		t.Fatalf("parseRecord failed: %v", err)
+	}
+	if r.dim != 3 || r.norm != 1 || r.meta == nil {
+		t.Errorf("Unexpected view: %+v", r)
+	}
+
+	buf := make([]float32, 0, 8)
+	got := r.vector(buf)
+	if &got[0] != &buf[:1][0] || !reflect.DeepEqual(got, []float32{1, 2, 3}) {
+		t.Errorf("Expected the vector to be decoded into the given buffer, got %v", got)
+	}
+	if allocs := testing.AllocsPerRun(100, func() {
+		r, _ := parseRecord(v)
+		buf = r.vector(buf)
+	}); allocs != 0 {
+		t.Errorf("Expected no allocations when viewing a record, got %v", allocs)
+	}
+}
+
+func TestParseRecordErrors(t *testing.T) {
+	v, _ := encodeRecord(vectorData{Vector: []float32{1, 2}, Norm: 1}, vector.PrecisionFloat32)
+
+	if _, err := parseRecord(v[:recordHeaderLen+4]); !errors.Is(err, errShortRecord) {
+		t.Errorf("Expected errShortRecord for a truncated payload, got %v", err)
+	}
+	if _, err := parseRecord(v[:4]); !errors.Is(err, errShortRecord) {
+		t.Errorf("Expected errShortRecord for a truncated header, got %v", err)
+	}
+	bumped := append([]byte(nil), v...)
+	bumped[1] = recordVersion + 1
+	if _, err := parseRecord(bumped); err == nil {
+		t.Error("Expected error for an unknown version, got nil")
+	}
+
+	// Gob streams never look like binary records.
+	var buf bytes.Buffer
+	_ = gob.NewEncoder(&buf).Encode(vectorData{Vector: make([]float32, 300)})
+	if isBinaryRecord(buf.Bytes()) {
+		t.Error("Expected gob record not to be recognized as binary")
+	}
+}
+
+func TestRecordPrecision(t *testing.T) {
+	data := vectorData{Vector: []float32{1, -2.5, 0.1}, Norm: 3, Meta: map[string]any{"k": "v"}}
+	for _, p := range []vector.Precision{vector.PrecisionFloat16, vector.PrecisionBFloat16} {
+		v, err := encodeRecord(data, p)
+		if err != nil {
+			t.Fatalf("encodeRecord failed: %v", err)
+		}
+		r, err := parseRecord(v)
+		if err != nil {
+			t.Fatalf("parseRecord failed: %v", err)
+		}
+		if r.precision != p || len(r.payload) != 2*3 || r.meta == nil {
+			t.Errorf("%s: unexpected view %+v", p, r)
+		}
+		if got, want := r.vector(nil), p.Round(nil, data.Vector); !slices.Equal(got, want) {
+			t.Errorf("%s: expected rounded vector %v, got %v", p, want, got)
+		}
+	}
+
+	v, _ := encodeRecord(data, vector.PrecisionFloat16)
+	v[2] |= recordBFloat16
+	if _, err := parseRecord(v); err == nil {
+		t.Error("Expected error for conflicting precision flags, got nil")
+	}
+	v[2] = recordHasMeta | 1<<5
+	if _, err := parseRecord(v); err == nil {
+		t.Error("Expected error for unknown flags, got nil")
+	}
+}
+
+func TestScorer(t *testing.T) {
+	query := []float32{0.5, -1, 2, 0.25, 3}
+	vec := []float32{1, 2, -0.5, 4, 0.1}
+	for _, p := range []vector.Precision{vector.PrecisionFloat32, vector.PrecisionFloat16, vector.PrecisionBFloat16} {
+		stored := p.Round(nil, vec)
+		payload := appendComponents(nil, vec, p)
+		norm := vector.Norm(stored)
+		for _, m := range []vector.Metric{vector.MetricCosine, vector.MetricDot, vector.MetricEuclidean} {
+			got := newScorer(m, query).score(p, payload, norm)
+			if want := m.ScoreNorms(query, stored, vector.Norm(query), norm); math.Abs(float64(got-want)) > 1e-5 {
+				t.Errorf("%s %s: score = %v, want %v", p, m, got, want)
+			}
+		}
+	}
+	if got := newScorer(vector.MetricCosine, query).score(vector.PrecisionFloat16, appendComponents(nil, vec, vector.PrecisionFloat16), 0); got != 0 {
+		t.Errorf("Expected 0 for a zero norm, got %v", got)
+	}
+}
+
+func TestFloat32Conversions(t *testing.T) {
+	f := []float32{1, -0.5, 3.25}
+	if got := bytesToFloat32Slice(float32SliceToBytes(f)); !reflect.DeepEqual(got, f) {
+		t.Errorf("Expected %v, got %v", f, got)
+	}
+	if got := bytesToFloat32(float32ToBytes(-7.5)); got != -7.5 {
+		t.Errorf("Expected -7.5, got %v", got)
+	}
+	if got := bytesToFloat32Slice([]byte{0, 0, 128, 63, 1}); !reflect.DeepEqual(got, []float32{1}) {
+		t.Errorf("Expected trailing bytes to be ignored, got %v", got)
+	}
+}
+
+// benchRecords encodes n 768-dimensional records with metadata in both layouts.
+func benchRecords(b *testing.B, n int) (gobs, binaries [][]byte) {
+	b.Helper()
+	for i := 0; i < n; i++ {
+		vec := make([]float32, 768)
+		for j := range vec {
+			vec[j] = float32((i+j)%31) / 31
+		}
+		data := vectorData{Vector: vec, Norm: 1, Meta: map[string]any{"tenant": "acme", "n": i}}
+
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(data); err != nil {
+			b.Fatal(err)
+		}
+		gobs = append(gobs, buf.Bytes())
+
+		v, err := encodeRecord(data, vector.PrecisionFloat32)
+		if err != nil {
+			b.Fatal(err)
+		}
+		binaries = append(binaries, v)
+	}
+	return gobs, binaries
+}
+
+// BenchmarkRecordScan decodes the vectors of 1000 records the way a
+// brute-force search does, in the legacy gob layout and the binary layout.
+func BenchmarkRecordScan(b *testing.B) {
+	gobs, binaries := benchRecords(b, 1000)
+
+	b.Run("gob", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, v := range gobs {
+				var data vectorData
+				if err := gob.NewDecoder(bytes.NewReader(v)).Decode(&data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+
+	b.Run("binary", func(b *testing.B) {
+		b.ReportAllocs()
+		var buf []float32
+		for i := 0; i < b.N; i++ {
+			for _, v := range binaries {
+				r, err := parseRecord(v)
+				if err != nil {
+					b.Fatal(err)
+				}
+				buf = r.vector(buf)
+			}
+		}
+	})
+}
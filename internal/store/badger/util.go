@@ -1,21 +1,81 @@
 package badger
 
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/ldaidone/goembedx/vector"
+)
+
+// float32SliceToBytes encodes f as little-endian float32 values.
 func float32SliceToBytes(f []float32) []byte {
-	// TODO
-	return nil
+	return appendFloat32s(make([]byte, 0, 4*len(f)), f)
 }
 
+// bytesToFloat32Slice decodes little-endian float32 values from b.
+// Trailing bytes that do not form a whole value are ignored.
 func bytesToFloat32Slice(b []byte) []float32 {
-	// TODO
-	return nil
+	return readFloat32s(make([]float32, len(b)/4), b)
 }
 
+// float32ToBytes encodes f as a little-endian float32.
 func float32ToBytes(f float32) []byte {
-	// TODO
-	return nil
+	return binary.LittleEndian.AppendUint32(nil, math.Float32bits(f))
 }
 
+// bytesToFloat32 decodes a little-endian float32 from the first 4 bytes of b.
 func bytesToFloat32(b []byte) float32 {
-	// TODO
-	return 0
+	return math.Float32frombits(binary.LittleEndian.Uint32(b))
+}
+
+// appendFloat32s appends f to b as little-endian float32 values.
+func appendFloat32s(b []byte, f []float32) []byte {
+	for _, x := range f {
+		b = binary.LittleEndian.AppendUint32(b, math.Float32bits(x))
+	}
+	return b
+}
+
+// readFloat32s decodes len(dst) little-endian float32 values from b into dst
+// and returns dst. b must hold at least 4*len(dst) bytes.
+func readFloat32s(dst []float32, b []byte) []float32 {
+	for i := range dst {
+		dst[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	return dst
+}
+
+// appendComponents appends f to b as little-endian values of precision p.
+func appendComponents(b []byte, f []float32, p vector.Precision) []byte {
+	switch p {
+	case vector.PrecisionFloat16:
+		for _, x := range f {
+			b = binary.LittleEndian.AppendUint16(b, uint16(vector.ToFloat16(x)))
+		}
+	case vector.PrecisionBFloat16:
+		for _, x := range f {
+			b = binary.LittleEndian.AppendUint16(b, uint16(vector.ToBFloat16(x)))
+		}
+	default:
+		b = appendFloat32s(b, f)
+	}
+	return b
+}
+
+// readComponents decodes len(dst) little-endian values of precision p from b
+// into dst and returns dst. b must hold at least p.Size()*len(dst) bytes.
+func readComponents(dst []float32, b []byte, p vector.Precision) []float32 {
+	switch p {
+	case vector.PrecisionFloat16:
+		for i := range dst {
+			dst[i] = vector.Float16(binary.LittleEndian.Uint16(b[2*i:])).Float32()
+		}
+	case vector.PrecisionBFloat16:
+		for i := range dst {
+			dst[i] = vector.BFloat16(binary.LittleEndian.Uint16(b[2*i:])).Float32()
+		}
+	default:
+		readFloat32s(dst, b)
+	}
+	return dst
 }
@@ -4,61 +4,621 @@
 package memory
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"github.com/ldaidone/goembedx/pkg/embedx"
 	"github.com/ldaidone/goembedx/vector"
+	"iter"
+	"maps"
+	"slices"
+	"sync"
 )
 
-// Vector represents a stored vector with its identifier and precomputed norm.
-// The norm is stored to enable efficient similarity calculations.
+// Vector represents a stored vector with its identifier and precomputed norm,
+// as returned by Data.
 type Vector struct {
 	// ID is the unique identifier for this vector.
 	ID string
 	// Val contains the actual float32 vector data.
+	// It is nil when the store is quantized without rescoring.
 	Val []float32
+	// Code contains the int8 codes of the vector when the store is quantized.
+	Code []int8
+	// Bits contains the binary code of the vector when the store is
+	// binary-quantized.
+	Bits vector.BitVector
 	// Norm is the precomputed L2 norm of the vector for efficient similarity calculations.
 	Norm float32
+	// Meta contains optional metadata associated with the vector.
+	Meta map[string]any
 }
 
 // MemoryStore is an in-memory vector container optimized for read-heavy workloads.
-// It maintains vectors of fixed dimension and precomputes their norms for fast similarity searches.
-// Note: This implementation is not thread-safe; callers must synchronize access if used concurrently.
+// It maintains vectors of fixed dimension in a vector.Arena, one contiguous
+// block with their precomputed norms, so that searches stream them through
+// Arena.Search. Metadata is kept on the side, keyed by vector ID.
+// It is safe for concurrent use; searches share a read lock.
+// Methods with a Context suffix stop their work and return the context's
+// error once it is done; scans check it every embedx.ContextCheckInterval vectors.
 type MemoryStore struct {
+	mu sync.RWMutex
 	// dim specifies the required dimension for all vectors in this store.
 	dim int
-	// data contains the slice of stored vectors.
-	data []Vector
+	// data holds the full-precision vectors. It is empty when the store is
+	// quantized without rescoring, and vectors are decoded from codes.
+	data *vector.Arena
+	// meta holds the metadata of the vectors stored with any, keyed by vector ID.
+	meta map[string]map[string]any
+	// metric scores vectors in Search and SearchWithFilter.
+	metric vector.Metric
+	// quant is the int8 quantizer set by Quantize, or nil.
+	quant *vector.ScalarQuantizer
+	// codes holds the int8 codes of the vectors, keyed by vector ID, when
+	// quant is set.
+	codes map[string][]int8
+	// norms holds the full-precision norms of the vectors, keyed by vector
+	// ID, when quant is set.
+	norms map[string]float32
+	// bits holds the binary codes of the vectors, keyed by vector ID, when
+	// the store is binary-quantized. data is always kept.
+	bits map[string]vector.BitVector
+	// binary reports whether the store is binary-quantized.
+	binary bool
+	// rescore is embedx.QuantizationConfig.Rescore.
+	rescore int
 }
 
 // NewMemoryStore creates a new in-memory vector store for vectors of the specified dimension.
 // The dimension must be greater than 0 and all vectors added to this store must match this dimension.
+//
+// This function will panic if dim is not positive.
 func NewMemoryStore(dim int) *MemoryStore {
 	return &MemoryStore{
 		dim:  dim,
-		data: make([]Vector, 0),
+		data: vector.NewArena(dim),
+		meta: make(map[string]map[string]any),
 	}
 }
 
+// NewMemoryStoreWithMetric creates a new in-memory vector store for vectors of the
+// specified dimension that ranks search results with the given metric.
+func NewMemoryStoreWithMetric(dim int, metric vector.Metric) *MemoryStore {
+	s := NewMemoryStore(dim)
+	s.metric = metric
+	return s
+}
+
 // Dim returns the dimensionality constraint of this store.
 // All vectors in this store have this same dimension.
 func (s *MemoryStore) Dim() int { return s.dim }
 
-// Add inserts a vector with the given ID into the store.
+// Add stores a vector with the given ID, replacing any vector stored under the same ID.
 // It precomputes the L2 norm of the vector for efficient similarity calculations.
-// Returns an error if the vector dimension doesn't match the store's dimension constraint.
+// Returns an error wrapping embedx.ErrInvalidID if the ID is empty, or a
+// *embedx.DimensionError if the vector dimension doesn't match the store's dimension constraint.
 func (s *MemoryStore) Add(id string, vec []float32) error {
+	return s.AddWithMeta(id, vec, nil)
+}
+
+// AddContext is like Add but returns ctx.Err() if ctx is done.
+func (s *MemoryStore) AddContext(ctx context.Context, id string, vec []float32) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.AddWithMeta(id, vec, nil)
+}
+
+// AddWithMeta stores a vector with the given ID and associated metadata,
+// replacing any vector stored under the same ID.
+// It precomputes the L2 norm of the vector for efficient similarity calculations.
+// Returns an error wrapping embedx.ErrInvalidID if the ID is empty, or a
+// *embedx.DimensionError if the vector dimension doesn't match the store's dimension constraint.
+func (s *MemoryStore) AddWithMeta(id string, vec []float32, meta map[string]any) error {
+	if err := s.validate(id, vec); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.put(id, vec, meta)
+	return nil
+}
+
+// validate checks id and vec against the constraints of the store.
+func (s *MemoryStore) validate(id string, vec []float32) error {
+	if id == "" {
+		return fmt.Errorf("%w: id cannot be empty", embedx.ErrInvalidID)
+	}
+	if len(vec) == 0 {
+		return errors.New("store: vector cannot be empty")
+	}
 	if len(vec) != s.dim {
-		return errors.New("store: vector dimension mismatch")
+		return &embedx.DimensionError{Expected: s.dim, Actual: len(vec)}
+	}
+	return nil
+}
+
+// put stores vec and a copy of the top level of meta under id, quantized if
+// the store is quantized, replacing any vector stored under it.
+// The caller must hold s.mu.
+func (s *MemoryStore) put(id string, vec []float32, meta map[string]any) {
+	if s.quant != nil {
+		s.codes[id] = s.quant.Encode(s.codes[id], vec)
+		s.norms[id] = vector.Norm(vec)
+	}
+	if s.binary {
+		s.bits[id] = vector.Binarize(s.bits[id], vec)
+	}
+	if s.quant == nil || s.rescore > 0 {
+		s.data.Set(id, vec)
+	}
+	if meta != nil {
+		s.meta[id] = maps.Clone(meta)
+	} else {
+		delete(s.meta, id)
+	}
+}
+
+// Upsert stores a vector with the given ID and associated metadata according to mode,
+// replacing any vector stored under the same ID.
+// Returns an error wrapping embedx.ErrAlreadyExists or embedx.ErrNotFound when mode
+// forbids the write, an error wrapping embedx.ErrInvalidID if the ID is empty, or a
+// *embedx.DimensionError if the vector dimension doesn't match the store's dimension constraint.
+func (s *MemoryStore) Upsert(id string, vec []float32, meta map[string]any, mode embedx.UpsertMode) error {
+	return s.UpsertContext(context.Background(), id, vec, meta, mode)
+}
+
+// UpsertContext is like Upsert but returns ctx.Err() if ctx is done.
+func (s *MemoryStore) UpsertContext(ctx context.Context, id string, vec []float32, meta map[string]any, mode embedx.UpsertMode) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := s.validate(id, vec); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := embedx.CheckUpsertMode(id, mode, s.has(id)); err != nil {
+		return err
+	}
+	s.put(id, vec, meta)
+	return nil
+}
+
+// AddBatch stores many vectors with their metadata according to opts.Mode,
+// as if Upsert were called for each record in order. When opts.Atomic is set
+// and any record is rejected, the store is left unchanged. Returns a
+// *embedx.BatchError listing the rejected records.
+func (s *MemoryStore) AddBatch(records []embedx.Record, opts embedx.BatchOptions) error {
+	return s.AddBatchContext(context.Background(), records, opts)
+}
+
+// AddBatchContext is like AddBatch but returns ctx.Err() if ctx is done
+// before the records are validated, in which case nothing is written.
+func (s *MemoryStore) AddBatchContext(ctx context.Context, records []embedx.Record, opts embedx.BatchOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var failed []embedx.ItemError
+	accepted := make([]int, 0, len(records))
+	pending := make(map[string]bool, len(records))
+	for i, r := range records {
+		if err := embedx.CheckContext(ctx, i); err != nil {
+			return err
+		}
+		err := s.validate(r.ID, r.Vector)
+		if err == nil {
+			err = embedx.CheckUpsertMode(r.ID, opts.Mode, pending[r.ID] || s.has(r.ID))
+		}
+		if err != nil {
+			failed = append(failed, embedx.ItemError{Index: i, ID: r.ID, Err: err})
+			continue
+		}
+		pending[r.ID] = true
+		accepted = append(accepted, i)
+	}
+
+	if opts.Atomic && len(failed) > 0 {
+		return embedx.NewBatchError(failed, 0)
+	}
+	for _, i := range accepted {
+		s.put(records[i].ID, records[i].Vector, records[i].Meta)
+	}
+	return embedx.NewBatchError(failed, len(accepted))
+}
+
+// Delete removes the vector stored under the given ID.
+// Returns an error wrapping embedx.ErrNotFound if the ID is not stored.
+func (s *MemoryStore) Delete(id string) error {
+	return s.DeleteContext(context.Background(), id)
+}
+
+// DeleteContext is like Delete but returns ctx.Err() if ctx is done.
+func (s *MemoryStore) DeleteContext(ctx context.Context, id string) error {
+	n, err := s.DeleteManyContext(ctx, []string{id})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("%w: %s", embedx.ErrNotFound, id)
+	}
+	return nil
+}
+
+// DeleteMany removes the vectors stored under any of the given IDs,
+// skipping IDs that are not stored. Returns the number of vectors removed.
+func (s *MemoryStore) DeleteMany(ids []string) (int, error) {
+	return s.DeleteManyContext(context.Background(), ids)
+}
+
+// DeleteManyContext is like DeleteMany but returns ctx.Err() if ctx is done
+// before the vectors are removed, in which case none is removed.
+func (s *MemoryStore) DeleteManyContext(ctx context.Context, ids []string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for _, id := range ids {
+		if s.remove(id) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// has reports whether a vector is stored under id.
+// The caller must hold s.mu.
+func (s *MemoryStore) has(id string) bool {
+	if s.quant != nil {
+		_, ok := s.codes[id]
+		return ok
+	}
+	_, _, ok := s.data.Get(id)
+	return ok
+}
+
+// remove deletes the vector stored under id and reports whether it was stored.
+// The caller must hold s.mu.
+func (s *MemoryStore) remove(id string) bool {
+	ok := s.has(id)
+	s.data.Delete(id)
+	delete(s.codes, id)
+	delete(s.norms, id)
+	delete(s.bits, id)
+	delete(s.meta, id)
+	return ok
+}
+
+// ids returns the IDs of the stored vectors.
+// The caller must hold s.mu.
+func (s *MemoryStore) ids() iter.Seq[string] {
+	if s.quant != nil {
+		return maps.Keys(s.codes)
+	}
+	return slices.Values(s.data.IDs())
+}
+
+// len returns the number of stored vectors.
+// The caller must hold s.mu.
+func (s *MemoryStore) len() int {
+	if s.quant != nil {
+		return len(s.codes)
+	}
+	return s.data.Len()
+}
+
+// vectorOf returns a copy of the vector stored under id, decoded from its
+// codes if the full-precision vector was dropped, and its norm.
+// The caller must hold s.mu.
+func (s *MemoryStore) vectorOf(id string) ([]float32, float32, bool) {
+	if vec, norm, ok := s.data.Get(id); ok {
+		return slices.Clone(vec), norm, true
+	}
+	if code, ok := s.codes[id]; ok {
+		return s.quant.Decode(nil, code), s.norms[id], true
+	}
+	return nil, 0, false
+}
+
+// normOf returns the full-precision norm of the vector stored under id.
+// The caller must hold s.mu.
+func (s *MemoryStore) normOf(id string) float32 {
+	if s.quant != nil {
+		return s.norms[id]
+	}
+	_, norm, _ := s.data.Get(id)
+	return norm
+}
+
+// Search returns the top-k stored vectors most similar to the query under the
+// store's metric (cosine by default), sorted by score in descending order and
+// by ID among equal scores. If k <= 0, every result is returned.
+// Once the store is quantized, scores are computed from the int8 codes, or
+// are Hamming similarities of the bit vectors, and are approximate unless
+// rescoring is enabled.
+// Returns a *embedx.DimensionError if the query dimension doesn't match the store's dimension constraint.
+func (s *MemoryStore) Search(query []float32, k int) ([]embedx.SearchResult, error) {
+	return s.SearchWithFilterContext(context.Background(), query, k, nil)
+}
+
+// SearchContext is like Search but stops scanning and returns ctx.Err() once
+// ctx is done.
+func (s *MemoryStore) SearchContext(ctx context.Context, query []float32, k int) ([]embedx.SearchResult, error) {
+	return s.SearchWithFilterContext(ctx, query, k, nil)
+}
+
+// SearchWithFilter performs Search restricted to vectors whose metadata matches filter.
+// A nil filter matches every vector.
+func (s *MemoryStore) SearchWithFilter(query []float32, k int, filter embedx.Filter) ([]embedx.SearchResult, error) {
+	return s.SearchWithFilterContext(context.Background(), query, k, filter)
+}
+
+// SearchWithFilterContext is like SearchWithFilter but stops scanning and
+// returns ctx.Err() once ctx is done.
+func (s *MemoryStore) SearchWithFilterContext(ctx context.Context, query []float32, k int, filter embedx.Filter) ([]embedx.SearchResult, error) {
+	if len(query) != s.dim {
+		return nil, &embedx.DimensionError{Expected: s.dim, Actual: len(query)}
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.quant != nil || s.binary {
+		return s.searchQuantized(ctx, query, k, filter)
+	}
+
+	var keep func(id string) bool
+	if filter != nil {
+		keep = func(id string) bool { return embedx.MatchFilter(filter, s.meta[id]) }
+	}
+	hits, err := s.data.Search(ctx, query, k, s.metric, keep)
+	if err != nil {
+		return nil, err
+	}
+	return s.searchResults(hits), nil
+}
+
+// SearchBatch performs Search for many queries at once and returns their
+// results in query order. The arena is read once for the whole batch with
+// vector.Arena.SearchBatch, so a batch is much faster than as many calls to
+// Search. Quantized stores search the queries one by one.
+// Returns an error wrapping a *embedx.DimensionError if a query dimension
+// doesn't match the store's dimension constraint.
+func (s *MemoryStore) SearchBatch(queries [][]float32, k int) ([][]embedx.SearchResult, error) {
+	return s.SearchBatchContext(context.Background(), queries, k)
+}
+
+// SearchBatchContext is like SearchBatch but stops scanning and returns
+// ctx.Err() once ctx is done.
+func (s *MemoryStore) SearchBatchContext(ctx context.Context, queries [][]float32, k int) ([][]embedx.SearchResult, error) {
+	for i, q := range queries {
+		if len(q) != s.dim {
+			return nil, fmt.Errorf("query %d: %w", i, &embedx.DimensionError{Expected: s.dim, Actual: len(q)})
+		}
+	}
+	results := make([][]embedx.SearchResult, len(queries))
+	if len(queries) == 0 {
+		return results, nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.quant != nil || s.binary {
+		for i, q := range queries {
+			r, err := s.searchQuantized(ctx, q, k, nil)
+			if err != nil {
+				return nil, err
+			}
+			results[i] = r
+		}
+		return results, nil
+	}
+
+	hits, err := s.data.SearchBatch(ctx, queries, k, s.metric)
+	if err != nil {
+		return nil, err
+	}
+	for i := range hits {
+		results[i] = s.searchResults(hits[i])
+	}
+	return results, nil
+}
+
+// searchResults returns the search results of hits with a copy of their metadata.
+// The caller must hold s.mu.
+func (s *MemoryStore) searchResults(hits []vector.Candidate) []embedx.SearchResult {
+	results := make([]embedx.SearchResult, len(hits))
+	for i, hit := range hits {
+		results[i] = embedx.SearchResult{ID: hit.ID, Score: hit.Score, Meta: maps.Clone(s.meta[hit.ID])}
+	}
+	return results
+}
+
+// searchQuantized scores the quantized vectors against the query and, when
+// rescoring is enabled, rescores the best k*s.rescore candidates against the
+// full-precision vectors. It returns ctx.Err() once ctx is done.
+// The caller must hold s.mu.
+func (s *MemoryStore) searchQuantized(ctx context.Context, query []float32, k int, filter embedx.Filter) ([]embedx.SearchResult, error) {
+	var score func(id string, norm float32) float32
+	if s.binary {
+		q := vector.Binarize(nil, query)
+		score = func(id string, _ float32) float32 {
+			return vector.HammingBitsSimilarity(q, s.bits[id], s.dim)
+		}
+	} else {
+		scorer := s.quant.Scorer(s.metric, query)
+		score = func(id string, norm float32) float32 {
+			return scorer.Score(s.codes[id], norm)
+		}
+	}
+
+	qn := vector.Norm(query)
+	candidates := k
+	if s.rescore > 0 {
+		candidates = k * s.rescore
+	}
+	top := vector.NewTopK(candidates)
+	n := 0
+	for id := range s.ids() {
+		if err := embedx.CheckContext(ctx, n); err != nil {
+			return nil, err
+		}
+		n++
+		if !embedx.MatchFilter(filter, s.meta[id]) {
+			continue
+		}
+		norm := s.normOf(id)
+		if s.metric == vector.MetricCosine && (qn == 0 || norm == 0) {
+			continue
+		}
+		top.Push(vector.Candidate{ID: id, Score: score(id, norm)})
+	}
+	hits := top.Results()
+
+	if s.rescore > 0 {
+		rescored := vector.NewTopK(k)
+		for i, hit := range hits {
+			if err := embedx.CheckContext(ctx, i); err != nil {
+				return nil, err
+			}
+			vec, norm, _ := s.data.Get(hit.ID)
+			hit.Score = s.metric.ScoreNorms(query, vec, qn, norm)
+			rescored.Push(hit)
+		}
+		hits = rescored.Results()
+	}
+	return s.searchResults(hits), nil
+}
+
+// Quantize calibrates an int8 quantizer on the stored vectors and quantizes
+// them, or binarizes them when cfg.Binary is set. Later searches score the
+// codes, and rescore the best k*cfg.Rescore candidates against the
+// full-precision vectors when cfg.Rescore is positive. With int8
+// quantization and cfg.Rescore 0, the full-precision vectors are dropped to
+// save memory.
+// Returns an error wrapping embedx.ErrEmptyStore if the store is empty, and an
+// error if product quantization is requested, or full-precision vectors are needed after an earlier Quantize dropped them.
+func (s *MemoryStore) Quantize(cfg embedx.QuantizationConfig) error {
+	if cfg.Rescore < 0 || cfg.Sample < 0 {
+		return errors.New("store: rescore and sample cannot be negative")
+	}
+	if cfg.Product != nil {
+		return errors.New("store: product quantization is not supported by in-memory stores")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if (cfg.Rescore > 0 || cfg.Binary) && s.quant != nil && s.rescore == 0 {
+		return errors.New("store: full-precision vectors were dropped by an earlier Quantize")
+	}
+	if s.len() == 0 {
+		return fmt.Errorf("store: cannot quantize: %w", embedx.ErrEmptyStore)
+	}
+
+	ids := slices.Collect(s.ids())
+	vecs := make([][]float32, len(ids))
+	norms := make([]float32, len(ids))
+	sample := vector.NewReservoir(cfg.Sample, 1)
+	for i, id := range ids {
+		vecs[i], norms[i], _ = s.vectorOf(id)
+		sample.Add(vecs[i])
+	}
+	if cfg.Binary {
+		s.quant, s.codes, s.norms = nil, nil, nil
+		s.binary, s.rescore = true, cfg.Rescore
+		s.bits = make(map[string]vector.BitVector, len(ids))
+		for i, id := range ids {
+			s.bits[id] = vector.Binarize(nil, vecs[i])
+		}
+		return nil
+	}
+	q, err := vector.TrainScalarQuantizer(sample.Sample(), cfg.Calibration)
+	if err != nil {
+		return err
+	}
+
+	s.quant, s.binary, s.bits, s.rescore = q, false, nil, cfg.Rescore
+	s.codes = make(map[string][]int8, len(ids))
+	s.norms = make(map[string]float32, len(ids))
+	for i, id := range ids {
+		s.codes[id] = q.Encode(nil, vecs[i])
+		s.norms[id] = norms[i]
+	}
+	if s.rescore == 0 {
+		s.data = vector.NewArena(s.dim)
 	}
-	n := vector.Norm(vec)
-	s.data = append(s.data, Vector{ID: id, Val: vec, Norm: n})
 	return nil
 }
 
-// Data returns the underlying slice of stored vectors.
-// Callers should treat the returned slice as read-only to maintain data integrity.
+// Quantizer returns the quantizer set by Quantize, or nil if the store is not quantized.
+// Callers can use it to decode the Code of stored vectors whose Val was dropped.
+func (s *MemoryStore) Quantizer() *vector.ScalarQuantizer {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.quant
+}
+
+// Data returns a copy of the stored vectors, sorted by ID, with their codes,
+// norms and metadata.
 func (s *MemoryStore) Data() []Vector {
-	return s.data
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := slices.Sorted(s.ids())
+	data := make([]Vector, len(ids))
+	for i, id := range ids {
+		v := Vector{ID: id, Norm: s.normOf(id), Meta: maps.Clone(s.meta[id])}
+		if vec, _, ok := s.data.Get(id); ok {
+			v.Val = slices.Clone(vec)
+		}
+		v.Code = slices.Clone(s.codes[id])
+		v.Bits = slices.Clone(s.bits[id])
+		data[i] = v
+	}
+	return data
 }
 
 // Len returns the number of vectors currently stored in this container.
-func (s *MemoryStore) Len() int { return len(s.data) }
+func (s *MemoryStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.len()
+}
+
+// Get returns a copy of the vector stored under id, reconstructed from its
+// codes if its full-precision vector was dropped by Quantize, with its norm
+// and a copy of its metadata.
+// Returns an error wrapping embedx.ErrNotFound if the ID is not stored.
+func (s *MemoryStore) Get(id string) ([]float32, float32, map[string]any, error) {
+	return s.GetContext(context.Background(), id)
+}
+
+// GetContext is like Get but returns ctx.Err() if ctx is done.
+func (s *MemoryStore) GetContext(ctx context.Context, id string) ([]float32, float32, map[string]any, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	vec, norm, ok := s.vectorOf(id)
+	if !ok {
+		return nil, 0, nil, fmt.Errorf("%w: %s", embedx.ErrNotFound, id)
+	}
+	return vec, norm, maps.Clone(s.meta[id]), nil
+}
+
+// Close releases the stored vectors. It always returns nil.
+func (s *MemoryStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data = vector.NewArena(s.dim)
+	clear(s.meta)
+	clear(s.codes)
+	clear(s.norms)
+	clear(s.bits)
+	return nil
+}
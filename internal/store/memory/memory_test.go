@@ -1,7 +1,13 @@
 package memory
 
 import (
+	"context"
+	"errors"
 	"testing"
+
+	"github.com/ldaidone/goembedx/pkg/embedx"
+	"github.com/ldaidone/goembedx/pkg/embedx/embedxtest"
+	"github.com/ldaidone/goembedx/vector"
 )
 
 func TestMemoryStoreAdd(t *testing.T) {
@@ -16,4 +22,234 @@ func TestMemoryStoreAdd(t *testing.T) {
 	if err := s.Add("bad", []float32{1}); err == nil {
 		t.Fatalf("expected dimension mismatch error")
 	}
+
+	// Adding an ID again replaces its vector.
+	if err := s.AddWithMeta("id1", []float32{3, 4}, map[string]any{"v": 2}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if vec, norm, meta, _ := s.Get("id1"); s.Len() != 1 || vec[0] != 3 || norm != 5 || meta["v"] != 2 {
+		t.Fatalf("expected id1 to be replaced, got %v, %v, %v", vec, norm, meta)
+	}
+}
+
+func TestMemoryStoreSearchWithFilter(t *testing.T) {
+	s := NewMemoryStore(2)
+	_ = s.AddWithMeta("a", []float32{1, 0}, map[string]any{"type": "pdf"})
+	_ = s.AddWithMeta("b", []float32{0.8, 0.2}, map[string]any{"type": "doc"})
+	_ = s.Add("c", []float32{0, 1})
+
+	res, err := s.Search([]float32{1, 0}, 2)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(res) != 2 || res[0].ID != "a" || res[1].ID != "b" {
+		t.Fatalf("expected [a b], got %v", res)
+	}
+
+	res, err = s.SearchWithFilter([]float32{1, 0}, 0, embedx.In("type", "doc", "html"))
+	if err != nil {
+		t.Fatalf("SearchWithFilter failed: %v", err)
+	}
+	if len(res) != 1 || res[0].ID != "b" {
+		t.Fatalf("expected [b], got %v", res)
+	}
+
+	// Deleting a moves c into its row; the metadata stays with its vector.
+	_ = s.Delete("a")
+	res, _ = s.SearchWithFilter([]float32{0, 1}, 0, embedx.Not(embedx.Exists("type")))
+	if len(res) != 1 || res[0].ID != "c" {
+		t.Fatalf("expected [c], got %v", res)
+	}
+
+	if _, err := s.Search([]float32{1}, 1); err == nil {
+		t.Fatalf("expected query dimension mismatch error")
+	}
+}
+
+func TestMemoryStoreDeleteUpsert(t *testing.T) {
+	s := NewMemoryStore(2)
+	_ = s.Add("a", []float32{1, 0})
+	_ = s.Add("b", []float32{0, 1})
+
+	if err := s.Upsert("a", []float32{1, 1}, map[string]any{"v": 2}, embedx.UpdateOnly); err != nil {
+		t.Fatalf("Upsert failed: %v", err)
+	}
+	if s.Len() != 2 || s.Data()[0].Val[1] != 1 || s.Data()[0].Meta["v"] != 2 {
+		t.Fatalf("expected a to be replaced in place, got %v", s.Data())
+	}
+	if err := s.Upsert("b", []float32{1, 1}, nil, embedx.InsertOnly); !errors.Is(err, embedx.ErrAlreadyExists) {
+		t.Fatalf("expected ErrAlreadyExists, got %v", err)
+	}
+	if err := s.Upsert("c", []float32{1, 1}, nil, embedx.UpdateOnly); !errors.Is(err, embedx.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if err := s.Upsert("c", []float32{1}, nil, embedx.UpsertAny); err == nil {
+		t.Fatalf("expected dimension mismatch error")
+	}
+
+	if err := s.Delete("a"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := s.Delete("a"); !errors.Is(err, embedx.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if n, _ := s.DeleteMany([]string{"b", "missing"}); n != 1 || s.Len() != 0 {
+		t.Fatalf("expected 1 deletion and empty store, got %d and %d", n, s.Len())
+	}
+}
+
+func TestMemoryStoreWithMetric(t *testing.T) {
+	s := NewMemoryStoreWithMetric(2, vector.MetricDot)
+	_ = s.Add("short", []float32{1, 0})
+	_ = s.Add("long", []float32{3, 1})
+	_ = s.Add("zero", []float32{0, 0})
+
+	res, err := s.Search([]float32{1, 0}, 0)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(res) != 3 || res[0].ID != "long" || res[0].Score != 3 || res[2].ID != "zero" {
+		t.Fatalf("expected [long short zero] ranked by inner product, got %v", res)
+	}
+}
+
+func TestMemoryStoreAddBatch(t *testing.T) {
+	s := NewMemoryStore(2)
+	_ = s.Add("existing", []float32{1, 1})
+
+	batch := []embedx.Record{
+		{ID: "a", Vector: []float32{1, 0}},
+		{ID: "bad", Vector: []float32{1}},
+		{ID: "existing", Vector: []float32{0, 1}, Meta: map[string]any{"updated": true}},
+		{ID: "a", Vector: []float32{0.5, 0.5}},
+	}
+
+	err := s.AddBatch(batch, embedx.BatchOptions{Atomic: true})
+	var be *embedx.BatchError
+	if !errors.As(err, &be) || len(be.Items) != 1 || be.Items[0].ID != "bad" {
+		t.Fatalf("Expected bad to be rejected, got %v", err)
+	}
+	if s.Len() != 1 {
+		t.Fatalf("Expected atomic batch to write nothing, got %d vectors", s.Len())
+	}
+
+	err = s.AddBatch(batch, embedx.BatchOptions{})
+	if !errors.As(err, &be) || be.Written != 3 {
+		t.Fatalf("Expected 3 written records, got %v", err)
+	}
+	if s.Len() != 2 {
+		t.Fatalf("Expected 2 vectors, got %d", s.Len())
+	}
+	if vec, _, _, _ := s.Get("a"); vec[0] != 0.5 {
+		t.Errorf("Expected the last record for a to win, got %v", vec)
+	}
+	if _, _, meta, _ := s.Get("existing"); meta["updated"] != true {
+		t.Errorf("Expected existing to be replaced with metadata, got %v", meta)
+	}
+
+	err = s.AddBatch([]embedx.Record{{ID: "missing", Vector: []float32{1, 0}}}, embedx.BatchOptions{Mode: embedx.UpdateOnly})
+	if !errors.Is(err, embedx.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for update-only batch, got %v", err)
+	}
+}
+
+func TestMemoryStoreQuantize(t *testing.T) {
+	s := NewMemoryStore(2)
+	if err := s.Quantize(embedx.QuantizationConfig{}); err == nil {
+		t.Error("Expected error quantizing an empty store, got nil")
+	}
+	_ = s.AddWithMeta("a", []float32{1, 0}, map[string]any{"k": 1})
+	_ = s.Add("b", []float32{0, 1})
+	_ = s.Add("c", []float32{0.6, 0.8})
+	if err := s.Quantize(embedx.QuantizationConfig{Product: &vector.DefaultPQConfig}); err == nil {
+		t.Error("Expected product quantization to be unsupported, got nil")
+	}
+
+	if err := s.Quantize(embedx.QuantizationConfig{}); err != nil {
+		t.Fatalf("Quantize failed: %v", err)
+	}
+	for _, v := range s.Data() {
+		if v.Val != nil || len(v.Code) != 2 {
+			t.Fatalf("Expected only codes to be kept, got %+v", v)
+		}
+	}
+	if got := s.Quantizer().Decode(nil, s.Data()[2].Code); got[1] < 0.79 || got[1] > 0.81 {
+		t.Errorf("Expected c to decode close to its vector, got %v", got)
+	}
+
+	_ = s.Upsert("d", []float32{0.1, 0.9}, nil, embedx.UpsertAny)
+	results, _ := s.SearchWithFilter([]float32{1, 0.1}, 2, embedx.Exists("k"))
+	if len(results) != 1 || results[0].ID != "a" {
+		t.Errorf("Unexpected filtered quantized results: %v", results)
+	}
+	if results, _ := s.Search([]float32{0, 1}, 2); len(results) != 2 || results[0].ID != "b" || results[1].ID != "d" {
+		t.Errorf("Unexpected quantized results: %v", results)
+	}
+	if err := s.Quantize(embedx.QuantizationConfig{Rescore: 3}); err == nil {
+		t.Error("Expected error rescoring after dropping full-precision vectors, got nil")
+	}
+
+	r := NewMemoryStore(2)
+	_ = r.Add("a", []float32{1, 0})
+	_ = r.Add("b", []float32{0.6, 0.8})
+	if err := r.Quantize(embedx.QuantizationConfig{Calibration: vector.CalibrateGlobal, Rescore: 1}); err != nil {
+		t.Fatalf("Quantize failed: %v", err)
+	}
+	if results, _ := r.Search([]float32{0.6, 0.8}, 1); len(results) != 1 || results[0].ID != "b" || results[0].Score < 0.9999 {
+		t.Errorf("Expected rescored exact score, got %v", results)
+	}
+}
+
+func TestMemoryStoreBinaryQuantize(t *testing.T) {
+	s := NewMemoryStore(4)
+	_ = s.AddWithMeta("a", []float32{1, 2, -1, -2}, map[string]any{"k": 1})
+	_ = s.Add("b", []float32{-1, -2, 1, 2})
+	if err := s.Quantize(embedx.QuantizationConfig{Binary: true, Rescore: 2}); err != nil {
+		t.Fatalf("Quantize failed: %v", err)
+	}
+	_ = s.Add("c", []float32{1, 2, 1, -2})
+	for _, v := range s.Data() {
+		if v.Val == nil || len(v.Bits) != 1 || v.Code != nil {
+			t.Fatalf("Expected vectors and bits to be kept, got %+v", v)
+		}
+	}
+
+	results, _ := s.Search([]float32{1, 2, 1, -2}, 2)
+	if len(results) != 2 || results[0].ID != "c" || results[0].Score < 0.9999 || results[1].ID != "a" {
+		t.Errorf("Unexpected rescored binary results: %v", results)
+	}
+	results, _ = s.SearchWithFilter([]float32{-1, -2, 1, 2}, 2, embedx.Exists("k"))
+	if len(results) != 1 || results[0].ID != "a" {
+		t.Errorf("Unexpected filtered binary results: %v", results)
+	}
+
+	// Switching to int8 drops the bits.
+	if err := s.Quantize(embedx.QuantizationConfig{Rescore: 1}); err != nil {
+		t.Fatalf("Quantize failed: %v", err)
+	}
+	for _, v := range s.Data() {
+		if v.Bits != nil {
+			t.Fatalf("Expected bits to be dropped, got %+v", v)
+		}
+	}
+}
+
+// conformingStore adapts a MemoryStore to embedx.Store, whose Add takes
+// metadata and replaces an existing vector like Upsert.
+type conformingStore struct {
+	*MemoryStore
+}
+
+func (s conformingStore) Add(id string, vec []float32, meta map[string]any) error {
+	return s.Upsert(id, vec, meta, embedx.UpsertAny)
+}
+
+func (s conformingStore) AddContext(ctx context.Context, id string, vec []float32, meta map[string]any) error {
+	return s.UpsertContext(ctx, id, vec, meta, embedx.UpsertAny)
+}
+
+func TestMemoryStoreConformance(t *testing.T) {
+	embedxtest.TestStore(t, func(t *testing.T) embedx.Store {
+		return conformingStore{NewMemoryStore(embedxtest.Dim)}
+	})
 }
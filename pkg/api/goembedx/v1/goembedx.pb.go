@@ -0,0 +1,966 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: goembedx/v1/goembedx.proto
+
+// Package goembedx.v1 defines the gRPC API of the goembedx vector store.
+
+package goembedxv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// UpsertMode controls how an upsert treats vectors that are already stored.
+type UpsertMode int32
+
+const (
+	// UPSERT_MODE_UNSPECIFIED inserts absent vectors and replaces stored ones.
+	UpsertMode_UPSERT_MODE_UNSPECIFIED UpsertMode = 0
+	// UPSERT_MODE_INSERT_ONLY fails with ALREADY_EXISTS if the ID is stored.
+	UpsertMode_UPSERT_MODE_INSERT_ONLY UpsertMode = 1
+	// UPSERT_MODE_UPDATE_ONLY fails with NOT_FOUND if the ID is not stored.
+	UpsertMode_UPSERT_MODE_UPDATE_ONLY UpsertMode = 2
+)
+
+// Enum value maps for UpsertMode.
+var (
+	UpsertMode_name = map[int32]string{
+		0: "UPSERT_MODE_UNSPECIFIED",
+		1: "UPSERT_MODE_INSERT_ONLY",
+		2: "UPSERT_MODE_UPDATE_ONLY",
+	}
+	UpsertMode_value = map[string]int32{
+		"UPSERT_MODE_UNSPECIFIED": 0,
+		"UPSERT_MODE_INSERT_ONLY": 1,
+		"UPSERT_MODE_UPDATE_ONLY": 2,
+	}
+)
+
+func (x UpsertMode) Enum() *UpsertMode {
+	p := new(UpsertMode)
+	*p = x
+	return p
+}
+
+func (x UpsertMode) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (UpsertMode) Descriptor() protoreflect.EnumDescriptor {
+	return file_goembedx_v1_goembedx_proto_enumTypes[0].Descriptor()
+}
+
+func (UpsertMode) Type() protoreflect.EnumType {
+	return &file_goembedx_v1_goembedx_proto_enumTypes[0]
+}
+
+func (x UpsertMode) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use UpsertMode.Descriptor instead.
+func (UpsertMode) EnumDescriptor() ([]byte, []int) {
+	return file_goembedx_v1_goembedx_proto_rawDescGZIP(), []int{0}
+}
+
+// Vector is a stored vector with its metadata.
+type Vector struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Id     string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Values []float32              `protobuf:"fixed32,2,rep,packed,name=values,proto3" json:"values,omitempty"`
+	Meta   *structpb.Struct       `protobuf:"bytes,3,opt,name=meta,proto3" json:"meta,omitempty"`
+	// norm is the L2 norm of values. It is set by the server and ignored on writes.
+	Norm          float32 `protobuf:"fixed32,4,opt,name=norm,proto3" json:"norm,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Vector) Reset() {
+	*x = Vector{}
+	mi := &file_goembedx_v1_goembedx_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Vector) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Vector) ProtoMessage() {}
+
+func (x *Vector) ProtoReflect() protoreflect.Message {
+	mi := &file_goembedx_v1_goembedx_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Vector.ProtoReflect.Descriptor instead.
+func (*Vector) Descriptor() ([]byte, []int) {
+	return file_goembedx_v1_goembedx_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Vector) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Vector) GetValues() []float32 {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
+func (x *Vector) GetMeta() *structpb.Struct {
+	if x != nil {
+		return x.Meta
+	}
+	return nil
+}
+
+func (x *Vector) GetNorm() float32 {
+	if x != nil {
+		return x.Norm
+	}
+	return 0
+}
+
+type UpsertRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Vector        *Vector                `protobuf:"bytes,1,opt,name=vector,proto3" json:"vector,omitempty"`
+	Mode          UpsertMode             `protobuf:"varint,2,opt,name=mode,proto3,enum=goembedx.v1.UpsertMode" json:"mode,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpsertRequest) Reset() {
+	*x = UpsertRequest{}
+	mi := &file_goembedx_v1_goembedx_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpsertRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpsertRequest) ProtoMessage() {}
+
+func (x *UpsertRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_goembedx_v1_goembedx_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpsertRequest.ProtoReflect.Descriptor instead.
+func (*UpsertRequest) Descriptor() ([]byte, []int) {
+	return file_goembedx_v1_goembedx_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *UpsertRequest) GetVector() *Vector {
+	if x != nil {
+		return x.Vector
+	}
+	return nil
+}
+
+func (x *UpsertRequest) GetMode() UpsertMode {
+	if x != nil {
+		return x.Mode
+	}
+	return UpsertMode_UPSERT_MODE_UNSPECIFIED
+}
+
+type UpsertResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpsertResponse) Reset() {
+	*x = UpsertResponse{}
+	mi := &file_goembedx_v1_goembedx_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpsertResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpsertResponse) ProtoMessage() {}
+
+func (x *UpsertResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_goembedx_v1_goembedx_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpsertResponse.ProtoReflect.Descriptor instead.
+func (*UpsertResponse) Descriptor() ([]byte, []int) {
+	return file_goembedx_v1_goembedx_proto_rawDescGZIP(), []int{2}
+}
+
+type GetRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetRequest) Reset() {
+	*x = GetRequest{}
+	mi := &file_goembedx_v1_goembedx_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetRequest) ProtoMessage() {}
+
+func (x *GetRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_goembedx_v1_goembedx_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetRequest.ProtoReflect.Descriptor instead.
+func (*GetRequest) Descriptor() ([]byte, []int) {
+	return file_goembedx_v1_goembedx_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GetRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type GetResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Vector        *Vector                `protobuf:"bytes,1,opt,name=vector,proto3" json:"vector,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetResponse) Reset() {
+	*x = GetResponse{}
+	mi := &file_goembedx_v1_goembedx_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetResponse) ProtoMessage() {}
+
+func (x *GetResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_goembedx_v1_goembedx_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetResponse.ProtoReflect.Descriptor instead.
+func (*GetResponse) Descriptor() ([]byte, []int) {
+	return file_goembedx_v1_goembedx_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GetResponse) GetVector() *Vector {
+	if x != nil {
+		return x.Vector
+	}
+	return nil
+}
+
+type DeleteRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteRequest) Reset() {
+	*x = DeleteRequest{}
+	mi := &file_goembedx_v1_goembedx_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteRequest) ProtoMessage() {}
+
+func (x *DeleteRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_goembedx_v1_goembedx_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteRequest.ProtoReflect.Descriptor instead.
+func (*DeleteRequest) Descriptor() ([]byte, []int) {
+	return file_goembedx_v1_goembedx_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *DeleteRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type DeleteResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteResponse) Reset() {
+	*x = DeleteResponse{}
+	mi := &file_goembedx_v1_goembedx_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteResponse) ProtoMessage() {}
+
+func (x *DeleteResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_goembedx_v1_goembedx_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteResponse.ProtoReflect.Descriptor instead.
+func (*DeleteResponse) Descriptor() ([]byte, []int) {
+	return file_goembedx_v1_goembedx_proto_rawDescGZIP(), []int{6}
+}
+
+type SearchRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Vector []float32              `protobuf:"fixed32,1,rep,packed,name=vector,proto3" json:"vector,omitempty"`
+	// k is the number of results to return. Defaults to 10.
+	K int32 `protobuf:"varint,2,opt,name=k,proto3" json:"k,omitempty"`
+	// filter is a metadata filter document, such as
+	// {"tenant": "acme", "year": {"$gte": 2024}}.
+	Filter        *structpb.Struct `protobuf:"bytes,3,opt,name=filter,proto3" json:"filter,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchRequest) Reset() {
+	*x = SearchRequest{}
+	mi := &file_goembedx_v1_goembedx_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchRequest) ProtoMessage() {}
+
+func (x *SearchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_goembedx_v1_goembedx_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchRequest.ProtoReflect.Descriptor instead.
+func (*SearchRequest) Descriptor() ([]byte, []int) {
+	return file_goembedx_v1_goembedx_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *SearchRequest) GetVector() []float32 {
+	if x != nil {
+		return x.Vector
+	}
+	return nil
+}
+
+func (x *SearchRequest) GetK() int32 {
+	if x != nil {
+		return x.K
+	}
+	return 0
+}
+
+func (x *SearchRequest) GetFilter() *structpb.Struct {
+	if x != nil {
+		return x.Filter
+	}
+	return nil
+}
+
+type SearchHit struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Score         float32                `protobuf:"fixed32,2,opt,name=score,proto3" json:"score,omitempty"`
+	Meta          *structpb.Struct       `protobuf:"bytes,3,opt,name=meta,proto3" json:"meta,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchHit) Reset() {
+	*x = SearchHit{}
+	mi := &file_goembedx_v1_goembedx_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchHit) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchHit) ProtoMessage() {}
+
+func (x *SearchHit) ProtoReflect() protoreflect.Message {
+	mi := &file_goembedx_v1_goembedx_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchHit.ProtoReflect.Descriptor instead.
+func (*SearchHit) Descriptor() ([]byte, []int) {
+	return file_goembedx_v1_goembedx_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *SearchHit) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *SearchHit) GetScore() float32 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+func (x *SearchHit) GetMeta() *structpb.Struct {
+	if x != nil {
+		return x.Meta
+	}
+	return nil
+}
+
+type SearchResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Results       []*SearchHit           `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchResponse) Reset() {
+	*x = SearchResponse{}
+	mi := &file_goembedx_v1_goembedx_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchResponse) ProtoMessage() {}
+
+func (x *SearchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_goembedx_v1_goembedx_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchResponse.ProtoReflect.Descriptor instead.
+func (*SearchResponse) Descriptor() ([]byte, []int) {
+	return file_goembedx_v1_goembedx_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *SearchResponse) GetResults() []*SearchHit {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+type BulkExportRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BulkExportRequest) Reset() {
+	*x = BulkExportRequest{}
+	mi := &file_goembedx_v1_goembedx_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BulkExportRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BulkExportRequest) ProtoMessage() {}
+
+func (x *BulkExportRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_goembedx_v1_goembedx_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BulkExportRequest.ProtoReflect.Descriptor instead.
+func (*BulkExportRequest) Descriptor() ([]byte, []int) {
+	return file_goembedx_v1_goembedx_proto_rawDescGZIP(), []int{10}
+}
+
+type BulkExportResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Vector        *Vector                `protobuf:"bytes,1,opt,name=vector,proto3" json:"vector,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BulkExportResponse) Reset() {
+	*x = BulkExportResponse{}
+	mi := &file_goembedx_v1_goembedx_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BulkExportResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BulkExportResponse) ProtoMessage() {}
+
+func (x *BulkExportResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_goembedx_v1_goembedx_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BulkExportResponse.ProtoReflect.Descriptor instead.
+func (*BulkExportResponse) Descriptor() ([]byte, []int) {
+	return file_goembedx_v1_goembedx_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *BulkExportResponse) GetVector() *Vector {
+	if x != nil {
+		return x.Vector
+	}
+	return nil
+}
+
+type BulkImportRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Vector        *Vector                `protobuf:"bytes,1,opt,name=vector,proto3" json:"vector,omitempty"`
+	Mode          UpsertMode             `protobuf:"varint,2,opt,name=mode,proto3,enum=goembedx.v1.UpsertMode" json:"mode,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BulkImportRequest) Reset() {
+	*x = BulkImportRequest{}
+	mi := &file_goembedx_v1_goembedx_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BulkImportRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BulkImportRequest) ProtoMessage() {}
+
+func (x *BulkImportRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_goembedx_v1_goembedx_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BulkImportRequest.ProtoReflect.Descriptor instead.
+func (*BulkImportRequest) Descriptor() ([]byte, []int) {
+	return file_goembedx_v1_goembedx_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *BulkImportRequest) GetVector() *Vector {
+	if x != nil {
+		return x.Vector
+	}
+	return nil
+}
+
+func (x *BulkImportRequest) GetMode() UpsertMode {
+	if x != nil {
+		return x.Mode
+	}
+	return UpsertMode_UPSERT_MODE_UNSPECIFIED
+}
+
+// ImportError reports a record that could not be stored.
+type ImportError struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// index is the position of the record in the stream, starting at 0.
+	Index         int64  `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	Id            string `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	Message       string `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ImportError) Reset() {
+	*x = ImportError{}
+	mi := &file_goembedx_v1_goembedx_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ImportError) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ImportError) ProtoMessage() {}
+
+func (x *ImportError) ProtoReflect() protoreflect.Message {
+	mi := &file_goembedx_v1_goembedx_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ImportError.ProtoReflect.Descriptor instead.
+func (*ImportError) Descriptor() ([]byte, []int) {
+	return file_goembedx_v1_goembedx_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *ImportError) GetIndex() int64 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *ImportError) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *ImportError) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type BulkImportResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Imported      int64                  `protobuf:"varint,1,opt,name=imported,proto3" json:"imported,omitempty"`
+	Errors        []*ImportError         `protobuf:"bytes,2,rep,name=errors,proto3" json:"errors,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BulkImportResponse) Reset() {
+	*x = BulkImportResponse{}
+	mi := &file_goembedx_v1_goembedx_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BulkImportResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BulkImportResponse) ProtoMessage() {}
+
+func (x *BulkImportResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_goembedx_v1_goembedx_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BulkImportResponse.ProtoReflect.Descriptor instead.
+func (*BulkImportResponse) Descriptor() ([]byte, []int) {
+	return file_goembedx_v1_goembedx_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *BulkImportResponse) GetImported() int64 {
+	if x != nil {
+		return x.Imported
+	}
+	return 0
+}
+
+func (x *BulkImportResponse) GetErrors() []*ImportError {
+	if x != nil {
+		return x.Errors
+	}
+	return nil
+}
+
+var File_goembedx_v1_goembedx_proto protoreflect.FileDescriptor
+
+const file_goembedx_v1_goembedx_proto_rawDesc = "" +
+	"\n" +
+	"\x1agoembedx/v1/goembedx.proto\x12\vgoembedx.v1\x1a\x1cgoogle/protobuf/struct.proto\"q\n" +
+	"\x06Vector\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x16\n" +
+	"\x06values\x18\x02 \x03(\x02R\x06values\x12+\n" +
+	"\x04meta\x18\x03 \x01(\v2\x17.google.protobuf.StructR\x04meta\x12\x12\n" +
+	"\x04norm\x18\x04 \x01(\x02R\x04norm\"i\n" +
+	"\rUpsertRequest\x12+\n" +
+	"\x06vector\x18\x01 \x01(\v2\x13.goembedx.v1.VectorR\x06vector\x12+\n" +
+	"\x04mode\x18\x02 \x01(\x0e2\x17.goembedx.v1.UpsertModeR\x04mode\"\x10\n" +
+	"\x0eUpsertResponse\"\x1c\n" +
+	"\n" +
+	"GetRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\":\n" +
+	"\vGetResponse\x12+\n" +
+	"\x06vector\x18\x01 \x01(\v2\x13.goembedx.v1.VectorR\x06vector\"\x1f\n" +
+	"\rDeleteRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\x10\n" +
+	"\x0eDeleteResponse\"f\n" +
+	"\rSearchRequest\x12\x16\n" +
+	"\x06vector\x18\x01 \x03(\x02R\x06vector\x12\f\n" +
+	"\x01k\x18\x02 \x01(\x05R\x01k\x12/\n" +
+	"\x06filter\x18\x03 \x01(\v2\x17.google.protobuf.StructR\x06filter\"^\n" +
+	"\tSearchHit\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x14\n" +
+	"\x05score\x18\x02 \x01(\x02R\x05score\x12+\n" +
+	"\x04meta\x18\x03 \x01(\v2\x17.google.protobuf.StructR\x04meta\"B\n" +
+	"\x0eSearchResponse\x120\n" +
+	"\aresults\x18\x01 \x03(\v2\x16.goembedx.v1.SearchHitR\aresults\"\x13\n" +
+	"\x11BulkExportRequest\"A\n" +
+	"\x12BulkExportResponse\x12+\n" +
+	"\x06vector\x18\x01 \x01(\v2\x13.goembedx.v1.VectorR\x06vector\"m\n" +
+	"\x11BulkImportRequest\x12+\n" +
+	"\x06vector\x18\x01 \x01(\v2\x13.goembedx.v1.VectorR\x06vector\x12+\n" +
+	"\x04mode\x18\x02 \x01(\x0e2\x17.goembedx.v1.UpsertModeR\x04mode\"M\n" +
+	"\vImportError\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\x03R\x05index\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\tR\x02id\x12\x18\n" +
+	"\amessage\x18\x03 \x01(\tR\amessage\"b\n" +
+	"\x12BulkImportResponse\x12\x1a\n" +
+	"\bimported\x18\x01 \x01(\x03R\bimported\x120\n" +
+	"\x06errors\x18\x02 \x03(\v2\x18.goembedx.v1.ImportErrorR\x06errors*c\n" +
+	"\n" +
+	"UpsertMode\x12\x1b\n" +
+	"\x17UPSERT_MODE_UNSPECIFIED\x10\x00\x12\x1b\n" +
+	"\x17UPSERT_MODE_INSERT_ONLY\x10\x01\x12\x1b\n" +
+	"\x17UPSERT_MODE_UPDATE_ONLY\x10\x022\xb4\x03\n" +
+	"\rEmbedxService\x12A\n" +
+	"\x06Upsert\x12\x1a.goembedx.v1.UpsertRequest\x1a\x1b.goembedx.v1.UpsertResponse\x128\n" +
+	"\x03Get\x12\x17.goembedx.v1.GetRequest\x1a\x18.goembedx.v1.GetResponse\x12A\n" +
+	"\x06Delete\x12\x1a.goembedx.v1.DeleteRequest\x1a\x1b.goembedx.v1.DeleteResponse\x12A\n" +
+	"\x06Search\x12\x1a.goembedx.v1.SearchRequest\x1a\x1b.goembedx.v1.SearchResponse\x12O\n" +
+	"\n" +
+	"BulkExport\x12\x1e.goembedx.v1.BulkExportRequest\x1a\x1f.goembedx.v1.BulkExportResponse0\x01\x12O\n" +
+	"\n" +
+	"BulkImport\x12\x1e.goembedx.v1.BulkImportRequest\x1a\x1f.goembedx.v1.BulkImportResponse(\x01B=Z;github.com/ldaidone/goembedx/pkg/api/goembedx/v1;goembedxv1b\x06proto3"
+
+var (
+	file_goembedx_v1_goembedx_proto_rawDescOnce sync.Once
+	file_goembedx_v1_goembedx_proto_rawDescData []byte
+)
+
+func file_goembedx_v1_goembedx_proto_rawDescGZIP() []byte {
+	file_goembedx_v1_goembedx_proto_rawDescOnce.Do(func() {
+		file_goembedx_v1_goembedx_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_goembedx_v1_goembedx_proto_rawDesc), len(file_goembedx_v1_goembedx_proto_rawDesc)))
+	})
+	return file_goembedx_v1_goembedx_proto_rawDescData
+}
+
+var file_goembedx_v1_goembedx_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_goembedx_v1_goembedx_proto_msgTypes = make([]protoimpl.MessageInfo, 15)
+var file_goembedx_v1_goembedx_proto_goTypes = []any{
+	(UpsertMode)(0),            // 0: goembedx.v1.UpsertMode
+	(*Vector)(nil),             // 1: goembedx.v1.Vector
+	(*UpsertRequest)(nil),      // 2: goembedx.v1.UpsertRequest
+	(*UpsertResponse)(nil),     // 3: goembedx.v1.UpsertResponse
+	(*GetRequest)(nil),         // 4: goembedx.v1.GetRequest
+	(*GetResponse)(nil),        // 5: goembedx.v1.GetResponse
+	(*DeleteRequest)(nil),      // 6: goembedx.v1.DeleteRequest
+	(*DeleteResponse)(nil),     // 7: goembedx.v1.DeleteResponse
+	(*SearchRequest)(nil),      // 8: goembedx.v1.SearchRequest
+	(*SearchHit)(nil),          // 9: goembedx.v1.SearchHit
+	(*SearchResponse)(nil),     // 10: goembedx.v1.SearchResponse
+	(*BulkExportRequest)(nil),  // 11: goembedx.v1.BulkExportRequest
+	(*BulkExportResponse)(nil), // 12: goembedx.v1.BulkExportResponse
+	(*BulkImportRequest)(nil),  // 13: goembedx.v1.BulkImportRequest
+	(*ImportError)(nil),        // 14: goembedx.v1.ImportError
+	(*BulkImportResponse)(nil), // 15: goembedx.v1.BulkImportResponse
+	(*structpb.Struct)(nil),    // 16: google.protobuf.Struct
+}
+var file_goembedx_v1_goembedx_proto_depIdxs = []int32{
+	16, // 0: goembedx.v1.Vector.meta:type_name -> google.protobuf.Struct
+	1,  // 1: goembedx.v1.UpsertRequest.vector:type_name -> goembedx.v1.Vector
+	0,  // 2: goembedx.v1.UpsertRequest.mode:type_name -> goembedx.v1.UpsertMode
+	1,  // 3: goembedx.v1.GetResponse.vector:type_name -> goembedx.v1.Vector
+	16, // 4: goembedx.v1.SearchRequest.filter:type_name -> google.protobuf.Struct
+	16, // 5: goembedx.v1.SearchHit.meta:type_name -> google.protobuf.Struct
+	9,  // 6: goembedx.v1.SearchResponse.results:type_name -> goembedx.v1.SearchHit
+	1,  // 7: goembedx.v1.BulkExportResponse.vector:type_name -> goembedx.v1.Vector
+	1,  // 8: goembedx.v1.BulkImportRequest.vector:type_name -> goembedx.v1.Vector
+	0,  // 9: goembedx.v1.BulkImportRequest.mode:type_name -> goembedx.v1.UpsertMode
+	14, // 10: goembedx.v1.BulkImportResponse.errors:type_name -> goembedx.v1.ImportError
+	2,  // 11: goembedx.v1.EmbedxService.Upsert:input_type -> goembedx.v1.UpsertRequest
+	4,  // 12: goembedx.v1.EmbedxService.Get:input_type -> goembedx.v1.GetRequest
+	6,  // 13: goembedx.v1.EmbedxService.Delete:input_type -> goembedx.v1.DeleteRequest
+	8,  // 14: goembedx.v1.EmbedxService.Search:input_type -> goembedx.v1.SearchRequest
+	11, // 15: goembedx.v1.EmbedxService.BulkExport:input_type -> goembedx.v1.BulkExportRequest
+	13, // 16: goembedx.v1.EmbedxService.BulkImport:input_type -> goembedx.v1.BulkImportRequest
+	3,  // 17: goembedx.v1.EmbedxService.Upsert:output_type -> goembedx.v1.UpsertResponse
+	5,  // 18: goembedx.v1.EmbedxService.Get:output_type -> goembedx.v1.GetResponse
+	7,  // 19: goembedx.v1.EmbedxService.Delete:output_type -> goembedx.v1.DeleteResponse
+	10, // 20: goembedx.v1.EmbedxService.Search:output_type -> goembedx.v1.SearchResponse
+	12, // 21: goembedx.v1.EmbedxService.BulkExport:output_type -> goembedx.v1.BulkExportResponse
+	15, // 22: goembedx.v1.EmbedxService.BulkImport:output_type -> goembedx.v1.BulkImportResponse
+	17, // [17:23] is the sub-list for method output_type
+	11, // [11:17] is the sub-list for method input_type
+	11, // [11:11] is the sub-list for extension type_name
+	11, // [11:11] is the sub-list for extension extendee
+	0,  // [0:11] is the sub-list for field type_name
+}
+
+func init() { file_goembedx_v1_goembedx_proto_init() }
+func file_goembedx_v1_goembedx_proto_init() {
+	if File_goembedx_v1_goembedx_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_goembedx_v1_goembedx_proto_rawDesc), len(file_goembedx_v1_goembedx_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   15,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_goembedx_v1_goembedx_proto_goTypes,
+		DependencyIndexes: file_goembedx_v1_goembedx_proto_depIdxs,
+		EnumInfos:         file_goembedx_v1_goembedx_proto_enumTypes,
+		MessageInfos:      file_goembedx_v1_goembedx_proto_msgTypes,
+	}.Build()
+	File_goembedx_v1_goembedx_proto = out.File
+	file_goembedx_v1_goembedx_proto_goTypes = nil
+	file_goembedx_v1_goembedx_proto_depIdxs = nil
+}
@@ -0,0 +1,328 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: goembedx/v1/goembedx.proto
+
+// Package goembedx.v1 defines the gRPC API of the goembedx vector store.
+
+package goembedxv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	EmbedxService_Upsert_FullMethodName     = "/goembedx.v1.EmbedxService/Upsert"
+	EmbedxService_Get_FullMethodName        = "/goembedx.v1.EmbedxService/Get"
+	EmbedxService_Delete_FullMethodName     = "/goembedx.v1.EmbedxService/Delete"
+	EmbedxService_Search_FullMethodName     = "/goembedx.v1.EmbedxService/Search"
+	EmbedxService_BulkExport_FullMethodName = "/goembedx.v1.EmbedxService/BulkExport"
+	EmbedxService_BulkImport_FullMethodName = "/goembedx.v1.EmbedxService/BulkImport"
+)
+
+// EmbedxServiceClient is the client API for EmbedxService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// EmbedxService stores vectors with metadata and answers similarity searches.
+type EmbedxServiceClient interface {
+	// Upsert stores a vector according to the requested mode.
+	Upsert(ctx context.Context, in *UpsertRequest, opts ...grpc.CallOption) (*UpsertResponse, error)
+	// Get returns a stored vector with its norm and metadata.
+	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error)
+	// Delete removes a stored vector.
+	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
+	// Search returns the vectors most similar to a query, optionally filtered by metadata.
+	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error)
+	// BulkExport streams every stored vector in ascending ID order.
+	BulkExport(ctx context.Context, in *BulkExportRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[BulkExportResponse], error)
+	// BulkImport stores a stream of vectors. Failed records are reported in the
+	// response instead of aborting the stream.
+	BulkImport(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[BulkImportRequest, BulkImportResponse], error)
+}
+
+type embedxServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewEmbedxServiceClient(cc grpc.ClientConnInterface) EmbedxServiceClient {
+	return &embedxServiceClient{cc}
+}
+
+func (c *embedxServiceClient) Upsert(ctx context.Context, in *UpsertRequest, opts ...grpc.CallOption) (*UpsertResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpsertResponse)
+	err := c.cc.Invoke(ctx, EmbedxService_Upsert_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *embedxServiceClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetResponse)
+	err := c.cc.Invoke(ctx, EmbedxService_Get_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *embedxServiceClient) Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteResponse)
+	err := c.cc.Invoke(ctx, EmbedxService_Delete_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *embedxServiceClient) Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SearchResponse)
+	err := c.cc.Invoke(ctx, EmbedxService_Search_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *embedxServiceClient) BulkExport(ctx context.Context, in *BulkExportRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[BulkExportResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &EmbedxService_ServiceDesc.Streams[0], EmbedxService_BulkExport_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[BulkExportRequest, BulkExportResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type EmbedxService_BulkExportClient = grpc.ServerStreamingClient[BulkExportResponse]
+
+func (c *embedxServiceClient) BulkImport(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[BulkImportRequest, BulkImportResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &EmbedxService_ServiceDesc.Streams[1], EmbedxService_BulkImport_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[BulkImportRequest, BulkImportResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type EmbedxService_BulkImportClient = grpc.ClientStreamingClient[BulkImportRequest, BulkImportResponse]
+
+// EmbedxServiceServer is the server API for EmbedxService service.
+// All implementations must embed UnimplementedEmbedxServiceServer
+// for forward compatibility.
+//
+// EmbedxService stores vectors with metadata and answers similarity searches.
+type EmbedxServiceServer interface {
+	// Upsert stores a vector according to the requested mode.
+	Upsert(context.Context, *UpsertRequest) (*UpsertResponse, error)
+	// Get returns a stored vector with its norm and metadata.
+	Get(context.Context, *GetRequest) (*GetResponse, error)
+	// Delete removes a stored vector.
+	Delete(context.Context, *DeleteRequest) (*DeleteResponse, error)
+	// Search returns the vectors most similar to a query, optionally filtered by metadata.
+	Search(context.Context, *SearchRequest) (*SearchResponse, error)
+	// BulkExport streams every stored vector in ascending ID order.
+	BulkExport(*BulkExportRequest, grpc.ServerStreamingServer[BulkExportResponse]) error
+	// BulkImport stores a stream of vectors. Failed records are reported in the
+	// response instead of aborting the stream.
+	BulkImport(grpc.ClientStreamingServer[BulkImportRequest, BulkImportResponse]) error
+	mustEmbedUnimplementedEmbedxServiceServer()
+}
+
+// UnimplementedEmbedxServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedEmbedxServiceServer struct{}
+
+func (UnimplementedEmbedxServiceServer) Upsert(context.Context, *UpsertRequest) (*UpsertResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Upsert not implemented")
+}
+func (UnimplementedEmbedxServiceServer) Get(context.Context, *GetRequest) (*GetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedEmbedxServiceServer) Delete(context.Context, *DeleteRequest) (*DeleteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedEmbedxServiceServer) Search(context.Context, *SearchRequest) (*SearchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Search not implemented")
+}
+func (UnimplementedEmbedxServiceServer) BulkExport(*BulkExportRequest, grpc.ServerStreamingServer[BulkExportResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method BulkExport not implemented")
+}
+func (UnimplementedEmbedxServiceServer) BulkImport(grpc.ClientStreamingServer[BulkImportRequest, BulkImportResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method BulkImport not implemented")
+}
+func (UnimplementedEmbedxServiceServer) mustEmbedUnimplementedEmbedxServiceServer() {}
+func (UnimplementedEmbedxServiceServer) testEmbeddedByValue()                       {}
+
+// UnsafeEmbedxServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to EmbedxServiceServer will
+// result in compilation errors.
+type UnsafeEmbedxServiceServer interface {
+	mustEmbedUnimplementedEmbedxServiceServer()
+}
+
+func RegisterEmbedxServiceServer(s grpc.ServiceRegistrar, srv EmbedxServiceServer) {
+	// If the following call pancis, it indicates UnimplementedEmbedxServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&EmbedxService_ServiceDesc, srv)
+}
+
+func _EmbedxService_Upsert_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpsertRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EmbedxServiceServer).Upsert(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EmbedxService_Upsert_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EmbedxServiceServer).Upsert(ctx, req.(*UpsertRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EmbedxService_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EmbedxServiceServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EmbedxService_Get_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EmbedxServiceServer).Get(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EmbedxService_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EmbedxServiceServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EmbedxService_Delete_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EmbedxServiceServer).Delete(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EmbedxService_Search_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EmbedxServiceServer).Search(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EmbedxService_Search_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EmbedxServiceServer).Search(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EmbedxService_BulkExport_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(BulkExportRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(EmbedxServiceServer).BulkExport(m, &grpc.GenericServerStream[BulkExportRequest, BulkExportResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type EmbedxService_BulkExportServer = grpc.ServerStreamingServer[BulkExportResponse]
+
+func _EmbedxService_BulkImport_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(EmbedxServiceServer).BulkImport(&grpc.GenericServerStream[BulkImportRequest, BulkImportResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type EmbedxService_BulkImportServer = grpc.ClientStreamingServer[BulkImportRequest, BulkImportResponse]
+
+// EmbedxService_ServiceDesc is the grpc.ServiceDesc for EmbedxService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var EmbedxService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "goembedx.v1.EmbedxService",
+	HandlerType: (*EmbedxServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Upsert",
+			Handler:    _EmbedxService_Upsert_Handler,
+		},
+		{
+			MethodName: "Get",
+			Handler:    _EmbedxService_Get_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _EmbedxService_Delete_Handler,
+		},
+		{
+			MethodName: "Search",
+			Handler:    _EmbedxService_Search_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "BulkExport",
+			Handler:       _EmbedxService_BulkExport_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "BulkImport",
+			Handler:       _EmbedxService_BulkImport_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "goembedx/v1/goembedx.proto",
+}
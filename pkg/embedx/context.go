@@ -31,3 +31,19 @@ func EngineFromContext(ctx context.Context) *Embedder {
 func FromContext(ctx context.Context) *Embedder {
 	return EngineFromContext(ctx)
 }
+
+// ContextCheckInterval is the number of vectors a scan loop handles between
+// checks of its context.
+const ContextCheckInterval = 256
+
+// CheckContext returns ctx.Err() when n, the number of vectors a loop has
+// handled, is a multiple of ContextCheckInterval, and nil otherwise.
+// Store implementations call it on every iteration of their scan loops, so
+// that the context variants of their methods stop soon after the context is
+// done without checking it for every vector.
+func CheckContext(ctx context.Context, n int) error {
+	if n%ContextCheckInterval != 0 {
+		return nil
+	}
+	return ctx.Err()
+}
@@ -3,30 +3,113 @@
 package embedx
 
 import (
+	"context"
 	"errors"
-	"math"
+	"fmt"
+	"iter"
+	"maps"
+	"slices"
 	"sort"
 	"sync"
+
+	"github.com/ldaidone/goembedx/vector"
 )
 
 // Embedder provides the core functionality for adding and searching vectors.
 type Embedder struct {
 	// store holds the underlying vector storage implementation.
 	store VectorStore
+	// index is an optional approximate nearest-neighbor index.
+	// If nil, Search performs a brute-force scan of the store.
+	index Index
+	// storeIndexed reports whether index is managed by the store itself,
+	// in which case the Embedder never writes to it.
+	storeIndexed bool
+	// metric scores vectors during brute-force searches.
+	metric vector.Metric
+	// metricSet reports whether metric was given with WithMetric, in which
+	// case it takes precedence over the metric of the store.
+	metricSet bool
+}
+
+// Option configures optional Embedder behavior in New.
+type Option func(*Embedder)
+
+// WithIndex configures the Embedder to maintain idx on every Add and to answer
+// Search queries from it instead of scanning all stored vectors.
+// Vectors already present in the store can be loaded with BuildIndex.
+func WithIndex(idx Index) Option {
+	return func(e *Embedder) {
+		e.index = idx
+	}
+}
+
+// WithMetric sets the similarity metric used by brute-force searches.
+// The default is the metric of the store if it is a SchemaStore or a
+// StatsProvider, and vector.MetricCosine otherwise. When the Embedder has an
+// index, results are scored by the metric the index was configured with.
+func WithMetric(m vector.Metric) Option {
+	return func(e *Embedder) {
+		e.metric = m
+		e.metricSet = true
+	}
+}
+
+// searchMetric returns the metric brute-force searches score with: the
+// metric given with WithMetric, or else the current schema metric of a
+// SchemaStore, whose schema may change after New while it holds no vectors.
+func (e *Embedder) searchMetric() vector.Metric {
+	if ss, ok := e.store.(SchemaStore); ok && !e.metricSet {
+		return ss.Schema().Metric
+	}
+	return e.metric
+}
+
+// Store returns the vector store the Embedder was created with.
+func (e *Embedder) Store() VectorStore {
+	return e.store
 }
 
 // New creates a new Embedder instance with the specified vector store.
 // The store must implement the VectorStore interface and handle the actual
 // storage and retrieval of vectors.
-func New(store VectorStore) *Embedder {
-	return &Embedder{store: store}
+// If the store is an IndexedStore with an index and no WithIndex option is
+// given, the Embedder searches the store's own index. Unless WithMetric is
+// given, brute-force searches score with the metric of the store.
+func New(store VectorStore, opts ...Option) *Embedder {
+	e := &Embedder{store: store}
+	for _, opt := range opts {
+		opt(e)
+	}
+	// The schema of a SchemaStore is read on every search instead.
+	if _, ok := store.(SchemaStore); !ok && !e.metricSet {
+		if sp, ok := store.(StatsProvider); ok {
+			if stats, err := sp.Stats(); err == nil {
+				e.metric = stats.Metric
+			}
+		}
+	}
+	if e.index == nil {
+		if is, ok := store.(IndexedStore); ok {
+			if idx := is.Index(); idx != nil {
+				e.index = idx
+				e.storeIndexed = true
+			}
+		}
+	}
+	return e
 }
 
 // Result represents a single search result with ID, similarity score, and vector data.
 type Result struct {
 	// ID is the identifier of the matching vector.
 	ID string
-	// Score is the cosine similarity score between -1.0 and 1.0.
+	// Score is the similarity of the vector to the query under the metric
+	// of the search: the cosine similarity between -1.0 and 1.0 for
+	// vector.MetricCosine, the dot product for vector.MetricDot,
+	// 1/(1+d) in (0, 1] for the distance d of vector.MetricEuclidean and
+	// vector.MetricManhattan, and the fraction of equal components in
+	// [0, 1] for vector.MetricHamming.
 	// Higher scores indicate greater similarity.
 	Score float32
 	// Vector contains the actual vector data of the result.
@@ -34,107 +117,462 @@ type Result struct {
 }
 
 // Add adds a vector with the specified ID to the store.
-// It returns an error if the vector is empty or if the underlying store returns an error.
+// If the Embedder has an index, the vector is also inserted into it.
+// It returns an error if the vector is empty or if the underlying store or index returns an error.
 func (e *Embedder) Add(id string, vec []float32) error {
+	return e.AddContext(context.Background(), id, vec)
+}
+
+// AddContext is like Add but returns ctx.Err() if ctx is done before the
+// vector is stored. A vector that was stored is always indexed.
+// If the index rejects the vector, the store is restored to the vector it
+// held before, so that the store and the index stay in sync.
+func (e *Embedder) AddContext(ctx context.Context, id string, vec []float32) error {
+	if len(vec) == 0 {
+		return errors.New("cannot store empty vector")
+	}
+	if e.index == nil || e.storeIndexed {
+		return e.store.SaveVectorContext(ctx, id, vec)
+	}
+	prev, err := e.previous(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := e.store.SaveVectorContext(ctx, id, vec); err != nil {
+		return err
+	}
+	return e.indexStored(id, vec, prev)
+}
+
+// Upsert stores a vector with the specified ID according to mode and keeps the index in sync.
+// It returns an error if the vector is empty, if mode forbids the write
+// (ErrAlreadyExists or ErrNotFound), or if the underlying store or index returns an error.
+func (e *Embedder) Upsert(id string, vec []float32, mode UpsertMode) error {
+	return e.UpsertContext(context.Background(), id, vec, mode)
+}
+
+// UpsertContext is like Upsert but returns ctx.Err() if ctx is done before
+// the vector is stored. A vector that was stored is always indexed.
+// If the index rejects the vector, the store is restored like AddContext does.
+func (e *Embedder) UpsertContext(ctx context.Context, id string, vec []float32, mode UpsertMode) error {
 	if len(vec) == 0 {
 		return errors.New("cannot store empty vector")
 	}
-	return e.store.SaveVector(id, vec)
+	if e.index == nil || e.storeIndexed {
+		return e.store.UpsertVectorContext(ctx, id, vec, mode)
+	}
+	prev, err := e.previous(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := e.store.UpsertVectorContext(ctx, id, vec, mode); err != nil {
+		return err
+	}
+	return e.indexStored(id, vec, prev)
+}
+
+// previous returns the vector stored under id, or nil if there is none.
+func (e *Embedder) previous(ctx context.Context, id string) ([]float32, error) {
+	prev, err := e.store.GetVectorContext(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	return prev, err
+}
+
+// indexStored adds vec, which was just stored under id, to the index. If the
+// index rejects it, prev is stored back under id, or the vector is deleted if
+// prev is nil, and the index error is returned along with any error restoring
+// the store.
+func (e *Embedder) indexStored(id string, vec, prev []float32) error {
+	err := e.index.Add(id, vec)
+	if err == nil {
+		return nil
+	}
+	var rerr error
+	if prev != nil {
+		rerr = e.store.SaveVector(id, prev)
+	} else {
+		rerr = e.store.Delete(id)
+	}
+	if rerr != nil {
+		return errors.Join(err, fmt.Errorf("restore vector %s: %w", id, rerr))
+	}
+	return err
+}
+
+// AddBatch stores many vectors with their metadata and keeps the index in sync.
+// If the store implements BatchWriter, the batch is written by the store in
+// bulk with the semantics of Store.AddBatch. Otherwise each record is upserted
+// in turn without its metadata, and atomic batches are not supported.
+// Returns a *BatchError if any record is rejected; the other records are
+// still indexed when the batch is best-effort.
+func (e *Embedder) AddBatch(records []Record, opts BatchOptions) error {
+	return e.AddBatchContext(context.Background(), records, opts)
+}
+
+// AddBatchContext is like AddBatch but stops writing and returns ctx.Err()
+// once ctx is done. A store that implements BatchWriter may keep records it
+// wrote before, as described by Store.AddBatchContext, without them being
+// added to an Embedder-managed index; with other stores, the records written
+// before are indexed.
+func (e *Embedder) AddBatchContext(ctx context.Context, records []Record, opts BatchOptions) error {
+	var failed []ItemError
+	var err, cerr error
+	if bw, ok := e.store.(BatchWriter); ok {
+		err = bw.AddBatchContext(ctx, records, opts)
+		var be *BatchError
+		if errors.As(err, &be) {
+			failed = be.Items
+		} else if err != nil {
+			return err
+		}
+	} else {
+		if opts.Atomic {
+			return errors.New("store does not support atomic batches")
+		}
+		for i, r := range records {
+			if cerr = ctx.Err(); cerr != nil {
+				records = records[:i]
+				break
+			}
+			if len(r.Vector) == 0 {
+				failed = append(failed, ItemError{Index: i, ID: r.ID, Err: errors.New("cannot store empty vector")})
+				continue
+			}
+			if uerr := e.store.UpsertVector(r.ID, r.Vector, opts.Mode); uerr != nil {
+				failed = append(failed, ItemError{Index: i, ID: r.ID, Err: uerr})
+			}
+		}
+		err = NewBatchError(failed, len(records)-len(failed))
+		if cerr != nil {
+			err = cerr
+		}
+	}
+
+	if e.index == nil || e.storeIndexed || (opts.Atomic && len(failed) > 0) {
+		return err
+	}
+	next := 0
+	for i, r := range records {
+		if next < len(failed) && failed[next].Index == i {
+			next++
+			continue
+		}
+		if ierr := e.index.Add(r.ID, r.Vector); ierr != nil {
+			return ierr
+		}
+	}
+	return err
+}
+
+// Delete removes the vector with the specified ID from the store and the index.
+// It returns ErrNotFound if the ID is not stored.
+func (e *Embedder) Delete(id string) error {
+	return e.DeleteContext(context.Background(), id)
+}
+
+// DeleteContext is like Delete but returns ctx.Err() if ctx is done before
+// the vector is deleted.
+func (e *Embedder) DeleteContext(ctx context.Context, id string) error {
+	if err := e.store.DeleteContext(ctx, id); err != nil {
+		return err
+	}
+	return e.unindex(id)
+}
+
+// DeleteMany removes the vectors with the specified IDs from the store and the index,
+// skipping IDs that are not stored. It returns the number of vectors removed.
+func (e *Embedder) DeleteMany(ids []string) (int, error) {
+	return e.DeleteManyContext(context.Background(), ids)
+}
+
+// DeleteManyContext is like DeleteMany but stops and returns ctx.Err(), with
+// the number of vectors removed so far, once ctx is done.
+func (e *Embedder) DeleteManyContext(ctx context.Context, ids []string) (int, error) {
+	n, err := e.store.DeleteManyContext(ctx, ids)
+	if err != nil {
+		return n, err
+	}
+	for _, id := range ids {
+		if err := e.unindex(id); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// unindex removes id from an Embedder-managed index. IDs the index does not
+// hold are ignored.
+func (e *Embedder) unindex(id string) error {
+	if e.index == nil || e.storeIndexed {
+		return nil
+	}
+	if err := e.index.Delete(id); err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+// BuildIndex loads every vector currently in the store into the Embedder's index.
+// It is meant for stores that already hold data when the Embedder is created.
+// It is a no-op when the index is managed by the store.
+// Returns an error if the Embedder has no index or if loading fails.
+func (e *Embedder) BuildIndex() error {
+	if e.index == nil {
+		return errors.New("embedder has no index")
+	}
+	if e.storeIndexed {
+		return nil
+	}
+
+	items, err := e.store.GetAllVectors()
+	if err != nil {
+		return err
+	}
+	for id, vec := range items {
+		if err := e.index.Add(id, vec); err != nil {
+			return fmt.Errorf("failed to index vector %s: %w", id, err)
+		}
+	}
+	return nil
 }
 
 // Search performs a similarity search against all stored vectors.
-// It computes cosine similarity between the query vector and all stored vectors,
-// then returns the top-k most similar results sorted by score in descending order.
+// It scores every stored vector against the query with the Embedder's metric
+// (see WithMetric), then returns the top-k most similar results sorted by
+// score in descending order, and by ID among equal scores, or every result
+// if k <= 0.
+// Vectors of another dimension than the query are skipped, as are zero
+// vectors under the cosine metric. The vectors of an ArenaScanner such as
+// MemoryStore are scored in place, without copying them first.
+// If the Embedder has an index, the approximate results of the index are returned instead.
 //
-// Returns an error if the query vector is empty, the store is empty, or if
-// the underlying store returns an error during retrieval.
+// Returns ErrEmptyStore if the store is empty, and an error if the query
+// vector is empty or if the underlying store returns an error during retrieval.
 func (e *Embedder) Search(query []float32, k int) ([]Result, error) {
+	return e.SearchContext(context.Background(), query, k)
+}
+
+// SearchContext is like Search but stops and returns ctx.Err() once ctx is done.
+func (e *Embedder) SearchContext(ctx context.Context, query []float32, k int) ([]Result, error) {
 	if len(query) == 0 {
 		return nil, errors.New("query vector is empty")
 	}
 
-	items, err := e.store.GetAllVectors()
+	if e.index != nil {
+		return e.searchIndex(ctx, query, k)
+	}
+	metric := e.searchMetric()
+	if as, ok := e.store.(ArenaScanner); ok {
+		if results, ok, err := as.ScanArena(ctx, query, k, metric); ok {
+			return results, err
+		}
+	}
+
+	items, err := e.store.GetAllVectorsContext(ctx)
 	if err != nil {
 		return nil, err
 	}
 	if len(items) == 0 {
-		return nil, errors.New("vector store is empty")
+		return nil, ErrEmptyStore
 	}
 
-	scores := make([]Result, 0, len(items))
-
+	top := vector.NewTopK(k)
+	queryNorm := vector.Norm(query)
+	n := 0
 	for id, vec := range items {
+		if err := CheckContext(ctx, n); err != nil {
+			return nil, err
+		}
+		n++
+
 		// Skip vectors with mismatched dimensions
 		if len(vec) != len(query) {
 			continue
 		}
 
-		score := cosineSimilarity(query, vec)
-
-		// Skip results with NaN scores
-		if math.IsNaN(float64(score)) {
+		norm := vector.Norm(vec)
+		if metric == vector.MetricCosine && (queryNorm == 0 || norm == 0) {
 			continue
 		}
 
-		scores = append(scores, Result{
-			ID:     id,
-			Score:  score,
-			Vector: vec, // later this might become optional for performance
-		})
+		// TopK ignores NaN scores
+		top.Push(vector.Candidate{ID: id, Score: metric.ScoreNorms(query, vec, queryNorm, norm)})
 	}
 
-	// Return empty slice if no matches found
-	if len(scores) == 0 {
-		return []Result{}, nil
+	hits := top.Results()
+	scores := make([]Result, len(hits))
+	for i, hit := range hits {
+		scores[i] = Result{
+			ID:     hit.ID,
+			Score:  hit.Score,
+			Vector: items[hit.ID], // later this might become optional for performance
+		}
 	}
+	return scores, nil
+}
 
-	sort.Slice(scores, func(i, j int) bool {
-		return scores[i].Score > scores[j].Score
-	})
+// SearchBatch performs Search for many queries at once and returns their
+// results in query order. A brute-force batch reads the stored vectors once,
+// instead of once per query as many calls to Search would, and scores them
+// against blocks of queries with vector.QueryBatch, or, for a MemoryStore,
+// scores its vectors in place with vector.Arena.SearchBatch. Scores are
+// exactly those of Search. If the Embedder has an index, the index answers
+// the queries one by one.
+//
+// Returns ErrEmptyStore if the store is empty, and an error if a query
+// vector is empty or if the underlying store returns an error during retrieval.
+func (e *Embedder) SearchBatch(queries [][]float32, k int) ([][]Result, error) {
+	return e.SearchBatchContext(context.Background(), queries, k)
+}
+
+// SearchBatchContext is like SearchBatch but stops and returns ctx.Err() once ctx is done.
+func (e *Embedder) SearchBatchContext(ctx context.Context, queries [][]float32, k int) ([][]Result, error) {
+	for i, q := range queries {
+		if len(q) == 0 {
+			return nil, fmt.Errorf("query %d: query vector is empty", i)
+		}
+	}
 
-	if len(scores) > k {
-		scores = scores[:k]
+	results := make([][]Result, len(queries))
+	if len(queries) == 0 {
+		return results, nil
+	}
+	if e.index != nil {
+		for i, q := range queries {
+			r, err := e.searchIndex(ctx, q, k)
+			if err != nil {
+				return nil, err
+			}
+			results[i] = r
+		}
+		return results, nil
+	}
+	metric := e.searchMetric()
+	if as, ok := e.store.(ArenaScanner); ok {
+		if results, ok, err := as.ScanArenaBatch(ctx, queries, k, metric); ok {
+			return results, err
+		}
 	}
 
-	return scores, nil
-}
+	items, err := e.store.GetAllVectorsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, ErrEmptyStore
+	}
 
-// cosineSimilarity computes the cosine similarity between two vectors.
-// It returns a value between -1.0 and 1.0 indicating the cosine of the angle between vectors.
-// Values closer to 1.0 indicate higher similarity.
-func cosineSimilarity(a, b []float32) float32 {
-	var dot, magA, magB float32
+	for _, group := range GroupQueries(queries) {
+		batch := vector.NewQueryBatch(pick(queries, group), k, metric)
+		n := 0
+		for id, vec := range items {
+			if err := CheckContext(ctx, n); err != nil {
+				return nil, err
+			}
+			n++
+			if len(vec) == batch.Dim() {
+				batch.Push(id, vec, vector.Norm(vec))
+			}
+		}
+		for j, hits := range batch.Results() {
+			r := make([]Result, len(hits))
+			for h, hit := range hits {
+				r[h] = Result{ID: hit.ID, Score: hit.Score, Vector: items[hit.ID]}
+			}
+			results[group[j]] = r
+		}
+	}
+	return results, nil
+}
 
-	for i := range a {
-		dot += a[i] * b[i]
-		magA += a[i] * a[i]
-		magB += b[i] * b[i]
+// searchIndex answers a query from the Embedder's index and loads the vector
+// data of each hit from the store. Hits whose vector is no longer in the store are skipped.
+// If k <= 0, every indexed vector is requested from the index.
+func (e *Embedder) searchIndex(ctx context.Context, query []float32, k int) ([]Result, error) {
+	if e.index.Len() == 0 {
+		return nil, ErrEmptyStore
+	}
+	if k <= 0 {
+		k = e.index.Len()
 	}
 
-	den := float32(math.Sqrt(float64(magA)) * math.Sqrt(float64(magB)))
-	if den == 0 {
-		return 0
+	hits, err := e.index.Search(query, k)
+	if err != nil {
+		return nil, err
 	}
 
-	return dot / den
+	results := make([]Result, 0, len(hits))
+	for _, hit := range hits {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		vec, err := e.store.GetVectorContext(ctx, hit.ID)
+		if errors.Is(err, ErrNotFound) {
+			// Deleted from the store but still in the index.
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load indexed vector %s: %w", hit.ID, err)
+		}
+		results = append(results, Result{
+			ID:     hit.ID,
+			Score:  hit.Score,
+			Vector: vec,
+		})
+	}
+	return results, nil
 }
 
 // MemoryStore implements an in-memory vector store with thread-safe operations.
 // It optionally enforces dimension constraints on stored vectors.
+// It implements both VectorStore and Store.
 type MemoryStore struct {
-	// data holds the map of vector IDs to vector data.
-	data map[string][]float32
+	// data holds the full-precision vectors in one arena per dimension,
+	// keyed by dimension, so that searches scan contiguous memory.
+	data map[int]*vector.Arena
+	// dims maps the ID of every full-precision vector to its dimension.
+	dims map[string]int
+	// meta holds the optional metadata of each vector, keyed by vector ID.
+	meta map[string]map[string]any
 	// dim specifies the required dimension for stored vectors.
 	// If 0, no dimension restriction is enforced.
 	dim int
+	// metric scores vectors in Search and SearchWithFilter.
+	metric vector.Metric
+	// quant is the int8 quantizer set by Quantize, or nil.
+	quant *vector.ScalarQuantizer
+	// codes holds the quantized vectors, keyed by vector ID, when quant is set.
+	codes map[string][]int8
+	// bits holds the binary codes of the vectors, keyed by vector ID, when
+	// the store is binary-quantized, or is nil. data is always kept.
+	bits map[string]vector.BitVector
+	// bitsDim is the dimension of the binary-quantized vectors.
+	bitsDim int
+	// norms holds the full-precision norms of the quantized vectors.
+	norms map[string]float32
+	// rescore is QuantizationConfig.Rescore. When it is 0 and quant is set,
+	// data is empty and vectors are reconstructed from codes.
+	rescore int
 	// mu provides read-write mutex for thread-safe access to data.
 	mu sync.RWMutex
 }
 
+// Compile-time interface checks
+var _ VectorStore = (*MemoryStore)(nil)
+var _ Store = (*MemoryStore)(nil)
+var _ StatsProvider = (*MemoryStore)(nil)
+var _ Scanner = (*MemoryStore)(nil)
+var _ Quantizable = (*MemoryStore)(nil)
+var _ ArenaScanner = (*MemoryStore)(nil)
+
 // NewMemoryStore creates a new in-memory vector store with no dimension restriction.
 func NewMemoryStore() *MemoryStore {
 	return &MemoryStore{
-		data: make(map[string][]float32),
+		data: make(map[int]*vector.Arena),
+		dims: make(map[string]int),
+		meta: make(map[string]map[string]any),
 		dim:  0, // no dimension restriction by default
 	}
 }
@@ -143,59 +581,760 @@ func NewMemoryStore() *MemoryStore {
 // All vectors stored in this store must have the given dimension.
 func NewMemoryStoreWithDim(dim int) *MemoryStore {
 	return &MemoryStore{
-		data: make(map[string][]float32),
+		data: make(map[int]*vector.Arena),
+		dims: make(map[string]int),
+		meta: make(map[string]map[string]any),
 		dim:  dim,
 	}
 }
 
-// SaveVector stores a vector with the given ID.
-// Returns an error if the ID is empty, the vector is empty,
-// or if the vector dimension doesn't match the store's dimension requirement.
+// NewMemoryStoreWithMetric creates a new in-memory vector store that ranks
+// search results with the given metric. A dim of 0 disables the dimension restriction.
+func NewMemoryStoreWithMetric(dim int, metric vector.Metric) *MemoryStore {
+	s := NewMemoryStoreWithDim(dim)
+	s.metric = metric
+	return s
+}
+
+// SaveVector stores a vector with the given ID, discarding any metadata previously stored under it.
+// Returns an error wrapping ErrInvalidID if the ID is empty, a *DimensionError
+// if the vector dimension doesn't match the store's dimension requirement,
+// or an error if the vector is empty.
 func (m *MemoryStore) SaveVector(id string, vec []float32) error {
-	if id == "" {
-		return errors.New("id cannot be empty")
+	return m.Add(id, vec, nil)
+}
+
+// SaveVectorContext is like SaveVector but returns ctx.Err() if ctx is done.
+func (m *MemoryStore) SaveVectorContext(ctx context.Context, id string, vec []float32) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
-	if len(vec) == 0 {
-		return errors.New("vector cannot be empty")
+	return m.SaveVector(id, vec)
+}
+
+// Add stores a vector with the given ID and associated metadata.
+// The vector and the top level of the metadata map are copied.
+// Returns an error wrapping ErrInvalidID if the ID is empty, a *DimensionError
+// if the vector dimension doesn't match the store's dimension requirement,
+// or an error if the vector is empty.
+func (m *MemoryStore) Add(id string, vec []float32, meta map[string]any) error {
+	return m.Upsert(id, vec, meta, UpsertAny)
+}
+
+// AddContext is like Add but returns ctx.Err() if ctx is done.
+func (m *MemoryStore) AddContext(ctx context.Context, id string, vec []float32, meta map[string]any) error {
+	return m.UpsertContext(ctx, id, vec, meta, UpsertAny)
+}
+
+// UpsertVector stores a vector without metadata according to mode.
+// Returns ErrAlreadyExists or ErrNotFound when mode forbids the write.
+func (m *MemoryStore) UpsertVector(id string, vec []float32, mode UpsertMode) error {
+	return m.Upsert(id, vec, nil, mode)
+}
+
+// UpsertVectorContext is like UpsertVector but returns ctx.Err() if ctx is done.
+func (m *MemoryStore) UpsertVectorContext(ctx context.Context, id string, vec []float32, mode UpsertMode) error {
+	return m.UpsertContext(ctx, id, vec, nil, mode)
+}
+
+// Upsert stores a vector with the given ID and associated metadata according to mode.
+// Returns ErrAlreadyExists or ErrNotFound when mode forbids the write, and the
+// same validation errors as Add.
+func (m *MemoryStore) Upsert(id string, vec []float32, meta map[string]any, mode UpsertMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.validate(id, vec); err != nil {
+		return err
+	}
+	if err := CheckUpsertMode(id, mode, m.has(id)); err != nil {
+		return err
 	}
+	m.put(id, vec, meta)
+	return nil
+}
 
-	if m.dim > 0 && len(vec) != m.dim {
-		return errors.New("vector dimension mismatch")
+// UpsertContext is like Upsert but returns ctx.Err() if ctx is done.
+func (m *MemoryStore) UpsertContext(ctx context.Context, id string, vec []float32, meta map[string]any, mode UpsertMode) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
+	return m.Upsert(id, vec, meta, mode)
+}
+
+// AddBatch stores many vectors with their metadata under a single lock.
+// Records are validated like Upsert, and a record may refer to an ID stored
+// earlier in the same batch. See Store.AddBatch for the batch semantics.
+func (m *MemoryStore) AddBatch(records []Record, opts BatchOptions) error {
+	return m.AddBatchContext(context.Background(), records, opts)
+}
 
+// AddBatchContext is like AddBatch but returns ctx.Err() if ctx is done
+// while the records are validated, in which case nothing is written.
+func (m *MemoryStore) AddBatchContext(ctx context.Context, records []Record, opts BatchOptions) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	m.data[id] = append([]float32(nil), vec...) // copy slice to avoid external mutation
+	var failed []ItemError
+	accepted := make([]int, 0, len(records))
+	seen := make(map[string]bool, len(records))
+	for i, r := range records {
+		if err := CheckContext(ctx, i); err != nil {
+			return err
+		}
+		err := m.validate(r.ID, r.Vector)
+		if err == nil {
+			err = CheckUpsertMode(r.ID, opts.Mode, m.has(r.ID) || seen[r.ID])
+		}
+		if err != nil {
+			failed = append(failed, ItemError{Index: i, ID: r.ID, Err: err})
+			continue
+		}
+		seen[r.ID] = true
+		accepted = append(accepted, i)
+	}
+
+	if opts.Atomic && len(failed) > 0 {
+		return NewBatchError(failed, 0)
+	}
+	for _, i := range accepted {
+		m.put(records[i].ID, records[i].Vector, records[i].Meta)
+	}
+	return NewBatchError(failed, len(accepted))
+}
+
+// validate checks id and vec against the constraints of the store.
+// The caller must hold m.mu.
+func (m *MemoryStore) validate(id string, vec []float32) error {
+	if id == "" {
+		return fmt.Errorf("%w: id cannot be empty", ErrInvalidID)
+	}
+	if len(vec) == 0 {
+		return errors.New("vector cannot be empty")
+	}
+	if m.dim > 0 && len(vec) != m.dim {
+		return &DimensionError{Expected: m.dim, Actual: len(vec)}
+	}
+	if m.quant != nil && len(vec) != m.quant.Dim() {
+		return &DimensionError{Expected: m.quant.Dim(), Actual: len(vec)}
+	}
+	if m.bits != nil && len(vec) != m.bitsDim {
+		return &DimensionError{Expected: m.bitsDim, Actual: len(vec)}
+	}
 	return nil
 }
 
+// put stores copies of vec and meta under id, quantizing vec if the store is
+// quantized. The caller must hold m.mu.
+func (m *MemoryStore) put(id string, vec []float32, meta map[string]any) {
+	if m.quant != nil {
+		m.codes[id] = m.quant.Encode(nil, vec)
+		m.norms[id] = vector.Norm(vec)
+	}
+	if m.bits != nil {
+		m.bits[id] = vector.Binarize(nil, vec)
+		m.norms[id] = vector.Norm(vec)
+	}
+	if m.quant == nil || m.rescore > 0 {
+		m.setVector(id, vec)
+	}
+	if meta == nil {
+		delete(m.meta, id)
+	} else {
+		m.meta[id] = maps.Clone(meta)
+	}
+}
+
 // GetVector retrieves a vector by its ID.
 // Returns an error if the vector is not found in the store.
 func (m *MemoryStore) GetVector(id string) ([]float32, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	vec, exists := m.data[id]
+	vec, exists := m.vectorOf(id)
 	if !exists {
-		return nil, errors.New("vector not found")
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
 	}
+	return vec, nil
+}
 
-	return append([]float32(nil), vec...), nil // copy slice before returning
+// GetVectorContext is like GetVector but returns ctx.Err() if ctx is done.
+func (m *MemoryStore) GetVectorContext(ctx context.Context, id string) ([]float32, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.GetVector(id)
 }
 
 // GetAllVectors returns all stored vectors as a map from ID to vector data.
 func (m *MemoryStore) GetAllVectors() (map[string][]float32, error) {
+	return m.GetAllVectorsContext(context.Background())
+}
+
+// GetAllVectorsContext is like GetAllVectors but stops copying the vectors
+// and returns ctx.Err() once ctx is done.
+func (m *MemoryStore) GetAllVectorsContext(ctx context.Context) (map[string][]float32, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
 	result := make(map[string][]float32)
-	for id, vec := range m.data {
-		result[id] = append([]float32(nil), vec...) // copy slice
+	for id := range m.ids() {
+		if err := CheckContext(ctx, len(result)); err != nil {
+			return nil, err
+		}
+		result[id], _ = m.vectorOf(id)
 	}
 	return result, nil
 }
 
+// Delete removes the vector with the given ID and its metadata.
+// Returns ErrNotFound if the ID is not stored.
+func (m *MemoryStore) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.remove(id) {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return nil
+}
+
+// DeleteContext is like Delete but returns ctx.Err() if ctx is done.
+func (m *MemoryStore) DeleteContext(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return m.Delete(id)
+}
+
+// DeleteMany removes the vectors with the given IDs, skipping IDs that are not stored.
+// Returns the number of vectors removed.
+func (m *MemoryStore) DeleteMany(ids []string) (int, error) {
+	return m.DeleteManyContext(context.Background(), ids)
+}
+
+// DeleteManyContext is like DeleteMany but stops and returns ctx.Err(), with
+// the number of vectors removed so far, once ctx is done.
+func (m *MemoryStore) DeleteManyContext(ctx context.Context, ids []string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	deleted := 0
+	for i, id := range ids {
+		if err := CheckContext(ctx, i); err != nil {
+			return deleted, err
+		}
+		if m.remove(id) {
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// Get retrieves a vector by its ID along with its L2 norm and metadata.
+// Returns an error if the vector is not found in the store.
+func (m *MemoryStore) Get(id string) ([]float32, float32, map[string]any, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	vec, exists := m.vectorOf(id)
+	if !exists {
+		return nil, 0, nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return vec, m.normOf(id, vec), maps.Clone(m.meta[id]), nil
+}
+
+// GetContext is like Get but returns ctx.Err() if ctx is done.
+func (m *MemoryStore) GetContext(ctx context.Context, id string) ([]float32, float32, map[string]any, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, nil, err
+	}
+	return m.Get(id)
+}
+
+// setVector stores a copy of vec under id in the arena of its dimension,
+// removing any vector of another dimension stored under id.
+// The caller must hold m.mu.
+func (m *MemoryStore) setVector(id string, vec []float32) {
+	if dim, ok := m.dims[id]; ok && dim != len(vec) {
+		m.deleteVector(id)
+	}
+	arena := m.data[len(vec)]
+	if arena == nil {
+		arena = vector.NewArena(len(vec))
+		m.data[len(vec)] = arena
+	}
+	arena.Set(id, vec)
+	m.dims[id] = len(vec)
+}
+
+// fullVector returns the full-precision vector stored under id and its norm
+// without copying it. The caller must hold m.mu and must not modify the vector.
+func (m *MemoryStore) fullVector(id string) ([]float32, float32, bool) {
+	dim, ok := m.dims[id]
+	if !ok {
+		return nil, 0, false
+	}
+	return m.data[dim].Get(id)
+}
+
+// deleteVector removes the full-precision vector stored under id, dropping
+// its arena once it is empty. The caller must hold m.mu.
+func (m *MemoryStore) deleteVector(id string) {
+	dim, ok := m.dims[id]
+	if !ok {
+		return
+	}
+	arena := m.data[dim]
+	arena.Delete(id)
+	if arena.Len() == 0 {
+		delete(m.data, dim)
+	}
+	delete(m.dims, id)
+}
+
+// has reports whether id is stored. The caller must hold m.mu.
+func (m *MemoryStore) has(id string) bool {
+	if _, ok := m.dims[id]; ok {
+		return true
+	}
+	_, ok := m.codes[id]
+	return ok
+}
+
+// ids iterates over the stored IDs. The caller must hold m.mu.
+func (m *MemoryStore) ids() iter.Seq[string] {
+	if m.quant != nil {
+		return maps.Keys(m.codes)
+	}
+	return maps.Keys(m.dims)
+}
+
+// vectorOf returns a copy of the vector stored under id, reconstructing it
+// from its codes if the full-precision vector was dropped. The caller must hold m.mu.
+func (m *MemoryStore) vectorOf(id string) ([]float32, bool) {
+	if vec, _, ok := m.fullVector(id); ok {
+		return append([]float32(nil), vec...), true // copy slice before returning
+	}
+	if code, ok := m.codes[id]; ok {
+		return m.quant.Decode(nil, code), true
+	}
+	return nil, false
+}
+
+// normOf returns the full-precision norm of vec, the vector stored under id.
+// The caller must hold m.mu.
+func (m *MemoryStore) normOf(id string, vec []float32) float32 {
+	if norm, ok := m.norms[id]; ok {
+		return norm
+	}
+	if _, norm, ok := m.fullVector(id); ok {
+		return norm
+	}
+	return vector.Norm(vec)
+}
+
+// remove deletes id and reports whether it was stored. The caller must hold m.mu.
+func (m *MemoryStore) remove(id string) bool {
+	if !m.has(id) {
+		return false
+	}
+	m.deleteVector(id)
+	delete(m.meta, id)
+	delete(m.codes, id)
+	delete(m.bits, id)
+	delete(m.norms, id)
+	return true
+}
+
+// Quantize calibrates an int8 quantizer on the stored vectors and quantizes
+// them, or packs them into bit vectors when cfg.Binary is set. Later searches
+// score the quantized vectors, and rescore the best k*cfg.Rescore candidates
+// against the full-precision vectors when cfg.Rescore is positive. With int8
+// quantization and cfg.Rescore 0 the full-precision vectors are dropped, and
+// Get, GetVector, GetAllVectors and Scan return the vectors reconstructed
+// from their codes.
+// Returns an error wrapping ErrEmptyStore if the store is empty, and an error
+// if the stored vectors have different dimensions, product quantization is requested, or full-precision vectors
+// are needed after an earlier Quantize dropped them.
+func (m *MemoryStore) Quantize(cfg QuantizationConfig) error {
+	if cfg.Rescore < 0 || cfg.Sample < 0 {
+		return errors.New("rescore and sample cannot be negative")
+	}
+	if cfg.Product != nil {
+		return errors.New("product quantization is not supported by in-memory stores")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if (cfg.Rescore > 0 || cfg.Binary) && m.quant != nil && m.rescore == 0 {
+		return errors.New("full-precision vectors were dropped by an earlier Quantize")
+	}
+	ids := slices.Collect(m.ids())
+	if len(ids) == 0 {
+		return fmt.Errorf("cannot quantize: %w", ErrEmptyStore)
+	}
+	vecs := make([][]float32, len(ids))
+	sample := vector.NewReservoir(cfg.Sample, 1)
+	for i, id := range ids {
+		vecs[i], _ = m.vectorOf(id)
+		sample.Add(vecs[i])
+	}
+	if cfg.Binary {
+		return m.binarize(ids, vecs, cfg.Rescore)
+	}
+	q, err := vector.TrainScalarQuantizer(sample.Sample(), cfg.Calibration)
+	if err != nil {
+		return err
+	}
+	for _, vec := range vecs {
+		if len(vec) != q.Dim() {
+			return errors.New("cannot quantize vectors of different dimensions")
+		}
+	}
+
+	norms := make(map[string]float32, len(ids))
+	for i, id := range ids {
+		norms[id] = m.normOf(id, vecs[i])
+	}
+	m.quant, m.rescore, m.norms = q, cfg.Rescore, norms
+	m.bits, m.bitsDim = nil, 0
+	m.codes = make(map[string][]int8, len(ids))
+	for i, id := range ids {
+		m.codes[id] = q.Encode(nil, vecs[i])
+	}
+	if m.rescore == 0 {
+		m.data, m.dims = make(map[int]*vector.Arena), make(map[string]int)
+	}
+	return nil
+}
+
+// binarize packs vecs, the vectors of ids, into bit vectors and switches the
+// store to binary quantization. The caller must hold m.mu.
+func (m *MemoryStore) binarize(ids []string, vecs [][]float32, rescore int) error {
+	dim := len(vecs[0])
+	for _, vec := range vecs {
+		if len(vec) != dim {
+			return errors.New("cannot quantize vectors of different dimensions")
+		}
+	}
+
+	bits := make(map[string]vector.BitVector, len(ids))
+	norms := make(map[string]float32, len(ids))
+	for i, id := range ids {
+		bits[id] = vector.Binarize(nil, vecs[i])
+		norms[id] = m.normOf(id, vecs[i])
+	}
+	m.quant, m.codes = nil, nil
+	m.bits, m.bitsDim, m.norms, m.rescore = bits, dim, norms, rescore
+	return nil
+}
+
+// Search performs a brute-force similarity search over all stored vectors using the store's metric.
+// It returns the top-k results sorted by score in descending order, and by ID
+// among equal scores, or every result if k <= 0. Vectors with mismatched dimensions are skipped, as are
+// zero-norm vectors under the cosine metric. If the store has a fixed
+// dimension, a query of another dimension returns a *DimensionError.
+// Once the store is quantized, scores are computed from the int8 codes, or
+// are Hamming similarities of the bit vectors, and are approximate unless
+// rescoring is enabled.
+func (m *MemoryStore) Search(query []float32, k int) ([]SearchResult, error) {
+	return m.SearchWithFilterContext(context.Background(), query, k, nil)
+}
+
+// SearchContext is like Search but stops scanning and returns ctx.Err() once
+// ctx is done.
+func (m *MemoryStore) SearchContext(ctx context.Context, query []float32, k int) ([]SearchResult, error) {
+	return m.SearchWithFilterContext(ctx, query, k, nil)
+}
+
+// SearchWithFilter performs Search restricted to vectors whose metadata matches filter.
+// A nil filter matches every vector.
+func (m *MemoryStore) SearchWithFilter(query []float32, k int, filter Filter) ([]SearchResult, error) {
+	return m.SearchWithFilterContext(context.Background(), query, k, filter)
+}
+
+// SearchWithFilterContext is like SearchWithFilter but stops scanning and
+// returns ctx.Err() once ctx is done.
+func (m *MemoryStore) SearchWithFilterContext(ctx context.Context, query []float32, k int, filter Filter) ([]SearchResult, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.dim > 0 && len(query) != m.dim {
+		return nil, &DimensionError{Expected: m.dim, Actual: len(query)}
+	}
+	if m.quant != nil || m.bits != nil {
+		return m.searchQuantized(ctx, query, k, filter)
+	}
+
+	// Only the arena of the query's dimension holds vectors to score.
+	arena := m.data[len(query)]
+	if arena == nil {
+		return []SearchResult{}, nil
+	}
+	var keep func(id string) bool
+	if filter != nil {
+		keep = func(id string) bool { return MatchFilter(filter, m.meta[id]) }
+	}
+	hits, err := arena.Search(ctx, query, k, m.metric, keep)
+	if err != nil {
+		return nil, err
+	}
+	return m.searchResults(hits), nil
+}
+
+// SearchBatch performs Search for many queries at once and returns their
+// results in query order. The queries of each dimension are answered in one
+// pass over the arena of that dimension, which is scored a block of vectors
+// at a time against blocks of queries with vector.DotMatrixFlat, so a batch
+// is much faster than as many calls to Search. Quantized stores search the
+// queries one by one.
+// If the store has a fixed dimension, a query of another dimension returns
+// an error wrapping a *DimensionError.
+func (m *MemoryStore) SearchBatch(queries [][]float32, k int) ([][]SearchResult, error) {
+	return m.SearchBatchContext(context.Background(), queries, k)
+}
+
+// SearchBatchContext is like SearchBatch but stops and returns ctx.Err() once
+// ctx is done.
+func (m *MemoryStore) SearchBatchContext(ctx context.Context, queries [][]float32, k int) ([][]SearchResult, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.dim > 0 {
+		for i, q := range queries {
+			if len(q) != m.dim {
+				return nil, fmt.Errorf("query %d: %w", i, &DimensionError{Expected: m.dim, Actual: len(q)})
+			}
+		}
+	}
+	results := make([][]SearchResult, len(queries))
+	if m.quant != nil || m.bits != nil {
+		for i, q := range queries {
+			r, err := m.searchQuantized(ctx, q, k, nil)
+			if err != nil {
+				return nil, err
+			}
+			results[i] = r
+		}
+		return results, nil
+	}
+
+	for _, group := range GroupQueries(queries) {
+		arena := m.data[len(queries[group[0]])]
+		if arena == nil {
+			for _, i := range group {
+				results[i] = []SearchResult{}
+			}
+			continue
+		}
+		hits, err := arena.SearchBatch(ctx, pick(queries, group), k, m.metric)
+		if err != nil {
+			return nil, err
+		}
+		for j, i := range group {
+			results[i] = m.searchResults(hits[j])
+		}
+	}
+	return results, nil
+}
+
+// pick returns the queries at the given indexes.
+func pick(queries [][]float32, indexes []int) [][]float32 {
+	out := make([][]float32, len(indexes))
+	for j, i := range indexes {
+		out[j] = queries[i]
+	}
+	return out
+}
+
+// ScanArena implements ArenaScanner by scoring the arena of the query's
+// dimension with metric. It reports false if Quantize dropped the
+// full-precision vectors.
+func (m *MemoryStore) ScanArena(ctx context.Context, query []float32, k int, metric vector.Metric) ([]Result, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.quant != nil && m.rescore == 0 {
+		return nil, false, nil
+	}
+	if len(m.dims) == 0 {
+		return nil, true, ErrEmptyStore
+	}
+	arena := m.data[len(query)]
+	if arena == nil {
+		return []Result{}, true, nil
+	}
+	hits, err := arena.Search(ctx, query, k, metric, nil)
+	if err != nil {
+		return nil, true, err
+	}
+	results := make([]Result, len(hits))
+	for i, hit := range hits {
+		vec, _, _ := arena.Get(hit.ID)
+		results[i] = Result{ID: hit.ID, Score: hit.Score, Vector: slices.Clone(vec)}
+	}
+	return results, true, nil
+}
+
+// ScanArenaBatch implements ArenaScanner, answering the queries of each
+// dimension with Arena.SearchBatch.
+func (m *MemoryStore) ScanArenaBatch(ctx context.Context, queries [][]float32, k int, metric vector.Metric) ([][]Result, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.quant != nil && m.rescore == 0 {
+		return nil, false, nil
+	}
+	if len(m.dims) == 0 {
+		return nil, true, ErrEmptyStore
+	}
+	results := make([][]Result, len(queries))
+	for _, group := range GroupQueries(queries) {
+		arena := m.data[len(queries[group[0]])]
+		if arena == nil {
+			for _, i := range group {
+				results[i] = []Result{}
+			}
+			continue
+		}
+		hits, err := arena.SearchBatch(ctx, pick(queries, group), k, metric)
+		if err != nil {
+			return nil, true, err
+		}
+		for j, i := range group {
+			results[i] = make([]Result, len(hits[j]))
+			for h, hit := range hits[j] {
+				vec, _, _ := arena.Get(hit.ID)
+				results[i][h] = Result{ID: hit.ID, Score: hit.Score, Vector: slices.Clone(vec)}
+			}
+		}
+	}
+	return results, true, nil
+}
+
+// searchResults returns the search results of hits with a copy of their
+// metadata. The caller must hold m.mu.
+func (m *MemoryStore) searchResults(hits []vector.Candidate) []SearchResult {
+	results := make([]SearchResult, len(hits))
+	for i, hit := range hits {
+		results[i] = SearchResult{ID: hit.ID, Score: hit.Score, Meta: maps.Clone(m.meta[hit.ID])}
+	}
+	return results
+}
+
+// searchQuantized scores the quantized vectors against the query and, when
+// rescoring is enabled, rescores the best k*m.rescore candidates against the
+// full-precision vectors. The caller must hold m.mu.
+func (m *MemoryStore) searchQuantized(ctx context.Context, query []float32, k int, filter Filter) ([]SearchResult, error) {
+	results := make([]SearchResult, 0)
+
+	var score func(id string, norm float32) float32
+	if m.bits != nil {
+		if len(query) != m.bitsDim {
+			return results, nil
+		}
+		q := vector.Binarize(nil, query)
+		score = func(id string, _ float32) float32 {
+			return vector.HammingBitsSimilarity(q, m.bits[id], m.bitsDim)
+		}
+	} else {
+		if len(query) != m.quant.Dim() {
+			return results, nil
+		}
+		scorer := m.quant.Scorer(m.metric, query)
+		score = func(id string, norm float32) float32 {
+			return scorer.Score(m.codes[id], norm)
+		}
+	}
+
+	queryNorm := vector.Norm(query)
+	candidates := k
+	if m.rescore > 0 {
+		candidates = k * m.rescore
+	}
+	top := vector.NewTopK(candidates)
+	n := 0
+	for id := range m.ids() {
+		if err := CheckContext(ctx, n); err != nil {
+			return nil, err
+		}
+		n++
+		meta := m.meta[id]
+		if !MatchFilter(filter, meta) {
+			continue
+		}
+		norm := m.norms[id]
+		if m.metric == vector.MetricCosine && (queryNorm == 0 || norm == 0) {
+			continue
+		}
+		top.Push(vector.Candidate{ID: id, Score: score(id, norm)})
+	}
+	hits := top.Results()
+
+	if m.rescore > 0 {
+		rescored := vector.NewTopK(k)
+		for _, hit := range hits {
+			vec, _, _ := m.fullVector(hit.ID)
+			hit.Score = m.metric.ScoreNorms(query, vec, queryNorm, m.norms[hit.ID])
+			rescored.Push(hit)
+		}
+		hits = rescored.Results()
+	}
+	return m.searchResults(hits), nil
+}
+
+// Scan calls fn for every stored record in ascending ID order.
+// The records are copies taken under a read lock, so fn may modify the store.
+func (m *MemoryStore) Scan(fn func(Record) error) error {
+	m.mu.RLock()
+	records := make([]Record, 0, len(m.dims))
+	for id := range m.ids() {
+		vec, _ := m.vectorOf(id)
+		records = append(records, Record{
+			ID:     id,
+			Vector: vec,
+			Norm:   m.normOf(id, vec),
+			Meta:   maps.Clone(m.meta[id]),
+		})
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].ID < records[j].ID
+	})
+	for _, r := range records {
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stats returns the number of stored vectors, the dimension constraint of the
+// store (or the dimension of a stored vector if there is none), its metric
+// and whether it is quantized.
+func (m *MemoryStore) Stats() (StoreStats, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stats := StoreStats{Count: len(m.dims), Dim: m.dim, Metric: m.metric}
+	if m.quant != nil {
+		stats.Count, stats.Dim, stats.Quantized = len(m.codes), m.quant.Dim(), true
+	}
+	if m.bits != nil {
+		stats.Dim, stats.Quantized = m.bitsDim, true
+	}
+	if stats.Dim == 0 {
+		for dim := range m.data {
+			stats.Dim = dim
+			break
+		}
+	}
+	return stats, nil
+}
+
 // Close releases any resources held by the memory store.
 // For this in-memory implementation, it's a no-op.
 func (m *MemoryStore) Close() error {
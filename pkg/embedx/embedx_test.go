@@ -1,9 +1,15 @@
 package embedx
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"math"
+	"math/rand"
 	"reflect"
 	"testing"
+
+	"github.com/ldaidone/goembedx/vector"
 )
 
 // mockVectorStore implements VectorStore interface for testing
@@ -12,6 +18,7 @@ type mockVectorStore struct {
 	saveErr  error
 	getErr   error
 	allErr   error
+	delErr   error
 	closeErr error
 }
 
@@ -32,7 +39,7 @@ func (m *mockVectorStore) GetVector(id string) ([]float32, error) {
 	}
 	vec, exists := m.data[id]
 	if !exists {
-		return nil, errors.New("vector not found")
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
 	}
 	return vec, nil
 }
@@ -44,10 +51,85 @@ func (m *mockVectorStore) GetAllVectors() (map[string][]float32, error) {
 	return m.data, nil
 }
 
+func (m *mockVectorStore) UpsertVector(id string, vec []float32, mode UpsertMode) error {
+	_, exists := m.data[id]
+	if err := CheckUpsertMode(id, mode, exists); err != nil {
+		return err
+	}
+	return m.SaveVector(id, vec)
+}
+
+func (m *mockVectorStore) Delete(id string) error {
+	if m.delErr != nil {
+		return m.delErr
+	}
+	if _, exists := m.data[id]; !exists {
+		return ErrNotFound
+	}
+	delete(m.data, id)
+	return nil
+}
+
+func (m *mockVectorStore) DeleteMany(ids []string) (int, error) {
+	if m.delErr != nil {
+		return 0, m.delErr
+	}
+	n := 0
+	for _, id := range ids {
+		if _, exists := m.data[id]; exists {
+			delete(m.data, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
 func (m *mockVectorStore) Close() error {
 	return m.closeErr
 }
 
+func (m *mockVectorStore) SaveVectorContext(ctx context.Context, id string, vec []float32) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return m.SaveVector(id, vec)
+}
+
+func (m *mockVectorStore) GetVectorContext(ctx context.Context, id string) ([]float32, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.GetVector(id)
+}
+
+func (m *mockVectorStore) GetAllVectorsContext(ctx context.Context) (map[string][]float32, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.GetAllVectors()
+}
+
+func (m *mockVectorStore) UpsertVectorContext(ctx context.Context, id string, vec []float32, mode UpsertMode) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return m.UpsertVector(id, vec, mode)
+}
+
+func (m *mockVectorStore) DeleteContext(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return m.Delete(id)
+}
+
+func (m *mockVectorStore) DeleteManyContext(ctx context.Context, ids []string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return m.DeleteMany(ids)
+}
+
 func TestNew(t *testing.T) {
 	store := &mockVectorStore{}
 	embedder := New(store)
@@ -143,6 +225,71 @@ func TestEmbedderSearch(t *testing.T) {
 	// The important thing is that no error occurred when processing mismatched dimensions
 }
 
+func TestEmbedderSearchBatch(t *testing.T) {
+	r := rand.New(rand.NewSource(5))
+	mock := &mockVectorStore{data: map[string][]float32{"short": {1, 0}, "zero": {0, 0, 0}}}
+	mem := NewMemoryStore()
+	for i := 0; i < 300; i++ {
+		v := []float32{r.Float32(), r.Float32() - 0.5, r.Float32()}
+		_ = mock.SaveVector(fmt.Sprint(i), v)
+		_ = mem.SaveVector(fmt.Sprint(i), v)
+	}
+	_ = mem.SaveVector("short", []float32{1, 0})
+	// Queries of two dimensions, and a zero query that matches nothing under cosine.
+	queries := [][]float32{{1, 0, 0}, {0, 1}, {0.2, -0.4, 1}, {0, 0, 0}, {1, 0, 0}}
+
+	for name, store := range map[string]VectorStore{"generic": mock, "MemoryStore": mem} {
+		for _, m := range []vector.Metric{vector.MetricCosine, vector.MetricEuclidean} {
+			e := New(store, WithMetric(m))
+			for _, k := range []int{3, 0} {
+				batch, err := e.SearchBatch(queries, k)
+				if err != nil {
+					t.Fatalf("%s: SearchBatch failed: %v", name, err)
+				}
+				if len(batch) != len(queries) {
+					t.Fatalf("%s: expected %d result lists, got %d", name, len(queries), len(batch))
+				}
+				for i, q := range queries {
+					want, _ := e.Search(q, k)
+					if !reflect.DeepEqual(batch[i], want) {
+						t.Errorf("%s, %s, k=%d: query %d: expected the results of Search, got %v", name, m, k, i, batch[i])
+					}
+				}
+			}
+		}
+	}
+
+	e := New(mock)
+	if _, err := e.SearchBatch([][]float32{{1, 0, 0}, {}}, 1); err == nil {
+		t.Error("Expected error for an empty query, got nil")
+	}
+	if batch, err := e.SearchBatch(nil, 1); err != nil || len(batch) != 0 {
+		t.Errorf("Expected no result lists for no queries, got %v, %v", batch, err)
+	}
+	mock.allErr = errors.New("store error")
+	if _, err := e.SearchBatch(queries, 1); err == nil {
+		t.Error("Expected store error to propagate, got nil")
+	}
+	if _, err := New(&mockVectorStore{}).SearchBatch(queries, 1); !errors.Is(err, ErrEmptyStore) {
+		t.Errorf("Expected ErrEmptyStore, got %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := New(mem).SearchBatchContext(ctx, queries, 1); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+
+	// An Embedder with an index asks the index for every query.
+	idx := &mockIndex{}
+	e = New(&mockVectorStore{}, WithIndex(idx))
+	_ = e.Add("a", []float32{1, 0})
+	idx.hits = []SearchResult{{ID: "a", Score: 0.9}}
+	batch, err := e.SearchBatch([][]float32{{1, 0}, {0, 1}}, 1)
+	if err != nil || len(batch) != 2 || len(batch[1]) != 1 || batch[1][0].ID != "a" {
+		t.Errorf("Expected a hit from the index for each query, got %v, %v", batch, err)
+	}
+}
+
 func TestMemoryStore(t *testing.T) {
 	// Test NewMemoryStore
 	store := NewMemoryStore()
@@ -212,28 +359,865 @@ func TestMemoryStore(t *testing.T) {
 	}
 }
 
-func TestCosineSimilarity(t *testing.T) {
-	// Test identical vectors (should give 1.0)
-	a := []float32{1, 0, 0}
-	b := []float32{1, 0, 0}
-	result := cosineSimilarity(a, b)
-	if result != 1.0 {
-		t.Errorf("Expected 1.0 for identical vectors, got %f", result)
+func TestEmbedderWithMetric(t *testing.T) {
+	vectors := map[string][]float32{
+		"small": {1, 0},
+		"large": {10, 1},
+		"far":   {-9, -9},
+	}
+	query := []float32{1, 0}
+
+	tests := []struct {
+		metric vector.Metric
+		want   string
+	}{
+		// Cosine ignores magnitude: "small" points exactly along the query.
+		{vector.MetricCosine, "small"},
+		// Inner product rewards magnitude.
+		{vector.MetricDot, "large"},
+		// Distance metrics prefer the nearest point.
+		{vector.MetricEuclidean, "small"},
+		{vector.MetricManhattan, "small"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.metric.String(), func(t *testing.T) {
+			embedder := New(&mockVectorStore{data: vectors}, WithMetric(tt.metric))
+			results, err := embedder.Search(query, 3)
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			if len(results) != 3 || results[0].ID != tt.want {
+				t.Fatalf("Expected %s first, got %v", tt.want, results)
+			}
+			if results[2].ID != "far" {
+				t.Errorf("Expected far last, got %v", results)
+			}
+			if want := tt.metric.Score(query, vectors[tt.want]); results[0].Score != want {
+				t.Errorf("Expected score %v, got %v", want, results[0].Score)
+			}
+		})
+	}
+}
+
+// arenaStore is a store that can only be searched through ArenaScanner.
+type arenaStore struct {
+	*MemoryStore
+}
+
+func (arenaStore) GetAllVectorsContext(ctx context.Context) (map[string][]float32, error) {
+	return nil, errors.New("vectors copied")
+}
+
+func TestEmbedderArenaScanner(t *testing.T) {
+	store := arenaStore{NewMemoryStore()}
+	_ = store.SaveVector("a", []float32{1, 0})
+	_ = store.SaveVector("b", []float32{0, 1})
+	e := New(store)
+
+	results, err := e.Search([]float32{1, 0}, 1)
+	if err != nil || len(results) != 1 || results[0].ID != "a" {
+		t.Fatalf("Expected a from an in-place scan, got %v, %v", results, err)
+	}
+	batch, err := e.SearchBatch([][]float32{{1, 0}, {0, 1}}, 1)
+	if err != nil || batch[0][0].ID != "a" || batch[1][0].ID != "b" {
+		t.Fatalf("Expected [a] [b] from an in-place scan, got %v, %v", batch, err)
+	}
+}
+
+// schemaStore is a mockVectorStore with a schema.
+type schemaStore struct {
+	*mockVectorStore
+	schema Schema
+}
+
+// statsStore is a mockVectorStore that reports stats.
+type statsStore struct {
+	*mockVectorStore
+	stats StoreStats
+}
+
+func (s *statsStore) Stats() (StoreStats, error) { return s.stats, nil }
+
+func (s *schemaStore) Schema() Schema { return s.schema }
+
+func (s *schemaStore) SetSchema(schema Schema) error {
+	s.schema = schema
+	return nil
+}
+
+func TestEmbedderStoreMetric(t *testing.T) {
+	query := []float32{1, 0}
+	large := []float32{10, 1}
+
+	mem := NewMemoryStoreWithMetric(0, vector.MetricDot)
+	_ = mem.SaveVector("small", []float32{1, 0})
+	_ = mem.SaveVector("large", large)
+	mock := &mockVectorStore{data: map[string][]float32{"small": {1, 0}, "large": large}}
+	stats := &statsStore{mockVectorStore: mock, stats: StoreStats{Metric: vector.MetricDot}}
+	schema := &schemaStore{mockVectorStore: mock}
+
+	for name, store := range map[string]VectorStore{"MemoryStore": mem, "StatsProvider": stats, "SchemaStore": schema} {
+		e := New(store)
+		// The schema of a SchemaStore may be set after New.
+		_ = schema.SetSchema(Schema{Metric: vector.MetricDot})
+		results, err := e.Search(query, 2)
+		if err != nil {
+			t.Fatalf("%s: Search failed: %v", name, err)
+		}
+		if want := vector.Dot(query, large); results[0].ID != "large" || results[0].Score != want {
+			t.Errorf("%s: expected large first with the dot score %v, got %v", name, want, results)
+		}
+		_ = schema.SetSchema(Schema{})
+	}
+
+	// WithMetric overrides the metric of the store.
+	results, err := New(mem, WithMetric(vector.MetricCosine)).Search(query, 2)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if results[0].ID != "small" {
+		t.Errorf("Expected small first under cosine, got %v", results)
+	}
+}
+
+func TestMemoryStoreWithMetric(t *testing.T) {
+	store := NewMemoryStoreWithMetric(2, vector.MetricEuclidean)
+	_ = store.SaveVector("origin", []float32{0, 0})
+	_ = store.SaveVector("near", []float32{1, 1})
+	_ = store.SaveVector("far", []float32{3, 4})
+
+	// Zero vectors are valid under distance metrics.
+	results, err := store.Search([]float32{0, 0}, 0)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 3 || results[0].ID != "origin" || results[2].ID != "far" {
+		t.Fatalf("Expected [origin near far], got %v", results)
+	}
+	if results[0].Score != 1 || results[2].Score != 1.0/6 {
+		t.Errorf("Expected scores 1 and 1/6, got %v and %v", results[0].Score, results[2].Score)
+	}
+	if err := store.SaveVector("bad", []float32{1, 2, 3}); err == nil {
+		t.Error("Expected dimension error, got nil")
+	}
+}
+
+// mockIndex implements Index interface for testing
+type mockIndex struct {
+	data    map[string][]float32
+	hits    []SearchResult
+	addErr  error
+	findErr error
+}
+
+func (m *mockIndex) Add(id string, vec []float32) error {
+	if m.addErr != nil {
+		return m.addErr
+	}
+	if m.data == nil {
+		m.data = make(map[string][]float32)
+	}
+	m.data[id] = vec
+	return nil
+}
+
+func (m *mockIndex) Delete(id string) error {
+	delete(m.data, id)
+	return nil
+}
+
+func (m *mockIndex) Search(query []float32, k int) ([]SearchResult, error) {
+	if m.findErr != nil {
+		return nil, m.findErr
+	}
+	return m.hits, nil
+}
+
+func (m *mockIndex) Len() int {
+	return len(m.data)
+}
+
+func TestEmbedderWithIndex(t *testing.T) {
+	store := &mockVectorStore{}
+	idx := &mockIndex{}
+	embedder := New(store, WithIndex(idx))
+
+	// Test empty index
+	if _, err := embedder.Search([]float32{1, 0}, 1); err == nil {
+		t.Error("Expected error for empty index, got nil")
+	}
+
+	// Test Add keeps the index in sync
+	if err := embedder.Add("a", []float32{1, 0}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if _, ok := idx.data["a"]; !ok {
+		t.Error("Add did not insert the vector into the index")
+	}
+
+	// Test Search returns index hits with vectors loaded from the store
+	idx.hits = []SearchResult{{ID: "a", Score: 0.9}, {ID: "gone", Score: 0.5}}
+	results, err := embedder.Search([]float32{1, 0}, 2)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != "a" || results[0].Score != 0.9 {
+		t.Errorf("Expected only hit 'a' with score 0.9, got %v", results)
+	}
+	if !reflect.DeepEqual(results[0].Vector, []float32{1, 0}) {
+		t.Errorf("Expected vector [1 0], got %v", results[0].Vector)
+	}
+
+	// Test store errors other than ErrNotFound fail the search
+	store.getErr = errors.New("disk error")
+	if _, err := embedder.Search([]float32{1, 0}, 2); !errors.Is(err, store.getErr) {
+		t.Errorf("Expected store error to propagate, got %v", err)
+	}
+	store.getErr = nil
+
+	// Test index error propagation
+	idx.findErr = errors.New("index error")
+	if _, err := embedder.Search([]float32{1, 0}, 1); err == nil {
+		t.Error("Expected index error to propagate, got nil")
+	}
+	idx.addErr = errors.New("index error")
+	if err := embedder.Add("b", []float32{0, 1}); err == nil {
+		t.Error("Expected index add error to propagate, got nil")
+	}
+}
+
+func TestEmbedderIndexRejectsVector(t *testing.T) {
+	store := NewMemoryStore()
+	idx := &mockIndex{}
+	embedder := New(store, WithIndex(idx))
+	if err := embedder.Add("a", []float32{1, 0}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	// A vector the index rejects is not kept by the store.
+	idx.addErr = &DimensionError{Expected: 2, Actual: 3}
+	if err := embedder.Add("b", []float32{1, 0, 0}); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("Expected ErrDimensionMismatch, got %v", err)
+	}
+	if _, err := store.GetVector("b"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected rejected vector to be removed from the store, got %v", err)
+	}
+
+	// A vector the index rejects does not replace the stored one.
+	for _, write := range []func() error{
+		func() error { return embedder.Add("a", []float32{0, 0, 1}) },
+		func() error { return embedder.Upsert("a", []float32{0, 0, 1}, UpsertAny) },
+	} {
+		if err := write(); !errors.Is(err, ErrDimensionMismatch) {
+			t.Errorf("Expected ErrDimensionMismatch, got %v", err)
+		}
+		if vec, err := store.GetVector("a"); err != nil || !reflect.DeepEqual(vec, []float32{1, 0}) {
+			t.Errorf("Expected stored vector [1 0] to be restored, got %v, %v", vec, err)
+		}
+	}
+	if all, _ := store.GetAllVectors(); len(all) != idx.Len() {
+		t.Errorf("Expected store and index to hold %d vectors, got %d", idx.Len(), len(all))
+	}
+}
+
+func TestEmbedderContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, idx := range []*mockIndex{nil, {}} {
+		store := &mockVectorStore{}
+		embedder := New(store)
+		if idx != nil {
+			idx.hits = []SearchResult{{ID: "a", Score: 1}}
+			embedder = New(store, WithIndex(idx))
+		}
+		if err := embedder.Add("a", []float32{1, 0}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+
+		if _, err := embedder.SearchContext(ctx, []float32{1, 0}, 1); !errors.Is(err, context.Canceled) {
+			t.Errorf("SearchContext: expected context.Canceled, got %v", err)
+		}
+		if err := embedder.AddContext(ctx, "b", []float32{0, 1}); !errors.Is(err, context.Canceled) {
+			t.Errorf("AddContext: expected context.Canceled, got %v", err)
+		}
+		if err := embedder.UpsertContext(ctx, "b", []float32{0, 1}, UpsertAny); !errors.Is(err, context.Canceled) {
+			t.Errorf("UpsertContext: expected context.Canceled, got %v", err)
+		}
+		err := embedder.AddBatchContext(ctx, []Record{{ID: "b", Vector: []float32{0, 1}}}, BatchOptions{})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("AddBatchContext: expected context.Canceled, got %v", err)
+		}
+		if err := embedder.DeleteContext(ctx, "a"); !errors.Is(err, context.Canceled) {
+			t.Errorf("DeleteContext: expected context.Canceled, got %v", err)
+		}
+		if n, err := embedder.DeleteManyContext(ctx, []string{"a"}); n != 0 || !errors.Is(err, context.Canceled) {
+			t.Errorf("DeleteManyContext: expected 0 and context.Canceled, got %d, %v", n, err)
+		}
+
+		// Cancelled calls change neither the store nor the index.
+		if len(store.data) != 1 || idx != nil && idx.Len() != 1 {
+			t.Errorf("Expected only vector a to be stored and indexed, got %v", store.data)
+		}
+		results, err := embedder.SearchContext(context.Background(), []float32{1, 0}, 1)
+		if err != nil || len(results) != 1 || results[0].ID != "a" {
+			t.Errorf("Expected a, got %v, %v", results, err)
+		}
+	}
+}
+
+func TestEmbedderBuildIndex(t *testing.T) {
+	// Test BuildIndex without an index
+	if err := New(&mockVectorStore{}).BuildIndex(); err == nil {
+		t.Error("Expected error building index without one, got nil")
+	}
+
+	store := &mockVectorStore{
+		data: map[string][]float32{
+			"vec1": {1, 0, 0},
+			"vec2": {0, 1, 0},
+		},
+	}
+	idx := &mockIndex{}
+	embedder := New(store, WithIndex(idx))
+	if err := embedder.BuildIndex(); err != nil {
+		t.Fatalf("BuildIndex failed: %v", err)
+	}
+	if idx.Len() != 2 {
+		t.Errorf("Expected 2 indexed vectors, got %d", idx.Len())
+	}
+
+	// Test store error propagation
+	store.allErr = errors.New("store error")
+	if err := embedder.BuildIndex(); err == nil {
+		t.Error("Expected store error to propagate, got nil")
+	}
+}
+
+func TestMemoryStoreAddGet(t *testing.T) {
+	store := NewMemoryStore()
+
+	meta := map[string]any{"key": "value"}
+	if err := store.Add("test", []float32{3, 4}, meta); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	vec, norm, gotMeta, err := store.Get("test")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if !reflect.DeepEqual(vec, []float32{3, 4}) {
+		t.Errorf("Expected vector [3 4], got %v", vec)
+	}
+	if norm != 5 {
+		t.Errorf("Expected norm 5, got %f", norm)
+	}
+	if !reflect.DeepEqual(gotMeta, meta) {
+		t.Errorf("Expected metadata %v, got %v", meta, gotMeta)
+	}
+
+	// Metadata is copied on the way in
+	meta["key"] = "changed"
+	_, _, gotMeta, _ = store.Get("test")
+	if gotMeta["key"] != "value" {
+		t.Error("Stored metadata was mutated through the caller's map")
+	}
+
+	// SaveVector drops metadata
+	_ = store.SaveVector("test", []float32{3, 4})
+	_, _, gotMeta, _ = store.Get("test")
+	if gotMeta != nil {
+		t.Errorf("Expected SaveVector to clear metadata, got %v", gotMeta)
+	}
+
+	if _, _, _, err := store.Get("nonexistent"); err == nil {
+		t.Error("Expected error for non-existent vector, got nil")
+	}
+}
+
+func TestMemoryStoreDeleteUpsert(t *testing.T) {
+	store := NewMemoryStore()
+	_ = store.Add("a", []float32{1, 0}, map[string]any{"k": "v"})
+	_ = store.SaveVector("b", []float32{0, 1})
+	_ = store.SaveVector("c", []float32{1, 1})
+
+	// Test Delete
+	if err := store.Delete("a"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, _, _, err := store.Get("a"); err == nil {
+		t.Error("Expected deleted vector to be gone")
+	}
+	if err := store.Delete("a"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	// Test DeleteMany skips missing IDs
+	n, err := store.DeleteMany([]string{"b", "missing", "c"})
+	if err != nil || n != 2 {
+		t.Errorf("Expected 2 deletions, got %d, %v", n, err)
+	}
+
+	// Test Upsert modes
+	if err := store.Upsert("x", []float32{1, 2}, nil, UpdateOnly); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for update-only on missing id, got %v", err)
+	}
+	if err := store.Upsert("x", []float32{1, 2}, nil, InsertOnly); err != nil {
+		t.Fatalf("insert-only Upsert failed: %v", err)
+	}
+	if err := store.UpsertVector("x", []float32{3, 4}, InsertOnly); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("Expected ErrAlreadyExists for insert-only on existing id, got %v", err)
+	}
+	if err := store.Upsert("x", []float32{3, 4}, map[string]any{"n": 1}, UpdateOnly); err != nil {
+		t.Fatalf("update-only Upsert failed: %v", err)
+	}
+	vec, _, meta, _ := store.Get("x")
+	if !reflect.DeepEqual(vec, []float32{3, 4}) || meta["n"] != 1 {
+		t.Errorf("Expected updated vector and metadata, got %v %v", vec, meta)
+	}
+	if err := store.UpsertVector("y", []float32{1, 2}, UpsertAny); err != nil {
+		t.Errorf("UpsertAny failed: %v", err)
+	}
+	if err := store.UpsertVector("z", []float32{1, 2}, UpsertMode(42)); err == nil {
+		t.Error("Expected error for invalid upsert mode, got nil")
+	}
+}
+
+func TestEmbedderDeleteUpsert(t *testing.T) {
+	store := &mockVectorStore{}
+	idx := &mockIndex{}
+	embedder := New(store, WithIndex(idx))
+
+	if err := embedder.Upsert("a", []float32{1, 0}, InsertOnly); err != nil {
+		t.Fatalf("Upsert failed: %v", err)
+	}
+	if _, ok := idx.data["a"]; !ok {
+		t.Error("Upsert did not insert the vector into the index")
+	}
+	if err := embedder.Upsert("a", []float32{0, 1}, InsertOnly); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("Expected ErrAlreadyExists, got %v", err)
+	}
+	if err := embedder.Upsert("a", nil, UpsertAny); err == nil {
+		t.Error("Expected error for empty vector, got nil")
+	}
+
+	if err := embedder.Delete("a"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, ok := idx.data["a"]; ok {
+		t.Error("Delete did not remove the vector from the index")
+	}
+	if err := embedder.Delete("a"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	_ = embedder.Add("b", []float32{1, 1})
+	_ = embedder.Add("c", []float32{1, 2})
+	n, err := embedder.DeleteMany([]string{"b", "c", "missing"})
+	if err != nil || n != 2 {
+		t.Errorf("Expected 2 deletions, got %d, %v", n, err)
+	}
+	if idx.Len() != 0 {
+		t.Errorf("Expected empty index, got %d vectors", idx.Len())
+	}
+
+	store.delErr = errors.New("store error")
+	if _, err := embedder.DeleteMany([]string{"x"}); err == nil {
+		t.Error("Expected store error to propagate, got nil")
+	}
+}
+
+func TestMemoryStoreScan(t *testing.T) {
+	store := NewMemoryStore()
+	_ = store.Add("b", []float32{3, 4}, map[string]any{"k": "v"})
+	_ = store.SaveVector("a", []float32{1, 0})
+	_ = store.SaveVector("c", []float32{0, 1})
+
+	var records []Record
+	if err := store.Scan(func(r Record) error {
+		records = append(records, r)
+		return nil
+	}); err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if len(records) != 3 || records[0].ID != "a" || records[1].ID != "b" || records[2].ID != "c" {
+		t.Fatalf("Expected records in ID order, got %v", records)
+	}
+	if records[1].Norm != 5 || records[1].Meta["k"] != "v" {
+		t.Errorf("Expected norm and metadata of b, got %+v", records[1])
+	}
+
+	stop := errors.New("stop")
+	n := 0
+	err := store.Scan(func(r Record) error {
+		n++
+		return stop
+	})
+	if !errors.Is(err, stop) || n != 1 {
+		t.Errorf("Expected Scan to stop at the first error, got %v after %d records", err, n)
+	}
+}
+
+func TestMemoryStoreAddBatch(t *testing.T) {
+	store := NewMemoryStoreWithDim(2)
+	_ = store.SaveVector("existing", []float32{1, 1})
+
+	batch := []Record{
+		{ID: "a", Vector: []float32{1, 0}, Meta: map[string]any{"n": 1}},
+		{ID: "b", Vector: []float32{1, 2, 3}},
+		{ID: "existing", Vector: []float32{0, 1}},
+		{ID: "a", Vector: []float32{0, 1}},
+		{ID: "", Vector: []float32{0, 1}},
+	}
+
+	// An atomic batch with rejected records writes nothing.
+	err := store.AddBatch(batch, BatchOptions{Mode: InsertOnly, Atomic: true})
+	var be *BatchError
+	if !errors.As(err, &be) || be.Written != 0 || len(be.Items) != 4 {
+		t.Fatalf("Expected 4 rejected items and nothing written, got %v", err)
+	}
+	if _, err := store.GetVector("a"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected atomic batch to write nothing, got %v", err)
+	}
+
+	// A best-effort batch writes the valid records.
+	err = store.AddBatch(batch, BatchOptions{Mode: InsertOnly})
+	if !errors.As(err, &be) || be.Written != 1 {
+		t.Fatalf("Expected one written record, got %v", err)
+	}
+	want := []int{1, 2, 3, 4}
+	for i, item := range be.Items {
+		if item.Index != want[i] {
+			t.Errorf("Expected rejected item %d at index %d, got %d", i, want[i], item.Index)
+		}
+	}
+	if !errors.Is(err, ErrAlreadyExists) {
+		t.Error("Expected BatchError to match ErrAlreadyExists")
+	}
+	_, _, meta, err := store.Get("a")
+	if err != nil || meta["n"] != 1 {
+		t.Errorf("Expected a with metadata, got %v, %v", meta, err)
+	}
+
+	// Later records overwrite earlier ones under UpsertAny.
+	if err := store.AddBatch(batch[3:4], BatchOptions{}); err != nil {
+		t.Fatalf("AddBatch failed: %v", err)
+	}
+	if vec, _ := store.GetVector("a"); !reflect.DeepEqual(vec, []float32{0, 1}) {
+		t.Errorf("Expected a to be replaced, got %v", vec)
+	}
+	if err := store.AddBatch(nil, BatchOptions{Atomic: true}); err != nil {
+		t.Errorf("Expected empty batch to succeed, got %v", err)
+	}
+}
+
+func TestEmbedderAddBatch(t *testing.T) {
+	batch := []Record{
+		{ID: "a", Vector: []float32{1, 0}},
+		{ID: "b"},
+		{ID: "c", Vector: []float32{0, 1}},
+	}
+
+	// Stores implementing BatchWriter write the batch themselves.
+	idx := &mockIndex{}
+	embedder := New(NewMemoryStore(), WithIndex(idx))
+	err := embedder.AddBatch(batch, BatchOptions{})
+	var be *BatchError
+	if !errors.As(err, &be) || len(be.Items) != 1 || be.Items[0].ID != "b" {
+		t.Fatalf("Expected b to be rejected, got %v", err)
+	}
+	if idx.Len() != 2 || idx.data["c"] == nil {
+		t.Errorf("Expected a and c to be indexed, got %v", idx.data)
+	}
+
+	idx = &mockIndex{}
+	embedder = New(NewMemoryStore(), WithIndex(idx))
+	if err := embedder.AddBatch(batch, BatchOptions{Atomic: true}); err == nil {
+		t.Fatal("Expected atomic batch to be rejected, got nil")
+	}
+	if idx.Len() != 0 {
+		t.Errorf("Expected nothing indexed after a rejected atomic batch, got %v", idx.data)
+	}
+
+	// Plain VectorStores fall back to one upsert per record.
+	store := &mockVectorStore{}
+	embedder = New(store)
+	err = embedder.AddBatch(batch, BatchOptions{})
+	if !errors.As(err, &be) || be.Written != 2 || len(store.data) != 2 {
+		t.Fatalf("Expected 2 records written by the fallback, got %v, %v", err, store.data)
+	}
+	if err := embedder.AddBatch(batch[:1], BatchOptions{Atomic: true}); err == nil {
+		t.Error("Expected error for atomic batch on a plain VectorStore, got nil")
+	}
+}
+
+func TestMemoryStoreQuantize(t *testing.T) {
+	s := NewMemoryStoreWithDim(2)
+	if err := s.Quantize(QuantizationConfig{}); err == nil {
+		t.Error("Expected error quantizing an empty store, got nil")
+	}
+	_ = s.Add("a", []float32{1, 0}, map[string]any{"k": "a"})
+	_ = s.Add("b", []float32{0, 1}, nil)
+	_ = s.Add("c", []float32{0.6, 0.8}, nil)
+	if err := s.Quantize(QuantizationConfig{Product: &vector.DefaultPQConfig}); err == nil {
+		t.Error("Expected product quantization to be unsupported, got nil")
+	}
+	if err := s.Quantize(QuantizationConfig{Sample: -1}); err == nil {
+		t.Error("Expected error for a negative sample size, got nil")
+	}
+
+	if err := s.Quantize(QuantizationConfig{}); err != nil {
+		t.Fatalf("Quantize failed: %v", err)
+	}
+	if len(s.data) != 0 {
+		t.Errorf("Expected full-precision vectors to be dropped without rescoring, got %d", len(s.data))
+	}
+	if stats, _ := s.Stats(); !stats.Quantized || stats.Count != 3 || stats.Dim != 2 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	vec, norm, meta, err := s.Get("c")
+	if err != nil || norm != 1 || meta["k"] != nil || math.Abs(float64(vec[1]-0.8)) > 0.01 {
+		t.Errorf("Unexpected reconstructed record: %v %v %v %v", vec, norm, meta, err)
+	}
+	results, _ := s.Search([]float32{1, 0.1}, 2)
+	if len(results) != 2 || results[0].ID != "a" || results[1].ID != "c" || results[0].Meta["k"] != "a" {
+		t.Errorf("Unexpected quantized results: %v", results)
+	}
+	if batch, err := s.SearchBatch([][]float32{{1, 0.1}, {0, 1}}, 2); err != nil || !reflect.DeepEqual(batch[0], results) || batch[1][0].ID != "b" {
+		t.Errorf("Expected quantized batch results like Search, got %v, %v", batch, err)
+	}
+
+	// New vectors are quantized on write, and must match the quantizer.
+	_ = s.Add("d", []float32{0.1, 0.9}, nil)
+	if results, _ := s.Search([]float32{0, 1}, 1); len(results) != 1 || results[0].ID != "b" {
+		t.Errorf("Unexpected results after add: %v", results)
+	}
+	if n, _ := s.DeleteMany([]string{"a", "d", "zzz"}); n != 2 {
+		t.Errorf("Expected 2 deletions, got %d", n)
+	}
+	if err := s.Quantize(QuantizationConfig{Rescore: 2}); err == nil {
+		t.Error("Expected error rescoring after dropping full-precision vectors, got nil")
+	}
+
+	u := NewMemoryStore()
+	_ = u.Add("a", []float32{1, 2, 3}, nil)
+	if err := u.Quantize(QuantizationConfig{Rescore: 2}); err != nil {
+		t.Fatalf("Quantize failed: %v", err)
+	}
+	if err := u.Add("b", []float32{1, 2}, nil); err == nil {
+		t.Error("Expected dimension mismatch after quantizing, got nil")
+	}
+	if vec, _ := u.GetVector("a"); !reflect.DeepEqual(vec, []float32{1, 2, 3}) {
+		t.Errorf("Expected full-precision vector with rescoring, got %v", vec)
+	}
+	if results, _ := u.Search([]float32{1, 2, 3}, 1); len(results) != 1 || results[0].Score != vector.Cosine([]float32{1, 2, 3}, []float32{1, 2, 3}) {
+		t.Errorf("Expected rescored exact score, got %v", results)
+	}
+}
+
+// quantizationDataset returns n random vectors and q queries of dimension dim.
+func quantizationDataset(n, q, dim int) (vecs, queries [][]float32) {
+	rng := rand.New(rand.NewSource(42))
+	gen := func() []float32 {
+		v := make([]float32, dim)
+		for i := range v {
+			v[i] = float32(rng.NormFloat64())
+		}
+		return v
+	}
+	for i := 0; i < n; i++ {
+		vecs = append(vecs, gen())
+	}
+	for i := 0; i < q; i++ {
+		queries = append(queries, gen())
+	}
+	return vecs, queries
+}
+
+// quantizedRecall returns the recall@k of a store quantized with cfg,
+// measured against the exact results of an unquantized store.
+func quantizedRecall(tb testing.TB, vecs, queries [][]float32, k int, cfg QuantizationConfig) float64 {
+	tb.Helper()
+	exact, quant := NewMemoryStore(), NewMemoryStore()
+	for i, v := range vecs {
+		id := fmt.Sprint(i)
+		_ = exact.Add(id, v, nil)
+		_ = quant.Add(id, v, nil)
+	}
+	if err := quant.Quantize(cfg); err != nil {
+		tb.Fatalf("Quantize failed: %v", err)
+	}
+
+	found := 0
+	for _, q := range queries {
+		want, _ := exact.Search(q, k)
+		got, _ := quant.Search(q, k)
+		ids := make(map[string]bool, k)
+		for _, r := range want {
+			ids[r.ID] = true
+		}
+		for _, r := range got {
+			if ids[r.ID] {
+				found++
+			}
+		}
+	}
+	return float64(found) / float64(k*len(queries))
+}
+
+func TestMemoryStoreBinaryQuantize(t *testing.T) {
+	s := NewMemoryStoreWithDim(4)
+	_ = s.Add("a", []float32{1, 2, -1, -2}, map[string]any{"k": "a"})
+	_ = s.Add("b", []float32{-1, -2, 1, 2}, nil)
+	_ = s.Add("c", []float32{1, 2, 1, -2}, nil)
+	if err := s.Quantize(QuantizationConfig{Binary: true}); err != nil {
+		t.Fatalf("Quantize failed: %v", err)
+	}
+
+	// Scores are Hamming similarities of the sign bits.
+	results, _ := s.Search([]float32{3, 1, -5, -1}, 3)
+	if len(results) != 3 || results[0].ID != "a" || results[0].Score != 1 || results[1].Score != 0.75 || results[2].Score != 0 {
+		t.Fatalf("Unexpected binary results: %v", results)
+	}
+	if results[0].Meta["k"] != "a" {
+		t.Errorf("Expected metadata, got %v", results[0].Meta)
+	}
+
+	// The full-precision vectors are kept, and writes are binarized.
+	if vec, _ := s.GetVector("c"); vec[2] != 1 {
+		t.Errorf("Expected the original vector, got %v", vec)
+	}
+	_ = s.Add("d", []float32{1, 2, -1, -1.5}, nil)
+	if err := s.Add("e", []float32{1}, nil); err == nil {
+		t.Error("Expected dimension mismatch, got nil")
+	}
+	if stats, _ := s.Stats(); !stats.Quantized || stats.Count != 4 || stats.Dim != 4 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	// Rescoring reranks the binary candidates with the originals.
+	if err := s.Quantize(QuantizationConfig{Binary: true, Rescore: 2}); err != nil {
+		t.Fatalf("Quantize failed: %v", err)
+	}
+	results, _ = s.Search([]float32{1, 2, -1, -1.5}, 1)
+	if len(results) != 1 || results[0].ID != "d" || results[0].Score < 0.9999 {
+		t.Errorf("Expected rescored exact match, got %v", results)
 	}
 
-	// Test orthogonal vectors (should give 0.0)
-	a = []float32{1, 0, 0}
-	b = []float32{0, 1, 0}
-	result = cosineSimilarity(a, b)
-	if result != 0.0 {
-		t.Errorf("Expected 0.0 for orthogonal vectors, got %f", result)
+	// Switching to int8 drops the bit vectors.
+	if err := s.Quantize(QuantizationConfig{Rescore: 1}); err != nil {
+		t.Fatalf("Quantize failed: %v", err)
 	}
+	if s.bits != nil {
+		t.Error("Expected bit vectors to be dropped")
+	}
+	if err := s.Quantize(QuantizationConfig{}); err != nil {
+		t.Fatalf("Quantize failed: %v", err)
+	}
+	if err := s.Quantize(QuantizationConfig{Binary: true}); err == nil {
+		t.Error("Expected error binarizing after the vectors were dropped, got nil")
+	}
+}
+
+func TestQuantizationRecall(t *testing.T) {
+	vecs, queries := quantizationDataset(2000, 50, 128)
+
+	tests := []struct {
+		cfg       QuantizationConfig
+		minRecall float64
+	}{
+		{QuantizationConfig{}, 0.9},
+		{QuantizationConfig{Calibration: vector.CalibrateGlobal}, 0.85},
+		{QuantizationConfig{Rescore: 4}, 0.99},
+		// One bit per dimension of 128 is a coarse first pass that
+		// rescoring makes usable.
+		{QuantizationConfig{Binary: true}, 0.1},
+		{QuantizationConfig{Binary: true, Rescore: 10}, 0.5},
+	}
+	for _, tt := range tests {
+		if recall := quantizedRecall(t, vecs, queries, 10, tt.cfg); recall < tt.minRecall {
+			t.Errorf("%+v: expected recall@10 >= %v, got %v", tt.cfg, tt.minRecall, recall)
+		}
+	}
+}
+
+// BenchmarkMemoryStoreQuantizedSearch reports search time, recall@10 and the
+// bytes stored per vector of 2000 768-dimensional vectors, unquantized and
+// quantized with and without rescoring.
+func BenchmarkMemoryStoreQuantizedSearch(b *testing.B) {
+	const dim = 768
+	vecs, queries := quantizationDataset(2000, 20, dim)
+
+	tests := []struct {
+		name  string
+		cfg   *QuantizationConfig
+		bytes int
+	}{
+		{"float32", nil, 4 * dim},
+		{"int8", &QuantizationConfig{}, dim + 4},
+		{"int8-rescore4", &QuantizationConfig{Rescore: 4}, 5*dim + 4},
+		{"binary-rescore10", &QuantizationConfig{Binary: true, Rescore: 10}, 4*dim + dim/8 + 4},
+	}
+	for _, tt := range tests {
+		b.Run(tt.name, func(b *testing.B) {
+			s := NewMemoryStore()
+			for i, v := range vecs {
+				_ = s.Add(fmt.Sprint(i), v, nil)
+			}
+			recall := 1.0
+			if tt.cfg != nil {
+				_ = s.Quantize(*tt.cfg)
+				recall = quantizedRecall(b, vecs, queries, 10, *tt.cfg)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, _ = s.Search(queries[i%len(queries)], 10)
+			}
+			b.ReportMetric(recall, "recall@10")
+			b.ReportMetric(float64(tt.bytes), "bytes/vec")
+		})
+	}
+}
+
+// BenchmarkMemoryStoreSearch1M measures exact searches over a million small
+// vectors, where selecting the top k dominates scoring.
+func BenchmarkMemoryStoreSearch1M(b *testing.B) {
+	vecs, queries := quantizationDataset(1_000_000, 20, 8)
+	s := NewMemoryStore()
+	for i, v := range vecs {
+		_ = s.Add(fmt.Sprint(i), v, nil)
+	}
+	for _, k := range []int{10, 100} {
+		b.Run(fmt.Sprintf("k=%d", k), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_, _ = s.Search(queries[i%len(queries)], k)
+			}
+		})
+	}
+}
 
-	// Test opposite vectors (should give -1.0)
-	a = []float32{1, 0, 0}
-	b = []float32{-1, 0, 0}
-	result = cosineSimilarity(a, b)
-	if result != -1.0 {
-		t.Errorf("Expected -1.0 for opposite vectors, got %f", result)
+// BenchmarkEmbedderSearchBatch compares a batch of 100 queries against as
+// many calls to Search, over 100k vectors of dimension 128.
+func BenchmarkEmbedderSearchBatch(b *testing.B) {
+	vecs, queries := quantizationDataset(100_000, 100, 128)
+	e := New(NewMemoryStore())
+	for i, v := range vecs {
+		_ = e.Add(fmt.Sprint(i), v)
 	}
+	b.Run("batch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, _ = e.SearchBatch(queries, 10)
+		}
+	})
+	b.Run("loop", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, q := range queries {
+				_, _ = e.Search(q, 10)
+			}
+		}
+	})
 }
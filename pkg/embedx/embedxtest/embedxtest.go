@@ -0,0 +1,835 @@
+// Package embedxtest implements conformance suites for implementations of
+// embedx.Store and embedx.VectorStore.
+//
+// A store package runs the suites from its own tests:
+//
+//	func TestConformance(t *testing.T) {
+//		embedxtest.TestStore(t, func(t *testing.T) embedx.Store {
+//			return mystore.New(embedxtest.Dim)
+//		})
+//	}
+package embedxtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/ldaidone/goembedx/pkg/embedx"
+)
+
+// Dim is the dimension of the vectors stored by the suites. Stores under test
+// must reject vectors and queries of any other dimension.
+const Dim = 4
+
+// tolerance bounds the difference between a stored and a returned vector
+// component, so that stores that round vectors, such as half-precision
+// stores, conform.
+const tolerance = 1e-2
+
+// query is the query of the search tests.
+var query = []float32{1, 0, 0, 0}
+
+// fixtures are unit vectors, so that they rank in the same order under the
+// cosine, dot and Euclidean metrics. Against query they rank in slice order.
+var fixtures = []struct {
+	id  string
+	vec []float32
+}{
+	{"a", []float32{1, 0, 0, 0}},
+	{"b", []float32{0.8, 0.6, 0, 0}},
+	{"c", []float32{0.6, 0, 0.8, 0}},
+	{"d", []float32{0, 0, 0, 1}},
+	{"e", []float32{-1, 0, 0, 0}},
+}
+
+// vec returns a copy of the fixture vector with the given ID.
+func vec(id string) []float32 {
+	for _, f := range fixtures {
+		if f.id == id {
+			return slices.Clone(f.vec)
+		}
+	}
+	panic("embedxtest: unknown fixture " + id)
+}
+
+// approxEqual reports whether a and b have the same length and their
+// components differ by at most tolerance.
+func approxEqual(a, b []float32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.Abs(float64(a[i]-b[i])) > tolerance {
+			return false
+		}
+	}
+	return true
+}
+
+// ids returns the IDs of results in order.
+func ids(results []embedx.SearchResult) []string {
+	out := make([]string, len(results))
+	for i, r := range results {
+		out[i] = r.ID
+	}
+	return out
+}
+
+// TestStore runs the embedx.Store conformance suite. newStore must return a
+// new empty store for vectors of dimension Dim on every call; the suite
+// closes every store it gets. The suite checks CRUD, upsert modes, batches,
+// search ordering, ties, k handling, filters, batch searches, typed errors, copy isolation,
+// cancellation and concurrent use. Run it with the race detector to catch unsynchronized
+// stores.
+func TestStore(t *testing.T, newStore func(t *testing.T) embedx.Store) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, s embedx.Store)
+	}{
+		{"AddGet", testAddGet},
+		{"AddReplaces", testAddReplaces},
+		{"GetMissing", testGetMissing},
+		{"UpsertModes", testUpsertModes},
+		{"Delete", testDelete},
+		{"InvalidWrites", testInvalidWrites},
+		{"AddBatch", testAddBatch},
+		{"SearchOrder", testSearchOrder},
+		{"SearchK", testSearchK},
+		{"SearchTies", testSearchTies},
+		{"SearchEmpty", testSearchEmpty},
+		{"SearchDimension", testSearchDimension},
+		{"SearchWithFilter", testSearchWithFilter},
+		{"SearchBatch", testSearchBatch},
+		{"CopyIsolation", testCopyIsolation},
+		{"Cancelled", testCancelled},
+		{"CancelledScan", testCancelledScan},
+		{"Concurrency", testConcurrency},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newStore(t)
+			defer func() {
+				if err := s.Close(); err != nil {
+					t.Errorf("Close failed: %v", err)
+				}
+			}()
+			tt.run(t, s)
+		})
+	}
+}
+
+// addFixtures adds every fixture to s with its ID as "name" metadata.
+func addFixtures(t *testing.T, s embedx.Store) {
+	t.Helper()
+	for _, f := range fixtures {
+		if err := s.Add(f.id, f.vec, map[string]any{"name": f.id}); err != nil {
+			t.Fatalf("Add(%q) failed: %v", f.id, err)
+		}
+	}
+}
+
+func testAddGet(t *testing.T, s embedx.Store) {
+	if err := s.Add("b", vec("b"), map[string]any{"color": "red"}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	got, norm, meta, err := s.Get("b")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if !approxEqual(got, vec("b")) {
+		t.Errorf("Expected vector %v, got %v", vec("b"), got)
+	}
+	if math.Abs(float64(norm-1)) > tolerance {
+		t.Errorf("Expected norm 1, got %v", norm)
+	}
+	if meta["color"] != "red" {
+		t.Errorf("Expected metadata color=red, got %v", meta)
+	}
+}
+
+func testAddReplaces(t *testing.T, s embedx.Store) {
+	if err := s.Add("a", vec("a"), map[string]any{"v": "1"}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := s.Add("a", vec("b"), nil); err != nil {
+		t.Fatalf("Add of an existing ID failed: %v", err)
+	}
+	got, _, meta, err := s.Get("a")
+	if err != nil || !approxEqual(got, vec("b")) || len(meta) != 0 {
+		t.Errorf("Expected the vector and metadata to be replaced, got %v, %v, %v", got, meta, err)
+	}
+	if results, err := s.Search(query, 0); err != nil || len(results) != 1 {
+		t.Errorf("Expected a single result, got %v, %v", results, err)
+	}
+}
+
+func testGetMissing(t *testing.T, s embedx.Store) {
+	if _, _, _, err := s.Get("missing"); !errors.Is(err, embedx.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func testUpsertModes(t *testing.T, s embedx.Store) {
+	if err := s.Upsert("a", vec("a"), nil, embedx.InsertOnly); err != nil {
+		t.Fatalf("InsertOnly of a new ID failed: %v", err)
+	}
+	if err := s.Upsert("a", vec("b"), nil, embedx.InsertOnly); !errors.Is(err, embedx.ErrAlreadyExists) {
+		t.Errorf("Expected ErrAlreadyExists, got %v", err)
+	}
+	if err := s.Upsert("b", vec("b"), nil, embedx.UpdateOnly); !errors.Is(err, embedx.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if _, _, _, err := s.Get("b"); !errors.Is(err, embedx.ErrNotFound) {
+		t.Errorf("Expected a rejected UpdateOnly to store nothing, got %v", err)
+	}
+	if err := s.Upsert("a", vec("c"), map[string]any{"v": "2"}, embedx.UpdateOnly); err != nil {
+		t.Fatalf("UpdateOnly of an existing ID failed: %v", err)
+	}
+	if got, _, meta, _ := s.Get("a"); !approxEqual(got, vec("c")) || meta["v"] != "2" {
+		t.Errorf("Expected the vector to be updated, got %v, %v", got, meta)
+	}
+	if err := s.Upsert("b", vec("b"), nil, embedx.UpsertAny); err != nil {
+		t.Errorf("UpsertAny of a new ID failed: %v", err)
+	}
+}
+
+func testDelete(t *testing.T, s embedx.Store) {
+	addFixtures(t, s)
+	if err := s.Delete("a"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, _, _, err := s.Get("a"); !errors.Is(err, embedx.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after Delete, got %v", err)
+	}
+	if err := s.Delete("a"); !errors.Is(err, embedx.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting twice, got %v", err)
+	}
+	if n, err := s.DeleteMany([]string{"b", "missing", "c"}); err != nil || n != 2 {
+		t.Errorf("Expected DeleteMany to remove 2 vectors, got %d, %v", n, err)
+	}
+	results, err := s.Search(query, 0)
+	if got := fmt.Sprint(ids(results)); err != nil || got != "[d e]" {
+		t.Errorf("Expected [d e] to remain, got %s, %v", got, err)
+	}
+}
+
+func testInvalidWrites(t *testing.T, s embedx.Store) {
+	if err := s.Add("", vec("a"), nil); !errors.Is(err, embedx.ErrInvalidID) {
+		t.Errorf("Expected ErrInvalidID for an empty ID, got %v", err)
+	}
+	if err := s.Upsert("", vec("a"), nil, embedx.UpsertAny); !errors.Is(err, embedx.ErrInvalidID) {
+		t.Errorf("Expected ErrInvalidID for an empty ID, got %v", err)
+	}
+	if err := s.Add("x", nil, nil); err == nil {
+		t.Error("Expected an error for a nil vector")
+	}
+	if err := s.Add("x", []float32{}, nil); err == nil {
+		t.Error("Expected an error for an empty vector")
+	}
+	var dimErr *embedx.DimensionError
+	err := s.Add("x", []float32{1, 2, 3}, nil)
+	if !errors.Is(err, embedx.ErrDimensionMismatch) || !errors.As(err, &dimErr) || dimErr.Expected != Dim || dimErr.Actual != 3 {
+		t.Errorf("Expected a DimensionError, got %v", err)
+	}
+	if _, _, _, err := s.Get("x"); !errors.Is(err, embedx.ErrNotFound) {
+		t.Errorf("Expected rejected writes to store nothing, got %v", err)
+	}
+	if results, err := s.Search(query, 0); err != nil || len(results) != 0 {
+		t.Errorf("Expected no results, got %v, %v", results, err)
+	}
+}
+
+func testAddBatch(t *testing.T, s embedx.Store) {
+	if err := s.Add("a", vec("a"), nil); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	// A best-effort batch writes every valid record.
+	err := s.AddBatch([]embedx.Record{
+		{ID: "a", Vector: vec("a")},
+		{ID: "b", Vector: vec("b")},
+		{ID: "", Vector: vec("c")},
+		{ID: "d", Vector: []float32{1}},
+	}, embedx.BatchOptions{Mode: embedx.InsertOnly})
+	var batchErr *embedx.BatchError
+	if !errors.As(err, &batchErr) || batchErr.Written != 1 || len(batchErr.Items) != 3 {
+		t.Fatalf("Expected a BatchError with 3 items and 1 write, got %v", err)
+	}
+	for i, want := range []error{embedx.ErrAlreadyExists, embedx.ErrInvalidID, embedx.ErrDimensionMismatch} {
+		if item := batchErr.Items[i]; item.Index != []int{0, 2, 3}[i] || !errors.Is(item, want) {
+			t.Errorf("Expected item %d to match %v, got %v", i, want, item)
+		}
+	}
+	if _, _, _, err := s.Get("b"); err != nil {
+		t.Errorf("Expected the valid record to be written, got %v", err)
+	}
+
+	// An atomic batch writes nothing if any record is rejected.
+	err = s.AddBatch([]embedx.Record{
+		{ID: "c", Vector: vec("c")},
+		{ID: "e", Vector: []float32{1}},
+	}, embedx.BatchOptions{Atomic: true})
+	if !errors.As(err, &batchErr) || batchErr.Written != 0 || len(batchErr.Items) != 1 {
+		t.Errorf("Expected an atomic BatchError with 1 item, got %v", err)
+	}
+	if _, _, _, err := s.Get("c"); !errors.Is(err, embedx.ErrNotFound) {
+		t.Errorf("Expected the atomic batch to write nothing, got %v", err)
+	}
+	if err := s.AddBatch(nil, embedx.BatchOptions{}); err != nil {
+		t.Errorf("Expected an empty batch to succeed, got %v", err)
+	}
+}
+
+func testSearchOrder(t *testing.T, s embedx.Store) {
+	// Insertion order must not matter.
+	for _, i := range []int{3, 0, 4, 2, 1} {
+		f := fixtures[i]
+		if err := s.Add(f.id, f.vec, map[string]any{"name": f.id}); err != nil {
+			t.Fatalf("Add(%q) failed: %v", f.id, err)
+		}
+	}
+	results, err := s.Search(query, 3)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if got := fmt.Sprint(ids(results)); got != "[a b c]" {
+		t.Fatalf("Expected [a b c], got %s", got)
+	}
+	for i, r := range results {
+		if i > 0 && r.Score >= results[i-1].Score {
+			t.Errorf("Expected strictly descending scores, got %v", results)
+		}
+		if r.Meta["name"] != r.ID {
+			t.Errorf("Expected the metadata of %s, got %v", r.ID, r.Meta)
+		}
+	}
+}
+
+func testSearchK(t *testing.T, s embedx.Store) {
+	addFixtures(t, s)
+	for _, tc := range []struct{ k, want int }{
+		{1, 1},
+		{len(fixtures), len(fixtures)},
+		{len(fixtures) + 10, len(fixtures)},
+		{0, len(fixtures)},
+		{-1, len(fixtures)},
+	} {
+		results, err := s.Search(query, tc.k)
+		if err != nil || len(results) != tc.want {
+			t.Errorf("Search with k=%d: expected %d results, got %d, %v", tc.k, tc.want, len(results), err)
+		}
+	}
+}
+
+func testSearchTies(t *testing.T, s embedx.Store) {
+	// Equal vectors score the same and rank by ID, whatever the insertion order.
+	for _, id := range []string{"t3", "t1", "t4", "t0", "t2"} {
+		if err := s.Add(id, fixtures[0].vec, nil); err != nil {
+			t.Fatalf("Add(%q) failed: %v", id, err)
+		}
+	}
+	for _, tc := range []struct {
+		k    int
+		want string
+	}{
+		{2, "[t0 t1]"},
+		{4, "[t0 t1 t2 t3]"},
+		{0, "[t0 t1 t2 t3 t4]"},
+	} {
+		results, err := s.Search(query, tc.k)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if got := fmt.Sprint(ids(results)); got != tc.want {
+			t.Errorf("Search with k=%d: expected %s, got %s", tc.k, tc.want, got)
+		}
+	}
+}
+
+func testSearchEmpty(t *testing.T, s embedx.Store) {
+	if results, err := s.Search(query, 5); err != nil || len(results) != 0 {
+		t.Errorf("Expected no results from an empty store, got %v, %v", results, err)
+	}
+}
+
+func testSearchDimension(t *testing.T, s embedx.Store) {
+	addFixtures(t, s)
+	var dimErr *embedx.DimensionError
+	_, err := s.Search([]float32{1, 0, 0, 0, 0}, 1)
+	if !errors.As(err, &dimErr) || dimErr.Expected != Dim || dimErr.Actual != Dim+1 {
+		t.Errorf("Expected a DimensionError for the query, got %v", err)
+	}
+}
+
+func testSearchWithFilter(t *testing.T, s embedx.Store) {
+	for i, f := range fixtures {
+		group := []string{"even", "odd"}[i%2]
+		if err := s.Add(f.id, f.vec, map[string]any{"group": group}); err != nil {
+			t.Fatalf("Add(%q) failed: %v", f.id, err)
+		}
+	}
+	results, err := s.SearchWithFilter(query, 0, embedx.Eq("group", "even"))
+	if got := fmt.Sprint(ids(results)); err != nil || got != "[a c e]" {
+		t.Errorf("Expected [a c e], got %s, %v", got, err)
+	}
+	results, err = s.SearchWithFilter(query, 1, embedx.Eq("group", "odd"))
+	if got := fmt.Sprint(ids(results)); err != nil || got != "[b]" {
+		t.Errorf("Expected [b], got %s, %v", got, err)
+	}
+	if results, err := s.SearchWithFilter(query, 0, nil); err != nil || len(results) != len(fixtures) {
+		t.Errorf("Expected a nil filter to match every vector, got %v, %v", results, err)
+	}
+}
+
+func testSearchBatch(t *testing.T, s embedx.Store) {
+	if batch, err := s.SearchBatch([][]float32{query}, 3); err != nil || len(batch) != 1 || len(batch[0]) != 0 {
+		t.Errorf("Expected one empty result list from an empty store, got %v, %v", batch, err)
+	}
+	addFixtures(t, s)
+
+	// Every query gets exactly the results of Search.
+	queries := [][]float32{query, vec("d"), vec("c"), query}
+	for _, k := range []int{1, 3, 0} {
+		batch, err := s.SearchBatch(queries, k)
+		if err != nil {
+			t.Fatalf("SearchBatch with k=%d failed: %v", k, err)
+		}
+		if len(batch) != len(queries) {
+			t.Fatalf("SearchBatch with k=%d: expected %d result lists, got %d", k, len(queries), len(batch))
+		}
+		for i, q := range queries {
+			want, err := s.Search(q, k)
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			if !reflect.DeepEqual(batch[i], want) {
+				t.Errorf("SearchBatch with k=%d: query %d: expected %v, got %v", k, i, want, batch[i])
+			}
+		}
+	}
+
+	if batch, err := s.SearchBatch(nil, 3); err != nil || len(batch) != 0 {
+		t.Errorf("Expected no result lists for no queries, got %v, %v", batch, err)
+	}
+	var dimErr *embedx.DimensionError
+	_, err := s.SearchBatch([][]float32{query, {1, 0, 0, 0, 0}}, 1)
+	if !errors.As(err, &dimErr) || dimErr.Expected != Dim || dimErr.Actual != Dim+1 {
+		t.Errorf("Expected a DimensionError for the second query, got %v", err)
+	}
+}
+
+func testCopyIsolation(t *testing.T, s embedx.Store) {
+	v := vec("a")
+	meta := map[string]any{"k": "v"}
+	if err := s.Add("a", v, meta); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	records := []embedx.Record{{ID: "b", Vector: vec("b"), Meta: map[string]any{"k": "v"}}}
+	if err := s.AddBatch(records, embedx.BatchOptions{}); err != nil {
+		t.Fatalf("AddBatch failed: %v", err)
+	}
+
+	// Changing what was written must not change what is stored.
+	v[0], meta["k"], meta["extra"] = 9, "changed", true
+	records[0].Vector[1], records[0].Meta["k"] = 9, "changed"
+	check := func(when string) {
+		t.Helper()
+		for _, id := range []string{"a", "b"} {
+			got, _, m, err := s.Get(id)
+			if err != nil || !approxEqual(got, vec(id)) || m["k"] != "v" || len(m) != 1 {
+				t.Errorf("%s: expected %s to be unchanged, got %v, %v, %v", when, id, got, m, err)
+			}
+		}
+	}
+	check("after changing the written values")
+
+	// Changing what was returned must not change what is stored.
+	got, _, m, _ := s.Get("a")
+	if len(got) > 0 {
+		got[0] = 9
+	}
+	if m != nil {
+		m["k"] = "changed"
+	}
+	results, _ := s.Search(query, 0)
+	for _, r := range results {
+		if r.Meta != nil {
+			r.Meta["k"] = "changed"
+		}
+	}
+	check("after changing the returned values")
+}
+
+func testConcurrency(t *testing.T, s embedx.Store) {
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range writes {
+				id := workerID(w, i)
+				if err := s.Add(id, workerVec(w, i), map[string]any{"worker": id}); err != nil {
+					t.Errorf("Add(%q) failed: %v", id, err)
+				}
+				if _, _, _, err := s.Get(id); err != nil {
+					t.Errorf("Get(%q) failed: %v", id, err)
+				}
+				if _, err := s.Search(query, 3); err != nil {
+					t.Errorf("Search failed: %v", err)
+				}
+				if i%2 == 1 {
+					if err := s.Delete(id); err != nil {
+						t.Errorf("Delete(%q) failed: %v", id, err)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for w := range workers {
+		for i := range writes {
+			_, _, _, err := s.Get(workerID(w, i))
+			if deleted := i%2 == 1; deleted != errors.Is(err, embedx.ErrNotFound) || !deleted && err != nil {
+				t.Errorf("Get(%q) after concurrent writes: deleted %v, got %v", workerID(w, i), deleted, err)
+			}
+		}
+	}
+}
+
+// Concurrency tests run workers goroutines that each write writes vectors
+// and delete every other one.
+const workers, writes = 8, 24
+
+// workerID returns the ID of the i-th vector written by worker w.
+func workerID(w, i int) string {
+	return fmt.Sprintf("w%d-%d", w, i)
+}
+
+// workerVec returns the i-th vector written by worker w. Vectors are
+// distinct, so that approximate indexes find them all.
+func workerVec(w, i int) []float32 {
+	return []float32{1, float32(w + 1), float32(i + 1), 0}
+}
+
+// cancelledContext returns a context that is already cancelled.
+func cancelledContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}
+
+// cancelAfter is a context that is done from the (n+1)-th call to Err on,
+// so that a store that checks it once before a scan sees it done inside the
+// scan. It must not be used concurrently.
+type cancelAfter struct {
+	context.Context
+	n int
+}
+
+func (c *cancelAfter) Err() error {
+	if c.n > 0 {
+		c.n--
+		return nil
+	}
+	return context.Canceled
+}
+
+func testCancelled(t *testing.T, s embedx.Store) {
+	addFixtures(t, s)
+	ctx := cancelledContext()
+
+	if _, err := s.SearchContext(ctx, query, 0); !errors.Is(err, context.Canceled) {
+		t.Errorf("SearchContext: expected context.Canceled, got %v", err)
+	}
+	if _, err := s.SearchWithFilterContext(ctx, query, 0, embedx.Eq("name", "a")); !errors.Is(err, context.Canceled) {
+		t.Errorf("SearchWithFilterContext: expected context.Canceled, got %v", err)
+	}
+	if _, err := s.SearchBatchContext(ctx, [][]float32{query}, 0); !errors.Is(err, context.Canceled) {
+		t.Errorf("SearchBatchContext: expected context.Canceled, got %v", err)
+	}
+	if _, _, _, err := s.GetContext(ctx, "a"); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetContext: expected context.Canceled, got %v", err)
+	}
+
+	// Cancelled writes leave the store unchanged.
+	if err := s.AddContext(ctx, "f", vec("a"), nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("AddContext: expected context.Canceled, got %v", err)
+	}
+	if err := s.UpsertContext(ctx, "f", vec("a"), nil, embedx.UpsertAny); !errors.Is(err, context.Canceled) {
+		t.Errorf("UpsertContext: expected context.Canceled, got %v", err)
+	}
+	for _, atomic := range []bool{false, true} {
+		err := s.AddBatchContext(ctx, []embedx.Record{{ID: "f", Vector: vec("a")}}, embedx.BatchOptions{Atomic: atomic})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("AddBatchContext(Atomic: %v): expected context.Canceled, got %v", atomic, err)
+		}
+	}
+	if _, _, _, err := s.Get("f"); !errors.Is(err, embedx.ErrNotFound) {
+		t.Errorf("Expected cancelled writes to store nothing, got %v", err)
+	}
+	if err := s.DeleteContext(ctx, "a"); !errors.Is(err, context.Canceled) {
+		t.Errorf("DeleteContext: expected context.Canceled, got %v", err)
+	}
+	if n, err := s.DeleteManyContext(ctx, []string{"a", "b"}); n != 0 || !errors.Is(err, context.Canceled) {
+		t.Errorf("DeleteManyContext: expected 0 and context.Canceled, got %d, %v", n, err)
+	}
+	if results, err := s.Search(query, 0); err != nil || len(results) != len(fixtures) {
+		t.Errorf("Expected cancelled deletes to keep %d vectors, got %v, %v", len(fixtures), ids(results), err)
+	}
+
+	// A background context behaves like the plain methods.
+	results, err := s.SearchContext(context.Background(), query, 2)
+	if want := []string{"a", "b"}; err != nil || !slices.Equal(ids(results), want) {
+		t.Errorf("SearchContext: expected %v, got %v, %v", want, ids(results), err)
+	}
+}
+
+func testCancelledScan(t *testing.T, s embedx.Store) {
+	records := make([]embedx.Record, embedx.ContextCheckInterval+1)
+	for i := range records {
+		records[i] = embedx.Record{ID: fmt.Sprintf("s%d", i), Vector: []float32{1, float32(i + 1), 0, 0}}
+	}
+	if err := s.AddBatch(records, embedx.BatchOptions{}); err != nil {
+		t.Fatalf("AddBatch failed: %v", err)
+	}
+
+	// The context is checked before the scan at most once, so the scan
+	// must check it too.
+	ctx := &cancelAfter{Context: context.Background(), n: 1}
+	if _, err := s.SearchContext(ctx, query, 0); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the scan to stop with context.Canceled, got %v", err)
+	}
+	ctx = &cancelAfter{Context: context.Background(), n: 1}
+	if _, err := s.SearchBatchContext(ctx, [][]float32{query}, 0); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the batch scan to stop with context.Canceled, got %v", err)
+	}
+}
+
+// TestVectorStore runs the embedx.VectorStore conformance suite. newStore
+// must return a new empty store for vectors of dimension Dim on every call;
+// the suite closes every store it gets. The suite checks CRUD, upsert modes,
+// typed errors, copy isolation, cancellation and concurrent use. Run it with the race
+// detector to catch unsynchronized stores.
+func TestVectorStore(t *testing.T, newStore func(t *testing.T) embedx.VectorStore) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, s embedx.VectorStore)
+	}{
+		{"SaveGet", testSaveGet},
+		{"SaveReplaces", testSaveReplaces},
+		{"GetMissing", testGetVectorMissing},
+		{"UpsertModes", testUpsertVectorModes},
+		{"Delete", testDeleteVectors},
+		{"InvalidWrites", testInvalidVectors},
+		{"CopyIsolation", testVectorCopyIsolation},
+		{"Cancelled", testCancelledVectors},
+		{"Concurrency", testVectorConcurrency},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newStore(t)
+			defer func() {
+				if err := s.Close(); err != nil {
+					t.Errorf("Close failed: %v", err)
+				}
+			}()
+			tt.run(t, s)
+		})
+	}
+}
+
+// saveFixtures saves every fixture to s.
+func saveFixtures(t *testing.T, s embedx.VectorStore) {
+	t.Helper()
+	for _, f := range fixtures {
+		if err := s.SaveVector(f.id, f.vec); err != nil {
+			t.Fatalf("SaveVector(%q) failed: %v", f.id, err)
+		}
+	}
+}
+
+// checkAll checks that GetAllVectors returns exactly the fixtures with the given IDs.
+func checkAll(t *testing.T, s embedx.VectorStore, want ...string) {
+	t.Helper()
+	all, err := s.GetAllVectors()
+	if err != nil {
+		t.Fatalf("GetAllVectors failed: %v", err)
+	}
+	if len(all) != len(want) {
+		t.Errorf("Expected %d vectors, got %v", len(want), all)
+	}
+	for _, id := range want {
+		if !approxEqual(all[id], vec(id)) {
+			t.Errorf("Expected %s to be %v, got %v", id, vec(id), all[id])
+		}
+	}
+}
+
+func testSaveGet(t *testing.T, s embedx.VectorStore) {
+	saveFixtures(t, s)
+	for _, f := range fixtures {
+		if got, err := s.GetVector(f.id); err != nil || !approxEqual(got, f.vec) {
+			t.Errorf("GetVector(%q): expected %v, got %v, %v", f.id, f.vec, got, err)
+		}
+	}
+	checkAll(t, s, "a", "b", "c", "d", "e")
+}
+
+func testSaveReplaces(t *testing.T, s embedx.VectorStore) {
+	if err := s.SaveVector("b", vec("a")); err != nil {
+		t.Fatalf("SaveVector failed: %v", err)
+	}
+	if err := s.SaveVector("b", vec("b")); err != nil {
+		t.Fatalf("SaveVector of an existing ID failed: %v", err)
+	}
+	checkAll(t, s, "b")
+}
+
+func testGetVectorMissing(t *testing.T, s embedx.VectorStore) {
+	if _, err := s.GetVector("missing"); !errors.Is(err, embedx.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func testUpsertVectorModes(t *testing.T, s embedx.VectorStore) {
+	if err := s.UpsertVector("a", vec("b"), embedx.InsertOnly); err != nil {
+		t.Fatalf("InsertOnly of a new ID failed: %v", err)
+	}
+	if err := s.UpsertVector("a", vec("c"), embedx.InsertOnly); !errors.Is(err, embedx.ErrAlreadyExists) {
+		t.Errorf("Expected ErrAlreadyExists, got %v", err)
+	}
+	if err := s.UpsertVector("b", vec("b"), embedx.UpdateOnly); !errors.Is(err, embedx.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if err := s.UpsertVector("a", vec("a"), embedx.UpdateOnly); err != nil {
+		t.Errorf("UpdateOnly of an existing ID failed: %v", err)
+	}
+	if err := s.UpsertVector("c", vec("c"), embedx.UpsertAny); err != nil {
+		t.Errorf("UpsertAny of a new ID failed: %v", err)
+	}
+	checkAll(t, s, "a", "c")
+}
+
+func testDeleteVectors(t *testing.T, s embedx.VectorStore) {
+	saveFixtures(t, s)
+	if err := s.Delete("a"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := s.GetVector("a"); !errors.Is(err, embedx.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after Delete, got %v", err)
+	}
+	if err := s.Delete("a"); !errors.Is(err, embedx.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting twice, got %v", err)
+	}
+	if n, err := s.DeleteMany([]string{"b", "missing", "c"}); err != nil || n != 2 {
+		t.Errorf("Expected DeleteMany to remove 2 vectors, got %d, %v", n, err)
+	}
+	checkAll(t, s, "d", "e")
+}
+
+func testInvalidVectors(t *testing.T, s embedx.VectorStore) {
+	if err := s.SaveVector("", vec("a")); !errors.Is(err, embedx.ErrInvalidID) {
+		t.Errorf("Expected ErrInvalidID for an empty ID, got %v", err)
+	}
+	if err := s.UpsertVector("", vec("a"), embedx.UpsertAny); !errors.Is(err, embedx.ErrInvalidID) {
+		t.Errorf("Expected ErrInvalidID for an empty ID, got %v", err)
+	}
+	if err := s.SaveVector("x", nil); err == nil {
+		t.Error("Expected an error for a nil vector")
+	}
+	var dimErr *embedx.DimensionError
+	err := s.SaveVector("x", []float32{1, 2, 3})
+	if !errors.Is(err, embedx.ErrDimensionMismatch) || !errors.As(err, &dimErr) || dimErr.Expected != Dim || dimErr.Actual != 3 {
+		t.Errorf("Expected a DimensionError, got %v", err)
+	}
+	checkAll(t, s)
+}
+
+func testVectorCopyIsolation(t *testing.T, s embedx.VectorStore) {
+	v := vec("a")
+	if err := s.SaveVector("a", v); err != nil {
+		t.Fatalf("SaveVector failed: %v", err)
+	}
+	v[0] = 9
+	checkAll(t, s, "a")
+
+	if got, err := s.GetVector("a"); err == nil {
+		got[0] = 9
+	}
+	if all, err := s.GetAllVectors(); err == nil {
+		all["a"][1] = 9
+	}
+	checkAll(t, s, "a")
+}
+
+func testVectorConcurrency(t *testing.T, s embedx.VectorStore) {
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range writes {
+				id := workerID(w, i)
+				if err := s.SaveVector(id, workerVec(w, i)); err != nil {
+					t.Errorf("SaveVector(%q) failed: %v", id, err)
+				}
+				if _, err := s.GetVector(id); err != nil {
+					t.Errorf("GetVector(%q) failed: %v", id, err)
+				}
+				if _, err := s.GetAllVectors(); err != nil {
+					t.Errorf("GetAllVectors failed: %v", err)
+				}
+				if i%2 == 1 {
+					if err := s.Delete(id); err != nil {
+						t.Errorf("Delete(%q) failed: %v", id, err)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	all, err := s.GetAllVectors()
+	if want := workers * writes / 2; err != nil || len(all) != want {
+		t.Errorf("Expected %d vectors after concurrent writes, got %d, %v", want, len(all), err)
+	}
+	for w := range workers {
+		for i := 0; i < writes; i += 2 {
+			if got := all[workerID(w, i)]; !approxEqual(got, workerVec(w, i)) {
+				t.Errorf("Expected %s to be %v, got %v", workerID(w, i), workerVec(w, i), got)
+			}
+		}
+	}
+}
+
+func testCancelledVectors(t *testing.T, s embedx.VectorStore) {
+	saveFixtures(t, s)
+	ctx := cancelledContext()
+
+	if _, err := s.GetVectorContext(ctx, "a"); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetVectorContext: expected context.Canceled, got %v", err)
+	}
+	if _, err := s.GetAllVectorsContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetAllVectorsContext: expected context.Canceled, got %v", err)
+	}
+	if err := s.SaveVectorContext(ctx, "a", vec("e")); !errors.Is(err, context.Canceled) {
+		t.Errorf("SaveVectorContext: expected context.Canceled, got %v", err)
+	}
+	if err := s.UpsertVectorContext(ctx, "b", vec("e"), embedx.UpsertAny); !errors.Is(err, context.Canceled) {
+		t.Errorf("UpsertVectorContext: expected context.Canceled, got %v", err)
+	}
+	if err := s.DeleteContext(ctx, "c"); !errors.Is(err, context.Canceled) {
+		t.Errorf("DeleteContext: expected context.Canceled, got %v", err)
+	}
+	if n, err := s.DeleteManyContext(ctx, []string{"d", "e"}); n != 0 || !errors.Is(err, context.Canceled) {
+		t.Errorf("DeleteManyContext: expected 0 and context.Canceled, got %d, %v", n, err)
+	}
+	checkAll(t, s, "a", "b", "c", "d", "e")
+}
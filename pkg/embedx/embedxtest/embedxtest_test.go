@@ -0,0 +1,19 @@
+package embedxtest
+
+import (
+	"testing"
+
+	"github.com/ldaidone/goembedx/pkg/embedx"
+)
+
+func TestMemoryStore(t *testing.T) {
+	TestStore(t, func(t *testing.T) embedx.Store {
+		return embedx.NewMemoryStoreWithDim(Dim)
+	})
+}
+
+func TestMemoryVectorStore(t *testing.T) {
+	TestVectorStore(t, func(t *testing.T) embedx.VectorStore {
+		return embedx.NewMemoryStoreWithDim(Dim)
+	})
+}
@@ -0,0 +1,60 @@
+// Package embedx provides core vector embedding storage functionality.
+package embedx
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNotFound is returned when an operation targets a vector ID that is not stored.
+var ErrNotFound = errors.New("vector not found")
+
+// ErrAlreadyExists is returned by insert-only upserts when the vector ID is already stored.
+var ErrAlreadyExists = errors.New("vector already exists")
+
+// ErrDimensionMismatch is matched by every *DimensionError, for callers that
+// only need to know that a vector or query had the wrong dimension.
+var ErrDimensionMismatch = errors.New("vector dimension mismatch")
+
+// ErrEmptyStore is returned by operations that need stored vectors, such as
+// Embedder searches and quantizer training, when the store holds none.
+var ErrEmptyStore = errors.New("vector store is empty")
+
+// ErrInvalidID is returned when a write uses an ID that the store cannot hold,
+// such as an empty ID.
+var ErrInvalidID = errors.New("invalid vector ID")
+
+// DimensionError is returned when a vector or query does not have the
+// dimension a store requires. It matches ErrDimensionMismatch with errors.Is.
+type DimensionError struct {
+	// Expected is the dimension the store requires.
+	Expected int
+	// Actual is the dimension of the rejected vector or query.
+	Actual int
+}
+
+// Error implements the error interface.
+func (e *DimensionError) Error() string {
+	return fmt.Sprintf("%s: expected %d, got %d", ErrDimensionMismatch, e.Expected, e.Actual)
+}
+
+// Is reports whether target is ErrDimensionMismatch.
+func (e *DimensionError) Is(target error) bool {
+	return target == ErrDimensionMismatch
+}
+
+// SchemaError is returned when a store is opened or configured with settings
+// that conflict with its persisted schema.
+type SchemaError struct {
+	// Field names the conflicting setting, such as "dim" or "metric".
+	Field string
+	// Stored is the value recorded in the store.
+	Stored string
+	// Requested is the conflicting value.
+	Requested string
+}
+
+// Error implements the error interface.
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("store schema mismatch: %s is %s, not %s", e.Field, e.Stored, e.Requested)
+}
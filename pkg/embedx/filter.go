@@ -0,0 +1,423 @@
+// Package embedx provides core vector embedding storage functionality.
+package embedx
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Filter is a predicate over vector metadata used to restrict search results.
+// Filters are built with Eq, In, Range, Gt, Gte, Lt, Lte, TimeRange, Exists,
+// And, Or and Not, and are evaluated by stores before a candidate vector is scored.
+type Filter interface {
+	// Match reports whether the metadata satisfies the filter.
+	// A nil metadata map behaves like an empty one.
+	Match(meta map[string]any) bool
+}
+
+// Eq matches vectors whose metadata field equals value.
+// Numeric values compare by value regardless of their Go type, so Eq("n", 3)
+// matches a stored float64(3).
+func Eq(field string, value any) Filter {
+	return eqFilter{field: field, value: value}
+}
+
+// In matches vectors whose metadata field equals any of the given values.
+func In(field string, values ...any) Filter {
+	return inFilter{field: field, values: values}
+}
+
+// Range matches vectors whose numeric metadata field lies in [min, max].
+func Range(field string, min, max float64) Filter {
+	return rangeFilter{field: field, min: min, max: max, hasMin: true, hasMax: true}
+}
+
+// TimeRange matches vectors whose metadata field holds a time within
+// [from, to]: a time.Time, or a string in RFC 3339 format such as
+// "2024-01-10T00:00:00Z" as sent by JSON clients. A zero from or to leaves
+// that end of the range open. Dates stored as numbers, such as Unix
+// timestamps or 20240110, are compared with Range instead.
+func TimeRange(field string, from, to time.Time) Filter {
+	return timeRangeFilter{field: field, min: from, max: to, hasMin: !from.IsZero(), hasMax: !to.IsZero()}
+}
+
+// Gt matches vectors whose numeric metadata field is greater than v.
+func Gt(field string, v float64) Filter {
+	return rangeFilter{field: field, min: v, hasMin: true, exclMin: true}
+}
+
+// Gte matches vectors whose numeric metadata field is greater than or equal to v.
+func Gte(field string, v float64) Filter {
+	return rangeFilter{field: field, min: v, hasMin: true}
+}
+
+// Lt matches vectors whose numeric metadata field is less than v.
+func Lt(field string, v float64) Filter {
+	return rangeFilter{field: field, max: v, hasMax: true, exclMax: true}
+}
+
+// Lte matches vectors whose numeric metadata field is less than or equal to v.
+func Lte(field string, v float64) Filter {
+	return rangeFilter{field: field, max: v, hasMax: true}
+}
+
+// Exists matches vectors whose metadata contains the field, whatever its value.
+func Exists(field string) Filter {
+	return existsFilter{field: field}
+}
+
+// And matches vectors that satisfy every given filter.
+// And with no filters matches everything.
+func And(filters ...Filter) Filter {
+	return andFilter(filters)
+}
+
+// Or matches vectors that satisfy at least one of the given filters.
+// Or with no filters matches nothing.
+func Or(filters ...Filter) Filter {
+	return orFilter(filters)
+}
+
+// Not matches vectors that do not satisfy f.
+func Not(f Filter) Filter {
+	return notFilter{f: f}
+}
+
+// MatchFilter reports whether meta satisfies f. A nil filter matches everything.
+func MatchFilter(f Filter, meta map[string]any) bool {
+	return f == nil || f.Match(meta)
+}
+
+// eqFilter implements Eq.
+type eqFilter struct {
+	field string
+	value any
+}
+
+// Match implements Filter.
+func (f eqFilter) Match(meta map[string]any) bool {
+	v, ok := meta[f.field]
+	return ok && valuesEqual(v, f.value)
+}
+
+// inFilter implements In.
+type inFilter struct {
+	field  string
+	values []any
+}
+
+// Match implements Filter.
+func (f inFilter) Match(meta map[string]any) bool {
+	v, ok := meta[f.field]
+	if !ok {
+		return false
+	}
+	for _, want := range f.values {
+		if valuesEqual(v, want) {
+			return true
+		}
+	}
+	return false
+}
+
+// rangeFilter implements Range, Gt, Gte, Lt and Lte.
+type rangeFilter struct {
+	field            string
+	min, max         float64
+	hasMin, hasMax   bool
+	exclMin, exclMax bool
+}
+
+// Match implements Filter.
+func (f rangeFilter) Match(meta map[string]any) bool {
+	n, ok := toFloat64(meta[f.field])
+	if !ok {
+		return false
+	}
+	if f.hasMin && (n < f.min || (f.exclMin && n == f.min)) {
+		return false
+	}
+	if f.hasMax && (n > f.max || (f.exclMax && n == f.max)) {
+		return false
+	}
+	return true
+}
+
+// timeRangeFilter implements TimeRange and the time bounds of ParseFilter.
+type timeRangeFilter struct {
+	field            string
+	min, max         time.Time
+	hasMin, hasMax   bool
+	exclMin, exclMax bool
+}
+
+// Match implements Filter.
+func (f timeRangeFilter) Match(meta map[string]any) bool {
+	t, ok := toTime(meta[f.field])
+	if !ok {
+		return false
+	}
+	if f.hasMin && (t.Before(f.min) || (f.exclMin && t.Equal(f.min))) {
+		return false
+	}
+	if f.hasMax && (t.After(f.max) || (f.exclMax && t.Equal(f.max))) {
+		return false
+	}
+	return true
+}
+
+// toTime converts a time.Time or an RFC 3339 string to a time.Time.
+func toTime(v any) (time.Time, bool) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, true
+	case string:
+		parsed, err := time.Parse(time.RFC3339, t)
+		return parsed, err == nil
+	default:
+		return time.Time{}, false
+	}
+}
+
+// existsFilter implements Exists.
+type existsFilter struct {
+	field string
+}
+
+// Match implements Filter.
+func (f existsFilter) Match(meta map[string]any) bool {
+	_, ok := meta[f.field]
+	return ok
+}
+
+// andFilter implements And.
+type andFilter []Filter
+
+// Match implements Filter.
+func (f andFilter) Match(meta map[string]any) bool {
+	for _, sub := range f {
+		if !MatchFilter(sub, meta) {
+			return false
+		}
+	}
+	return true
+}
+
+// orFilter implements Or.
+type orFilter []Filter
+
+// Match implements Filter.
+func (f orFilter) Match(meta map[string]any) bool {
+	for _, sub := range f {
+		if MatchFilter(sub, meta) {
+			return true
+		}
+	}
+	return false
+}
+
+// notFilter implements Not.
+type notFilter struct {
+	f Filter
+}
+
+// Match implements Filter.
+func (f notFilter) Match(meta map[string]any) bool {
+	return !MatchFilter(f.f, meta)
+}
+
+// valuesEqual compares two metadata values, treating all numeric types as numbers.
+func valuesEqual(a, b any) bool {
+	if x, ok := toFloat64(a); ok {
+		y, ok := toFloat64(b)
+		return ok && x == y
+	}
+	ta, tb := reflect.TypeOf(a), reflect.TypeOf(b)
+	if ta != tb {
+		return false
+	}
+	if ta != nil && ta.Comparable() {
+		return a == b
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// toFloat64 converts any Go numeric value to float64.
+func toFloat64(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
+
+// ParseFilter builds a Filter from a decoded JSON filter document, so that
+// filters can be sent over the wire by non-Go clients.
+//
+// Every top-level field must match. A field mapped to a plain value is an Eq
+// filter; a field mapped to an object applies the operators $eq, $ne, $in,
+// $gt, $gte, $lt, $lte and $exists to that field. The keys $and and $or take
+// arrays of filter documents and $not takes a single document:
+//
+//	{"tenant": "acme", "year": {"$gte": 2024}, "$or": [{"type": "pdf"}, {"type": "doc"}]}
+//
+// The bounds of $gt, $gte, $lt and $lte are numbers, or RFC 3339 strings that
+// compare times as TimeRange does:
+//
+//	{"created": {"$gte": "2024-01-01T00:00:00Z", "$lt": "2024-02-01T00:00:00Z"}}
+//
+// A nil or empty document matches everything.
+func ParseFilter(doc map[string]any) (Filter, error) {
+	filters := make([]Filter, 0, len(doc))
+	for key, value := range doc {
+		f, err := parseFilterEntry(key, value)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+	if len(filters) == 1 {
+		return filters[0], nil
+	}
+	return And(filters...), nil
+}
+
+// parseFilterEntry parses a single key of a filter document.
+func parseFilterEntry(key string, value any) (Filter, error) {
+	switch key {
+	case "$and", "$or":
+		docs, ok := value.([]any)
+		if !ok {
+			return nil, fmt.Errorf("filter: %s requires an array", key)
+		}
+		subs := make([]Filter, len(docs))
+		for i, d := range docs {
+			doc, ok := d.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("filter: %s element %d must be an object", key, i)
+			}
+			f, err := ParseFilter(doc)
+			if err != nil {
+				return nil, err
+			}
+			subs[i] = f
+		}
+		if key == "$and" {
+			return And(subs...), nil
+		}
+		return Or(subs...), nil
+	case "$not":
+		doc, ok := value.(map[string]any)
+		if !ok {
+			return nil, errors.New("filter: $not requires an object")
+		}
+		f, err := ParseFilter(doc)
+		if err != nil {
+			return nil, err
+		}
+		return Not(f), nil
+	}
+
+	if strings.HasPrefix(key, "$") {
+		return nil, fmt.Errorf("filter: unknown operator %s", key)
+	}
+	ops, ok := value.(map[string]any)
+	if !ok {
+		return Eq(key, value), nil
+	}
+
+	filters := make([]Filter, 0, len(ops))
+	for op, arg := range ops {
+		f, err := parseFieldOperator(key, op, arg)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+	if len(filters) == 1 {
+		return filters[0], nil
+	}
+	return And(filters...), nil
+}
+
+// parseFieldOperator parses an operator applied to a metadata field.
+func parseFieldOperator(field, op string, arg any) (Filter, error) {
+	switch op {
+	case "$eq":
+		return Eq(field, arg), nil
+	case "$ne":
+		return Not(Eq(field, arg)), nil
+	case "$in":
+		values, ok := arg.([]any)
+		if !ok {
+			return nil, fmt.Errorf("filter: $in on %s requires an array", field)
+		}
+		return In(field, values...), nil
+	case "$exists":
+		exists, ok := arg.(bool)
+		if !ok {
+			return nil, fmt.Errorf("filter: $exists on %s requires a boolean", field)
+		}
+		if exists {
+			return Exists(field), nil
+		}
+		return Not(Exists(field)), nil
+	case "$gt", "$gte", "$lt", "$lte":
+		if s, ok := arg.(string); ok {
+			t, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				return nil, fmt.Errorf("filter: %s on %s requires a number or an RFC 3339 time: %w", op, field, err)
+			}
+			f := timeRangeFilter{field: field}
+			switch op {
+			case "$gt", "$gte":
+				f.min, f.hasMin, f.exclMin = t, true, op == "$gt"
+			default:
+				f.max, f.hasMax, f.exclMax = t, true, op == "$lt"
+			}
+			return f, nil
+		}
+		n, ok := toFloat64(arg)
+		if !ok {
+			return nil, fmt.Errorf("filter: %s on %s requires a number or an RFC 3339 time", op, field)
+		}
+		switch op {
+		case "$gt":
+			return Gt(field, n), nil
+		case "$gte":
+			return Gte(field, n), nil
+		case "$lt":
+			return Lt(field, n), nil
+		default:
+			return Lte(field, n), nil
+		}
+	default:
+		return nil, fmt.Errorf("filter: unknown operator %s on %s", op, field)
+	}
+}
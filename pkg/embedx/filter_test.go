@@ -0,0 +1,186 @@
+package embedx
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestFilters(t *testing.T) {
+	meta := map[string]any{
+		"tenant": "acme",
+		"type":   "pdf",
+		"date":   int64(20240115),
+		"score":  0.75,
+		"tags":   []string{"a", "b"},
+		"public": true,
+		// Dates as a time.Time or an RFC 3339 string, as decoded from JSON.
+		"created": time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC),
+		"updated": "2024-01-20T08:30:00+01:00",
+	}
+	jan := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	feb := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   bool
+	}{
+		{"eq string", Eq("tenant", "acme"), true},
+		{"eq string mismatch", Eq("tenant", "other"), false},
+		{"eq missing field", Eq("missing", "acme"), false},
+		{"eq numeric across types", Eq("date", 20240115), true},
+		{"eq numeric vs string", Eq("date", "20240115"), false},
+		{"eq bool", Eq("public", true), true},
+		{"eq slice", Eq("tags", []string{"a", "b"}), true},
+		{"in match", In("type", "doc", "pdf"), true},
+		{"in no match", In("type", "doc", "html"), false},
+		{"in missing field", In("missing", "pdf"), false},
+		{"range inclusive", Range("date", 20240101, 20240115), true},
+		{"range outside", Range("date", 20240201, 20240301), false},
+		{"range non-numeric field", Range("tenant", 0, 1), false},
+		{"gt", Gt("score", 0.5), true},
+		{"gt boundary", Gt("score", 0.75), false},
+		{"gte boundary", Gte("score", 0.75), true},
+		{"lt boundary", Lt("score", 0.75), false},
+		{"lte boundary", Lte("score", 0.75), true},
+		{"time range", TimeRange("created", jan, feb), true},
+		{"time range outside", TimeRange("created", feb, time.Time{}), false},
+		{"time range inclusive", TimeRange("created", time.Time{}, time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)), true},
+		{"time range rfc 3339 string", TimeRange("updated", jan, feb), true},
+		{"time range string in another zone", TimeRange("updated", time.Date(2024, 1, 20, 7, 30, 0, 0, time.UTC), time.Time{}), true},
+		{"time range non-time string", TimeRange("tenant", jan, feb), false},
+		{"time range numeric date", TimeRange("date", jan, feb), false},
+		{"exists", Exists("tags"), true},
+		{"exists missing", Exists("missing"), false},
+		{"and", And(Eq("tenant", "acme"), Eq("type", "pdf")), true},
+		{"and one false", And(Eq("tenant", "acme"), Eq("type", "doc")), false},
+		{"and empty", And(), true},
+		{"or", Or(Eq("type", "doc"), Eq("type", "pdf")), true},
+		{"or none true", Or(Eq("type", "doc"), Eq("type", "html")), false},
+		{"or empty", Or(), false},
+		{"not", Not(Eq("tenant", "other")), true},
+		{"not true", Not(Exists("tenant")), false},
+		{"nested", And(Eq("tenant", "acme"), Or(Gte("date", 20240101), Not(Exists("date")))), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(meta); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchFilterNil(t *testing.T) {
+	if !MatchFilter(nil, nil) {
+		t.Error("nil filter should match everything")
+	}
+	if Eq("a", 1).Match(nil) {
+		t.Error("Eq should not match nil metadata")
+	}
+	if !Not(Exists("a")).Match(nil) {
+		t.Error("Not(Exists) should match nil metadata")
+	}
+}
+
+func TestMemoryStoreSearchWithFilter(t *testing.T) {
+	store := NewMemoryStore()
+	_ = store.Add("a", []float32{1, 0}, map[string]any{"tenant": "x", "year": 2023})
+	_ = store.Add("b", []float32{0.9, 0.1}, map[string]any{"tenant": "y", "year": 2024})
+	_ = store.Add("c", []float32{0, 1}, map[string]any{"tenant": "x", "year": 2024})
+	_ = store.SaveVector("d", []float32{1, 0})
+
+	results, err := store.SearchWithFilter([]float32{1, 0}, 10, Eq("tenant", "x"))
+	if err != nil {
+		t.Fatalf("SearchWithFilter failed: %v", err)
+	}
+	if len(results) != 2 || results[0].ID != "a" || results[1].ID != "c" {
+		t.Errorf("Expected [a c], got %v", results)
+	}
+
+	results, _ = store.SearchWithFilter([]float32{1, 0}, 10, And(Eq("tenant", "x"), Gte("year", 2024)))
+	if len(results) != 1 || results[0].ID != "c" {
+		t.Errorf("Expected [c], got %v", results)
+	}
+
+	results, _ = store.SearchWithFilter([]float32{1, 0}, 10, Not(Exists("tenant")))
+	if len(results) != 1 || results[0].ID != "d" {
+		t.Errorf("Expected [d], got %v", results)
+	}
+
+	// Unfiltered search returns everything, top-k trimmed.
+	results, _ = store.Search([]float32{1, 0}, 2)
+	if len(results) != 2 {
+		t.Errorf("Expected 2 results, got %d", len(results))
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	meta := map[string]any{"tenant": "acme", "year": float64(2024), "type": "pdf", "created": "2024-01-15T12:00:00Z"}
+
+	tests := []struct {
+		name string
+		doc  string
+		want bool
+	}{
+		{"empty", `{}`, true},
+		{"implicit eq", `{"tenant": "acme"}`, true},
+		{"implicit and", `{"tenant": "acme", "type": "doc"}`, false},
+		{"eq op", `{"year": {"$eq": 2024}}`, true},
+		{"ne op", `{"tenant": {"$ne": "acme"}}`, false},
+		{"in op", `{"type": {"$in": ["doc", "pdf"]}}`, true},
+		{"range ops", `{"year": {"$gte": 2020, "$lt": 2024}}`, false},
+		{"gt op", `{"year": {"$gt": 2023}}`, true},
+		{"lte op", `{"year": {"$lte": 2024}}`, true},
+		{"time range ops", `{"created": {"$gte": "2024-01-01T00:00:00Z", "$lt": "2024-02-01T00:00:00Z"}}`, true},
+		{"time gt boundary", `{"created": {"$gt": "2024-01-15T12:00:00Z"}}`, false},
+		{"time lte boundary", `{"created": {"$lte": "2024-01-15T12:00:00Z"}}`, true},
+		{"time bound on number", `{"year": {"$gte": "2024-01-01T00:00:00Z"}}`, false},
+		{"exists", `{"tenant": {"$exists": true}}`, true},
+		{"not exists", `{"missing": {"$exists": false}}`, true},
+		{"or", `{"$or": [{"type": "doc"}, {"type": "pdf"}]}`, true},
+		{"and", `{"$and": [{"tenant": "acme"}, {"year": {"$lt": 2000}}]}`, false},
+		{"not", `{"$not": {"tenant": "other"}}`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var doc map[string]any
+			if err := json.Unmarshal([]byte(tt.doc), &doc); err != nil {
+				t.Fatalf("invalid test document: %v", err)
+			}
+			f, err := ParseFilter(doc)
+			if err != nil {
+				t.Fatalf("ParseFilter failed: %v", err)
+			}
+			if got := f.Match(meta); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	for _, doc := range []string{
+		`{"$or": {"a": 1}}`,
+		`{"$and": [1]}`,
+		`{"$not": []}`,
+		`{"$foo": 1}`,
+		`{"a": {"$in": 1}}`,
+		`{"a": {"$gt": "x"}}`,
+		`{"a": {"$lt": "2024-01-01"}}`,
+		`{"a": {"$exists": "yes"}}`,
+		`{"a": {"$regex": "x"}}`,
+		`{"$or": [{"a": {"$bad": 1}}]}`,
+	} {
+		var m map[string]any
+		if err := json.Unmarshal([]byte(doc), &m); err != nil {
+			t.Fatalf("invalid test document %s: %v", doc, err)
+		}
+		if _, err := ParseFilter(m); err == nil {
+			t.Errorf("Expected error for %s, got nil", doc)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+// Package embedx provides core vector embedding storage functionality.
+package embedx
+
+// Index defines an approximate nearest-neighbor index over stored vectors.
+// An Embedder configured with an Index keeps it in sync on writes and answers
+// Search queries from it instead of scanning every vector in the store.
+type Index interface {
+	// Add inserts a vector with the given ID, replacing any previous vector
+	// stored under the same ID.
+	Add(id string, vec []float32) error
+	// Delete removes the vector with the given ID from future search results.
+	Delete(id string) error
+	// Search returns the approximate top-k most similar vectors to the query,
+	// sorted by score in descending order and by ID among equal scores, or
+	// every vector if k <= 0.
+	Search(query []float32, k int) ([]SearchResult, error)
+	// Len returns the number of live (non-deleted) vectors in the index.
+	Len() int
+}
+
+// IndexedStore is implemented by stores that maintain their own Index as part
+// of every write, such as a store that persists the index next to its vectors.
+// An Embedder created over an IndexedStore searches the store's index and
+// leaves index updates to the store.
+type IndexedStore interface {
+	VectorStore
+	// Index returns the store-managed index, or nil if the store has none.
+	Index() Index
+}
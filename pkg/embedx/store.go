@@ -1,6 +1,13 @@
 // Package embedx provides core vector embedding storage functionality.
 package embedx
 
+import (
+	"context"
+	"fmt"
+
+	"github.com/ldaidone/goembedx/vector"
+)
+
 // SearchResult represents a single search result with ID, score, and metadata.
 type SearchResult struct {
 	// ID is the identifier of the matching vector.
@@ -11,8 +18,25 @@ type SearchResult struct {
 	Meta map[string]any
 }
 
+// UpsertMode controls how an upsert treats vectors that are already stored.
+type UpsertMode int
+
+const (
+	// UpsertAny inserts the vector if its ID is absent and replaces it otherwise.
+	UpsertAny UpsertMode = iota
+	// InsertOnly stores the vector only if its ID is absent, failing with ErrAlreadyExists otherwise.
+	InsertOnly
+	// UpdateOnly replaces the vector only if its ID is present, failing with ErrNotFound otherwise.
+	UpdateOnly
+)
+
 // VectorStore defines the interface for basic vector storage operations.
 // It provides methods for storing, retrieving, and managing vectors.
+//
+// Every method that reads or writes vectors has a variant taking a
+// context.Context. Once the context is done, the variants stop their work and
+// return the context's error: reads abandon their scan, and writes that have
+// not committed leave the store unchanged.
 type VectorStore interface {
 	// SaveVector stores a vector with the given ID.
 	// Returns an error if saving fails.
@@ -23,21 +47,342 @@ type VectorStore interface {
 	// GetAllVectors returns all stored vectors.
 	// Returns an error if retrieval fails.
 	GetAllVectors() (map[string][]float32, error)
+	// UpsertVector stores a vector according to mode.
+	// Returns ErrAlreadyExists or ErrNotFound when mode forbids the write.
+	UpsertVector(id string, vec []float32, mode UpsertMode) error
+	// Delete removes the vector with the given ID.
+	// Returns ErrNotFound if the ID is not stored.
+	Delete(id string) error
+	// DeleteMany removes the vectors with the given IDs, skipping IDs that are not stored.
+	// Returns the number of vectors removed.
+	DeleteMany(ids []string) (int, error)
+	// SaveVectorContext is SaveVector with a context.
+	SaveVectorContext(ctx context.Context, id string, vec []float32) error
+	// GetVectorContext is GetVector with a context.
+	GetVectorContext(ctx context.Context, id string) ([]float32, error)
+	// GetAllVectorsContext is GetAllVectors with a context.
+	GetAllVectorsContext(ctx context.Context) (map[string][]float32, error)
+	// UpsertVectorContext is UpsertVector with a context.
+	UpsertVectorContext(ctx context.Context, id string, vec []float32, mode UpsertMode) error
+	// DeleteContext is Delete with a context.
+	DeleteContext(ctx context.Context, id string) error
+	// DeleteManyContext is DeleteMany with a context. IDs deleted before the
+	// context was done stay deleted and are counted.
+	DeleteManyContext(ctx context.Context, ids []string) (int, error)
 	// Close releases any resources held by the store.
 	Close() error
 }
 
 // Store defines the full-featured store interface with metadata and search capabilities.
 // It extends basic vector storage with metadata support and search functionality.
+// Like those of VectorStore, its methods have variants taking a context.Context.
 type Store interface {
 	// Add stores a vector with metadata.
 	Add(id string, vec []float32, meta map[string]any) error
+	// Upsert stores a vector with metadata according to mode.
+	// Returns ErrAlreadyExists or ErrNotFound when mode forbids the write.
+	Upsert(id string, vec []float32, meta map[string]any, mode UpsertMode) error
 	// Get retrieves a vector by ID along with its norm and metadata.
 	// Returns the vector, its L2 norm, associated metadata, and any error.
 	Get(id string) ([]float32, float32, map[string]any, error)
 	// Search performs similarity search on stored vectors.
-	// Returns the top-k most similar vectors to the query.
+	// Returns the top-k most similar vectors to the query, sorted by score in
+	// descending order and by ID among equal scores, or every vector if k <= 0.
 	Search(query []float32, k int) ([]SearchResult, error)
+	// SearchWithFilter performs similarity search restricted to vectors whose
+	// metadata matches filter. A nil filter matches every vector.
+	SearchWithFilter(query []float32, k int, filter Filter) ([]SearchResult, error)
+	// SearchBatch performs Search for many queries at once and returns their
+	// results in query order, reading the stored vectors once for the whole
+	// batch where the store can.
+	SearchBatch(queries [][]float32, k int) ([][]SearchResult, error)
+	// Delete removes the vector with the given ID.
+	// Returns ErrNotFound if the ID is not stored.
+	Delete(id string) error
+	// DeleteMany removes the vectors with the given IDs, skipping IDs that are not stored.
+	// Returns the number of vectors removed.
+	DeleteMany(ids []string) (int, error)
+	// AddBatch stores many vectors with their metadata, as if Upsert were
+	// called for each record in order, but with fewer round trips.
+	// Record.Norm is ignored and recomputed. Returns a *BatchError if any
+	// record is rejected.
+	AddBatch(records []Record, opts BatchOptions) error
+	// AddContext is Add with a context.
+	AddContext(ctx context.Context, id string, vec []float32, meta map[string]any) error
+	// UpsertContext is Upsert with a context.
+	UpsertContext(ctx context.Context, id string, vec []float32, meta map[string]any, mode UpsertMode) error
+	// GetContext is Get with a context.
+	GetContext(ctx context.Context, id string) ([]float32, float32, map[string]any, error)
+	// SearchContext is Search with a context.
+	SearchContext(ctx context.Context, query []float32, k int) ([]SearchResult, error)
+	// SearchWithFilterContext is SearchWithFilter with a context.
+	SearchWithFilterContext(ctx context.Context, query []float32, k int, filter Filter) ([]SearchResult, error)
+	// SearchBatchContext is SearchBatch with a context.
+	SearchBatchContext(ctx context.Context, queries [][]float32, k int) ([][]SearchResult, error)
+	// DeleteContext is Delete with a context.
+	DeleteContext(ctx context.Context, id string) error
+	// DeleteManyContext is DeleteMany with a context. IDs deleted before the
+	// context was done stay deleted and are counted.
+	DeleteManyContext(ctx context.Context, ids []string) (int, error)
+	// AddBatchContext is AddBatch with a context. Atomic batches write
+	// nothing once the context is done; best-effort batches may have
+	// written some of their records.
+	AddBatchContext(ctx context.Context, records []Record, opts BatchOptions) error
 	// Close releases any resources held by the store.
 	Close() error
 }
+
+// BatchOptions configures AddBatch.
+type BatchOptions struct {
+	// Mode controls how records whose ID is already stored, or appears earlier
+	// in the batch, are treated.
+	Mode UpsertMode
+	// Atomic makes the batch all-or-nothing: if any record is rejected, no
+	// record is written. Otherwise the batch is best-effort: every valid
+	// record is written and the rejected ones are reported.
+	Atomic bool
+}
+
+// ItemError describes a record of a batch that was rejected.
+type ItemError struct {
+	// Index is the position of the record in the batch.
+	Index int
+	// ID is the identifier of the record.
+	ID string
+	// Err is the reason the record was rejected.
+	Err error
+}
+
+// Error implements the error interface.
+func (e ItemError) Error() string {
+	return fmt.Sprintf("item %d (%s): %v", e.Index, e.ID, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e ItemError) Unwrap() error { return e.Err }
+
+// BatchError reports the records of a batch that were rejected.
+// errors.Is matches the errors of the individual items, so a batch
+// rejected for an existing ID matches ErrAlreadyExists.
+type BatchError struct {
+	// Items lists the rejected records in batch order.
+	Items []ItemError
+	// Written is the number of records that were written: 0 for an atomic
+	// batch, and the number of valid records for a best-effort batch.
+	Written int
+}
+
+// NewBatchError returns a *BatchError for items, or nil if items is empty.
+func NewBatchError(items []ItemError, written int) error {
+	if len(items) == 0 {
+		return nil
+	}
+	return &BatchError{Items: items, Written: written}
+}
+
+// Error implements the error interface.
+func (e *BatchError) Error() string {
+	if len(e.Items) == 1 {
+		return fmt.Sprintf("batch: %v", e.Items[0])
+	}
+	return fmt.Sprintf("batch: %d items rejected, first %v", len(e.Items), e.Items[0])
+}
+
+// Unwrap returns the errors of the rejected items.
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, len(e.Items))
+	for i, item := range e.Items {
+		errs[i] = item
+	}
+	return errs
+}
+
+// BatchWriter is implemented by stores that can write many vectors at once.
+// Every Store is a BatchWriter; Embedder.AddBatch uses it when available.
+type BatchWriter interface {
+	// AddBatch stores many vectors with their metadata. See Store.AddBatch.
+	AddBatch(records []Record, opts BatchOptions) error
+	// AddBatchContext is AddBatch with a context. See Store.AddBatchContext.
+	AddBatchContext(ctx context.Context, records []Record, opts BatchOptions) error
+}
+
+// Record is a stored vector together with its norm and metadata.
+type Record struct {
+	// ID is the identifier of the vector.
+	ID string
+	// Vector contains the vector data.
+	Vector []float32
+	// Norm is the L2 norm of Vector.
+	Norm float32
+	// Meta contains the metadata associated with the vector, or nil.
+	Meta map[string]any
+}
+
+// Scanner is implemented by stores that can stream their records without
+// loading them all into memory at once.
+type Scanner interface {
+	// Scan calls fn for every stored record in ascending ID order.
+	// Scanning stops at the first error returned by fn, which Scan returns.
+	Scan(fn func(Record) error) error
+}
+
+// ArenaScanner is implemented by stores that can score their full-precision
+// vectors in place, such as the contiguous vector.Arena rows of MemoryStore.
+// Brute-force Embedder searches use it instead of copying every vector with
+// GetAllVectors.
+type ArenaScanner interface {
+	// ScanArena scores the stored vectors of the query's dimension with
+	// metric and returns the top k as Embedder.Search would, or
+	// ErrEmptyStore if the store is empty. It reports false, leaving the
+	// search to the caller, if the store cannot score its vectors in place.
+	ScanArena(ctx context.Context, query []float32, k int, metric vector.Metric) ([]Result, bool, error)
+	// ScanArenaBatch is ScanArena for every query of a batch, returning
+	// the results of each query as ScanArena would.
+	ScanArenaBatch(ctx context.Context, queries [][]float32, k int, metric vector.Metric) ([][]Result, bool, error)
+}
+
+// StoreStats summarizes the contents of a store.
+type StoreStats struct {
+	// Count is the number of stored vectors.
+	Count int
+	// Dim is the dimension of the stored vectors, or 0 if it is not known yet.
+	Dim int
+	// Metric is the metric used to rank search results.
+	Metric vector.Metric
+	// Precision is the precision the vector components are stored in.
+	Precision vector.Precision
+	// Model names the embedding model of the store schema, or is empty.
+	Model string
+	// Indexed reports whether unfiltered searches are answered by an approximate index.
+	Indexed bool
+	// Quantized reports whether searches score int8-quantized vectors.
+	Quantized bool
+}
+
+// StatsProvider is implemented by stores that can summarize their contents.
+type StatsProvider interface {
+	// Stats returns a summary of the store.
+	Stats() (StoreStats, error)
+}
+
+// Schema describes the vectors a store holds. It is persisted with the store
+// when it is created, and every write is validated against it.
+type Schema struct {
+	// Dim is the dimension of every vector, or 0 if vectors of any dimension
+	// are accepted.
+	Dim int
+	// Metric is the metric used to rank search results.
+	Metric vector.Metric
+	// Model names the embedding model the vectors come from, or is empty.
+	Model string
+	// ModelVersion is the version of the embedding model, or is empty.
+	ModelVersion string
+	// Precision is the precision the vector components are stored in.
+	Precision vector.Precision
+}
+
+// SchemaStore is implemented by stores that persist a schema.
+type SchemaStore interface {
+	// Schema returns the schema of the store.
+	Schema() Schema
+	// SetSchema replaces the schema of a store that holds no vectors, and
+	// succeeds without changes if schema equals the current one. Otherwise
+	// it returns a *SchemaError.
+	SetSchema(schema Schema) error
+}
+
+// CollectionStore is implemented by stores that hold named collections in
+// one database. Each collection is a Store with its own schema, vectors and
+// index, independent of the other collections and of the store itself.
+type CollectionStore interface {
+	// CreateCollection creates an empty collection with the given schema and
+	// returns it. Returns an error wrapping ErrAlreadyExists if a collection
+	// with that name exists.
+	CreateCollection(name string, schema Schema) (Store, error)
+	// OpenCollection returns the collection with the given name.
+	// Returns an error wrapping ErrNotFound if it does not exist.
+	OpenCollection(name string) (Store, error)
+	// DropCollection deletes the collection with the given name and
+	// everything it holds. Returns an error wrapping ErrNotFound if it does
+	// not exist.
+	DropCollection(name string) error
+	// ListCollections returns the names of the collections in ascending order.
+	ListCollections() ([]string, error)
+}
+
+// QuantizationConfig configures int8 scalar quantization of a store.
+type QuantizationConfig struct {
+	// Calibration selects per-dimension or global value ranges.
+	// The default is vector.CalibratePerDimension.
+	Calibration vector.Calibration
+	// Rescore is the number of quantized candidates per requested result
+	// that are rescored against the full-precision vectors, so that a search
+	// for k results rescores the best k*Rescore candidates. In-memory stores
+	// keep the full-precision vectors only when Rescore is positive; with
+	// Rescore 0 they drop them, use a quarter of the memory, and return the
+	// reconstructed vectors from Get.
+	Rescore int
+	// Product selects product quantization with the given codebook settings
+	// instead of int8 scalar quantization, in which case Calibration is
+	// ignored. Stores that do not support product quantization return an error.
+	Product *vector.PQConfig
+	// Sample bounds the number of vectors, drawn at random, that the
+	// quantizer is trained on. 0 trains on every stored vector.
+	Sample int
+	// Binary selects binary quantization: every vector is packed into one bit
+	// per dimension, set when the component is positive (vector.Binarize),
+	// and searches rank candidates by Hamming similarity, rescoring the best
+	// k*Rescore against the full-precision vectors when Rescore is positive.
+	// Binary codes cannot reconstruct the vectors, so stores always keep the
+	// full-precision vectors in this mode. Calibration, Product and Sample
+	// are ignored.
+	Binary bool
+}
+
+// Quantizable is implemented by stores that support quantization: int8 scalar
+// quantization, and product or binary quantization where noted.
+type Quantizable interface {
+	// Quantize calibrates a quantizer on the stored vectors and quantizes
+	// them. Vectors written afterwards are quantized on write, and must have
+	// the dimension of the vectors the quantizer was calibrated on.
+	// Quantizing an already quantized store recalibrates it.
+	Quantize(cfg QuantizationConfig) error
+}
+
+// CheckUpsertMode validates an upsert of id against mode, given whether the ID
+// is already stored. Store implementations call it before writing so that all
+// stores report the same errors.
+func CheckUpsertMode(id string, mode UpsertMode, exists bool) error {
+	switch mode {
+	case InsertOnly:
+		if exists {
+			return fmt.Errorf("%w: %s", ErrAlreadyExists, id)
+		}
+	case UpdateOnly:
+		if !exists {
+			return fmt.Errorf("%w: %s", ErrNotFound, id)
+		}
+	case UpsertAny:
+	default:
+		return fmt.Errorf("invalid upsert mode %d", mode)
+	}
+	return nil
+}
+
+// GroupQueries groups the indexes of queries by dimension, in order of first
+// appearance. Store implementations use it to answer the queries of each
+// dimension of a SearchBatch in one pass with a vector.QueryBatch.
+func GroupQueries(queries [][]float32) [][]int {
+	var groups [][]int
+	pos := make(map[int]int)
+	for i, q := range queries {
+		g, ok := pos[len(q)]
+		if !ok {
+			g = len(groups)
+			pos[len(q)] = g
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], i)
+	}
+	return groups
+}
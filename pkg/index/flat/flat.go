@@ -0,0 +1,138 @@
+// Package flat implements an exact in-memory index over float32 vectors.
+//
+// The index keeps every vector in one contiguous arena (vector.Arena) with
+// its precomputed norm and answers a search by scoring all of them, so its
+// results are exact. It needs no training and no memory beyond the vectors,
+// and suits collections small enough to scan on every query, or serves as
+// the ground truth that approximate indexes are measured against.
+package flat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ldaidone/goembedx/pkg/embedx"
+	"github.com/ldaidone/goembedx/vector"
+)
+
+// Config holds the settings of a flat index.
+type Config struct {
+	// Metric is the similarity function the index is searched with.
+	// The zero value is vector.MetricCosine.
+	Metric vector.Metric
+}
+
+// Index is a thread-safe flat index.
+type Index struct {
+	// cfg holds the settings.
+	cfg Config
+	// mu guards arena.
+	mu sync.RWMutex
+	// arena holds the vectors, or is nil before the first insert, which
+	// fixes their dimension.
+	arena *vector.Arena
+}
+
+// Compile-time interface check
+var _ embedx.Index = (*Index)(nil)
+
+// New creates an empty index with the given configuration.
+// An invalid metric is replaced by vector.MetricCosine.
+func New(cfg Config) *Index {
+	if !cfg.Metric.Valid() {
+		cfg.Metric = vector.MetricCosine
+	}
+	return &Index{cfg: cfg}
+}
+
+// Config returns the configuration the index was created with.
+func (ix *Index) Config() Config {
+	return ix.cfg
+}
+
+// Len returns the number of vectors in the index.
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	if ix.arena == nil {
+		return 0
+	}
+	return ix.arena.Len()
+}
+
+// Add inserts a copy of a vector with the given ID, replacing any previous
+// vector stored under the same ID.
+// Returns an error wrapping embedx.ErrInvalidID if the ID is empty, an error
+// wrapping a *embedx.DimensionError if the vector dimension differs from the
+// vectors already indexed, or an error if the vector is empty.
+func (ix *Index) Add(id string, vec []float32) error {
+	if id == "" {
+		return fmt.Errorf("flat: %w: id cannot be empty", embedx.ErrInvalidID)
+	}
+	if len(vec) == 0 {
+		return errors.New("flat: vector cannot be empty")
+	}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	if ix.arena == nil {
+		ix.arena = vector.NewArena(len(vec))
+	}
+	if len(vec) != ix.arena.Dim() {
+		return fmt.Errorf("flat: %w", &embedx.DimensionError{Expected: ix.arena.Dim(), Actual: len(vec)})
+	}
+	ix.arena.Set(id, vec)
+	return nil
+}
+
+// Delete removes the vector with the given ID.
+// Returns an error wrapping embedx.ErrNotFound if the ID is not present.
+func (ix *Index) Delete(id string) error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	if ix.arena == nil || !ix.arena.Delete(id) {
+		return fmt.Errorf("flat: %w: %s", embedx.ErrNotFound, id)
+	}
+	return nil
+}
+
+// Search returns the top-k most similar vectors to the query, scored by the
+// configured metric and sorted by score in descending order and by ID among
+// equal scores, or every vector if k <= 0. Under the cosine metric, zero
+// vectors are never returned.
+// Returns an error if the query is empty, or an error wrapping a
+// *embedx.DimensionError if its dimension does not match the index.
+func (ix *Index) Search(query []float32, k int) ([]embedx.SearchResult, error) {
+	return ix.SearchContext(context.Background(), query, k)
+}
+
+// SearchContext is like Search but stops and returns ctx.Err() once ctx is done.
+func (ix *Index) SearchContext(ctx context.Context, query []float32, k int) ([]embedx.SearchResult, error) {
+	if len(query) == 0 {
+		return nil, errors.New("flat: query vector is empty")
+	}
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	if ix.arena == nil || ix.arena.Len() == 0 {
+		return []embedx.SearchResult{}, nil
+	}
+	if len(query) != ix.arena.Dim() {
+		return nil, fmt.Errorf("flat: %w", &embedx.DimensionError{Expected: ix.arena.Dim(), Actual: len(query)})
+	}
+
+	hits, err := ix.arena.Search(ctx, query, k, ix.cfg.Metric, nil)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]embedx.SearchResult, len(hits))
+	for i, hit := range hits {
+		results[i] = embedx.SearchResult{ID: hit.ID, Score: hit.Score}
+	}
+	return results, nil
+}
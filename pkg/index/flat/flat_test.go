@@ -0,0 +1,115 @@
+package flat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/ldaidone/goembedx/pkg/embedx"
+	"github.com/ldaidone/goembedx/vector"
+)
+
+func randomVectors(r *rand.Rand, n, dim int) [][]float32 {
+	vecs := make([][]float32, n)
+	for i := range vecs {
+		v := make([]float32, dim)
+		for j := range v {
+			v[j] = r.Float32()*2 - 1
+		}
+		vecs[i] = v
+	}
+	return vecs
+}
+
+func TestIndexMatchesBruteForce(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	data := randomVectors(r, 500, 16)
+
+	for _, m := range []vector.Metric{vector.MetricCosine, vector.MetricDot, vector.MetricEuclidean} {
+		brute := embedx.New(embedx.NewMemoryStore(), embedx.WithMetric(m))
+		ix := New(Config{Metric: m})
+		for i, v := range data {
+			id := fmt.Sprintf("v%d", i)
+			_ = brute.Add(id, v)
+			if err := ix.Add(id, v); err != nil {
+				t.Fatalf("Add failed: %v", err)
+			}
+		}
+		for _, q := range randomVectors(r, 10, 16) {
+			expected, _ := brute.Search(q, 10)
+			got, err := ix.Search(q, 10)
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			for i := range expected {
+				if got[i].ID != expected[i].ID || got[i].Score != expected[i].Score {
+					t.Fatalf("%s: result %d: expected %s (%v), got %s (%v)", m, i, expected[i].ID, expected[i].Score, got[i].ID, got[i].Score)
+				}
+			}
+		}
+	}
+}
+
+func TestIndexAddDelete(t *testing.T) {
+	ix := New(Config{})
+	_ = ix.Add("a", []float32{1, 0})
+	_ = ix.Add("b", []float32{0, 1})
+	_ = ix.Add("a", []float32{0, 1})
+	if ix.Len() != 2 {
+		t.Fatalf("Expected 2 vectors, got %d", ix.Len())
+	}
+	if err := ix.Delete("b"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := ix.Delete("b"); !errors.Is(err, embedx.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	results, err := ix.Search([]float32{0, 1}, 0)
+	if err != nil || len(results) != 1 || results[0].ID != "a" || results[0].Score != 1 {
+		t.Errorf("Expected only the replaced a, got %v, %v", results, err)
+	}
+}
+
+func TestIndexErrors(t *testing.T) {
+	ix := New(Config{})
+	if err := ix.Delete("a"); !errors.Is(err, embedx.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound from an empty index, got %v", err)
+	}
+	if results, err := ix.Search([]float32{1}, 1); err != nil || len(results) != 0 {
+		t.Errorf("Expected no results from an empty index, got %v, %v", results, err)
+	}
+	if err := ix.Add("", []float32{1, 2}); !errors.Is(err, embedx.ErrInvalidID) {
+		t.Errorf("Expected ErrInvalidID, got %v", err)
+	}
+	if err := ix.Add("a", nil); err == nil {
+		t.Error("Expected error for empty vector")
+	}
+	_ = ix.Add("a", []float32{1, 2})
+	if err := ix.Add("b", []float32{1, 2, 3}); !errors.Is(err, embedx.ErrDimensionMismatch) {
+		t.Errorf("Expected ErrDimensionMismatch, got %v", err)
+	}
+	if _, err := ix.Search(nil, 1); err == nil {
+		t.Error("Expected error for empty query")
+	}
+	if _, err := ix.Search([]float32{1}, 1); !errors.Is(err, embedx.ErrDimensionMismatch) {
+		t.Errorf("Expected ErrDimensionMismatch for the query, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ix.SearchContext(ctx, []float32{1, 2}, 1); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestEmbedderWithIndex(t *testing.T) {
+	e := embedx.New(embedx.NewMemoryStore(), embedx.WithIndex(New(Config{})))
+	_ = e.Add("x", []float32{1, 0})
+	_ = e.Add("y", []float32{0, 1})
+	results, err := e.Search([]float32{1, 0.1}, 1)
+	if err != nil || len(results) != 1 || results[0].ID != "x" {
+		t.Errorf("Expected x, got %v, %v", results, err)
+	}
+}
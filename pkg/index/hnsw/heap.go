@@ -0,0 +1,95 @@
+package hnsw
+
+// candidate is a node index paired with its distance to the current query.
+type candidate struct {
+	idx  uint32
+	dist float32
+}
+
+// minHeap is a binary heap of candidates with the closest candidate on top.
+type minHeap struct {
+	items []candidate
+}
+
+// Len returns the number of candidates in the heap.
+func (h *minHeap) Len() int { return len(h.items) }
+
+// push adds a candidate to the heap.
+func (h *minHeap) push(c candidate) {
+	h.items = append(h.items, c)
+	siftUp(h.items, len(h.items)-1, closer)
+}
+
+// pop removes and returns the closest candidate.
+func (h *minHeap) pop() candidate {
+	return popTop(&h.items, closer)
+}
+
+// maxHeap is a binary heap of candidates with the farthest candidate on top.
+type maxHeap struct {
+	items []candidate
+}
+
+// Len returns the number of candidates in the heap.
+func (h *maxHeap) Len() int { return len(h.items) }
+
+// top returns the farthest candidate without removing it.
+func (h *maxHeap) top() candidate { return h.items[0] }
+
+// push adds a candidate to the heap.
+func (h *maxHeap) push(c candidate) {
+	h.items = append(h.items, c)
+	siftUp(h.items, len(h.items)-1, farther)
+}
+
+// pop removes and returns the farthest candidate.
+func (h *maxHeap) pop() candidate {
+	return popTop(&h.items, farther)
+}
+
+// closer orders candidates for a min-heap.
+func closer(a, b candidate) bool { return a.dist < b.dist }
+
+// farther orders candidates for a max-heap.
+func farther(a, b candidate) bool { return a.dist > b.dist }
+
+// siftUp restores the heap property after appending the element at i.
+func siftUp(items []candidate, i int, less func(a, b candidate) bool) {
+	for i > 0 {
+		parent := (i - 1) / 2
+		if !less(items[i], items[parent]) {
+			return
+		}
+		items[i], items[parent] = items[parent], items[i]
+		i = parent
+	}
+}
+
+// popTop removes the root of the heap and restores the heap property.
+func popTop(items *[]candidate, less func(a, b candidate) bool) candidate {
+	h := *items
+	top := h[0]
+	last := len(h) - 1
+	h[0] = h[last]
+	h = h[:last]
+
+	i := 0
+	for {
+		l, r := 2*i+1, 2*i+2
+		best := i
+		if l < len(h) && less(h[l], h[best]) {
+			best = l
+		}
+		if r < len(h) && less(h[r], h[best]) {
+			best = r
+		}
+		if best == i {
+			break
+		}
+		h[i], h[best] = h[best], h[i]
+		i = best
+	}
+
+	*items = h
+	return top
+}
@@ -0,0 +1,33 @@
+package hnsw
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestHeapsOrdering(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	minH, maxH := &minHeap{}, &maxHeap{}
+	for i := 0; i < 100; i++ {
+		c := candidate{idx: uint32(i), dist: r.Float32()}
+		minH.push(c)
+		maxH.push(c)
+	}
+
+	prev := float32(-1)
+	for minH.Len() > 0 {
+		c := minH.pop()
+		if c.dist < prev {
+			t.Fatalf("minHeap popped %f after %f", c.dist, prev)
+		}
+		prev = c.dist
+	}
+
+	prev = 2
+	for maxH.Len() > 0 {
+		if maxH.top().dist > prev {
+			t.Fatalf("maxHeap top %f exceeds previous %f", maxH.top().dist, prev)
+		}
+		prev = maxH.pop().dist
+	}
+}
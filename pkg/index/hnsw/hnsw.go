@@ -0,0 +1,495 @@
+// Package hnsw implements a Hierarchical Navigable Small World graph for
+// approximate nearest-neighbor search over float32 vectors.
+//
+// The graph supports incremental inserts and deletes. Deleted vectors are
+// tombstoned: they keep routing queries through the graph but never appear
+// in search results. Tombstones keep their memory and slow searches down
+// until Compact rebuilds the graph from its live vectors.
+package hnsw
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/ldaidone/goembedx/pkg/embedx"
+	"github.com/ldaidone/goembedx/vector"
+)
+
+// Config holds the tuning parameters of an HNSW graph.
+type Config struct {
+	// M is the maximum number of neighbors per node on the upper layers.
+	// Layer 0 allows up to 2*M neighbors.
+	M int
+	// EfConstruction is the size of the candidate list used while inserting.
+	// Larger values build a better graph at the cost of slower inserts.
+	EfConstruction int
+	// EfSearch is the size of the candidate list used while searching.
+	// Larger values improve recall at the cost of slower queries.
+	EfSearch int
+	// Seed initializes the random generator used to assign node levels.
+	Seed int64
+	// Metric is the similarity function the graph is built and searched with.
+	// The zero value is vector.MetricCosine.
+	Metric vector.Metric
+}
+
+// DefaultConfig provides reasonable default values for general-purpose embeddings.
+var DefaultConfig = Config{
+	M:              16,
+	EfConstruction: 200,
+	EfSearch:       64,
+	Seed:           1,
+}
+
+// node is a single vector in the graph together with its per-layer adjacency lists.
+type node struct {
+	// id is the external identifier of the vector.
+	id string
+	// vec is a private copy of the vector data.
+	vec []float32
+	// norm is the precomputed L2 norm of vec.
+	norm float32
+	// level is the highest layer this node appears on.
+	level int
+	// links holds the neighbor node indexes for layers 0..level.
+	links [][]uint32
+	// deleted marks a tombstoned node.
+	deleted bool
+}
+
+// Graph is a thread-safe HNSW index.
+type Graph struct {
+	// cfg holds the tuning parameters.
+	cfg Config
+	// mu guards all fields below.
+	mu sync.RWMutex
+	// nodes holds every node ever inserted, including tombstones.
+	nodes []*node
+	// ids maps external IDs to the index of their live node.
+	ids map[string]uint32
+	// entry is the index of the entry point node.
+	entry uint32
+	// maxLevel is the level of the entry point, or -1 when the graph is empty.
+	maxLevel int
+	// live counts the non-deleted nodes.
+	live int
+	// dim is the dimension of the indexed vectors, fixed by the first insert.
+	dim int
+	// rng assigns levels to new nodes.
+	rng *rand.Rand
+	// levelMult is the level generation factor 1/ln(M).
+	levelMult float64
+	// dirty collects the indexes of nodes modified since the last TakeDirty call.
+	// It is nil unless change tracking is enabled.
+	dirty map[uint32]struct{}
+}
+
+// Compile-time interface check
+var _ embedx.Index = (*Graph)(nil)
+
+// New creates an empty graph with the given configuration.
+// Zero or negative fields in cfg are replaced by the values of DefaultConfig.
+func New(cfg Config) *Graph {
+	if cfg.M <= 1 {
+		cfg.M = DefaultConfig.M
+	}
+	if cfg.EfConstruction <= 0 {
+		cfg.EfConstruction = DefaultConfig.EfConstruction
+	}
+	if cfg.EfSearch <= 0 {
+		cfg.EfSearch = DefaultConfig.EfSearch
+	}
+	if !cfg.Metric.Valid() {
+		cfg.Metric = DefaultConfig.Metric
+	}
+	return &Graph{
+		cfg:       cfg,
+		ids:       make(map[string]uint32),
+		maxLevel:  -1,
+		rng:       rand.New(rand.NewSource(cfg.Seed)),
+		levelMult: 1 / math.Log(float64(cfg.M)),
+	}
+}
+
+// Config returns the configuration the graph was created with.
+func (g *Graph) Config() Config {
+	return g.cfg
+}
+
+// SetEfSearch changes the size of the candidate list used by Search.
+// Values less than or equal to 0 are ignored.
+func (g *Graph) SetEfSearch(ef int) {
+	if ef <= 0 {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.cfg.EfSearch = ef
+}
+
+// Len returns the number of live vectors in the graph.
+func (g *Graph) Len() int {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.live
+}
+
+// Add inserts a vector with the given ID into the graph.
+// If the ID is already present with different data, the old node is
+// tombstoned and a new node is linked in its place; re-adding an identical
+// vector is a no-op.
+// Returns an error wrapping embedx.ErrInvalidID if the ID is empty, an error
+// wrapping a *embedx.DimensionError if the vector dimension differs from the
+// vectors already indexed, or an error if the vector is empty.
+func (g *Graph) Add(id string, vec []float32) error {
+	if id == "" {
+		return fmt.Errorf("hnsw: %w: id cannot be empty", embedx.ErrInvalidID)
+	}
+	if len(vec) == 0 {
+		return errors.New("hnsw: vector cannot be empty")
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.dim != 0 && len(vec) != g.dim {
+		return fmt.Errorf("hnsw: %w", &embedx.DimensionError{Expected: g.dim, Actual: len(vec)})
+	}
+
+	if idx, ok := g.ids[id]; ok {
+		old := g.nodes[idx]
+		if slices.Equal(old.vec, vec) {
+			return nil
+		}
+		old.deleted = true
+		g.live--
+		delete(g.ids, id)
+		g.markDirty(idx)
+	}
+
+	g.insert(id, vec)
+	return nil
+}
+
+// Delete tombstones the vector with the given ID so it no longer appears in
+// search results. The node stays in the graph to preserve connectivity until
+// the next Compact.
+// Returns an error wrapping embedx.ErrNotFound if the ID is not present.
+func (g *Graph) Delete(id string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	idx, ok := g.ids[id]
+	if !ok {
+		return fmt.Errorf("hnsw: %w: %s", embedx.ErrNotFound, id)
+	}
+	g.nodes[idx].deleted = true
+	g.live--
+	delete(g.ids, id)
+	g.markDirty(idx)
+	return nil
+}
+
+// Tombstones returns the number of deleted nodes still held by the graph.
+func (g *Graph) Tombstones() int {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return len(g.nodes) - g.live
+}
+
+// Compact reclaims tombstones by rebuilding the graph from its live vectors,
+// inserted again in the order they were first added. It is a no-op if the
+// graph holds no tombstones. Compacting costs as much as inserting every live
+// vector; it pays off once tombstones make up a large share of the nodes.
+// Compact renumbers the nodes, so callers persisting the graph must write it
+// again from scratch rather than only the nodes reported by TakeDirty.
+func (g *Graph) Compact() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.live == len(g.nodes) {
+		return
+	}
+	live := make([]*node, 0, g.live)
+	for _, n := range g.nodes {
+		if !n.deleted {
+			live = append(live, n)
+		}
+	}
+
+	g.nodes = make([]*node, 0, len(live))
+	clear(g.ids)
+	g.entry, g.maxLevel, g.live, g.dim = 0, -1, 0, 0
+	if g.dirty != nil {
+		clear(g.dirty)
+	}
+	for _, n := range live {
+		g.insert(n.id, n.vec)
+	}
+}
+
+// Search returns the approximate top-k most similar live vectors to the query,
+// scored by the configured metric and sorted by score in descending order and
+// by ID among equal scores. If k <= 0, the search is widened to every live
+// vector it can reach, which is every live vector of a well-connected graph.
+// Returns an error if the query is empty, or an error wrapping a
+// *embedx.DimensionError if its dimension does not match the graph.
+func (g *Graph) Search(query []float32, k int) ([]embedx.SearchResult, error) {
+	if len(query) == 0 {
+		return nil, errors.New("hnsw: query vector is empty")
+	}
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	if g.live == 0 {
+		return []embedx.SearchResult{}, nil
+	}
+	if len(query) != g.dim {
+		return nil, fmt.Errorf("hnsw: %w", &embedx.DimensionError{Expected: g.dim, Actual: len(query)})
+	}
+
+	if k <= 0 {
+		k = g.live
+	}
+
+	q := vector.Norm(query)
+	ep := g.entry
+	for l := g.maxLevel; l > 0; l-- {
+		ep = g.greedyClosest(query, q, ep, l)
+	}
+
+	ef := g.cfg.EfSearch
+	if ef < k {
+		ef = k
+	}
+	found := g.searchLayer(query, q, []uint32{ep}, ef, 0, true)
+
+	top := vector.NewTopK(k)
+	for _, c := range found {
+		top.Push(vector.Candidate{ID: g.nodes[c.idx].id, Score: -c.dist})
+	}
+	hits := top.Results()
+	results := make([]embedx.SearchResult, len(hits))
+	for i, hit := range hits {
+		results[i] = embedx.SearchResult{ID: hit.ID, Score: hit.Score}
+	}
+	return results, nil
+}
+
+// insert links a new node into the graph. The caller must hold the write lock.
+func (g *Graph) insert(id string, vec []float32) {
+	level := g.randomLevel()
+	n := &node{
+		id:    id,
+		vec:   append([]float32(nil), vec...),
+		norm:  vector.Norm(vec),
+		level: level,
+		links: make([][]uint32, level+1),
+	}
+	idx := uint32(len(g.nodes))
+	g.nodes = append(g.nodes, n)
+	g.ids[id] = idx
+	g.live++
+	g.markDirty(idx)
+
+	if g.maxLevel < 0 {
+		g.dim = len(vec)
+		g.entry = idx
+		g.maxLevel = level
+		return
+	}
+
+	ep := g.entry
+	for l := g.maxLevel; l > level; l-- {
+		ep = g.greedyClosest(n.vec, n.norm, ep, l)
+	}
+
+	eps := []uint32{ep}
+	for l := min(level, g.maxLevel); l >= 0; l-- {
+		found := g.searchLayer(n.vec, n.norm, eps, g.cfg.EfConstruction, l, false)
+		n.links[l] = g.selectNeighbors(found, g.maxLinks(l))
+		for _, nb := range n.links[l] {
+			g.link(nb, idx, l)
+		}
+		eps = eps[:0]
+		for _, c := range found {
+			eps = append(eps, c.idx)
+		}
+	}
+
+	if level > g.maxLevel {
+		g.entry = idx
+		g.maxLevel = level
+	}
+}
+
+// link adds a directed edge from -> to on the given layer, pruning the
+// adjacency list of from when it exceeds the layer's capacity.
+func (g *Graph) link(from, to uint32, layer int) {
+	n := g.nodes[from]
+	n.links[layer] = append(n.links[layer], to)
+	g.markDirty(from)
+
+	limit := g.maxLinks(layer)
+	if len(n.links[layer]) <= limit {
+		return
+	}
+
+	cands := make([]candidate, len(n.links[layer]))
+	for i, nb := range n.links[layer] {
+		cands[i] = candidate{idx: nb, dist: g.nodeDist(from, nb)}
+	}
+	sortCandidates(cands)
+	n.links[layer] = g.selectNeighbors(cands, limit)
+}
+
+// selectNeighbors picks up to m neighbors from candidates sorted by ascending
+// distance using the HNSW diversity heuristic: a candidate is kept only if it
+// is closer to the base than to every neighbor already selected. Pruned
+// candidates fill any remaining slots.
+func (g *Graph) selectNeighbors(cands []candidate, m int) []uint32 {
+	if len(cands) <= m {
+		out := make([]uint32, len(cands))
+		for i, c := range cands {
+			out[i] = c.idx
+		}
+		return out
+	}
+
+	selected := make([]uint32, 0, m)
+	pruned := make([]uint32, 0, len(cands))
+	for _, c := range cands {
+		if len(selected) == m {
+			break
+		}
+		keep := true
+		for _, s := range selected {
+			if g.nodeDist(c.idx, s) < c.dist {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			selected = append(selected, c.idx)
+		} else {
+			pruned = append(pruned, c.idx)
+		}
+	}
+	for _, p := range pruned {
+		if len(selected) == m {
+			break
+		}
+		selected = append(selected, p)
+	}
+	return selected
+}
+
+// greedyClosest walks the given layer from ep towards the query and returns
+// the closest node it can reach.
+func (g *Graph) greedyClosest(query []float32, qNorm float32, ep uint32, layer int) uint32 {
+	best := ep
+	bestDist := g.dist(query, qNorm, ep)
+	for changed := true; changed; {
+		changed = false
+		for _, nb := range g.nodes[best].links[layer] {
+			if d := g.dist(query, qNorm, nb); d < bestDist {
+				best, bestDist = nb, d
+				changed = true
+			}
+		}
+	}
+	return best
+}
+
+// searchLayer performs a best-first search of the given layer starting from
+// eps and returns up to ef nearest nodes sorted by ascending distance.
+// If liveOnly is set, tombstoned nodes are traversed but left out of the result.
+func (g *Graph) searchLayer(query []float32, qNorm float32, eps []uint32, ef, layer int, liveOnly bool) []candidate {
+	visited := make(map[uint32]struct{}, ef*4)
+	cands := &minHeap{}
+	found := &maxHeap{}
+
+	for _, ep := range eps {
+		visited[ep] = struct{}{}
+		c := candidate{idx: ep, dist: g.dist(query, qNorm, ep)}
+		cands.push(c)
+		if !liveOnly || !g.nodes[ep].deleted {
+			found.push(c)
+		}
+	}
+
+	for cands.Len() > 0 {
+		c := cands.pop()
+		if found.Len() >= ef && c.dist > found.top().dist {
+			break
+		}
+		for _, nb := range g.nodes[c.idx].links[layer] {
+			if _, seen := visited[nb]; seen {
+				continue
+			}
+			visited[nb] = struct{}{}
+
+			d := g.dist(query, qNorm, nb)
+			if found.Len() < ef || d < found.top().dist {
+				cands.push(candidate{idx: nb, dist: d})
+				if liveOnly && g.nodes[nb].deleted {
+					continue
+				}
+				found.push(candidate{idx: nb, dist: d})
+				if found.Len() > ef {
+					found.pop()
+				}
+			}
+		}
+	}
+
+	out := make([]candidate, found.Len())
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = found.pop()
+	}
+	return out
+}
+
+// markDirty records a modified node when change tracking is enabled.
+func (g *Graph) markDirty(idx uint32) {
+	if g.dirty != nil {
+		g.dirty[idx] = struct{}{}
+	}
+}
+
+// maxLinks returns the adjacency list capacity of the given layer.
+func (g *Graph) maxLinks(layer int) int {
+	if layer == 0 {
+		return 2 * g.cfg.M
+	}
+	return g.cfg.M
+}
+
+// randomLevel draws the level of a new node from an exponentially decaying distribution.
+func (g *Graph) randomLevel() int {
+	return int(math.Floor(-math.Log(1-g.rng.Float64()) * g.levelMult))
+}
+
+// dist returns the distance between the query and the node at idx,
+// the negated metric score. Smaller values indicate greater similarity.
+func (g *Graph) dist(query []float32, qNorm float32, idx uint32) float32 {
+	n := g.nodes[idx]
+	return -g.cfg.Metric.ScoreNorms(query, n.vec, qNorm, n.norm)
+}
+
+// nodeDist returns the distance between two nodes.
+func (g *Graph) nodeDist(a, b uint32) float32 {
+	na, nb := g.nodes[a], g.nodes[b]
+	return -g.cfg.Metric.ScoreNorms(na.vec, nb.vec, na.norm, nb.norm)
+}
+
+// sortCandidates orders candidates by ascending distance.
+func sortCandidates(c []candidate) {
+	sort.Slice(c, func(i, j int) bool { return c[i].dist < c[j].dist })
+}
@@ -0,0 +1,341 @@
+package hnsw
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/ldaidone/goembedx/pkg/embedx"
+	"github.com/ldaidone/goembedx/vector"
+)
+
+func randomVectors(r *rand.Rand, n, dim int) [][]float32 {
+	vecs := make([][]float32, n)
+	for i := range vecs {
+		v := make([]float32, dim)
+		for j := range v {
+			v[j] = r.Float32()*2 - 1
+		}
+		vecs[i] = v
+	}
+	return vecs
+}
+
+// recall returns the fraction of expected IDs present in got.
+func recall(expected []embedx.Result, got []embedx.Result) float64 {
+	want := make(map[string]struct{}, len(expected))
+	for _, r := range expected {
+		want[r.ID] = struct{}{}
+	}
+	hits := 0
+	for _, r := range got {
+		if _, ok := want[r.ID]; ok {
+			hits++
+		}
+	}
+	return float64(hits) / float64(len(expected))
+}
+
+func TestGraphRecallAgainstBruteForce(t *testing.T) {
+	const (
+		n       = 2000
+		dim     = 32
+		queries = 50
+		k       = 10
+	)
+	r := rand.New(rand.NewSource(7))
+	data := randomVectors(r, n, dim)
+
+	brute := embedx.New(embedx.NewMemoryStore())
+	indexed := embedx.New(embedx.NewMemoryStore(), embedx.WithIndex(New(DefaultConfig)))
+	for i, v := range data {
+		id := fmt.Sprintf("v%d", i)
+		if err := brute.Add(id, v); err != nil {
+			t.Fatalf("brute Add failed: %v", err)
+		}
+		if err := indexed.Add(id, v); err != nil {
+			t.Fatalf("indexed Add failed: %v", err)
+		}
+	}
+
+	var total float64
+	for _, q := range randomVectors(r, queries, dim) {
+		expected, err := brute.Search(q, k)
+		if err != nil {
+			t.Fatalf("brute Search failed: %v", err)
+		}
+		got, err := indexed.Search(q, k)
+		if err != nil {
+			t.Fatalf("indexed Search failed: %v", err)
+		}
+		if len(got) != k {
+			t.Fatalf("Expected %d results, got %d", k, len(got))
+		}
+		for i := 1; i < len(got); i++ {
+			if got[i].Score > got[i-1].Score {
+				t.Fatalf("results not sorted by descending score: %v", got)
+			}
+		}
+		total += recall(expected, got)
+	}
+
+	if avg := total / queries; avg < 0.9 {
+		t.Errorf("Expected average recall@%d >= 0.9, got %.3f", k, avg)
+	}
+}
+
+func TestGraphRecallLowEfSearch(t *testing.T) {
+	r := rand.New(rand.NewSource(11))
+	data := randomVectors(r, 1000, 16)
+
+	brute := embedx.New(embedx.NewMemoryStore())
+	g := New(Config{M: 8, EfConstruction: 100, EfSearch: 10, Seed: 3})
+	for i, v := range data {
+		id := fmt.Sprintf("v%d", i)
+		_ = brute.Add(id, v)
+		if err := g.Add(id, v); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+
+	low, high := 0.0, 0.0
+	qs := randomVectors(r, 30, 16)
+	for _, q := range qs {
+		expected, _ := brute.Search(q, 10)
+		got, _ := g.Search(q, 10)
+		low += recall(expected, toResults(got))
+	}
+	g.SetEfSearch(200)
+	for _, q := range qs {
+		expected, _ := brute.Search(q, 10)
+		got, _ := g.Search(q, 10)
+		high += recall(expected, toResults(got))
+	}
+
+	if high < low {
+		t.Errorf("Expected recall to improve with larger efSearch: low=%.3f high=%.3f", low/30, high/30)
+	}
+	if high/30 < 0.95 {
+		t.Errorf("Expected recall >= 0.95 with efSearch=200, got %.3f", high/30)
+	}
+}
+
+func toResults(hits []embedx.SearchResult) []embedx.Result {
+	out := make([]embedx.Result, len(hits))
+	for i, h := range hits {
+		out[i] = embedx.Result{ID: h.ID, Score: h.Score}
+	}
+	return out
+}
+
+func TestGraphDelete(t *testing.T) {
+	g := New(DefaultConfig)
+	_ = g.Add("a", []float32{1, 0, 0})
+	_ = g.Add("b", []float32{0.9, 0.1, 0})
+	_ = g.Add("c", []float32{0, 1, 0})
+
+	if err := g.Delete("a"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if g.Len() != 2 {
+		t.Errorf("Expected 2 live vectors, got %d", g.Len())
+	}
+
+	res, err := g.Search([]float32{1, 0, 0}, 3)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	for _, r := range res {
+		if r.ID == "a" {
+			t.Error("Deleted vector returned in search results")
+		}
+	}
+	if len(res) != 2 || res[0].ID != "b" {
+		t.Errorf("Expected [b c], got %v", res)
+	}
+
+	if err := g.Delete("a"); err == nil {
+		t.Error("Expected error deleting a missing vector, got nil")
+	}
+
+	// Re-adding a deleted ID makes it searchable again.
+	if err := g.Add("a", []float32{1, 0, 0}); err != nil {
+		t.Fatalf("re-Add failed: %v", err)
+	}
+	res, _ = g.Search([]float32{1, 0, 0}, 1)
+	if len(res) != 1 || res[0].ID != "a" {
+		t.Errorf("Expected re-added vector a first, got %v", res)
+	}
+}
+
+func TestGraphDeleteManyKeepsRecall(t *testing.T) {
+	r := rand.New(rand.NewSource(5))
+	data := randomVectors(r, 1000, 16)
+
+	brute := embedx.New(embedx.NewMemoryStore())
+	g := New(DefaultConfig)
+	for i, v := range data {
+		id := fmt.Sprintf("v%d", i)
+		_ = g.Add(id, v)
+		if i%2 == 0 {
+			_ = g.Delete(id)
+			continue
+		}
+		_ = brute.Add(id, v)
+	}
+
+	var total float64
+	qs := randomVectors(r, 20, 16)
+	for _, q := range qs {
+		expected, _ := brute.Search(q, 10)
+		got, err := g.Search(q, 10)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		total += recall(expected, toResults(got))
+	}
+	if avg := total / float64(len(qs)); avg < 0.9 {
+		t.Errorf("Expected recall >= 0.9 with half the graph tombstoned, got %.3f", avg)
+	}
+}
+
+func TestGraphCompact(t *testing.T) {
+	r := rand.New(rand.NewSource(6))
+	data := randomVectors(r, 500, 16)
+
+	brute := embedx.New(embedx.NewMemoryStore())
+	g := New(DefaultConfig)
+	for i, v := range data {
+		id := fmt.Sprintf("v%d", i)
+		_ = g.Add(id, v)
+		if i%4 != 0 {
+			_ = g.Delete(id)
+			continue
+		}
+		_ = brute.Add(id, v)
+	}
+	if g.Tombstones() != 375 || g.NodeCount() != 500 {
+		t.Fatalf("Expected 375 tombstones of 500 nodes, got %d of %d", g.Tombstones(), g.NodeCount())
+	}
+
+	g.Compact()
+	if g.Tombstones() != 0 || g.NodeCount() != 125 || g.Len() != 125 {
+		t.Fatalf("Expected 125 live nodes and no tombstones, got %d nodes, %d live, %d tombstones", g.NodeCount(), g.Len(), g.Tombstones())
+	}
+
+	var total float64
+	for _, q := range randomVectors(r, 20, 16) {
+		expected, _ := brute.Search(q, 10)
+		got, err := g.Search(q, 10)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		total += recall(expected, toResults(got))
+	}
+	if avg := total / 20; avg < 0.9 {
+		t.Errorf("Expected recall >= 0.9 after compaction, got %.3f", avg)
+	}
+
+	// The compacted graph keeps accepting writes.
+	if err := g.Delete("v0"); err != nil {
+		t.Fatalf("Delete after Compact failed: %v", err)
+	}
+	if err := g.Add("v1", data[1]); err != nil {
+		t.Fatalf("Add after Compact failed: %v", err)
+	}
+	if res, _ := g.Search(data[1], 1); len(res) != 1 || res[0].ID != "v1" {
+		t.Errorf("Expected v1 first, got %v", res)
+	}
+}
+
+func TestGraphUpdate(t *testing.T) {
+	g := New(DefaultConfig)
+	_ = g.Add("a", []float32{1, 0})
+	_ = g.Add("b", []float32{0, 1})
+
+	// Identical re-add is a no-op.
+	if err := g.Add("a", []float32{1, 0}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if len(g.nodes) != 2 {
+		t.Errorf("Expected identical re-add to be a no-op, graph has %d nodes", len(g.nodes))
+	}
+
+	// Changing the vector replaces the old node.
+	if err := g.Add("a", []float32{0, -1}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if g.Len() != 2 {
+		t.Errorf("Expected 2 live vectors, got %d", g.Len())
+	}
+	res, _ := g.Search([]float32{0, -1}, 1)
+	if len(res) != 1 || res[0].ID != "a" || res[0].Score < 0.99 {
+		t.Errorf("Expected updated vector a to match, got %v", res)
+	}
+}
+
+func TestGraphErrors(t *testing.T) {
+	g := New(Config{})
+	if g.Config().M != DefaultConfig.M {
+		t.Errorf("Expected zero config to fall back to defaults, got %+v", g.Config())
+	}
+
+	if err := g.Add("", []float32{1}); err == nil {
+		t.Error("Expected error for empty id, got nil")
+	}
+	if err := g.Add("a", nil); err == nil {
+		t.Error("Expected error for empty vector, got nil")
+	}
+	if _, err := g.Search(nil, 1); err == nil {
+		t.Error("Expected error for empty query, got nil")
+	}
+
+	res, err := g.Search([]float32{1, 2}, 1)
+	if err != nil || len(res) != 0 {
+		t.Errorf("Expected empty result on empty graph, got %v, %v", res, err)
+	}
+
+	_ = g.Add("a", []float32{1, 2})
+	if err := g.Add("b", []float32{1, 2, 3}); err == nil {
+		t.Error("Expected dimension mismatch error, got nil")
+	}
+	if _, err := g.Search([]float32{1, 2, 3}, 1); err == nil {
+		t.Error("Expected query dimension mismatch error, got nil")
+	}
+}
+
+func TestGraphRecallWithMetrics(t *testing.T) {
+	const k = 10
+	for _, m := range []vector.Metric{vector.MetricDot, vector.MetricEuclidean, vector.MetricManhattan} {
+		t.Run(m.String(), func(t *testing.T) {
+			r := rand.New(rand.NewSource(5))
+			data := randomVectors(r, 1000, 16)
+
+			cfg := DefaultConfig
+			cfg.Metric = m
+			brute := embedx.New(embedx.NewMemoryStore(), embedx.WithMetric(m))
+			indexed := embedx.New(embedx.NewMemoryStore(), embedx.WithIndex(New(cfg)))
+			for i, v := range data {
+				id := fmt.Sprintf("v%d", i)
+				_ = brute.Add(id, v)
+				_ = indexed.Add(id, v)
+			}
+
+			var total float64
+			for _, q := range randomVectors(r, 20, 16) {
+				expected, _ := brute.Search(q, k)
+				got, err := indexed.Search(q, k)
+				if err != nil {
+					t.Fatalf("indexed Search failed: %v", err)
+				}
+				if got[0].Score != m.Score(q, got[0].Vector) {
+					t.Fatalf("Expected score %v, got %v", m.Score(q, got[0].Vector), got[0].Score)
+				}
+				total += recall(expected, got)
+			}
+			if avg := total / 20; avg < 0.9 {
+				t.Errorf("Expected average recall@%d >= 0.9, got %.3f", k, avg)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package hnsw
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+
+	"github.com/ldaidone/goembedx/vector"
+)
+
+// NodeState is the exported form of a graph node, used to persist the graph
+// outside of memory and to restore it later.
+type NodeState struct {
+	// ID is the external identifier of the vector.
+	ID string
+	// Vector is the vector data of the node.
+	Vector []float32
+	// Level is the highest layer the node appears on.
+	Level int
+	// Links holds the neighbor node indexes for layers 0..Level.
+	Links [][]uint32
+	// Deleted marks a tombstoned node.
+	Deleted bool
+}
+
+// TrackChanges enables recording of modified nodes so that callers persisting
+// the graph can write only what changed. Modified nodes are retrieved with TakeDirty.
+func (g *Graph) TrackChanges() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.dirty == nil {
+		g.dirty = make(map[uint32]struct{})
+	}
+}
+
+// TakeDirty returns the indexes of the nodes modified since the previous call,
+// in ascending order, and clears the change set.
+// It returns nil if change tracking is not enabled.
+func (g *Graph) TakeDirty() []uint32 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.dirty) == 0 {
+		return nil
+	}
+
+	out := make([]uint32, 0, len(g.dirty))
+	for idx := range g.dirty {
+		out = append(out, idx)
+	}
+	clear(g.dirty)
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+// Node returns a copy of the state of the node at idx.
+// It panics if idx is out of range.
+func (g *Graph) Node(idx uint32) NodeState {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	n := g.nodes[idx]
+	links := make([][]uint32, len(n.links))
+	for l, ls := range n.links {
+		links[l] = append([]uint32(nil), ls...)
+	}
+	return NodeState{
+		ID:      n.id,
+		Vector:  append([]float32(nil), n.vec...),
+		Level:   n.level,
+		Links:   links,
+		Deleted: n.deleted,
+	}
+}
+
+// NodeCount returns the number of nodes in the graph, including tombstones.
+func (g *Graph) NodeCount() int {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return len(g.nodes)
+}
+
+// EntryPoint returns the index of the entry point node and its level.
+// The level is -1 when the graph is empty.
+func (g *Graph) EntryPoint() (uint32, int) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.entry, g.maxLevel
+}
+
+// Restore rebuilds a graph from previously exported nodes and entry point.
+// nodes[i] must be the state of the node exported at index i.
+// Returns an error if the nodes reference missing neighbors, if a live ID
+// appears more than once, or if the vectors have inconsistent dimensions.
+func Restore(cfg Config, nodes []NodeState, entry uint32, maxLevel int) (*Graph, error) {
+	g := New(cfg)
+	if len(nodes) == 0 {
+		return g, nil
+	}
+	if int(entry) >= len(nodes) || maxLevel < 0 || nodes[entry].Level != maxLevel {
+		return nil, fmt.Errorf("hnsw: invalid entry point %d at level %d", entry, maxLevel)
+	}
+
+	g.nodes = make([]*node, len(nodes))
+	for i, ns := range nodes {
+		if len(ns.Vector) == 0 {
+			return nil, fmt.Errorf("hnsw: node %d has no vector", i)
+		}
+		if g.dim == 0 {
+			g.dim = len(ns.Vector)
+		} else if len(ns.Vector) != g.dim {
+			return nil, fmt.Errorf("hnsw: node %d dimension mismatch: expected %d, got %d", i, g.dim, len(ns.Vector))
+		}
+		if len(ns.Links) != ns.Level+1 {
+			return nil, fmt.Errorf("hnsw: node %d has %d link layers for level %d", i, len(ns.Links), ns.Level)
+		}
+
+		links := make([][]uint32, len(ns.Links))
+		for l, ls := range ns.Links {
+			for _, nb := range ls {
+				if int(nb) >= len(nodes) || nodes[nb].Level < l {
+					return nil, fmt.Errorf("hnsw: node %d links to missing node %d on layer %d", i, nb, l)
+				}
+			}
+			links[l] = append([]uint32(nil), ls...)
+		}
+
+		g.nodes[i] = &node{
+			id:      ns.ID,
+			vec:     append([]float32(nil), ns.Vector...),
+			norm:    vector.Norm(ns.Vector),
+			level:   ns.Level,
+			links:   links,
+			deleted: ns.Deleted,
+		}
+		if ns.Deleted {
+			continue
+		}
+		if _, dup := g.ids[ns.ID]; dup {
+			return nil, fmt.Errorf("hnsw: duplicate live node for id %s", ns.ID)
+		}
+		g.ids[ns.ID] = uint32(i)
+		g.live++
+	}
+
+	g.entry = entry
+	g.maxLevel = maxLevel
+	// Reseed so restored graphs do not replay the level sequence of the original build.
+	g.rng = rand.New(rand.NewSource(g.cfg.Seed + int64(len(nodes))))
+	return g, nil
+}
@@ -0,0 +1,232 @@
+package ivf
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/ldaidone/goembedx/vector"
+)
+
+// centroidsVersion is the encoding version written by Centroids.MarshalBinary.
+const centroidsVersion = 1
+
+// Centroids partitions vectors into posting lists, one per centroid.
+// A vector belongs to the list of the centroid it scores highest against:
+// the largest cosine similarity or dot product for those metrics, and the
+// smallest Euclidean distance otherwise.
+type Centroids struct {
+	// metric selects how vectors are compared with the centroids.
+	metric vector.Metric
+	// dim is the dimension of the centroids.
+	dim int
+	// data holds centroid c at data[c*dim:(c+1)*dim].
+	data []float32
+}
+
+// TrainCentroids runs k-means on sample and returns up to cfg.Lists centroids;
+// fewer are trained when the sample is smaller. For cosine similarity the
+// sample and the centroids are normalized, so that lists group vectors by
+// direction.
+// Returns an error if sample is empty, the vectors have different or zero
+// dimensions, or cfg.Lists is not positive.
+func TrainCentroids(sample [][]float32, cfg Config) (*Centroids, error) {
+	if len(sample) == 0 {
+		return nil, errors.New("ivf: cannot train centroids without vectors")
+	}
+	if cfg.Lists <= 0 {
+		return nil, errors.New("ivf: lists must be positive")
+	}
+	points, err := trainingPoints(sample, cfg.Metric)
+	if err != nil {
+		return nil, err
+	}
+
+	iterations := cfg.Iterations
+	if iterations <= 0 {
+		iterations = DefaultConfig.Iterations
+	}
+	c := &Centroids{metric: cfg.Metric, dim: len(points[0])}
+	for _, centroid := range vector.KMeans(points, min(cfg.Lists, len(points)), iterations, cfg.Seed) {
+		c.data = append(c.data, centroid...)
+	}
+	c.normalize()
+	return c, nil
+}
+
+// Refine returns centroids refined with up to iterations rounds of k-means on
+// sample, starting from c. Lists left empty are reseeded from the largest
+// list, which rebalances a partition that has drifted from the data.
+// Returns an error if sample is empty or its vectors do not have the
+// dimension of the centroids.
+func (c *Centroids) Refine(sample [][]float32, iterations int, seed int64) (*Centroids, error) {
+	if len(sample) == 0 {
+		return nil, errors.New("ivf: cannot refine centroids without vectors")
+	}
+	points, err := trainingPoints(sample, c.metric)
+	if err != nil {
+		return nil, err
+	}
+	if len(points[0]) != c.dim {
+		return nil, fmt.Errorf("ivf: vector dimension mismatch: expected %d, got %d", c.dim, len(points[0]))
+	}
+	if iterations <= 0 {
+		iterations = DefaultConfig.Iterations
+	}
+
+	r := &Centroids{metric: c.metric, dim: c.dim, data: append([]float32(nil), c.data...)}
+	centroids := make([][]float32, r.Len())
+	for i := range centroids {
+		centroids[i] = r.centroid(i)
+	}
+	vector.RefineKMeans(centroids, points, iterations, seed)
+	r.normalize()
+	return r, nil
+}
+
+// normalize scales the centroids to unit length for cosine similarity.
+func (c *Centroids) normalize() {
+	if c.metric != vector.MetricCosine {
+		return
+	}
+	for i := 0; i < c.Len(); i++ {
+		centroid := c.centroid(i)
+		if n := vector.Norm(centroid); n > 0 {
+			for j := range centroid {
+				centroid[j] /= n
+			}
+		}
+	}
+}
+
+// trainingPoints checks that sample holds non-empty vectors of one dimension
+// and returns the points k-means runs on, normalized for cosine similarity.
+func trainingPoints(sample [][]float32, m vector.Metric) ([][]float32, error) {
+	dim := len(sample[0])
+	if dim == 0 {
+		return nil, errors.New("ivf: cannot train centroids on empty vectors")
+	}
+	for _, v := range sample {
+		if len(v) != dim {
+			return nil, errors.New("ivf: cannot train centroids on vectors of different lengths")
+		}
+	}
+	if m != vector.MetricCosine {
+		return sample, nil
+	}
+
+	points := make([][]float32, len(sample))
+	for i, v := range sample {
+		points[i] = make([]float32, dim)
+		if n := vector.Norm(v); n > 0 {
+			for j, x := range v {
+				points[i][j] = x / n
+			}
+		}
+	}
+	return points, nil
+}
+
+// Len returns the number of centroids, which is the number of lists.
+func (c *Centroids) Len() int { return len(c.data) / c.dim }
+
+// Dim returns the dimension of the centroids.
+func (c *Centroids) Dim() int { return c.dim }
+
+// centroid returns centroid i.
+func (c *Centroids) centroid(i int) []float32 {
+	return c.data[i*c.dim : (i+1)*c.dim]
+}
+
+// score returns how close vec is to centroid i, higher being closer.
+func (c *Centroids) score(vec []float32, i int) float32 {
+	switch c.metric {
+	case vector.MetricCosine, vector.MetricDot:
+		// Cosine centroids have unit length, so the dot product ranks them
+		// like the cosine similarity.
+		return vector.Dot(vec, c.centroid(i))
+	default:
+		return -vector.L2Squared(vec, c.centroid(i))
+	}
+}
+
+// Assign returns the list vec belongs to.
+//
+// This function will panic if vec does not have the dimension of the centroids.
+func (c *Centroids) Assign(vec []float32) int {
+	if len(vec) != c.dim {
+		panic("ivf: Assign requires a vector of the centroids' dimension")
+	}
+	best, bestScore := 0, float32(math.Inf(-1))
+	for i := 0; i < c.Len(); i++ {
+		if s := c.score(vec, i); s > bestScore {
+			best, bestScore = i, s
+		}
+	}
+	return best
+}
+
+// Probe returns the n lists closest to query, closest first.
+// Every list is returned if n <= 0 or n exceeds the number of lists.
+//
+// This function will panic if query does not have the dimension of the centroids.
+func (c *Centroids) Probe(query []float32, n int) []int {
+	if len(query) != c.dim {
+		panic("ivf: Probe requires a query of the centroids' dimension")
+	}
+	lists := make([]int, c.Len())
+	scores := make([]float32, c.Len())
+	for i := range lists {
+		lists[i], scores[i] = i, c.score(query, i)
+	}
+	sort.SliceStable(lists, func(i, j int) bool {
+		return scores[lists[i]] > scores[lists[j]]
+	})
+	if n > 0 && n < len(lists) {
+		lists = lists[:n]
+	}
+	return lists
+}
+
+// MarshalBinary encodes the centroids in a versioned little-endian layout.
+func (c *Centroids) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 0, 10+4*len(c.data))
+	b = append(b, centroidsVersion, byte(c.metric))
+	b = binary.LittleEndian.AppendUint32(b, uint32(c.Len()))
+	b = binary.LittleEndian.AppendUint32(b, uint32(c.dim))
+	for _, x := range c.data {
+		b = binary.LittleEndian.AppendUint32(b, math.Float32bits(x))
+	}
+	return b, nil
+}
+
+// UnmarshalBinary decodes centroids encoded by MarshalBinary.
+func (c *Centroids) UnmarshalBinary(b []byte) error {
+	if len(b) < 10 {
+		return errors.New("ivf: centroids encoding is truncated")
+	}
+	if b[0] != centroidsVersion {
+		return fmt.Errorf("ivf: unsupported centroids version %d", b[0])
+	}
+	metric := vector.Metric(b[1])
+	if !metric.Valid() {
+		return fmt.Errorf("ivf: invalid metric %d", b[1])
+	}
+	n := binary.LittleEndian.Uint32(b[2:])
+	dim := binary.LittleEndian.Uint32(b[6:])
+	if n == 0 || dim == 0 {
+		return errors.New("ivf: centroids encoding has no centroids")
+	}
+	if uint64(len(b)-10) != 4*uint64(n)*uint64(dim) {
+		return errors.New("ivf: centroids encoding is truncated")
+	}
+
+	c.metric, c.dim = metric, int(dim)
+	c.data = make([]float32, (len(b)-10)/4)
+	for i := range c.data {
+		c.data[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[10+4*i:]))
+	}
+	return nil
+}
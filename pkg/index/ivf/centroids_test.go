@@ -0,0 +1,84 @@
+package ivf
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/ldaidone/goembedx/vector"
+)
+
+func TestTrainCentroids(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	sample := randomVectors(r, 100, 8)
+
+	c, err := TrainCentroids(sample, Config{Lists: 16, Seed: 1})
+	if err != nil {
+		t.Fatalf("TrainCentroids failed: %v", err)
+	}
+	if c.Len() != 16 || c.Dim() != 8 {
+		t.Fatalf("Expected 16 centroids of dimension 8, got %d of %d", c.Len(), c.Dim())
+	}
+	// Cosine centroids are normalized.
+	for i := 0; i < c.Len(); i++ {
+		if n := vector.Norm(c.centroid(i)); n < 0.999 || n > 1.001 {
+			t.Errorf("Expected unit centroid %d, got norm %v", i, n)
+		}
+	}
+
+	// Probing returns the assigned list first.
+	for _, v := range sample[:10] {
+		if probe := c.Probe(v, 3); len(probe) != 3 || probe[0] != c.Assign(v) {
+			t.Errorf("Expected probe to start with the assigned list %d, got %v", c.Assign(v), probe)
+		}
+	}
+	if probe := c.Probe(sample[0], 0); len(probe) != 16 {
+		t.Errorf("Expected every list for n=0, got %v", probe)
+	}
+
+	// Fewer centroids are trained for small samples.
+	if c, err := TrainCentroids(sample[:4], Config{Lists: 16}); err != nil || c.Len() != 4 {
+		t.Errorf("Expected 4 centroids for 4 vectors, got %v", err)
+	}
+
+	for name, bad := range map[string][][]float32{
+		"empty sample": nil,
+		"empty vector": {{}},
+		"mixed length": {{1, 2}, {1}},
+	} {
+		if _, err := TrainCentroids(bad, Config{Lists: 2}); err == nil {
+			t.Errorf("%s: expected error, got nil", name)
+		}
+	}
+	if _, err := TrainCentroids(sample, Config{}); err == nil {
+		t.Error("Expected error for zero lists, got nil")
+	}
+}
+
+func TestCentroidsMarshal(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	c, err := TrainCentroids(randomVectors(r, 50, 4), Config{Lists: 5, Seed: 1, Metric: vector.MetricEuclidean})
+	if err != nil {
+		t.Fatalf("TrainCentroids failed: %v", err)
+	}
+	b, err := c.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+
+	var got Centroids
+	if err := got.UnmarshalBinary(b); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+	if got.metric != c.metric || got.dim != c.dim || !slices.Equal(got.data, c.data) {
+		t.Errorf("Round trip mismatch: %+v != %+v", got, *c)
+	}
+
+	if err := got.UnmarshalBinary(b[:len(b)-1]); err == nil {
+		t.Error("Expected error for truncated encoding, got nil")
+	}
+	b[0] = 99
+	if err := got.UnmarshalBinary(b); err == nil {
+		t.Error("Expected error for unknown version, got nil")
+	}
+}
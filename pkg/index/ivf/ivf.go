@@ -0,0 +1,330 @@
+// Package ivf implements an inverted file (IVF) index for approximate
+// nearest-neighbor search over float32 vectors.
+//
+// The vectors are partitioned into posting lists by their closest k-means
+// centroid, and a search only scores the vectors of the NProbe lists whose
+// centroids are closest to the query. Unlike HNSW, the index keeps no links
+// between vectors, so it needs little memory beyond the vectors themselves.
+//
+// The centroids are trained explicitly with Train once the index holds
+// representative data; until then every vector is kept in a single list and
+// searches are exhaustive. Rebalance refines the centroids when the data has
+// drifted and lists have grown uneven.
+package ivf
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ldaidone/goembedx/pkg/embedx"
+	"github.com/ldaidone/goembedx/vector"
+)
+
+// Config holds the tuning parameters of an IVF index.
+type Config struct {
+	// Lists is the number of posting lists, and of k-means centroids.
+	// A common choice is around the square root of the number of vectors.
+	Lists int
+	// NProbe is the number of lists scored by a search.
+	// Larger values improve recall at the cost of slower queries.
+	NProbe int
+	// Iterations bounds the number of k-means iterations when training.
+	Iterations int
+	// Sample is the number of vectors the centroids are trained on, drawn
+	// at random from the indexed vectors. Every vector is used if it is 0.
+	Sample int
+	// Seed initializes the random generator used for sampling and k-means.
+	Seed int64
+	// Metric is the similarity function the index is searched with.
+	// The zero value is vector.MetricCosine.
+	Metric vector.Metric
+}
+
+// DefaultConfig provides reasonable default values for general-purpose embeddings.
+var DefaultConfig = Config{
+	Lists:      256,
+	NProbe:     8,
+	Iterations: 25,
+	Sample:     65536,
+	Seed:       1,
+}
+
+// entry is a vector in a posting list.
+type entry struct {
+	// id is the external identifier of the vector.
+	id string
+	// vec is a private copy of the vector data.
+	vec []float32
+	// norm is the precomputed L2 norm of vec.
+	norm float32
+}
+
+// location is the position of a vector in the posting lists.
+type location struct {
+	list, pos int
+}
+
+// Index is a thread-safe IVF index.
+type Index struct {
+	// cfg holds the tuning parameters.
+	cfg Config
+	// mu guards all fields below.
+	mu sync.RWMutex
+	// centroids assigns vectors to lists, or is nil before Train.
+	centroids *Centroids
+	// lists holds the posting lists, a single one before Train.
+	lists [][]entry
+	// ids maps external IDs to the location of their vector.
+	ids map[string]location
+	// dim is the dimension of the indexed vectors, fixed by the first insert.
+	dim int
+}
+
+// Compile-time interface check
+var _ embedx.Index = (*Index)(nil)
+
+// New creates an empty, untrained index with the given configuration.
+// Zero or negative fields in cfg are replaced by the values of DefaultConfig,
+// except Sample, for which 0 means every vector.
+func New(cfg Config) *Index {
+	if cfg.Lists <= 0 {
+		cfg.Lists = DefaultConfig.Lists
+	}
+	if cfg.NProbe <= 0 {
+		cfg.NProbe = DefaultConfig.NProbe
+	}
+	if cfg.Iterations <= 0 {
+		cfg.Iterations = DefaultConfig.Iterations
+	}
+	if cfg.Sample < 0 {
+		cfg.Sample = DefaultConfig.Sample
+	}
+	if !cfg.Metric.Valid() {
+		cfg.Metric = DefaultConfig.Metric
+	}
+	return &Index{
+		cfg:   cfg,
+		lists: make([][]entry, 1),
+		ids:   make(map[string]location),
+	}
+}
+
+// Config returns the configuration the index was created with.
+func (ix *Index) Config() Config {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return ix.cfg
+}
+
+// SetNProbe changes the number of lists scored by Search.
+// Values less than or equal to 0 are ignored.
+func (ix *Index) SetNProbe(n int) {
+	if n <= 0 {
+		return
+	}
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.cfg.NProbe = n
+}
+
+// Trained reports whether the centroids have been trained.
+func (ix *Index) Trained() bool {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return ix.centroids != nil
+}
+
+// Len returns the number of vectors in the index.
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.ids)
+}
+
+// ListSizes returns the number of vectors in each posting list.
+func (ix *Index) ListSizes() []int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	sizes := make([]int, len(ix.lists))
+	for i, l := range ix.lists {
+		sizes[i] = len(l)
+	}
+	return sizes
+}
+
+// Add inserts a vector with the given ID, replacing any previous vector
+// stored under the same ID. The vector is appended to the list of its
+// closest centroid.
+// Returns an error wrapping embedx.ErrInvalidID if the ID is empty, an error
+// wrapping a *embedx.DimensionError if the vector dimension differs from the
+// vectors already indexed, or an error if the vector is empty.
+func (ix *Index) Add(id string, vec []float32) error {
+	if id == "" {
+		return fmt.Errorf("ivf: %w: id cannot be empty", embedx.ErrInvalidID)
+	}
+	if len(vec) == 0 {
+		return errors.New("ivf: vector cannot be empty")
+	}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	if ix.dim != 0 && len(vec) != ix.dim {
+		return fmt.Errorf("ivf: %w", &embedx.DimensionError{Expected: ix.dim, Actual: len(vec)})
+	}
+	ix.dim = len(vec)
+
+	if _, ok := ix.ids[id]; ok {
+		ix.remove(id)
+	}
+	ix.insert(entry{id: id, vec: append([]float32(nil), vec...), norm: vector.Norm(vec)})
+	return nil
+}
+
+// Delete removes the vector with the given ID.
+// Returns an error wrapping embedx.ErrNotFound if the ID is not present.
+func (ix *Index) Delete(id string) error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	if _, ok := ix.ids[id]; !ok {
+		return fmt.Errorf("ivf: %w: %s", embedx.ErrNotFound, id)
+	}
+	ix.remove(id)
+	return nil
+}
+
+// insert appends e to the list of its closest centroid.
+// The caller must hold the write lock.
+func (ix *Index) insert(e entry) {
+	list := 0
+	if ix.centroids != nil {
+		list = ix.centroids.Assign(e.vec)
+	}
+	ix.ids[e.id] = location{list: list, pos: len(ix.lists[list])}
+	ix.lists[list] = append(ix.lists[list], e)
+}
+
+// remove deletes the vector of id, moving the last vector of its list into
+// its place. The caller must hold the write lock.
+func (ix *Index) remove(id string) {
+	loc := ix.ids[id]
+	l := ix.lists[loc.list]
+	last := len(l) - 1
+	if loc.pos != last {
+		l[loc.pos] = l[last]
+		ix.ids[l[loc.pos].id] = loc
+	}
+	l[last] = entry{}
+	ix.lists[loc.list] = l[:last]
+	delete(ix.ids, id)
+}
+
+// Search returns the approximate top-k most similar vectors to the query,
+// scored by the configured metric and sorted by score in descending order and
+// by ID among equal scores. Only the vectors in the NProbe lists closest to
+// the query are scored. If k <= 0, every list is read and every vector returned.
+// Returns an error if the query is empty, or an error wrapping a
+// *embedx.DimensionError if its dimension does not match the index.
+func (ix *Index) Search(query []float32, k int) ([]embedx.SearchResult, error) {
+	if len(query) == 0 {
+		return nil, errors.New("ivf: query vector is empty")
+	}
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	if len(ix.ids) == 0 {
+		return []embedx.SearchResult{}, nil
+	}
+	if len(query) != ix.dim {
+		return nil, fmt.Errorf("ivf: %w", &embedx.DimensionError{Expected: ix.dim, Actual: len(query)})
+	}
+
+	lists := []int{0}
+	if ix.centroids != nil {
+		nprobe := ix.cfg.NProbe
+		if k <= 0 {
+			nprobe = 0
+		}
+		lists = ix.centroids.Probe(query, nprobe)
+	}
+
+	q := vector.Norm(query)
+	top := vector.NewTopK(k)
+	for _, list := range lists {
+		for _, e := range ix.lists[list] {
+			if ix.cfg.Metric == vector.MetricCosine && (q == 0 || e.norm == 0) {
+				continue
+			}
+			top.Push(vector.Candidate{ID: e.id, Score: ix.cfg.Metric.ScoreNorms(query, e.vec, q, e.norm)})
+		}
+	}
+	hits := top.Results()
+	results := make([]embedx.SearchResult, len(hits))
+	for i, hit := range hits {
+		results[i] = embedx.SearchResult{ID: hit.ID, Score: hit.Score}
+	}
+	return results, nil
+}
+
+// Train runs k-means on a sample of the indexed vectors and reassigns every
+// vector to the list of its closest centroid. Training an index that is
+// already trained replaces its centroids.
+// Returns an error if the index is empty.
+func (ix *Index) Train() error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	c, err := TrainCentroids(ix.sample(), ix.cfg)
+	if err != nil {
+		return err
+	}
+	ix.reassign(c)
+	return nil
+}
+
+// Rebalance refines the trained centroids on a sample of the indexed vectors,
+// reseeding lists that became empty from the largest ones, and reassigns
+// every vector. Unlike Train, it starts from the current centroids, so it is
+// cheaper and keeps most vectors in their list.
+// Returns an error if the index is empty or has not been trained.
+func (ix *Index) Rebalance() error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	if ix.centroids == nil {
+		return errors.New("ivf: cannot rebalance an untrained index")
+	}
+	c, err := ix.centroids.Refine(ix.sample(), ix.cfg.Iterations, ix.cfg.Seed)
+	if err != nil {
+		return err
+	}
+	ix.reassign(c)
+	return nil
+}
+
+// sample draws up to cfg.Sample indexed vectors. The caller must hold the lock.
+func (ix *Index) sample() [][]float32 {
+	r := vector.NewReservoir(ix.cfg.Sample, ix.cfg.Seed)
+	for _, l := range ix.lists {
+		for _, e := range l {
+			r.Add(e.vec)
+		}
+	}
+	return r.Sample()
+}
+
+// reassign replaces the centroids with c and rebuilds the posting lists.
+// The caller must hold the write lock.
+func (ix *Index) reassign(c *Centroids) {
+	old := ix.lists
+	ix.centroids = c
+	ix.lists = make([][]entry, c.Len())
+	for _, l := range old {
+		for _, e := range l {
+			ix.insert(e)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package ivf
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/ldaidone/goembedx/pkg/embedx"
+	"github.com/ldaidone/goembedx/vector"
+)
+
+func randomVectors(r *rand.Rand, n, dim int) [][]float32 {
+	vecs := make([][]float32, n)
+	for i := range vecs {
+		v := make([]float32, dim)
+		for j := range v {
+			v[j] = r.Float32()*2 - 1
+		}
+		vecs[i] = v
+	}
+	return vecs
+}
+
+// recall returns the fraction of expected IDs present in got.
+func recall(expected []embedx.Result, got []embedx.SearchResult) float64 {
+	want := make(map[string]struct{}, len(expected))
+	for _, r := range expected {
+		want[r.ID] = struct{}{}
+	}
+	hits := 0
+	for _, r := range got {
+		if _, ok := want[r.ID]; ok {
+			hits++
+		}
+	}
+	return float64(hits) / float64(len(expected))
+}
+
+func TestIndexRecallWithNProbe(t *testing.T) {
+	const (
+		n       = 2000
+		dim     = 16
+		queries = 30
+		k       = 10
+	)
+	r := rand.New(rand.NewSource(7))
+	data := randomVectors(r, n, dim)
+
+	brute := embedx.New(embedx.NewMemoryStore())
+	ix := New(Config{Lists: 32, NProbe: 1, Seed: 1})
+	for i, v := range data {
+		id := fmt.Sprintf("v%d", i)
+		_ = brute.Add(id, v)
+		if err := ix.Add(id, v); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+	if err := ix.Train(); err != nil {
+		t.Fatalf("Train failed: %v", err)
+	}
+	if !ix.Trained() || len(ix.ListSizes()) != 32 {
+		t.Fatalf("Expected 32 trained lists, got %v", ix.ListSizes())
+	}
+
+	qs := randomVectors(r, queries, dim)
+	measure := func() float64 {
+		var total float64
+		for _, q := range qs {
+			expected, _ := brute.Search(q, k)
+			got, err := ix.Search(q, k)
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			for i := 1; i < len(got); i++ {
+				if got[i].Score > got[i-1].Score {
+					t.Fatalf("results not sorted by descending score: %v", got)
+				}
+			}
+			total += recall(expected, got)
+		}
+		return total / queries
+	}
+
+	low := measure()
+	ix.SetNProbe(8)
+	mid := measure()
+	ix.SetNProbe(32)
+	full := measure()
+	if mid < low || full < mid {
+		t.Errorf("Expected recall to improve with nprobe: 1=%.3f 8=%.3f 32=%.3f", low, mid, full)
+	}
+	if full != 1 {
+		t.Errorf("Expected exact results when probing every list, got recall %.3f", full)
+	}
+}
+
+func TestIndexUntrained(t *testing.T) {
+	ix := New(Config{})
+	_ = ix.Add("a", []float32{1, 0})
+	_ = ix.Add("b", []float32{0, 1})
+
+	// Before training, searches are exhaustive.
+	results, err := ix.Search([]float32{0.1, 1}, 1)
+	if err != nil || len(results) != 1 || results[0].ID != "b" {
+		t.Errorf("Unexpected untrained results: %v, %v", results, err)
+	}
+	if err := ix.Rebalance(); err == nil {
+		t.Error("Expected error rebalancing an untrained index, got nil")
+	}
+	if err := New(Config{}).Train(); err == nil {
+		t.Error("Expected error training an empty index, got nil")
+	}
+}
+
+func TestIndexUpdateAndDelete(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	ix := New(Config{Lists: 8, NProbe: 8, Seed: 1, Metric: vector.MetricEuclidean})
+	for i, v := range randomVectors(r, 200, 8) {
+		_ = ix.Add(fmt.Sprint(i), v)
+	}
+	if err := ix.Train(); err != nil {
+		t.Fatalf("Train failed: %v", err)
+	}
+
+	// Re-adding an ID moves it to the list of its new vector.
+	target := []float32{5, 5, 5, 5, 5, 5, 5, 5}
+	if err := ix.Add("7", target); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if ix.Len() != 200 {
+		t.Errorf("Expected 200 vectors after update, got %d", ix.Len())
+	}
+	if results, _ := ix.Search(target, 1); len(results) != 1 || results[0].ID != "7" || results[0].Score != 1 {
+		t.Errorf("Expected updated vector to match exactly, got %v", results)
+	}
+
+	if err := ix.Delete("7"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := ix.Delete("7"); !errors.Is(err, embedx.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if results, _ := ix.Search(target, 200); len(results) != 199 {
+		t.Errorf("Expected 199 results probing every list, got %d", len(results))
+	}
+
+	// Locations stay consistent after the swap-removal of many vectors.
+	for i := 0; i < 100; i++ {
+		if err := ix.Delete(fmt.Sprint(i * 2)); err != nil && i*2 != 7 {
+			t.Fatalf("Delete %d failed: %v", i*2, err)
+		}
+	}
+	total := 0
+	for _, n := range ix.ListSizes() {
+		total += n
+	}
+	if total != ix.Len() || ix.Len() != 99 {
+		t.Errorf("Expected 99 vectors in the lists, got %d in %v", ix.Len(), ix.ListSizes())
+	}
+}
+
+func TestIndexRebalance(t *testing.T) {
+	r := rand.New(rand.NewSource(5))
+	ix := New(Config{Lists: 8, Seed: 1, Metric: vector.MetricEuclidean})
+	for i, v := range randomVectors(r, 400, 4) {
+		_ = ix.Add(fmt.Sprint(i), v)
+	}
+	if err := ix.Train(); err != nil {
+		t.Fatalf("Train failed: %v", err)
+	}
+
+	// New data far from every centroid piles into a single list.
+	for i, v := range randomVectors(r, 400, 4) {
+		for j := range v {
+			v[j] += 10
+		}
+		_ = ix.Add(fmt.Sprintf("shifted%d", i), v)
+	}
+	largest := func() int {
+		m := 0
+		for _, n := range ix.ListSizes() {
+			m = max(m, n)
+		}
+		return m
+	}
+	before := largest()
+	if err := ix.Rebalance(); err != nil {
+		t.Fatalf("Rebalance failed: %v", err)
+	}
+	if after := largest(); after >= before {
+		t.Errorf("Expected rebalancing to shrink the largest list, got %d -> %d", before, after)
+	}
+	if ix.Len() != 800 {
+		t.Errorf("Expected 800 vectors after rebalancing, got %d", ix.Len())
+	}
+}
+
+func TestIndexErrors(t *testing.T) {
+	ix := New(DefaultConfig)
+	if err := ix.Add("", []float32{1}); err == nil {
+		t.Error("Expected error for empty ID")
+	}
+	if err := ix.Add("a", nil); err == nil {
+		t.Error("Expected error for empty vector")
+	}
+	_ = ix.Add("a", []float32{1, 2})
+	if err := ix.Add("b", []float32{1, 2, 3}); err == nil {
+		t.Error("Expected error for dimension mismatch")
+	}
+	if _, err := ix.Search(nil, 1); err == nil {
+		t.Error("Expected error for empty query")
+	}
+	if _, err := ix.Search([]float32{1}, 1); err == nil {
+		t.Error("Expected error for query dimension mismatch")
+	}
+	if results, err := ix.Search([]float32{1, 2}, 0); err != nil || len(results) != 1 {
+		t.Errorf("Expected every vector for k=0, got %v, %v", results, err)
+	}
+}
+
+func BenchmarkIndexSearch(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	ix := New(Config{Lists: 64, NProbe: 8, Seed: 1})
+	for i, v := range randomVectors(r, 10000, 128) {
+		_ = ix.Add(fmt.Sprint(i), v)
+	}
+	if err := ix.Train(); err != nil {
+		b.Fatal(err)
+	}
+	query := randomVectors(r, 1, 128)[0]
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ix.Search(query, 10); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
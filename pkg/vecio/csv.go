@@ -0,0 +1,138 @@
+package vecio
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/ldaidone/goembedx/pkg/embedx"
+)
+
+// CSVReader reads records from comma-separated values.
+//
+// Without a header, the first column holds the ID and the remaining columns
+// the vector components. A header row is detected when its second field is
+// not a number; it may then name an "id" column and a "meta" column holding
+// a JSON object, and every other column is a vector component.
+type CSVReader struct {
+	r *csv.Reader
+	// index counts the data rows read so far.
+	index int
+	// started reports whether the header detection has run.
+	started bool
+	// pending holds the first row when it turned out not to be a header.
+	pending []string
+	// idCol and metaCol are the column indexes of the ID and metadata, or -1.
+	idCol, metaCol int
+}
+
+// NewCSVReader returns a CSVReader reading from r with the given field
+// delimiter. A zero comma defaults to ','.
+func NewCSVReader(r io.Reader, comma rune) *CSVReader {
+	cr := csv.NewReader(r)
+	if comma != 0 {
+		cr.Comma = comma
+	}
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+	return &CSVReader{r: cr, idCol: 0, metaCol: -1}
+}
+
+// Read implements Reader. Rows that are not valid records are reported as *RecordError.
+func (c *CSVReader) Read() (embedx.Record, error) {
+	if !c.started {
+		c.started = true
+		if err := c.readHeader(); err != nil {
+			return embedx.Record{}, err
+		}
+	}
+
+	row := c.pending
+	c.pending = nil
+	if row == nil {
+		var err error
+		row, err = c.r.Read()
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			index := c.index
+			c.index++
+			return embedx.Record{}, &RecordError{Index: index, Err: err}
+		}
+		if err != nil {
+			return embedx.Record{}, err
+		}
+	}
+
+	index := c.index
+	c.index++
+	return c.parseRow(index, row)
+}
+
+// readHeader reads the first row and either records its column layout or
+// keeps it as the first data row.
+func (c *CSVReader) readHeader() error {
+	row, err := c.r.Read()
+	if err != nil {
+		return err
+	}
+	if len(row) > 1 {
+		if _, err := strconv.ParseFloat(strings.TrimSpace(row[1]), 32); err == nil {
+			c.pending = append([]string(nil), row...)
+			return nil
+		}
+	}
+
+	c.idCol = -1
+	for i, name := range row {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "id":
+			c.idCol = i
+		case "meta", "metadata":
+			c.metaCol = i
+		}
+	}
+	if c.idCol < 0 {
+		return errors.New("vecio: CSV header has no id column")
+	}
+	return nil
+}
+
+// parseRow converts a data row to a record.
+func (c *CSVReader) parseRow(index int, row []string) (embedx.Record, error) {
+	if c.idCol >= len(row) {
+		return embedx.Record{}, &RecordError{Index: index, Err: errors.New("missing id column")}
+	}
+	rec := embedx.Record{ID: strings.TrimSpace(row[c.idCol])}
+	if rec.ID == "" {
+		return embedx.Record{}, &RecordError{Index: index, Err: errors.New("id is empty")}
+	}
+
+	rec.Vector = make([]float32, 0, len(row))
+	for i, field := range row {
+		switch i {
+		case c.idCol:
+			continue
+		case c.metaCol:
+			if strings.TrimSpace(field) == "" {
+				continue
+			}
+			if err := json.Unmarshal([]byte(field), &rec.Meta); err != nil {
+				return embedx.Record{}, &RecordError{Index: index, ID: rec.ID, Err: fmt.Errorf("invalid metadata: %w", err)}
+			}
+			continue
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(field), 32)
+		if err != nil {
+			return embedx.Record{}, &RecordError{Index: index, ID: rec.ID, Err: fmt.Errorf("invalid number in column %d: %q", i, field)}
+		}
+		rec.Vector = append(rec.Vector, float32(f))
+	}
+	if len(rec.Vector) == 0 {
+		return embedx.Record{}, &RecordError{Index: index, ID: rec.ID, Err: errors.New("vector is empty")}
+	}
+	return rec, nil
+}
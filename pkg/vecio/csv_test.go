@@ -0,0 +1,37 @@
+package vecio
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCSVReaderWithoutHeader(t *testing.T) {
+	input := "a,1,2\nb,3,x\n,5,6\nc, 7 ,8\n"
+
+	recs, errs := readAll(t, NewCSVReader(strings.NewReader(input), 0))
+	if len(recs) != 2 || recs[0].ID != "a" || recs[1].ID != "c" || recs[1].Vector[0] != 7 {
+		t.Fatalf("Unexpected records: %v", recs)
+	}
+	if len(errs) != 2 || errs[0].Index != 1 || errs[0].ID != "b" || errs[1].Index != 2 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+}
+
+func TestCSVReaderWithHeader(t *testing.T) {
+	input := "x,y,id,meta\n1,2,a,\"{\"\"tenant\"\":\"\"acme\"\"}\"\n3,4,b,\n5,6,c,{bad}\n"
+
+	recs, errs := readAll(t, NewCSVReader(strings.NewReader(input), 0))
+	if len(recs) != 2 || recs[0].ID != "a" || recs[0].Meta["tenant"] != "acme" || recs[1].Meta != nil {
+		t.Fatalf("Unexpected records: %v", recs)
+	}
+	if recs[0].Vector[0] != 1 || recs[0].Vector[1] != 2 {
+		t.Errorf("Unexpected vector: %v", recs[0].Vector)
+	}
+	if len(errs) != 1 || errs[0].ID != "c" {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+
+	if _, err := NewCSVReader(strings.NewReader("x,y\n1,2\n"), 0).Read(); err == nil {
+		t.Error("Expected error for header without id column, got nil")
+	}
+}
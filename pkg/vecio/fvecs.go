@@ -0,0 +1,89 @@
+package vecio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/ldaidone/goembedx/pkg/embedx"
+)
+
+// maxVecsDim bounds the dimension read from .fvecs/.ivecs headers so that a
+// corrupt file cannot trigger a huge allocation.
+const maxVecsDim = 1 << 20
+
+// VecsReader reads the .fvecs and .ivecs formats used by ANN benchmarks such
+// as SIFT1M. Every vector is stored as a little-endian int32 dimension followed
+// by that many little-endian float32 (.fvecs) or int32 (.ivecs) components.
+// IDs come from the IDSource.
+type VecsReader struct {
+	r     *bufio.Reader
+	ints  bool
+	index int
+	ids   IDSource
+	buf   []byte
+}
+
+// NewFvecsReader returns a VecsReader for the .fvecs stream r.
+func NewFvecsReader(r io.Reader, ids IDSource) *VecsReader {
+	return newVecsReader(r, false, ids)
+}
+
+// NewIvecsReader returns a VecsReader for the .ivecs stream r.
+// The int32 components are converted to float32.
+func NewIvecsReader(r io.Reader, ids IDSource) *VecsReader {
+	return newVecsReader(r, true, ids)
+}
+
+// newVecsReader returns a VecsReader for r.
+func newVecsReader(r io.Reader, ints bool, ids IDSource) *VecsReader {
+	if ids == nil {
+		ids = SequentialIDs("")
+	}
+	return &VecsReader{r: bufio.NewReader(r), ints: ints, ids: ids}
+}
+
+// Read implements Reader.
+func (v *VecsReader) Read() (embedx.Record, error) {
+	var hdr [4]byte
+	if _, err := io.ReadFull(v.r, hdr[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return embedx.Record{}, fmt.Errorf("vecio: truncated vector %d", v.index)
+		}
+		return embedx.Record{}, err
+	}
+	dim := int32(binary.LittleEndian.Uint32(hdr[:]))
+	if dim <= 0 || dim > maxVecsDim {
+		return embedx.Record{}, fmt.Errorf("vecio: invalid dimension %d for vector %d", dim, v.index)
+	}
+
+	if n := int(dim) * 4; cap(v.buf) < n {
+		v.buf = make([]byte, n)
+	} else {
+		v.buf = v.buf[:n]
+	}
+	if _, err := io.ReadFull(v.r, v.buf); err != nil {
+		return embedx.Record{}, fmt.Errorf("vecio: truncated vector %d", v.index)
+	}
+
+	index := v.index
+	v.index++
+	id, err := v.ids(index)
+	if err != nil {
+		return embedx.Record{}, err
+	}
+
+	vec := make([]float32, dim)
+	for i := range vec {
+		bits := binary.LittleEndian.Uint32(v.buf[i*4:])
+		if v.ints {
+			vec[i] = float32(int32(bits))
+		} else {
+			vec[i] = math.Float32frombits(bits)
+		}
+	}
+	return embedx.Record{ID: id, Vector: vec}, nil
+}
@@ -0,0 +1,52 @@
+package vecio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// fvecs encodes vectors in the .fvecs format.
+func fvecs(vecs ...[]float32) []byte {
+	var buf bytes.Buffer
+	for _, v := range vecs {
+		_ = binary.Write(&buf, binary.LittleEndian, int32(len(v)))
+		for _, x := range v {
+			_ = binary.Write(&buf, binary.LittleEndian, math.Float32bits(x))
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestFvecsReader(t *testing.T) {
+	data := fvecs([]float32{1, 2, 3}, []float32{-1.5, 0, 2})
+
+	recs, _ := readAll(t, NewFvecsReader(bytes.NewReader(data), SequentialIDs("v")))
+	if len(recs) != 2 || recs[0].ID != "v0" || recs[1].ID != "v1" || recs[1].Vector[0] != -1.5 {
+		t.Fatalf("Unexpected records: %v", recs)
+	}
+
+	r := NewFvecsReader(bytes.NewReader(data[:len(data)-2]), nil)
+	if _, err := r.Read(); err != nil {
+		t.Fatalf("first vector should still be readable: %v", err)
+	}
+	if _, err := r.Read(); err == nil {
+		t.Error("Expected error for truncated vector, got nil")
+	}
+	if _, err := NewFvecsReader(bytes.NewReader([]byte{0, 0, 0, 0}), nil).Read(); err == nil {
+		t.Error("Expected error for zero dimension, got nil")
+	}
+}
+
+func TestIvecsReader(t *testing.T) {
+	var buf bytes.Buffer
+	for _, v := range []int32{2, 7, -3} {
+		_ = binary.Write(&buf, binary.LittleEndian, v)
+	}
+
+	recs, _ := readAll(t, NewIvecsReader(&buf, nil))
+	if len(recs) != 1 || recs[0].ID != "0" || recs[0].Vector[0] != 7 || recs[0].Vector[1] != -3 {
+		t.Fatalf("Unexpected records: %v", recs)
+	}
+}
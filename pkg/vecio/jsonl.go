@@ -0,0 +1,93 @@
+package vecio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/ldaidone/goembedx/pkg/embedx"
+)
+
+// jsonRecord is the JSON Lines representation of a record.
+type jsonRecord struct {
+	ID     string         `json:"id"`
+	Vector []float32      `json:"vector"`
+	Norm   *float32       `json:"norm,omitempty"`
+	Meta   map[string]any `json:"meta,omitempty"`
+}
+
+// JSONLReader reads records from JSON Lines, one object per line:
+//
+//	{"id": "doc1", "vector": [0.1, 0.2], "meta": {"tenant": "acme"}}
+//
+// Blank lines are skipped and unknown fields are ignored.
+type JSONLReader struct {
+	r     *bufio.Reader
+	index int
+}
+
+// NewJSONLReader returns a JSONLReader reading from r.
+func NewJSONLReader(r io.Reader) *JSONLReader {
+	return &JSONLReader{r: bufio.NewReader(r)}
+}
+
+// Read implements Reader. Lines that are not valid records are reported as *RecordError.
+func (j *JSONLReader) Read() (embedx.Record, error) {
+	for {
+		line, err := j.r.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) == 0 {
+			if err != nil {
+				return embedx.Record{}, err
+			}
+			continue
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return embedx.Record{}, err
+		}
+
+		index := j.index
+		j.index++
+
+		var jr jsonRecord
+		if err := json.Unmarshal(line, &jr); err != nil {
+			return embedx.Record{}, &RecordError{Index: index, Err: err}
+		}
+		if jr.ID == "" {
+			return embedx.Record{}, &RecordError{Index: index, Err: errors.New("id is empty")}
+		}
+		if len(jr.Vector) == 0 {
+			return embedx.Record{}, &RecordError{Index: index, ID: jr.ID, Err: errors.New("vector is empty")}
+		}
+		rec := embedx.Record{ID: jr.ID, Vector: jr.Vector, Meta: jr.Meta}
+		if jr.Norm != nil {
+			rec.Norm = *jr.Norm
+		}
+		return rec, nil
+	}
+}
+
+// JSONLWriter writes records as JSON Lines, including their norms, in the
+// format read by JSONLReader.
+type JSONLWriter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+// NewJSONLWriter returns a JSONLWriter writing to w.
+func NewJSONLWriter(w io.Writer) *JSONLWriter {
+	bw := bufio.NewWriter(w)
+	return &JSONLWriter{w: bw, enc: json.NewEncoder(bw)}
+}
+
+// Write implements Writer.
+func (j *JSONLWriter) Write(rec embedx.Record) error {
+	norm := rec.Norm
+	return j.enc.Encode(jsonRecord{ID: rec.ID, Vector: rec.Vector, Norm: &norm, Meta: rec.Meta})
+}
+
+// Close implements Writer. It flushes buffered output.
+func (j *JSONLWriter) Close() error {
+	return j.w.Flush()
+}
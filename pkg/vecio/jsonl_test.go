@@ -0,0 +1,54 @@
+package vecio
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ldaidone/goembedx/pkg/embedx"
+)
+
+func TestJSONLReader(t *testing.T) {
+	input := `{"id":"a","vector":[1,2],"meta":{"n":1},"extra":true}
+
+{"id":"","vector":[1]}
+{"id":"b","vector":[]}
+{"id":"c","vector":[3,4]}`
+
+	recs, errs := readAll(t, NewJSONLReader(strings.NewReader(input)))
+	if len(recs) != 2 || recs[0].ID != "a" || recs[0].Meta["n"] != float64(1) || recs[1].ID != "c" {
+		t.Fatalf("Unexpected records: %v", recs)
+	}
+	if len(errs) != 2 || errs[0].Index != 1 || errs[1].Index != 2 || errs[1].ID != "b" {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+}
+
+func TestJSONLReaderLongLines(t *testing.T) {
+	vec := strings.TrimSuffix(strings.Repeat("0.123456789,", 20000), ",")
+	recs, errs := readAll(t, NewJSONLReader(strings.NewReader(`{"id":"big","vector":[`+vec+`]}`)))
+	if len(errs) != 0 || len(recs) != 1 || len(recs[0].Vector) != 20000 {
+		t.Fatalf("Expected one 20000-dim record, got %d records and %v", len(recs), errs)
+	}
+}
+
+func TestJSONLWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewJSONLWriter(&buf)
+	if err := w.Write(embedx.Record{ID: "a", Vector: []float32{3, 4}, Norm: 5, Meta: map[string]any{"n": 1}}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	want := `{"id":"a","vector":[3,4],"norm":5,"meta":{"n":1}}` + "\n"
+	if buf.String() != want {
+		t.Errorf("Expected %s, got %s", want, buf.String())
+	}
+
+	recs, _ := readAll(t, NewJSONLReader(&buf))
+	if len(recs) != 1 || recs[0].Norm != 5 {
+		t.Errorf("Expected norm to round-trip, got %v", recs)
+	}
+}
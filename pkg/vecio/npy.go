@@ -0,0 +1,465 @@
+package vecio
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ldaidone/goembedx/pkg/embedx"
+)
+
+const (
+	// npyMagic starts every .npy file.
+	npyMagic = "\x93NUMPY"
+
+	// maxNPYHeaderLen and maxNPYElemSize bound the header length and the
+	// element size read from .npy headers so that a corrupt file cannot
+	// trigger a huge allocation.
+	maxNPYHeaderLen = 1 << 20
+	maxNPYElemSize  = 1 << 16
+)
+
+// npyHeader describes the array stored in a .npy file.
+type npyHeader struct {
+	// order is the byte order of multi-byte elements.
+	order binary.ByteOrder
+	// kind is the NumPy type kind: 'f', 'i', 'u', 'U' or 'S'.
+	kind byte
+	// size is the element size in bytes, or the string length for 'U' and 'S'.
+	size int
+	// shape holds the array dimensions.
+	shape []int
+}
+
+var (
+	npyDescrRe   = regexp.MustCompile(`'descr'\s*:\s*'([<>|=])([a-zA-Z])(\d+)'`)
+	npyFortranRe = regexp.MustCompile(`'fortran_order'\s*:\s*(True|False)`)
+	npyShapeRe   = regexp.MustCompile(`'shape'\s*:\s*\(([^)]*)\)`)
+)
+
+// readNPYHeader reads and parses the header of a .npy stream, leaving r at
+// the first byte of the array data.
+func readNPYHeader(r io.Reader) (npyHeader, error) {
+	var h npyHeader
+
+	prefix := make([]byte, len(npyMagic)+2)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return h, fmt.Errorf("vecio: reading npy header: %w", err)
+	}
+	if string(prefix[:len(npyMagic)]) != npyMagic {
+		return h, errors.New("vecio: not an npy file")
+	}
+
+	var headerLen int
+	switch major := prefix[len(npyMagic)]; major {
+	case 1:
+		var n uint16
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			return h, fmt.Errorf("vecio: reading npy header: %w", err)
+		}
+		headerLen = int(n)
+	case 2, 3:
+		var n uint32
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			return h, fmt.Errorf("vecio: reading npy header: %w", err)
+		}
+		headerLen = int(n)
+	default:
+		return h, fmt.Errorf("vecio: unsupported npy version %d", major)
+	}
+	if headerLen > maxNPYHeaderLen {
+		return h, fmt.Errorf("vecio: npy header of %d bytes is too large", headerLen)
+	}
+
+	header := make([]byte, headerLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return h, fmt.Errorf("vecio: reading npy header: %w", err)
+	}
+
+	m := npyDescrRe.FindSubmatch(header)
+	if m == nil {
+		return h, fmt.Errorf("vecio: npy header has no supported descr: %s", header)
+	}
+	h.order = binary.LittleEndian
+	if m[1][0] == '>' {
+		h.order = binary.BigEndian
+	}
+	h.kind = m[2][0]
+	size, err := strconv.Atoi(string(m[3]))
+	if err != nil || size <= 0 || size > maxNPYElemSize {
+		return h, fmt.Errorf("vecio: invalid npy element size in descr %s", m[0])
+	}
+	h.size = size
+
+	if f := npyFortranRe.FindSubmatch(header); f != nil && string(f[1]) == "True" {
+		return h, errors.New("vecio: Fortran-ordered npy arrays are not supported")
+	}
+
+	s := npyShapeRe.FindSubmatch(header)
+	if s == nil {
+		return h, fmt.Errorf("vecio: npy header has no shape: %s", header)
+	}
+	for _, dim := range strings.Split(string(s[1]), ",") {
+		dim = strings.TrimSpace(dim)
+		if dim == "" {
+			continue
+		}
+		n, err := strconv.Atoi(dim)
+		if err != nil || n < 0 {
+			return h, fmt.Errorf("vecio: invalid npy shape: %s", s[1])
+		}
+		h.shape = append(h.shape, n)
+	}
+	return h, nil
+}
+
+// elemSize returns the number of bytes of one array element.
+func (h npyHeader) elemSize() int {
+	if h.kind == 'U' {
+		return 4 * h.size
+	}
+	return h.size
+}
+
+// decodeFloat converts one numeric element to float32.
+func (h npyHeader) decodeFloat(b []byte) (float32, error) {
+	switch {
+	case h.kind == 'f' && h.size == 4:
+		return math.Float32frombits(h.order.Uint32(b)), nil
+	case h.kind == 'f' && h.size == 8:
+		return float32(math.Float64frombits(h.order.Uint64(b))), nil
+	case h.kind == 'i' && h.size == 1:
+		return float32(int8(b[0])), nil
+	case h.kind == 'i' && h.size == 2:
+		return float32(int16(h.order.Uint16(b))), nil
+	case h.kind == 'i' && h.size == 4:
+		return float32(int32(h.order.Uint32(b))), nil
+	case h.kind == 'i' && h.size == 8:
+		return float32(int64(h.order.Uint64(b))), nil
+	case h.kind == 'u' && h.size == 1:
+		return float32(b[0]), nil
+	case h.kind == 'u' && h.size == 2:
+		return float32(h.order.Uint16(b)), nil
+	case h.kind == 'u' && h.size == 4:
+		return float32(h.order.Uint32(b)), nil
+	case h.kind == 'u' && h.size == 8:
+		return float32(h.order.Uint64(b)), nil
+	default:
+		return 0, fmt.Errorf("vecio: unsupported npy dtype %c%d", h.kind, h.size)
+	}
+}
+
+// decodeString converts one string or integer element to an ID.
+func (h npyHeader) decodeString(b []byte) (string, error) {
+	switch h.kind {
+	case 'U':
+		var sb strings.Builder
+		for i := 0; i+4 <= len(b); i += 4 {
+			r := rune(h.order.Uint32(b[i:]))
+			if r == 0 {
+				break
+			}
+			if !utf8.ValidRune(r) {
+				return "", fmt.Errorf("vecio: invalid code point %d in npy string", r)
+			}
+			sb.WriteRune(r)
+		}
+		return sb.String(), nil
+	case 'S':
+		return string(bytes.TrimRight(b, "\x00")), nil
+	case 'i', 'u':
+		f, err := h.decodeFloat(b)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatInt(int64(f), 10), nil
+	default:
+		return "", fmt.Errorf("vecio: unsupported npy ID dtype %c%d", h.kind, h.size)
+	}
+}
+
+// NPYReader reads the rows of a 2-D NumPy array as vectors. A 1-D array is
+// read as a single vector. Floating point, signed and unsigned integer dtypes
+// are converted to float32. IDs come from the IDSource.
+type NPYReader struct {
+	r     io.Reader
+	h     npyHeader
+	rows  int
+	dim   int
+	index int
+	ids   IDSource
+	buf   []byte
+}
+
+// NewNPYReader reads the header of the .npy stream r and returns a reader for its rows.
+func NewNPYReader(r io.Reader, ids IDSource) (*NPYReader, error) {
+	br := bufio.NewReader(r)
+	h, err := readNPYHeader(br)
+	if err != nil {
+		return nil, err
+	}
+	if h.kind != 'f' && h.kind != 'i' && h.kind != 'u' {
+		return nil, fmt.Errorf("vecio: unsupported npy vector dtype %c%d", h.kind, h.size)
+	}
+	if _, err := h.decodeFloat(make([]byte, h.size)); err != nil {
+		return nil, err
+	}
+
+	n := &NPYReader{r: br, h: h, ids: ids}
+	switch len(h.shape) {
+	case 1:
+		n.rows, n.dim = 1, h.shape[0]
+	case 2:
+		n.rows, n.dim = h.shape[0], h.shape[1]
+	default:
+		return nil, fmt.Errorf("vecio: npy array must be 1-D or 2-D, got shape %v", h.shape)
+	}
+	if n.dim > maxVecsDim {
+		return nil, fmt.Errorf("vecio: invalid npy dimension %d", n.dim)
+	}
+	if ids == nil {
+		n.ids = SequentialIDs("")
+	}
+	n.buf = make([]byte, n.dim*h.elemSize())
+	return n, nil
+}
+
+// Dim returns the dimension of the vectors in the array.
+func (n *NPYReader) Dim() int { return n.dim }
+
+// Len returns the number of vectors in the array.
+func (n *NPYReader) Len() int { return n.rows }
+
+// Read implements Reader.
+func (n *NPYReader) Read() (embedx.Record, error) {
+	if n.index >= n.rows {
+		return embedx.Record{}, io.EOF
+	}
+	if _, err := io.ReadFull(n.r, n.buf); err != nil {
+		return embedx.Record{}, fmt.Errorf("vecio: reading npy row %d: %w", n.index, io.ErrUnexpectedEOF)
+	}
+
+	index := n.index
+	n.index++
+	id, err := n.ids(index)
+	if err != nil {
+		return embedx.Record{}, err
+	}
+
+	vec := make([]float32, n.dim)
+	size := n.h.elemSize()
+	for i := range vec {
+		vec[i], _ = n.h.decodeFloat(n.buf[i*size:])
+	}
+	return embedx.Record{ID: id, Vector: vec}, nil
+}
+
+// npyIDs returns an IDSource reading successive elements of the 1-D .npy stream r.
+func npyIDs(r io.Reader) (IDSource, error) {
+	br := bufio.NewReader(r)
+	h, err := readNPYHeader(br)
+	if err != nil {
+		return nil, err
+	}
+	if len(h.shape) != 1 {
+		return nil, fmt.Errorf("vecio: npy ID array must be 1-D, got shape %v", h.shape)
+	}
+	buf := make([]byte, h.elemSize())
+	return func(index int) (string, error) {
+		if index >= h.shape[0] {
+			return "", fmt.Errorf("vecio: ID array has no ID for record %d", index)
+		}
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return "", fmt.Errorf("vecio: reading ID %d: %w", index, err)
+		}
+		return h.decodeString(buf)
+	}, nil
+}
+
+// sizedReaderAt is implemented by in-memory readers such as *bytes.Reader.
+type sizedReaderAt interface {
+	io.ReaderAt
+	Size() int64
+}
+
+// NewNPZReader returns a reader for the vector array of the .npz archive r.
+// key selects the array by name (without the .npy suffix); an empty key
+// selects the first array not named "ids". If the archive holds an "ids"
+// array of strings or integers, it supplies the record IDs and ids is ignored.
+func NewNPZReader(r io.Reader, key string, ids IDSource) (*NPYReader, error) {
+	var (
+		ra   io.ReaderAt
+		size int64
+	)
+	switch v := r.(type) {
+	case *os.File:
+		fi, err := v.Stat()
+		if err != nil {
+			return nil, err
+		}
+		ra, size = v, fi.Size()
+	case sizedReaderAt:
+		ra, size = v, v.Size()
+	default:
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		ra, size = bytes.NewReader(data), int64(len(data))
+	}
+
+	zr, err := zip.NewReader(ra, size)
+	if err != nil {
+		return nil, fmt.Errorf("vecio: reading npz archive: %w", err)
+	}
+
+	var vecFile, idFile *zip.File
+	for _, f := range zr.File {
+		name := strings.TrimSuffix(f.Name, ".npy")
+		switch {
+		case name == "ids":
+			idFile = f
+		case vecFile == nil && (key == "" || name == key):
+			vecFile = f
+		}
+	}
+	if vecFile == nil {
+		if key != "" {
+			return nil, fmt.Errorf("vecio: npz archive has no array %q", key)
+		}
+		return nil, errors.New("vecio: npz archive has no vector array")
+	}
+
+	if idFile != nil {
+		rc, err := idFile.Open()
+		if err != nil {
+			return nil, err
+		}
+		if ids, err = npyIDs(rc); err != nil {
+			return nil, err
+		}
+	}
+
+	rc, err := vecFile.Open()
+	if err != nil {
+		return nil, err
+	}
+	return NewNPYReader(rc, ids)
+}
+
+// npyPreambleLen is the length of the magic, version, header length and
+// header written by NPYWriter. It is fixed so that the shape can be rewritten
+// in place once the number of rows is known, and keeps the data 64-byte aligned.
+const npyPreambleLen = 128
+
+// writeNPYHeader writes a version 1.0 header for a float32 array of the given shape.
+func writeNPYHeader(w io.Writer, rows, dim int) error {
+	header := fmt.Sprintf("{'descr': '<f4', 'fortran_order': False, 'shape': (%d, %d), }", rows, dim)
+	pad := npyPreambleLen - len(npyMagic) - 4 - len(header) - 1
+	if pad < 0 {
+		return fmt.Errorf("vecio: npy shape (%d, %d) does not fit the header", rows, dim)
+	}
+	header += strings.Repeat(" ", pad) + "\n"
+
+	buf := make([]byte, 0, npyPreambleLen)
+	buf = append(buf, npyMagic...)
+	buf = append(buf, 1, 0)
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(len(header)))
+	buf = append(buf, header...)
+	_, err := w.Write(buf)
+	return err
+}
+
+// NPYWriter writes records as the rows of a 2-D float32 .npy array. The .npy
+// format has no room for IDs and metadata: IDs are written one per line to an
+// optional sidecar writer, in the format read by IDsFromLines, and metadata is
+// dropped. All vectors must have the same dimension.
+type NPYWriter struct {
+	w     io.WriteSeeker
+	bw    *bufio.Writer
+	ids   *bufio.Writer
+	start int64
+	rows  int
+	dim   int
+}
+
+// NewNPYWriter returns an NPYWriter writing the array to w and the IDs to ids,
+// which may be nil. w must be seekable because the header is rewritten with
+// the final row count on Close.
+func NewNPYWriter(w io.WriteSeeker, ids io.Writer) (*NPYWriter, error) {
+	start, err := w.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, fmt.Errorf("vecio: npy output must be seekable: %w", err)
+	}
+	n := &NPYWriter{w: w, bw: bufio.NewWriter(w), start: start}
+	if ids != nil {
+		n.ids = bufio.NewWriter(ids)
+	}
+	// Reserve the header; it is rewritten with the real shape on Close.
+	if err := writeNPYHeader(n.bw, 0, 0); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// Write implements Writer.
+func (n *NPYWriter) Write(rec embedx.Record) error {
+	if n.rows == 0 {
+		n.dim = len(rec.Vector)
+	}
+	if len(rec.Vector) != n.dim {
+		return fmt.Errorf("vecio: vector %s has dimension %d, want %d", rec.ID, len(rec.Vector), n.dim)
+	}
+	if n.ids != nil {
+		if strings.ContainsAny(rec.ID, "\r\n") {
+			return fmt.Errorf("vecio: ID %q contains a line break and cannot be written to the IDs file", rec.ID)
+		}
+		if _, err := n.ids.WriteString(rec.ID + "\n"); err != nil {
+			return err
+		}
+	}
+	var b [4]byte
+	for _, x := range rec.Vector {
+		binary.LittleEndian.PutUint32(b[:], math.Float32bits(x))
+		if _, err := n.bw.Write(b[:]); err != nil {
+			return err
+		}
+	}
+	n.rows++
+	return nil
+}
+
+// Close implements Writer. It flushes buffered output and rewrites the header
+// with the final shape, leaving w positioned at the end of the array.
+func (n *NPYWriter) Close() error {
+	if n.ids != nil {
+		if err := n.ids.Flush(); err != nil {
+			return err
+		}
+	}
+	if err := n.bw.Flush(); err != nil {
+		return err
+	}
+	end, err := n.w.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := n.w.Seek(n.start, io.SeekStart); err != nil {
+		return err
+	}
+	if err := writeNPYHeader(n.w, n.rows, n.dim); err != nil {
+		return err
+	}
+	_, err = n.w.Seek(end, io.SeekStart)
+	return err
+}
@@ -0,0 +1,233 @@
+package vecio
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ldaidone/goembedx/pkg/embedx"
+)
+
+// npy encodes a .npy version 1.0 file with the given dtype, shape and raw data.
+func npy(descr, shape string, data []byte) []byte {
+	header := fmt.Sprintf("{'descr': '%s', 'fortran_order': False, 'shape': %s, }", descr, shape)
+	// Pad the header so that the data is 64-byte aligned, as NumPy does.
+	pad := 64 - (10+len(header)+1)%64
+	header += strings.Repeat(" ", pad) + "\n"
+
+	var buf bytes.Buffer
+	buf.WriteString(npyMagic)
+	buf.Write([]byte{1, 0})
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(len(header)))
+	buf.WriteString(header)
+	buf.Write(data)
+	return buf.Bytes()
+}
+
+// float32Bytes encodes values as little-endian float32.
+func float32Bytes(values ...float32) []byte {
+	b := make([]byte, 4*len(values))
+	for i, v := range values {
+		binary.LittleEndian.PutUint32(b[i*4:], math.Float32bits(v))
+	}
+	return b
+}
+
+// unicodeBytes encodes strings as a NumPy '<U{width}' array.
+func unicodeBytes(width int, strs ...string) []byte {
+	b := make([]byte, 4*width*len(strs))
+	for i, s := range strs {
+		for j, r := range []rune(s) {
+			binary.LittleEndian.PutUint32(b[(i*width+j)*4:], uint32(r))
+		}
+	}
+	return b
+}
+
+func TestNPYReader(t *testing.T) {
+	data := npy("<f4", "(2, 3)", float32Bytes(1, 2, 3, 4, 5, 6))
+	r, err := NewNPYReader(bytes.NewReader(data), SequentialIDs("row"))
+	if err != nil {
+		t.Fatalf("NewNPYReader failed: %v", err)
+	}
+	if r.Len() != 2 || r.Dim() != 3 {
+		t.Errorf("Expected 2x3 array, got %dx%d", r.Len(), r.Dim())
+	}
+	recs, _ := readAll(t, r)
+	if len(recs) != 2 || recs[1].ID != "row1" || recs[1].Vector[2] != 6 {
+		t.Fatalf("Unexpected records: %v", recs)
+	}
+}
+
+func TestNPYReaderDtypes(t *testing.T) {
+	f8 := make([]byte, 16)
+	binary.LittleEndian.PutUint64(f8, math.Float64bits(0.5))
+	binary.LittleEndian.PutUint64(f8[8:], math.Float64bits(-2))
+	be := make([]byte, 8)
+	binary.BigEndian.PutUint32(be, math.Float32bits(3))
+	binary.BigEndian.PutUint32(be[4:], math.Float32bits(4))
+
+	tests := []struct {
+		name, descr, shape string
+		data               []byte
+		want               []float32
+	}{
+		{"float64", "<f8", "(2,)", f8, []float32{0.5, -2}},
+		{"big endian", ">f4", "(1, 2)", be, []float32{3, 4}},
+		{"int8", "|i1", "(1, 2)", []byte{0xff, 5}, []float32{-1, 5}},
+		{"uint8", "|u1", "(1, 2)", []byte{0xff, 5}, []float32{255, 5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewNPYReader(bytes.NewReader(npy(tt.descr, tt.shape, tt.data)), nil)
+			if err != nil {
+				t.Fatalf("NewNPYReader failed: %v", err)
+			}
+			recs, _ := readAll(t, r)
+			if len(recs) != 1 || fmt.Sprint(recs[0].Vector) != fmt.Sprint(tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, recs)
+			}
+		})
+	}
+}
+
+func TestNPYReaderErrors(t *testing.T) {
+	tests := map[string][]byte{
+		"bad magic":     []byte("not an npy file at all"),
+		"fortran order": []byte(strings.Replace(string(npy("<f4", "(1, 1)", float32Bytes(1))), "False", "True", 1)),
+		"3-D":           npy("<f4", "(1, 1, 1)", float32Bytes(1)),
+		"complex":       npy("<c8", "(1, 1)", make([]byte, 8)),
+		"strings":       npy("<U3", "(1, 1)", make([]byte, 12)),
+		// Malformed headers must fail instead of allocating or panicking.
+		"negative dim":      npy("<f4", "(2, -3)", nil),
+		"negative rows":     npy("<f4", "(-2, 3)", nil),
+		"oversized dim":     npy("<f4", fmt.Sprintf("(1, %d)", maxVecsDim+1), nil),
+		"overflowing dim":   npy("<f4", "(1, 99999999999999999999)", nil),
+		"zero element size": npy("<f0", "(1, 1)", nil),
+		"overflowing size":  npy("<f99999999999999999999", "(1, 1)", nil),
+		"oversized size":    npy("<U99999999", "(1,)", nil),
+		"oversized header":  oversizedHeader(),
+	}
+	for name, data := range tests {
+		if _, err := NewNPYReader(bytes.NewReader(data), nil); err == nil {
+			t.Errorf("%s: expected error, got nil", name)
+		}
+	}
+
+	r, err := NewNPYReader(bytes.NewReader(npy("<f4", "(2, 2)", float32Bytes(1, 2, 3))), nil)
+	if err != nil {
+		t.Fatalf("NewNPYReader failed: %v", err)
+	}
+	_, _ = r.Read()
+	if _, err := r.Read(); err == nil {
+		t.Error("Expected error for truncated data, got nil")
+	}
+}
+
+// oversizedHeader returns a version 2.0 .npy prefix announcing a 4 GiB header.
+func oversizedHeader() []byte {
+	b := append([]byte(npyMagic), 2, 0)
+	return binary.LittleEndian.AppendUint32(b, math.MaxUint32)
+}
+
+func TestNPZReader(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range map[string][]byte{
+		"ids.npy":        npy("<U5", "(2,)", unicodeBytes(5, "alpha", "beta")),
+		"embeddings.npy": npy("<f4", "(2, 2)", float32Bytes(1, 2, 3, 4)),
+	} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("zip Create failed: %v", err)
+		}
+		_, _ = w.Write(data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zip Close failed: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "vectors.npz")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer f.Close()
+
+	r, err := NewNPZReader(f, "", nil)
+	if err != nil {
+		t.Fatalf("NewNPZReader failed: %v", err)
+	}
+	recs, _ := readAll(t, r)
+	if len(recs) != 2 || recs[0].ID != "alpha" || recs[1].ID != "beta" || recs[1].Vector[1] != 4 {
+		t.Fatalf("Unexpected records: %v", recs)
+	}
+
+	// Non-seekable input is buffered in memory.
+	r, err = NewNPZReader(bytes.NewBufferString(buf.String()), "embeddings", nil)
+	if err != nil {
+		t.Fatalf("NewNPZReader from buffer failed: %v", err)
+	}
+	if recs, _ := readAll(t, r); len(recs) != 2 {
+		t.Errorf("Expected 2 records, got %v", recs)
+	}
+
+	if _, err := NewNPZReader(bytes.NewReader(buf.Bytes()), "missing", nil); err == nil {
+		t.Error("Expected error for missing array, got nil")
+	}
+}
+
+func TestNPYWriter(t *testing.T) {
+	dir := t.TempDir()
+	f, err := os.Create(filepath.Join(dir, "vectors.npy"))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer f.Close()
+	var ids bytes.Buffer
+
+	w, err := NewNPYWriter(f, &ids)
+	if err != nil {
+		t.Fatalf("NewNPYWriter failed: %v", err)
+	}
+	for i, id := range []string{"a", "b", "c"} {
+		if err := w.Write(embedx.Record{ID: id, Vector: []float32{float32(i), 1}}); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := w.Write(embedx.Record{ID: "d", Vector: []float32{1, 2, 3}}); err == nil {
+		t.Error("Expected error for dimension mismatch, got nil")
+	}
+	if err := w.Write(embedx.Record{ID: "e\nf", Vector: []float32{1, 2}}); err == nil {
+		t.Error("Expected error for ID with a line break, got nil")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatalf("Seek failed: %v", err)
+	}
+	r, err := NewNPYReader(f, IDsFromLines(&ids))
+	if err != nil {
+		t.Fatalf("NewNPYReader failed: %v", err)
+	}
+	if r.Len() != 3 || r.Dim() != 2 {
+		t.Errorf("Expected 3x2 array, got %dx%d", r.Len(), r.Dim())
+	}
+	recs, _ := readAll(t, r)
+	if len(recs) != 3 || recs[2].ID != "c" || recs[2].Vector[0] != 2 {
+		t.Errorf("Unexpected records: %v", recs)
+	}
+}
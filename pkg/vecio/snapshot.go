@@ -0,0 +1,323 @@
+package vecio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+
+	"github.com/ldaidone/goembedx/pkg/embedx"
+	"github.com/ldaidone/goembedx/vector"
+)
+
+// Snapshot layout
+//
+// A snapshot starts with the 8-byte magic "GOEMBEDX" and a little-endian
+// uint16 format version, followed by a sequence of frames:
+//
+//	kind     byte    'H' (header), 'R' (record) or 'E' (end)
+//	length   uvarint payload length
+//	payload  [length]byte
+//	checksum uint32  little-endian CRC-32C of kind and payload
+//
+// The first frame is the header: the metric name and the vector dimension.
+// Each record frame holds the ID, the vector components as little-endian
+// float32, the norm and the metadata as JSON. The end frame holds the number
+// of records, so that a truncated snapshot is detected.
+const (
+	snapshotMagic   = "GOEMBEDX"
+	snapshotVersion = 1
+
+	frameHeader = 'H'
+	frameRecord = 'R'
+	frameEnd    = 'E'
+
+	// maxFrameLen bounds the payload length read from a frame so that a
+	// corrupt snapshot cannot trigger a huge allocation.
+	maxFrameLen = 1 << 28
+)
+
+// ErrCorruptSnapshot is returned when a snapshot fails validation.
+var ErrCorruptSnapshot = errors.New("vecio: corrupt snapshot")
+
+// crcTable is the Castagnoli table used for frame checksums.
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// SnapshotHeader describes the store a snapshot was taken from.
+type SnapshotHeader struct {
+	// Metric is the metric of the source store.
+	Metric vector.Metric
+	// Dim is the dimension of the stored vectors, or 0 if it is not known.
+	Dim int
+}
+
+// SnapshotWriter writes records in the versioned, checksummed snapshot format.
+// Snapshots keep IDs, vectors, norms and metadata, and restore into any store
+// through SnapshotReader and Import.
+type SnapshotWriter struct {
+	w       *bufio.Writer
+	buf     []byte
+	records uint64
+}
+
+// NewSnapshotWriter writes the snapshot preamble and header to w and returns
+// a writer for the records.
+func NewSnapshotWriter(w io.Writer, h SnapshotHeader) (*SnapshotWriter, error) {
+	s := &SnapshotWriter{w: bufio.NewWriter(w)}
+	if _, err := s.w.WriteString(snapshotMagic); err != nil {
+		return nil, err
+	}
+	if err := binary.Write(s.w, binary.LittleEndian, uint16(snapshotVersion)); err != nil {
+		return nil, err
+	}
+
+	payload := binary.AppendUvarint(nil, uint64(len(h.Metric.String())))
+	payload = append(payload, h.Metric.String()...)
+	payload = binary.AppendUvarint(payload, uint64(h.Dim))
+	if err := s.writeFrame(frameHeader, payload); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Write implements Writer.
+func (s *SnapshotWriter) Write(rec embedx.Record) error {
+	var meta []byte
+	if len(rec.Meta) > 0 {
+		var err error
+		if meta, err = json.Marshal(rec.Meta); err != nil {
+			return fmt.Errorf("vecio: encoding metadata of %s: %w", rec.ID, err)
+		}
+	}
+
+	p := s.buf[:0]
+	p = binary.AppendUvarint(p, uint64(len(rec.ID)))
+	p = append(p, rec.ID...)
+	p = binary.AppendUvarint(p, uint64(len(rec.Vector)))
+	for _, x := range rec.Vector {
+		p = binary.LittleEndian.AppendUint32(p, math.Float32bits(x))
+	}
+	p = binary.LittleEndian.AppendUint32(p, math.Float32bits(rec.Norm))
+	p = binary.AppendUvarint(p, uint64(len(meta)))
+	p = append(p, meta...)
+	s.buf = p
+
+	if len(p) > maxFrameLen {
+		return fmt.Errorf("vecio: record %s is too large for a snapshot", rec.ID)
+	}
+	if err := s.writeFrame(frameRecord, p); err != nil {
+		return err
+	}
+	s.records++
+	return nil
+}
+
+// Close implements Writer. It writes the end frame and flushes buffered output.
+// A snapshot that is not closed is reported as truncated when read.
+func (s *SnapshotWriter) Close() error {
+	if err := s.writeFrame(frameEnd, binary.AppendUvarint(nil, s.records)); err != nil {
+		return err
+	}
+	return s.w.Flush()
+}
+
+// writeFrame writes one frame with its checksum.
+func (s *SnapshotWriter) writeFrame(kind byte, payload []byte) error {
+	var hdr [1 + binary.MaxVarintLen64]byte
+	hdr[0] = kind
+	n := binary.PutUvarint(hdr[1:], uint64(len(payload)))
+	if _, err := s.w.Write(hdr[:1+n]); err != nil {
+		return err
+	}
+	if _, err := s.w.Write(payload); err != nil {
+		return err
+	}
+	crc := crc32.Update(crc32.Update(0, crcTable, hdr[:1]), crcTable, payload)
+	return binary.Write(s.w, binary.LittleEndian, crc)
+}
+
+// SnapshotReader reads records written by SnapshotWriter. Every frame is
+// verified against its checksum before it is returned; corruption and
+// truncation are fatal errors wrapping ErrCorruptSnapshot.
+type SnapshotReader struct {
+	r       *bufio.Reader
+	header  SnapshotHeader
+	records uint64
+	done    bool
+	buf     []byte
+}
+
+// NewSnapshotReader reads the preamble and header of the snapshot r.
+func NewSnapshotReader(r io.Reader) (*SnapshotReader, error) {
+	s := &SnapshotReader{r: bufio.NewReader(r)}
+
+	prefix := make([]byte, len(snapshotMagic)+2)
+	if _, err := io.ReadFull(s.r, prefix); err != nil {
+		return nil, fmt.Errorf("%w: reading preamble: %v", ErrCorruptSnapshot, err)
+	}
+	if string(prefix[:len(snapshotMagic)]) != snapshotMagic {
+		return nil, errors.New("vecio: not a goembedx snapshot")
+	}
+	if v := binary.LittleEndian.Uint16(prefix[len(snapshotMagic):]); v != snapshotVersion {
+		return nil, fmt.Errorf("vecio: unsupported snapshot version %d", v)
+	}
+
+	kind, payload, err := s.readFrame()
+	if err != nil {
+		return nil, err
+	}
+	if kind != frameHeader {
+		return nil, fmt.Errorf("%w: missing header", ErrCorruptSnapshot)
+	}
+	d := decoder{b: payload}
+	name := d.bytes()
+	dim := d.uvarint()
+	if d.err != nil {
+		return nil, d.err
+	}
+	metric, err := vector.ParseMetric(string(name))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorruptSnapshot, err)
+	}
+	s.header = SnapshotHeader{Metric: metric, Dim: int(dim)}
+	return s, nil
+}
+
+// Header returns the header of the snapshot.
+func (s *SnapshotReader) Header() SnapshotHeader { return s.header }
+
+// Read implements Reader.
+func (s *SnapshotReader) Read() (embedx.Record, error) {
+	if s.done {
+		return embedx.Record{}, io.EOF
+	}
+	kind, payload, err := s.readFrame()
+	if err != nil {
+		return embedx.Record{}, err
+	}
+
+	d := decoder{b: payload}
+	switch kind {
+	case frameRecord:
+	case frameEnd:
+		n := d.uvarint()
+		if d.err != nil {
+			return embedx.Record{}, d.err
+		}
+		if n != s.records {
+			return embedx.Record{}, fmt.Errorf("%w: end frame counts %d records, read %d", ErrCorruptSnapshot, n, s.records)
+		}
+		s.done = true
+		return embedx.Record{}, io.EOF
+	default:
+		return embedx.Record{}, fmt.Errorf("%w: unknown frame kind %q", ErrCorruptSnapshot, kind)
+	}
+
+	var rec embedx.Record
+	rec.ID = string(d.bytes())
+	n := d.uvarint()
+	if n > uint64(len(d.b))/4 {
+		return embedx.Record{}, fmt.Errorf("%w: record %s overflows its frame", ErrCorruptSnapshot, rec.ID)
+	}
+	rec.Vector = make([]float32, n)
+	for i := range rec.Vector {
+		rec.Vector[i] = math.Float32frombits(d.uint32())
+	}
+	rec.Norm = math.Float32frombits(d.uint32())
+	if meta := d.bytes(); len(meta) > 0 && d.err == nil {
+		if err := json.Unmarshal(meta, &rec.Meta); err != nil {
+			return embedx.Record{}, fmt.Errorf("%w: metadata of %s: %v", ErrCorruptSnapshot, rec.ID, err)
+		}
+	}
+	if d.err != nil {
+		return embedx.Record{}, d.err
+	}
+	s.records++
+	return rec, nil
+}
+
+// readFrame reads the next frame and verifies its checksum.
+func (s *SnapshotReader) readFrame() (byte, []byte, error) {
+	kind, err := s.r.ReadByte()
+	if err != nil {
+		return 0, nil, truncated(err)
+	}
+	n, err := binary.ReadUvarint(s.r)
+	if err != nil {
+		return 0, nil, truncated(err)
+	}
+	if n > maxFrameLen {
+		return 0, nil, fmt.Errorf("%w: frame of %d bytes", ErrCorruptSnapshot, n)
+	}
+	if uint64(cap(s.buf)) < n {
+		s.buf = make([]byte, n)
+	}
+	payload := s.buf[:n]
+	if _, err := io.ReadFull(s.r, payload); err != nil {
+		return 0, nil, truncated(err)
+	}
+	var crc uint32
+	if err := binary.Read(s.r, binary.LittleEndian, &crc); err != nil {
+		return 0, nil, truncated(err)
+	}
+	if crc32.Update(crc32.Update(0, crcTable, []byte{kind}), crcTable, payload) != crc {
+		return 0, nil, fmt.Errorf("%w: checksum mismatch after record %d", ErrCorruptSnapshot, s.records)
+	}
+	return kind, payload, nil
+}
+
+// truncated converts an unexpected end of input into a corruption error.
+func truncated(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: truncated", ErrCorruptSnapshot)
+	}
+	return err
+}
+
+// decoder reads the fields of a frame payload, recording the first error.
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) fail() {
+	if d.err == nil {
+		d.err = fmt.Errorf("%w: malformed frame", ErrCorruptSnapshot)
+	}
+	d.b = nil
+}
+
+func (d *decoder) uvarint() uint64 {
+	v, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.fail()
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) uint32() uint32 {
+	if len(d.b) < 4 {
+		d.fail()
+		return 0
+	}
+	v := binary.LittleEndian.Uint32(d.b)
+	d.b = d.b[4:]
+	return v
+}
+
+func (d *decoder) bytes() []byte {
+	n := d.uvarint()
+	if n > uint64(len(d.b)) {
+		d.fail()
+		return nil
+	}
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v
+}
@@ -0,0 +1,123 @@
+package vecio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/ldaidone/goembedx/pkg/embedx"
+	"github.com/ldaidone/goembedx/vector"
+)
+
+// snapshot encodes recs as a closed snapshot.
+func snapshot(t *testing.T, h SnapshotHeader, recs ...embedx.Record) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewSnapshotWriter(&buf, h)
+	if err != nil {
+		t.Fatalf("NewSnapshotWriter failed: %v", err)
+	}
+	for _, rec := range recs {
+		if err := w.Write(rec); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	h := SnapshotHeader{Metric: vector.MetricEuclidean, Dim: 2}
+	data := snapshot(t, h,
+		embedx.Record{ID: "a", Vector: []float32{3, 4}, Norm: 5, Meta: map[string]any{"tenant": "acme", "n": 2}},
+		embedx.Record{ID: "b", Vector: []float32{0, -1}, Norm: 1},
+	)
+
+	r, err := NewSnapshotReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("NewSnapshotReader failed: %v", err)
+	}
+	if r.Header() != h {
+		t.Errorf("Expected header %+v, got %+v", h, r.Header())
+	}
+	recs, _ := readAll(t, r)
+	if len(recs) != 2 {
+		t.Fatalf("Expected 2 records, got %v", recs)
+	}
+	if recs[0].ID != "a" || recs[0].Norm != 5 || recs[0].Vector[1] != 4 || recs[0].Meta["tenant"] != "acme" || recs[0].Meta["n"] != float64(2) {
+		t.Errorf("Unexpected first record: %+v", recs[0])
+	}
+	if recs[1].ID != "b" || recs[1].Meta != nil || recs[1].Vector[1] != -1 {
+		t.Errorf("Unexpected second record: %+v", recs[1])
+	}
+	if _, err := r.Read(); !errors.Is(err, io.EOF) {
+		t.Errorf("Expected io.EOF after the end frame, got %v", err)
+	}
+}
+
+func TestSnapshotCorruption(t *testing.T) {
+	rec := embedx.Record{ID: "a", Vector: []float32{1, 2, 3}, Norm: 1}
+	data := snapshot(t, SnapshotHeader{}, rec, rec)
+
+	// readErr returns the first error other than a record from reading data.
+	readErr := func(data []byte) error {
+		r, err := NewSnapshotReader(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		for {
+			if _, err := r.Read(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := readErr(data); !errors.Is(err, io.EOF) {
+		t.Fatalf("Expected intact snapshot to read to io.EOF, got %v", err)
+	}
+
+	flipped := append([]byte(nil), data...)
+	flipped[len(flipped)/2] ^= 0x40
+	if err := readErr(flipped); !errors.Is(err, ErrCorruptSnapshot) {
+		t.Errorf("Expected ErrCorruptSnapshot for a flipped bit, got %v", err)
+	}
+
+	for _, cut := range []int{1, 8, len(data) / 2} {
+		if err := readErr(data[:len(data)-cut]); !errors.Is(err, ErrCorruptSnapshot) {
+			t.Errorf("Expected ErrCorruptSnapshot when truncated by %d bytes, got %v", cut, err)
+		}
+	}
+
+	if err := readErr([]byte("NOTASNAPSHOT")); err == nil || errors.Is(err, io.EOF) {
+		t.Errorf("Expected error for wrong magic, got %v", err)
+	}
+	bumped := append([]byte(nil), data...)
+	bumped[len(snapshotMagic)] = 99
+	if err := readErr(bumped); err == nil || errors.Is(err, io.EOF) {
+		t.Errorf("Expected error for unsupported version, got %v", err)
+	}
+}
+
+func TestSnapshotUnclosed(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewSnapshotWriter(&buf, SnapshotHeader{})
+	if err != nil {
+		t.Fatalf("NewSnapshotWriter failed: %v", err)
+	}
+	_ = w.Write(embedx.Record{ID: "a", Vector: []float32{1}})
+	_ = w.w.Flush() // simulate an interrupted export
+
+	r, err := NewSnapshotReader(&buf)
+	if err != nil {
+		t.Fatalf("NewSnapshotReader failed: %v", err)
+	}
+	if _, err := r.Read(); err != nil {
+		t.Fatalf("Expected the written record, got %v", err)
+	}
+	if _, err := r.Read(); !errors.Is(err, ErrCorruptSnapshot) {
+		t.Errorf("Expected ErrCorruptSnapshot for a snapshot without end frame, got %v", err)
+	}
+}
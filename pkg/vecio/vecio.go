@@ -0,0 +1,346 @@
+// Package vecio streams vectors between files and embedx stores.
+//
+// Readers decode JSON Lines, CSV, NumPy .npy/.npz, the .fvecs/.ivecs
+// benchmark formats and goembedx snapshots one record at a time, so files
+// larger than memory can be imported. Import writes the records of a Reader to
+// a store in batches, reporting progress and per-record failures instead of
+// aborting on the first bad record.
+//
+// Writers encode records as JSON Lines, .npy with a sidecar IDs file, or
+// snapshots. Export streams every record of an embedx.Scanner to a Writer.
+package vecio
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ldaidone/goembedx/pkg/embedx"
+)
+
+// Format identifies a vector file format.
+type Format int
+
+const (
+	// FormatJSONL is JSON Lines with one {"id", "vector", "meta"} object per line.
+	FormatJSONL Format = iota + 1
+	// FormatCSV is comma-separated values with an ID column followed by vector components.
+	FormatCSV
+	// FormatNPY is a NumPy .npy file holding a 2-D array with one vector per row.
+	FormatNPY
+	// FormatNPZ is a NumPy .npz archive holding a vector array and an optional "ids" array.
+	FormatNPZ
+	// FormatFvecs is the .fvecs benchmark format of little-endian float32 vectors.
+	FormatFvecs
+	// FormatIvecs is the .ivecs benchmark format of little-endian int32 vectors.
+	FormatIvecs
+	// FormatSnapshot is the versioned, checksummed goembedx snapshot format.
+	FormatSnapshot
+)
+
+// formatNames maps each format to its canonical name.
+var formatNames = map[Format]string{
+	FormatJSONL:    "jsonl",
+	FormatCSV:      "csv",
+	FormatNPY:      "npy",
+	FormatNPZ:      "npz",
+	FormatFvecs:    "fvecs",
+	FormatIvecs:    "ivecs",
+	FormatSnapshot: "snapshot",
+}
+
+// String returns the canonical name of the format, as accepted by ParseFormat.
+func (f Format) String() string {
+	if name, ok := formatNames[f]; ok {
+		return name
+	}
+	return fmt.Sprintf("Format(%d)", int(f))
+}
+
+// ParseFormat returns the format with the given name. Matching is case-insensitive
+// and accepts "json" and "ndjson" as aliases of "jsonl", and "snap" as an
+// alias of "snapshot".
+func ParseFormat(name string) (Format, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	switch name {
+	case "json", "ndjson":
+		return FormatJSONL, nil
+	case "snap":
+		return FormatSnapshot, nil
+	}
+	for f, n := range formatNames {
+		if n == name {
+			return f, nil
+		}
+	}
+	return 0, fmt.Errorf("vecio: unknown format %q", name)
+}
+
+// FormatFromPath infers the format of a file from its extension.
+func FormatFromPath(path string) (Format, error) {
+	ext := strings.TrimPrefix(filepath.Ext(path), ".")
+	if ext == "" {
+		return 0, fmt.Errorf("vecio: cannot infer format of %s without an extension", path)
+	}
+	return ParseFormat(ext)
+}
+
+// Reader streams records from a vector file.
+type Reader interface {
+	// Read returns the next record, or io.EOF when there are no more records.
+	// A *RecordError reports a malformed record that was skipped; reading can
+	// continue after it. Any other error is fatal.
+	Read() (embedx.Record, error)
+}
+
+// RecordError describes a single record that could not be read or stored.
+type RecordError struct {
+	// Index is the position of the record in the input, starting at 0.
+	Index int
+	// ID is the identifier of the record, if it is known.
+	ID string
+	// Err is the underlying error.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *RecordError) Error() string {
+	if e.ID != "" {
+		return fmt.Sprintf("record %d (%s): %v", e.Index, e.ID, e.Err)
+	}
+	return fmt.Sprintf("record %d: %v", e.Index, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *RecordError) Unwrap() error { return e.Err }
+
+// ReadOptions configures how records are decoded by NewReader.
+type ReadOptions struct {
+	// IDPrefix is prepended to the record index to generate IDs for formats
+	// that do not store IDs (.npy, .npz without "ids", .fvecs, .ivecs).
+	IDPrefix string
+	// IDs, if set, supplies one ID per line for formats that do not store IDs,
+	// such as the sidecar IDs file written next to an exported .npy file.
+	IDs io.Reader
+	// Comma is the CSV field delimiter. Defaults to ','.
+	Comma rune
+	// NPZKey selects the vector array of an .npz archive. Defaults to the first
+	// array that is not named "ids".
+	NPZKey string
+}
+
+// NewReader returns a Reader decoding r in the given format.
+// .npz archives need random access: if r is not an io.ReaderAt with a known
+// size (such as an *os.File), it is read into memory first.
+func NewReader(format Format, r io.Reader, opts ReadOptions) (Reader, error) {
+	ids := newIDSource(opts)
+	switch format {
+	case FormatJSONL:
+		return NewJSONLReader(r), nil
+	case FormatCSV:
+		return NewCSVReader(r, opts.Comma), nil
+	case FormatNPY:
+		return NewNPYReader(r, ids)
+	case FormatNPZ:
+		return NewNPZReader(r, opts.NPZKey, ids)
+	case FormatFvecs:
+		return NewFvecsReader(r, ids), nil
+	case FormatIvecs:
+		return NewIvecsReader(r, ids), nil
+	case FormatSnapshot:
+		return NewSnapshotReader(r)
+	default:
+		return nil, fmt.Errorf("vecio: unsupported format %s", format)
+	}
+}
+
+// IDSource generates the IDs of records in formats that do not store them.
+type IDSource func(index int) (string, error)
+
+// newIDSource returns the IDSource described by opts.
+func newIDSource(opts ReadOptions) IDSource {
+	if opts.IDs != nil {
+		return IDsFromLines(opts.IDs)
+	}
+	return SequentialIDs(opts.IDPrefix)
+}
+
+// SequentialIDs returns an IDSource producing prefix followed by the record index.
+func SequentialIDs(prefix string) IDSource {
+	return func(index int) (string, error) {
+		return prefix + strconv.Itoa(index), nil
+	}
+}
+
+// IDsFromLines returns an IDSource reading one ID per line from r.
+// It must be called with consecutive indexes starting at 0.
+func IDsFromLines(r io.Reader) IDSource {
+	sc := bufio.NewScanner(r)
+	return func(index int) (string, error) {
+		if !sc.Scan() {
+			if err := sc.Err(); err != nil {
+				return "", err
+			}
+			return "", fmt.Errorf("vecio: IDs file has no ID for record %d", index)
+		}
+		return strings.TrimRight(sc.Text(), "\r"), nil
+	}
+}
+
+// DefaultBatchSize is the number of records Import writes per batch by default.
+const DefaultBatchSize = 1000
+
+// ImportOptions configures Import.
+type ImportOptions struct {
+	// BatchSize is the number of records buffered before they are written.
+	// Defaults to DefaultBatchSize.
+	BatchSize int
+	// Mode controls how records whose ID is already stored are treated.
+	Mode embedx.UpsertMode
+	// Progress, if set, is called after every batch with the running totals.
+	Progress func(ImportStats)
+	// OnError, if set, is called for every record that could not be read or stored.
+	OnError func(*RecordError)
+}
+
+// ImportStats summarizes an import.
+type ImportStats struct {
+	// Read is the number of records read from the input, including malformed ones.
+	Read int
+	// Imported is the number of records written to the store.
+	Imported int
+	// Failed is the number of records that could not be read or stored.
+	Failed int
+}
+
+// Import reads every record from r and writes it to store in batches with
+// Store.AddBatch. Malformed records and records the store rejects are
+// reported to opts.OnError and counted in ImportStats.Failed; they do not stop
+// the import. Import returns early only on a fatal read error or when the
+// store fails to write a batch.
+func Import(store embedx.Store, r Reader, opts ImportOptions) (ImportStats, error) {
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	var stats ImportStats
+	fail := func(e *RecordError) {
+		stats.Failed++
+		if opts.OnError != nil {
+			opts.OnError(e)
+		}
+	}
+
+	batch := make([]embedx.Record, 0, batchSize)
+	indexes := make([]int, 0, batchSize)
+	flush := func() error {
+		if len(batch) > 0 {
+			err := store.AddBatch(batch, embedx.BatchOptions{Mode: opts.Mode})
+			var be *embedx.BatchError
+			switch {
+			case errors.As(err, &be):
+				for _, item := range be.Items {
+					fail(&RecordError{Index: indexes[item.Index], ID: item.ID, Err: item.Err})
+				}
+				stats.Imported += be.Written
+			case err != nil:
+				return err
+			default:
+				stats.Imported += len(batch)
+			}
+		}
+		batch = batch[:0]
+		indexes = indexes[:0]
+		if opts.Progress != nil {
+			opts.Progress(stats)
+		}
+		return nil
+	}
+
+	for {
+		rec, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var recErr *RecordError
+		if errors.As(err, &recErr) {
+			stats.Read++
+			fail(recErr)
+			continue
+		}
+		if err != nil {
+			return stats, errors.Join(err, flush())
+		}
+
+		batch = append(batch, rec)
+		indexes = append(indexes, stats.Read)
+		stats.Read++
+		if len(batch) == batchSize {
+			if err := flush(); err != nil {
+				return stats, err
+			}
+		}
+	}
+	return stats, flush()
+}
+
+// Writer encodes records to a vector file.
+type Writer interface {
+	// Write encodes one record.
+	Write(rec embedx.Record) error
+	// Close finishes the file and flushes buffered output. It does not close
+	// the underlying io.Writer.
+	Close() error
+}
+
+// WriteOptions configures how records are encoded by NewWriter.
+type WriteOptions struct {
+	// IDs receives one ID per line for formats that do not store IDs (.npy).
+	// If nil, IDs are not written.
+	IDs io.Writer
+	// Snapshot is written to the header of snapshots.
+	Snapshot SnapshotHeader
+}
+
+// NewWriter returns a Writer encoding records to w in the given format.
+// .npy output must be an io.WriteSeeker, such as an *os.File.
+func NewWriter(format Format, w io.Writer, opts WriteOptions) (Writer, error) {
+	switch format {
+	case FormatJSONL:
+		return NewJSONLWriter(w), nil
+	case FormatNPY:
+		ws, ok := w.(io.WriteSeeker)
+		if !ok {
+			return nil, errors.New("vecio: npy output must be seekable")
+		}
+		return NewNPYWriter(ws, opts.IDs)
+	case FormatSnapshot:
+		return NewSnapshotWriter(w, opts.Snapshot)
+	default:
+		return nil, fmt.Errorf("vecio: writing %s is not supported", format)
+	}
+}
+
+// Export writes every record of src to w in ascending ID order, then closes w.
+// It returns the number of records written. On error w is left unclosed, so
+// that an interrupted snapshot is reported as truncated rather than restored
+// partially.
+func Export(src embedx.Scanner, w Writer) (int, error) {
+	n := 0
+	err := src.Scan(func(rec embedx.Record) error {
+		if err := w.Write(rec); err != nil {
+			return err
+		}
+		n++
+		return nil
+	})
+	if err != nil {
+		return n, err
+	}
+	return n, w.Close()
+}
@@ -0,0 +1,266 @@
+package vecio
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ldaidone/goembedx/internal/store/badger"
+	"github.com/ldaidone/goembedx/pkg/embedx"
+)
+
+// readAll drains r, collecting records and per-record errors.
+func readAll(t *testing.T, r Reader) ([]embedx.Record, []*RecordError) {
+	t.Helper()
+	var recs []embedx.Record
+	var errs []*RecordError
+	for {
+		rec, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return recs, errs
+		}
+		var recErr *RecordError
+		if errors.As(err, &recErr) {
+			errs = append(errs, recErr)
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		recs = append(recs, rec)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, f := range []Format{FormatJSONL, FormatCSV, FormatNPY, FormatNPZ, FormatFvecs, FormatIvecs, FormatSnapshot} {
+		got, err := ParseFormat(f.String())
+		if err != nil || got != f {
+			t.Errorf("ParseFormat(%q) = %v, %v", f.String(), got, err)
+		}
+	}
+	if f, err := ParseFormat("NDJSON"); err != nil || f != FormatJSONL {
+		t.Errorf("Expected ndjson alias, got %v, %v", f, err)
+	}
+	if f, err := FormatFromPath("backup.snap"); err != nil || f != FormatSnapshot {
+		t.Errorf("Expected snapshot from .snap path, got %v, %v", f, err)
+	}
+	if _, err := ParseFormat("parquet"); err == nil {
+		t.Error("Expected error for unknown format, got nil")
+	}
+
+	if f, err := FormatFromPath("data/sift_base.fvecs"); err != nil || f != FormatFvecs {
+		t.Errorf("Expected fvecs from path, got %v, %v", f, err)
+	}
+	if _, err := FormatFromPath("data/vectors"); err == nil {
+		t.Error("Expected error for path without extension, got nil")
+	}
+}
+
+func TestIDSources(t *testing.T) {
+	seq := SequentialIDs("doc-")
+	if id, _ := seq(7); id != "doc-7" {
+		t.Errorf("Expected doc-7, got %s", id)
+	}
+
+	lines := IDsFromLines(strings.NewReader("a\r\nb\n"))
+	if id, _ := lines(0); id != "a" {
+		t.Errorf("Expected a, got %s", id)
+	}
+	if id, _ := lines(1); id != "b" {
+		t.Errorf("Expected b, got %s", id)
+	}
+	if _, err := lines(2); err == nil {
+		t.Error("Expected error when IDs run out, got nil")
+	}
+}
+
+func TestImport(t *testing.T) {
+	store := embedx.NewMemoryStoreWithDim(2)
+	_ = store.SaveVector("existing", []float32{1, 1})
+
+	input := strings.Join([]string{
+		`{"id":"a","vector":[1,0],"meta":{"tenant":"x"}}`,
+		`{"id":"b","vector":[0,1]}`,
+		`not json`,
+		`{"id":"c","vector":[1,2,3]}`,
+		`{"id":"existing","vector":[0,1]}`,
+		`{"id":"d","vector":[0.5,0.5]}`,
+	}, "\n")
+
+	var failed []*RecordError
+	var progress []ImportStats
+	stats, err := Import(store, NewJSONLReader(strings.NewReader(input)), ImportOptions{
+		BatchSize: 2,
+		Mode:      embedx.InsertOnly,
+		Progress:  func(s ImportStats) { progress = append(progress, s) },
+		OnError:   func(e *RecordError) { failed = append(failed, e) },
+	})
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if stats != (ImportStats{Read: 6, Imported: 3, Failed: 3}) {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	if len(failed) != 3 || failed[0].Index != 2 || failed[1].ID != "c" || !errors.Is(failed[2].Err, embedx.ErrAlreadyExists) {
+		t.Errorf("Unexpected failures: %v", failed)
+	}
+	if len(progress) < 2 || progress[len(progress)-1] != stats {
+		t.Errorf("Expected progress after every batch ending with the totals, got %v", progress)
+	}
+
+	_, _, meta, err := store.Get("a")
+	if err != nil || meta["tenant"] != "x" {
+		t.Errorf("Expected a with metadata, got %v, %v", meta, err)
+	}
+}
+
+func TestImportBadgerStore(t *testing.T) {
+	store, err := badger.NewBadgerStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewBadgerStore failed: %v", err)
+	}
+	defer store.Close()
+
+	// Arrays and nested objects in JSON metadata are stored as decoded.
+	meta := `{"tags":["a","b"],"author":{"name":"ann","ids":[1,2]}}`
+	readers := map[string]Reader{
+		"jsonl": NewJSONLReader(strings.NewReader(`{"id":"j","vector":[1,0],"meta":` + meta + `}`)),
+		"csv":   NewCSVReader(strings.NewReader("id,x,y,meta\nc,0,1,\""+strings.ReplaceAll(meta, `"`, `""`)+"\"\n"), 0),
+	}
+	var want map[string]any
+	_ = json.Unmarshal([]byte(meta), &want)
+
+	for name, r := range readers {
+		var failed []*RecordError
+		stats, err := Import(store, r, ImportOptions{OnError: func(e *RecordError) { failed = append(failed, e) }})
+		if err != nil || stats.Imported != 1 || len(failed) != 0 {
+			t.Fatalf("%s: expected 1 record imported, got %+v, %v, %v", name, stats, failed, err)
+		}
+	}
+	for _, id := range []string{"j", "c"} {
+		if _, _, got, err := store.Get(id); err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: expected metadata %v, got %v, %v", id, want, got, err)
+		}
+	}
+}
+
+// failingReader returns a fatal error after its records.
+type failingReader struct {
+	recs []embedx.Record
+}
+
+func (f *failingReader) Read() (embedx.Record, error) {
+	if len(f.recs) == 0 {
+		return embedx.Record{}, errors.New("disk on fire")
+	}
+	rec := f.recs[0]
+	f.recs = f.recs[1:]
+	return rec, nil
+}
+
+func TestImportFatalError(t *testing.T) {
+	store := embedx.NewMemoryStore()
+	r := &failingReader{recs: []embedx.Record{{ID: "a", Vector: []float32{1}}}}
+
+	stats, err := Import(store, r, ImportOptions{})
+	if err == nil {
+		t.Fatal("Expected fatal read error, got nil")
+	}
+	if stats.Imported != 1 {
+		t.Errorf("Expected records read before the error to be imported, got %+v", stats)
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	r, err := NewReader(FormatCSV, strings.NewReader("a;1;2\n"), ReadOptions{Comma: ';'})
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	recs, _ := readAll(t, r)
+	if len(recs) != 1 || recs[0].ID != "a" || len(recs[0].Vector) != 2 {
+		t.Errorf("Unexpected records: %v", recs)
+	}
+
+	r, err = NewReader(FormatFvecs, strings.NewReader(string(fvecs([]float32{1, 2}))), ReadOptions{IDs: strings.NewReader("first\n")})
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	recs, _ = readAll(t, r)
+	if len(recs) != 1 || recs[0].ID != "first" {
+		t.Errorf("Expected ID from IDs file, got %v", recs)
+	}
+
+	if _, err := NewReader(Format(0), strings.NewReader(""), ReadOptions{}); err == nil {
+		t.Error("Expected error for unsupported format, got nil")
+	}
+}
+
+func TestExport(t *testing.T) {
+	src := embedx.NewMemoryStore()
+	_ = src.Add("b", []float32{0, 2}, map[string]any{"tags": []any{"x", "y"}})
+	_ = src.Add("a", []float32{3, 4}, nil)
+
+	for _, format := range []Format{FormatJSONL, FormatSnapshot} {
+		t.Run(format.String(), func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewWriter(format, &buf, WriteOptions{})
+			if err != nil {
+				t.Fatalf("NewWriter failed: %v", err)
+			}
+			n, err := Export(src, w)
+			if err != nil || n != 2 {
+				t.Fatalf("Export = %d, %v", n, err)
+			}
+
+			r, err := NewReader(format, &buf, ReadOptions{})
+			if err != nil {
+				t.Fatalf("NewReader failed: %v", err)
+			}
+			dst := embedx.NewMemoryStore()
+			if stats, err := Import(dst, r, ImportOptions{}); err != nil || stats.Imported != 2 {
+				t.Fatalf("Import = %+v, %v", stats, err)
+			}
+
+			vec, norm, meta, err := dst.Get("b")
+			if err != nil || norm != 2 || fmt.Sprint(vec) != "[0 2]" || fmt.Sprint(meta["tags"]) != "[x y]" {
+				t.Errorf("Unexpected restored record: %v %v %v %v", vec, norm, meta, err)
+			}
+			if _, err := dst.GetVector("a"); err != nil {
+				t.Errorf("Expected a to be restored, got %v", err)
+			}
+		})
+	}
+}
+
+// errWriter fails every write.
+type errWriter struct{ closed bool }
+
+func (e *errWriter) Write(embedx.Record) error { return errors.New("disk full") }
+func (e *errWriter) Close() error              { e.closed = true; return nil }
+
+func TestExportWriteError(t *testing.T) {
+	src := embedx.NewMemoryStore()
+	_ = src.Add("a", []float32{1}, nil)
+
+	w := &errWriter{}
+	if _, err := Export(src, w); err == nil {
+		t.Fatal("Expected write error, got nil")
+	}
+	if w.closed {
+		t.Error("Expected writer to be left unclosed after an error")
+	}
+}
+
+func TestNewWriter(t *testing.T) {
+	if _, err := NewWriter(FormatCSV, io.Discard, WriteOptions{}); err == nil {
+		t.Error("Expected error for unsupported output format, got nil")
+	}
+	if _, err := NewWriter(FormatNPY, io.Discard, WriteOptions{}); err == nil {
+		t.Error("Expected error for non-seekable npy output, got nil")
+	}
+}
@@ -0,0 +1,194 @@
+package vector
+
+import (
+	"context"
+	"fmt"
+)
+
+// arenaBlock is the number of rows an Arena scores at a time in Search.
+const arenaBlock = 1024
+
+// Arena stores vectors of one dimension contiguously in a single []float32,
+// in row-major order, with the precomputed L2 norm and the ID of each row.
+// Keeping the rows in one allocation lets Search stream them through
+// DotBatchFlat instead of chasing a pointer per vector.
+//
+// Rows are addressed by ID. Deleting a row moves the last row into its
+// place, so row positions change on every Delete. An Arena is not safe for
+// concurrent use.
+type Arena struct {
+	dim   int
+	data  []float32
+	norms []float32
+	ids   []string
+	pos   map[string]int
+}
+
+// NewArena returns an empty arena for vectors of dimension dim.
+//
+// This function will panic if dim is not positive.
+func NewArena(dim int) *Arena {
+	if dim <= 0 {
+		panic("vector: NewArena requires a positive dimension")
+	}
+	return &Arena{dim: dim, pos: make(map[string]int)}
+}
+
+// Dim returns the dimension of the vectors in the arena.
+func (a *Arena) Dim() int { return a.dim }
+
+// Len returns the number of vectors in the arena.
+func (a *Arena) Len() int { return len(a.ids) }
+
+// Set stores a copy of vec under id, replacing any vector stored under it.
+//
+// This function will panic if vec does not have the arena's dimension.
+func (a *Arena) Set(id string, vec []float32) {
+	if len(vec) != a.dim {
+		panic(fmt.Sprintf("vector: Arena.Set requires a vector of dimension %d, got %d", a.dim, len(vec)))
+	}
+	i, ok := a.pos[id]
+	if !ok {
+		i = len(a.ids)
+		a.pos[id] = i
+		a.ids = append(a.ids, id)
+		a.norms = append(a.norms, 0)
+		a.data = append(a.data, vec...)
+	} else {
+		copy(a.row(i), vec)
+	}
+	a.norms[i] = Norm(vec)
+}
+
+// Get returns the vector stored under id and its norm, and reports whether
+// id is stored. The vector aliases the arena: it must not be modified and is
+// only valid until the next Set or Delete.
+func (a *Arena) Get(id string) ([]float32, float32, bool) {
+	i, ok := a.pos[id]
+	if !ok {
+		return nil, 0, false
+	}
+	return a.row(i), a.norms[i], true
+}
+
+// Delete removes the vector stored under id and reports whether it was stored.
+func (a *Arena) Delete(id string) bool {
+	i, ok := a.pos[id]
+	if !ok {
+		return false
+	}
+	last := len(a.ids) - 1
+	if i != last {
+		copy(a.row(i), a.row(last))
+		a.norms[i] = a.norms[last]
+		a.ids[i] = a.ids[last]
+		a.pos[a.ids[i]] = i
+	}
+	a.data = a.data[:last*a.dim]
+	a.norms = a.norms[:last]
+	a.ids[last] = ""
+	a.ids = a.ids[:last]
+	delete(a.pos, id)
+	return true
+}
+
+// IDs returns the IDs of the stored vectors in row order. The slice aliases
+// the arena: it must not be modified and is only valid until the next Set or
+// Delete.
+func (a *Arena) IDs() []string { return a.ids }
+
+// row returns row i of the arena.
+func (a *Arena) row(i int) []float32 {
+	return a.data[i*a.dim : (i+1)*a.dim : (i+1)*a.dim]
+}
+
+// Search scores every stored vector against the query with metric m and
+// returns the top k as candidates, ranked like TopK, whose Pos is their row.
+// If k <= 0, every vector is returned. Under MetricCosine, vectors with a
+// zero norm are skipped, and no vector is returned for a zero query.
+// If keep is not nil, only the vectors whose ID it accepts are scored and
+// returned.
+// Cosine and dot scores are computed a block of rows at a time with
+// DotBatchFlat and the precomputed norms.
+// Search stops and returns ctx.Err() once ctx is done, checking it before
+// every block and once the scan is done.
+//
+// This function will panic if the query does not have the arena's dimension
+// or m is not a valid metric.
+func (a *Arena) Search(ctx context.Context, query []float32, k int, m Metric, keep func(id string) bool) ([]Candidate, error) {
+	if len(query) != a.dim {
+		panic(fmt.Sprintf("vector: Arena.Search requires a query of dimension %d, got %d", a.dim, len(query)))
+	}
+	top := NewTopK(k)
+	qn := Norm(query)
+	if m == MetricCosine && qn == 0 {
+		return top.Results(), nil
+	}
+
+	var dots []float32
+	for lo := 0; lo < len(a.ids); lo += arenaBlock {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		hi := min(lo+arenaBlock, len(a.ids))
+		if m == MetricCosine || m == MetricDot {
+			dots = DotBatchFlat(dots, query, a.data[lo*a.dim:hi*a.dim])
+		}
+		for i := lo; i < hi; i++ {
+			if keep != nil && !keep(a.ids[i]) {
+				continue
+			}
+			var score float32
+			switch m {
+			case MetricCosine:
+				if a.norms[i] == 0 {
+					continue
+				}
+				score = dots[i-lo] / (qn * a.norms[i])
+			case MetricDot:
+				score = dots[i-lo]
+			default:
+				score = m.ScoreNorms(query, a.row(i), qn, a.norms[i])
+			}
+			if !top.Admits(score) {
+				continue
+			}
+			top.Push(Candidate{ID: a.ids[i], Score: score, Pos: i})
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return top.Results(), nil
+}
+
+// SearchBatch is like Search without a filter for many queries at once: it
+// returns the top k candidates of each query, in query order. The rows are
+// scored in place a block at a time against all the queries, like a
+// QueryBatch does, so that each block is read once rather than once per
+// query. Scores are exactly those of Search.
+//
+// This function will panic if a query does not have the arena's dimension
+// or m is not a valid metric.
+func (a *Arena) SearchBatch(ctx context.Context, queries [][]float32, k int, m Metric) ([][]Candidate, error) {
+	if len(queries) == 0 {
+		return [][]Candidate{}, nil
+	}
+	for _, q := range queries {
+		if len(q) != a.dim {
+			panic(fmt.Sprintf("vector: Arena.SearchBatch requires queries of dimension %d, got %d", a.dim, len(q)))
+		}
+	}
+	b := NewQueryBatch(queries, k, m)
+	for lo := 0; lo < len(a.ids); lo += arenaBlock {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		hi := min(lo+arenaBlock, len(a.ids))
+		b.score(a.ids[lo:hi], a.data[lo*a.dim:hi*a.dim], a.norms[lo:hi], lo)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return b.Results(), nil
+}
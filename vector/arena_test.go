@@ -0,0 +1,219 @@
+package vector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func TestDotBatchFlat(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	// 1000 rows of dimension 256 are scored in parallel when there are several
+	// CPUs, 3 rows always serially.
+	for _, tc := range []struct{ n, dim int }{{3, 4}, {1000, 256}} {
+		a := randomVec(rng, tc.dim)
+		B := randomVec(rng, tc.n*tc.dim)
+		got := DotBatchFlat(nil, a, B)
+		if len(got) != tc.n {
+			t.Fatalf("Expected %d products, got %d", tc.n, len(got))
+		}
+		for i := range got {
+			if want := Dot(a, B[i*tc.dim:(i+1)*tc.dim]); got[i] != want {
+				t.Fatalf("n=%d: row %d: expected %v, got %v", tc.n, i, want, got[i])
+			}
+		}
+	}
+
+	dst := make([]float32, 10)
+	if got := DotBatchFlat(dst, []float32{1, 2}, []float32{1, 1, 2, 2}); &got[0] != &dst[0] || !slices.Equal(got, []float32{3, 6}) {
+		t.Errorf("Expected [3 6] in dst, got %v", got)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic for a matrix of partial rows")
+		}
+	}()
+	DotBatchFlat(nil, []float32{1, 2}, []float32{1, 2, 3})
+}
+
+func TestDotMatrixFlat(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	// Tiles of 8192 components hold 2 rows of dimension 4000, so that the
+	// products cross tile boundaries; 40x1000 rows of dimension 64 are
+	// computed in parallel when there are several CPUs.
+	for _, tc := range []struct{ na, nb, dim int }{{1, 1, 3}, {5, 7, 4000}, {40, 1000, 64}} {
+		A := randomVec(rng, tc.na*tc.dim)
+		B := randomVec(rng, tc.nb*tc.dim)
+		got := DotMatrixFlat(nil, A, B, tc.dim)
+		if len(got) != tc.na*tc.nb {
+			t.Fatalf("Expected %d products, got %d", tc.na*tc.nb, len(got))
+		}
+		for i := 0; i < tc.na; i++ {
+			for j := 0; j < tc.nb; j++ {
+				want := Dot(A[i*tc.dim:(i+1)*tc.dim], B[j*tc.dim:(j+1)*tc.dim])
+				if got[i*tc.nb+j] != want {
+					t.Fatalf("%dx%d: product (%d, %d): expected %v, got %v", tc.na, tc.nb, i, j, want, got[i*tc.nb+j])
+				}
+			}
+		}
+	}
+
+	dst := make([]float32, 10)
+	if got := DotMatrixFlat(dst, []float32{1, 2, 3, 4}, []float32{1, 0, 0, 1}, 2); &got[0] != &dst[0] || !slices.Equal(got, []float32{1, 2, 3, 4}) {
+		t.Errorf("Expected [1 2 3 4] in dst, got %v", got)
+	}
+	if got := DotMatrixFlat(nil, nil, []float32{1, 2}, 2); len(got) != 0 {
+		t.Errorf("Expected no products without rows, got %v", got)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic for a matrix of partial rows")
+		}
+	}()
+	DotMatrixFlat(nil, []float32{1, 2}, []float32{1, 2, 3}, 2)
+}
+
+// randomVec returns a vector of n components in [-1, 1).
+func randomVec(rng *rand.Rand, n int) []float32 {
+	v := make([]float32, n)
+	for i := range v {
+		v[i] = rng.Float32()*2 - 1
+	}
+	return v
+}
+
+func TestArenaSetGetDelete(t *testing.T) {
+	a := NewArena(2)
+	a.Set("a", []float32{3, 4})
+	a.Set("b", []float32{1, 0})
+	a.Set("c", []float32{0, 2})
+	a.Set("a", []float32{0, 1})
+	if a.Len() != 3 || a.Dim() != 2 {
+		t.Fatalf("Expected 3 vectors of dimension 2, got %d of %d", a.Len(), a.Dim())
+	}
+	if vec, norm, ok := a.Get("a"); !ok || !slices.Equal(vec, []float32{0, 1}) || norm != 1 {
+		t.Errorf("Expected the replaced vector, got %v, %v, %v", vec, norm, ok)
+	}
+
+	if !a.Delete("a") || a.Delete("a") {
+		t.Error("Expected Delete to report whether the vector was stored")
+	}
+	if _, _, ok := a.Get("a"); ok {
+		t.Error("Expected a deleted vector to be gone")
+	}
+	// The last row moved into the deleted one.
+	if vec, norm, ok := a.Get("c"); !ok || !slices.Equal(vec, []float32{0, 2}) || norm != 2 {
+		t.Errorf("Expected c to survive the delete, got %v, %v, %v", vec, norm, ok)
+	}
+	if got := fmt.Sprint(a.IDs()); got != "[c b]" {
+		t.Errorf("Expected IDs [c b], got %s", got)
+	}
+}
+
+func TestArenaSearch(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	const n, dim = 3*arenaBlock + 17, 8
+	a := NewArena(dim)
+	vecs := make(map[string][]float32, n)
+	for i := 0; i < n; i++ {
+		id := fmt.Sprintf("v%05d", i)
+		vecs[id] = randomVec(rng, dim)
+		a.Set(id, vecs[id])
+	}
+	a.Set("zero", make([]float32, dim))
+	query := randomVec(rng, dim)
+
+	for _, m := range []Metric{MetricCosine, MetricDot, MetricEuclidean, MetricManhattan} {
+		top := NewTopK(10)
+		for id, vec := range vecs {
+			top.Push(Candidate{ID: id, Score: m.Score(query, vec)})
+		}
+		want := top.Results()
+		got, err := a.Search(context.Background(), query, 10, m, nil)
+		if err != nil {
+			t.Fatalf("%s: Search failed: %v", m, err)
+		}
+		for i := range got {
+			if got[i].ID != want[i].ID || got[i].Score != want[i].Score || a.IDs()[got[i].Pos] != got[i].ID {
+				t.Fatalf("%s: expected %v, got %v", m, want, got)
+			}
+		}
+	}
+
+	all, _ := a.Search(context.Background(), query, 0, MetricCosine, nil)
+	if len(all) != n {
+		t.Errorf("Expected every vector but the zero one for k=0, got %d", len(all))
+	}
+	odd, _ := a.Search(context.Background(), query, 0, MetricDot, func(id string) bool { return id[0] == 'v' && id[len(id)-1]%2 == 1 })
+	if len(odd) != n/2 {
+		t.Errorf("Expected %d odd vectors, got %d", n/2, len(odd))
+	}
+	if zero, _ := a.Search(context.Background(), make([]float32, dim), 5, MetricCosine, nil); len(zero) != 0 {
+		t.Errorf("Expected no cosine results for a zero query, got %v", zero)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := a.Search(ctx, query, 5, MetricCosine, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestArenaSearchBatch(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	const n, dim = 2*arenaBlock + 5, 16
+	a := NewArena(dim)
+	for i := 0; i < n; i++ {
+		a.Set(fmt.Sprintf("v%05d", i), randomVec(rng, dim))
+	}
+	a.Set("zero", make([]float32, dim))
+	queries := [][]float32{randomVec(rng, dim), make([]float32, dim), randomVec(rng, dim)}
+
+	for _, m := range []Metric{MetricCosine, MetricDot, MetricEuclidean, MetricManhattan} {
+		for _, k := range []int{1, 10, 0} {
+			got, err := a.SearchBatch(context.Background(), queries, k, m)
+			if err != nil {
+				t.Fatalf("%s: SearchBatch failed: %v", m, err)
+			}
+			if len(got) != len(queries) {
+				t.Fatalf("%s: expected %d result lists, got %d", m, len(queries), len(got))
+			}
+			for q, query := range queries {
+				want, _ := a.Search(context.Background(), query, k, m, nil)
+				if !slices.Equal(got[q], want) {
+					t.Fatalf("%s, k=%d: query %d: expected the results of Search, got %d results differing from %d", m, k, q, len(got[q]), len(want))
+				}
+			}
+		}
+	}
+
+	if got, err := a.SearchBatch(context.Background(), nil, 5, MetricCosine); err != nil || got == nil || len(got) != 0 {
+		t.Errorf("Expected no result lists for no queries, got %v, %v", got, err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := a.SearchBatch(ctx, queries, 5, MetricCosine); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+// BenchmarkArenaSearch1M measures exact searches over a million vectors.
+func BenchmarkArenaSearch1M(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	for _, dim := range []int{8, 128} {
+		a := NewArena(dim)
+		for i := 0; i < 1_000_000; i++ {
+			a.Set(fmt.Sprint(i), randomVec(rng, dim))
+		}
+		query := randomVec(rng, dim)
+		b.Run(fmt.Sprintf("dim=%d", dim), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_, _ = a.Search(context.Background(), query, 10, MetricCosine, nil)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package vector
+
+import "math/bits"
+
+// BitVector is a binary vector packed 64 dimensions per word: dimension i is
+// bit i%64 of word i/64. Bits past the dimension of the vector are zero.
+type BitVector []uint64
+
+// BitWords returns the number of words of a BitVector of dimension dim.
+func BitWords(dim int) int { return (dim + 63) / 64 }
+
+// Binarize packs the signs of v into dst, growing it if needed, and returns it.
+// Dimension i is set if v[i] is positive.
+func Binarize(dst BitVector, v []float32) BitVector {
+	n := BitWords(len(v))
+	if cap(dst) < n {
+		dst = make(BitVector, n)
+	}
+	dst = dst[:n]
+	clear(dst)
+	for i, x := range v {
+		if x > 0 {
+			dst[i/64] |= 1 << (i % 64)
+		}
+	}
+	return dst
+}
+
+// Bit reports whether dimension i is set.
+//
+// This function will panic if i is outside the vector.
+func (b BitVector) Bit(i int) bool {
+	return b[i/64]&(1<<(i%64)) != 0
+}
+
+// Unpack returns the vector of dimension dim with +1 for set dimensions and
+// -1 for the others, written to dst, growing it if needed.
+//
+// This function will panic if b has fewer than BitWords(dim) words.
+func (b BitVector) Unpack(dst []float32, dim int) []float32 {
+	_ = b[BitWords(dim)-1]
+	if cap(dst) < dim {
+		dst = make([]float32, dim)
+	}
+	dst = dst[:dim]
+	for i := range dst {
+		if b.Bit(i) {
+			dst[i] = 1
+		} else {
+			dst[i] = -1
+		}
+	}
+	return dst
+}
+
+// HammingBits returns the number of differing bits of two bit vectors.
+//
+// This function will panic if the vectors have different lengths.
+func HammingBits(a, b BitVector) int {
+	if len(a) != len(b) {
+		panic("vector: HammingBits requires vectors of equal length")
+	}
+	var d0, d1, d2, d3 int
+	i := 0
+	for ; i+4 <= len(a); i += 4 {
+		d0 += bits.OnesCount64(a[i] ^ b[i])
+		d1 += bits.OnesCount64(a[i+1] ^ b[i+1])
+		d2 += bits.OnesCount64(a[i+2] ^ b[i+2])
+		d3 += bits.OnesCount64(a[i+3] ^ b[i+3])
+	}
+	for ; i < len(a); i++ {
+		d0 += bits.OnesCount64(a[i] ^ b[i])
+	}
+	return d0 + d1 + d2 + d3
+}
+
+// HammingBitsSimilarity returns 1 - HammingBits(a, b)/dim, the fraction of
+// the dim dimensions on which two bit vectors agree, in [0, 1].
+//
+// This function will panic if the vectors have different lengths.
+func HammingBitsSimilarity(a, b BitVector, dim int) float32 {
+	if dim == 0 {
+		return 0
+	}
+	return 1 - float32(HammingBits(a, b))/float32(dim)
+}
@@ -0,0 +1,77 @@
+package vector
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestBinarize(t *testing.T) {
+	v := make([]float32, 130)
+	for i := range v {
+		v[i] = -1
+	}
+	v[0], v[63], v[64], v[129] = 0.5, 2, 0.1, 3
+
+	b := Binarize(nil, v)
+	if len(b) != BitWords(130) || len(b) != 3 {
+		t.Fatalf("Expected 3 words, got %d", len(b))
+	}
+	for i := range v {
+		if b.Bit(i) != (v[i] > 0) {
+			t.Errorf("bit %d = %v, want %v", i, b.Bit(i), v[i] > 0)
+		}
+	}
+	if b[2]>>2 != 0 {
+		t.Errorf("Expected bits past the dimension to be zero, got %b", b[2])
+	}
+
+	// Reusing dst clears its previous bits.
+	if b = Binarize(b, make([]float32, 130)); b[0] != 0 || b[1] != 0 || b[2] != 0 {
+		t.Errorf("Expected a cleared vector, got %v", b)
+	}
+
+	u := Binarize(nil, []float32{1, -2, 0, 3}).Unpack(nil, 4)
+	if want := []float32{1, -1, -1, 1}; !equalFloats(u, want) {
+		t.Errorf("Unpack = %v, want %v", u, want)
+	}
+}
+
+func TestHammingBits(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, dim := range []int{1, 64, 100, 300, 1024} {
+		a := make([]float32, dim)
+		b := make([]float32, dim)
+		for i := range a {
+			a[i] = r.Float32()*2 - 1
+			b[i] = r.Float32()*2 - 1
+		}
+		// The packed distance matches the distance of the sign vectors.
+		want := Hamming(Binarize(nil, a).Unpack(nil, dim), Binarize(nil, b).Unpack(nil, dim))
+		if got := HammingBits(Binarize(nil, a), Binarize(nil, b)); got != want {
+			t.Errorf("dim %d: HammingBits = %d, want %d", dim, got, want)
+		}
+		if s := HammingBitsSimilarity(Binarize(nil, a), Binarize(nil, a), dim); s != 1 {
+			t.Errorf("dim %d: self similarity = %v, want 1", dim, s)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic for vectors of different lengths")
+		}
+	}()
+	HammingBits(make(BitVector, 1), make(BitVector, 2))
+}
+
+func BenchmarkHammingBits(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	x, y := make(BitVector, 12), make(BitVector, 12) // 768 dimensions
+	for i := range x {
+		x[i], y[i] = r.Uint64(), r.Uint64()
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = HammingBits(x, y)
+	}
+}
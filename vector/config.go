@@ -6,6 +6,10 @@ package vector
 type DotConfig struct {
 	// BlockSize specifies the size of blocks for blocked computation algorithms.
 	BlockSize int
+	// TileSize is the number of components in each tile of rows that
+	// DotMatrixFlat multiplies at a time, so that the rows of a tile pair
+	// stay in cache while they are reused.
+	TileSize int
 	// Workers specifies the number of worker goroutines for parallel operations.
 	// If 0, runtime.GOMAXPROCS(0) is used.
 	Workers int
@@ -20,6 +24,7 @@ type DotConfig struct {
 // These values have been tuned for general-purpose performance across different vector sizes.
 var DefaultDotConfig = DotConfig{
 	BlockSize:         64,
+	TileSize:          8192,
 	Workers:           0,
 	MinDimForParallel: 128,
 	MinBatchFactor:    4,
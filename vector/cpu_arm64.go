@@ -0,0 +1,29 @@
+//go:build arm64
+
+// This file contains CPU feature detection specific to the ARM64 architecture.
+package vector
+
+import (
+	"runtime"
+
+	"golang.org/x/sys/cpu"
+)
+
+// cpuHasAVX2 always returns false on ARM64.
+func cpuHasAVX2() bool { return false }
+
+// cpuHasAVX512 always returns false on ARM64.
+func cpuHasAVX512() bool { return false }
+
+// cpuHasNEON checks if the CPU supports the Advanced SIMD (NEON) instructions.
+// golang.org/x/sys/cpu reads the feature bits on Linux, NetBSD and OpenBSD
+// only; elsewhere, as on macOS, NEON is assumed, since the ARM64 port of Go
+// requires it.
+func cpuHasNEON() bool {
+	switch runtime.GOOS {
+	case "linux", "android", "netbsd", "openbsd":
+		return cpu.ARM64.HasASIMD
+	default:
+		return true
+	}
+}
@@ -1,16 +1,18 @@
 package vector
 
-import "runtime"
-
-// hasAVX2 checks if the current CPU architecture supports AVX2 instructions.
-// Currently, this returns true for x86-64 architecture, though actual AVX2 support
-// is determined in cpu_x86.go and cpu_other.go files.
+// hasAVX2 reports whether the CPU supports AVX2 and FMA and the operating
+// system saves the YMM registers.
 func hasAVX2() bool {
-	return runtime.GOARCH == "amd64"
+	return cpuHasAVX2()
+}
+
+// hasAVX512 reports whether the CPU supports AVX-512F and the operating
+// system saves the ZMM registers.
+func hasAVX512() bool {
+	return cpuHasAVX512()
 }
 
-// hasNEON checks if the current CPU architecture supports NEON instructions.
-// This returns true for ARM64 architecture which typically includes NEON SIMD capabilities.
+// hasNEON reports whether the CPU supports the Advanced SIMD (NEON) instructions.
 func hasNEON() bool {
-	return runtime.GOARCH == "arm64"
+	return cpuHasNEON()
 }
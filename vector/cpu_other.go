@@ -1,8 +1,14 @@
-//go:build !amd64
+//go:build !amd64 && !arm64
 
-// This file contains CPU feature detection for non-x86-64 architectures.
+// This file contains CPU feature detection for architectures without SIMD kernels.
 package vector
 
 // cpuHasAVX2 always returns false on non-x86-64 architectures.
 // AVX2 instruction set is specific to x86-64 processors.
 func cpuHasAVX2() bool { return false }
+
+// cpuHasAVX512 always returns false on non-x86-64 architectures.
+func cpuHasAVX512() bool { return false }
+
+// cpuHasNEON always returns false on non-ARM64 architectures.
+func cpuHasNEON() bool { return false }
@@ -7,9 +7,20 @@ import (
 	"golang.org/x/sys/cpu"
 )
 
-// cpuHasAVX2 checks if the CPU supports AVX2 instruction set.
-// AVX2 (Advanced Vector Extensions 2) provides enhanced SIMD capabilities
-// that can significantly speed up vector operations.
+// cpuHasAVX2 checks if the CPU supports the AVX2 and FMA instruction sets.
+// AVX2 (Advanced Vector Extensions 2) provides 256-bit SIMD integer and
+// floating-point operations, and FMA fused multiply-adds on the same registers.
+// The feature bits of golang.org/x/sys/cpu are only set when the operating
+// system also saves the registers the instructions use.
 func cpuHasAVX2() bool {
-	return cpu.X86.HasAVX2
+	return cpu.X86.HasAVX2 && cpu.X86.HasFMA
 }
+
+// cpuHasAVX512 checks if the CPU supports the AVX-512 foundation instructions,
+// which widen the AVX2 operations to 512-bit registers.
+func cpuHasAVX512() bool {
+	return cpu.X86.HasAVX512F && cpuHasAVX2()
+}
+
+// cpuHasNEON always returns false on x86-64.
+func cpuHasNEON() bool { return false }
@@ -4,43 +4,74 @@ package vector
 
 import (
 	"sync"
+
+	"github.com/ldaidone/goembedx/vector/internal"
 )
 
+// kernels holds the implementations of the loops that every metric is built on.
+type kernels struct {
+	// name identifies the instruction set of the kernels.
+	name string
+	// dot returns the dot product of two vectors of equal length.
+	dot func(a, b []float32) float32
+	// l2Squared returns the squared L2 distance of two vectors of equal length.
+	l2Squared func(a, b []float32) float32
+	// sumSquares returns the sum of the squares of the components of a vector.
+	sumSquares func(a []float32) float32
+}
+
 var (
-	dotImpl func(a, b []float32) float32
-	once    sync.Once
+	impl kernels
+	once sync.Once
 )
 
-// initDot initializes the optimal dot product implementation based on CPU capabilities.
-// It selects between AVX2, NEON, or generic implementations depending on the hardware.
-func initDot() {
-	switch {
-	case hasAVX2():
-		// Real implementation later; for now wrap generic/blocked
-		dotImpl = func(a, b []float32) float32 {
-			ensureAutoTune()
-			return dotBlocked(a, b, DefaultDotConfig)
-		}
-	case hasNEON():
-		dotImpl = func(a, b []float32) float32 {
-			ensureAutoTune()
-			return dotBlocked(a, b, DefaultDotConfig)
-		}
-	default:
-		dotImpl = func(a, b []float32) float32 {
-			ensureAutoTune()
-			if len(a) > 512 {
-				return dotBlocked(a, b, DefaultDotConfig)
-			}
-			return dotGeneric(a, b)
-		}
+// genericKernels are the pure-Go kernels used when the CPU has no supported
+// SIMD instruction set.
+var genericKernels = kernels{
+	name:       "generic",
+	dot:        dotPureGo,
+	l2Squared:  internal.L2SquaredGeneric,
+	sumSquares: func(a []float32) float32 { return dotPureGo(a, a) },
+}
+
+// dotPureGo computes the dot product with the blocked kernel for long
+// vectors and the simple loop for short ones.
+func dotPureGo(a, b []float32) float32 {
+	ensureAutoTune()
+	if len(a) > 512 {
+		return dotBlocked(a, b, DefaultDotConfig)
 	}
+	return dotGeneric(a, b)
+}
+
+// initKernels selects the optimal kernels based on CPU capabilities: the
+// assembly kernels for AVX-512, AVX2 and FMA, or NEON when the CPU supports
+// them, and the generic kernels otherwise.
+func initKernels() {
+	impl = genericKernels
+	if ks := simdKernels(); len(ks) > 0 {
+		impl = ks[0]
+	}
+}
+
+// Kernels returns the name of the instruction set the vector kernels use:
+// "avx512", "avx2", "neon" or "generic".
+func Kernels() string {
+	once.Do(initKernels)
+	return impl.name
 }
 
 // Dot computes the dot product of two float32 slices.
 // It returns the sum of element-wise products: Σ(a[i] * b[i]) for i = 0 to len(a)-1.
 // The function automatically selects the optimal implementation based on CPU capabilities.
+// SIMD implementations sum the products in a different order than a simple
+// loop, so their results may differ from it by rounding.
+//
+// This function will panic if the vectors have different lengths.
 func Dot(a, b []float32) float32 {
-	once.Do(initDot)
-	return dotImpl(a, b)
+	if len(a) != len(b) {
+		panic("vector: Dot requires vectors of equal length")
+	}
+	once.Do(initKernels)
+	return impl.dot(a, b)
 }
@@ -84,3 +84,134 @@ func dotBatchParallel(a []float32, B [][]float32, workers int) []float32 {
 	wg.Wait()
 	return res
 }
+
+// DotBatchFlat computes the dot products of vector `a` against each row of
+// the row-major matrix `B`, whose rows have the length of `a` and are stored
+// contiguously. Like DotBatch, it scores the rows in parallel when the
+// dimension and the number of rows are large enough.
+// The products are written to dst, which is grown if it is too short, and
+// dst[:rows] is returned, where dst[i] = a · B[i*len(a):(i+1)*len(a)].
+//
+// This function will panic if a is empty or len(B) is not a multiple of len(a).
+func DotBatchFlat(dst, a, B []float32) []float32 {
+	dim := len(a)
+	if dim == 0 || len(B)%dim != 0 {
+		panic("vector: DotBatchFlat requires rows of the query's length")
+	}
+	n := len(B) / dim
+	if cap(dst) < n {
+		dst = make([]float32, n)
+	}
+	dst = dst[:n]
+
+	cfg := DefaultDotConfig
+	workers := runtime.GOMAXPROCS(0)
+	if workers == 1 || dim < cfg.MinDimForParallel || n < workers*cfg.MinBatchFactor {
+		dotBatchFlatRange(dst, a, B, 0, n)
+	} else {
+		dotBatchFlatParallel(dst, a, B, workers)
+	}
+	return dst
+}
+
+// dotBatchFlatParallel computes the dot products of DotBatchFlat in parallel,
+// giving each worker a contiguous range of rows.
+func dotBatchFlatParallel(dst, a, B []float32, workers int) {
+	n := len(dst)
+	per := (n + workers - 1) / workers
+	var wg sync.WaitGroup
+	for lo := 0; lo < n; lo += per {
+		hi := min(lo+per, n)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			dotBatchFlatRange(dst, a, B, lo, hi)
+		}()
+	}
+	wg.Wait()
+}
+
+// dotBatchFlatRange computes the dot products of a against the rows lo to hi of B.
+func dotBatchFlatRange(dst, a, B []float32, lo, hi int) {
+	dim := len(a)
+	for i := lo; i < hi; i++ {
+		dst[i] = Dot(a, B[i*dim:(i+1)*dim])
+	}
+}
+
+// DotMatrixFlat computes the dot products of every row of the row-major
+// matrix A against every row of the row-major matrix B, whose rows both have
+// length dim: the matrix product of A and the transpose of B.
+// The products are written to dst, which is grown if it is too short, and
+// dst[:rowsA*rowsB] is returned, where dst[i*rowsB+j] is the dot product of
+// row i of A and row j of B.
+//
+// The product is computed one pair of tiles at a time, each tile holding
+// about DefaultDotConfig.TileSize components of rows, so that a tile of B is
+// read from cache for every row of the tile of A instead of from memory.
+// Like DotBatchFlat, the rows of B are split among parallel workers when
+// there are enough of them and enough work per row.
+//
+// This function will panic if dim is not positive or len(A) or len(B) is
+// not a multiple of dim.
+func DotMatrixFlat(dst, A, B []float32, dim int) []float32 {
+	if dim <= 0 || len(A)%dim != 0 || len(B)%dim != 0 {
+		panic("vector: DotMatrixFlat requires rows of length dim")
+	}
+	na, nb := len(A)/dim, len(B)/dim
+	if cap(dst) < na*nb {
+		dst = make([]float32, na*nb)
+	}
+	dst = dst[:na*nb]
+	if na == 0 || nb == 0 {
+		return dst
+	}
+
+	cfg := DefaultDotConfig
+	tile := max(1, cfg.TileSize/dim)
+	workers := runtime.GOMAXPROCS(0)
+	// Every row of B costs na dot products, which play the part of the
+	// dimension in the DotBatchFlat heuristic.
+	if workers == 1 || na*dim < cfg.MinDimForParallel || nb < workers*cfg.MinBatchFactor {
+		dotMatrixRange(dst, A, B, dim, tile, 0, nb)
+	} else {
+		dotMatrixParallel(dst, A, B, dim, tile, workers)
+	}
+	return dst
+}
+
+// dotMatrixParallel computes the products of DotMatrixFlat in parallel,
+// giving each worker a contiguous range of rows of B.
+func dotMatrixParallel(dst, A, B []float32, dim, tile, workers int) {
+	nb := len(B) / dim
+	per := (nb + workers - 1) / workers
+	var wg sync.WaitGroup
+	for lo := 0; lo < nb; lo += per {
+		hi := min(lo+per, nb)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			dotMatrixRange(dst, A, B, dim, tile, lo, hi)
+		}()
+	}
+	wg.Wait()
+}
+
+// dotMatrixRange computes the products of every row of A against the rows lo
+// to hi of B, tile rows of each at a time.
+func dotMatrixRange(dst, A, B []float32, dim, tile, lo, hi int) {
+	na, nb := len(A)/dim, len(B)/dim
+	for jlo := lo; jlo < hi; jlo += tile {
+		jhi := min(jlo+tile, hi)
+		for ilo := 0; ilo < na; ilo += tile {
+			ihi := min(ilo+tile, na)
+			for i := ilo; i < ihi; i++ {
+				a := A[i*dim : (i+1)*dim]
+				out := dst[i*nb : (i+1)*nb]
+				for j := jlo; j < jhi; j++ {
+					out[j] = Dot(a, B[j*dim:(j+1)*dim])
+				}
+			}
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package vector
 
 import (
+	"fmt"
 	"testing"
 )
 
@@ -35,3 +36,18 @@ func BenchmarkBatch1M_100(b *testing.B) {
 		DotBatch(a, B)
 	}
 }
+
+// BenchmarkKernels compares the dot product kernels the CPU supports.
+func BenchmarkKernels(b *testing.B) {
+	for _, dim := range []int{128, 768, 4096} {
+		x, y := randVec(dim), randVec(dim)
+		for _, k := range allKernels() {
+			b.Run(fmt.Sprintf("%s/dim=%d", k.name, dim), func(b *testing.B) {
+				b.SetBytes(int64(8 * dim))
+				for i := 0; i < b.N; i++ {
+					k.dot(x, y)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package vector
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Precision selects how the components of stored vectors are encoded.
+// Half-precision encodings take half the space of float32 and keep the
+// scores within about 1e-3 of their full-precision values, without the
+// calibration of int8 quantization.
+type Precision int
+
+const (
+	// PrecisionFloat32 stores components as IEEE 754 single-precision values.
+	// It is the zero value and the default precision.
+	PrecisionFloat32 Precision = iota
+	// PrecisionFloat16 stores components as IEEE 754 half-precision values,
+	// with 11 significant bits and a range of ±65504.
+	PrecisionFloat16
+	// PrecisionBFloat16 stores components as bfloat16 values, which keep the
+	// range of float32 with 8 significant bits.
+	PrecisionBFloat16
+)
+
+// precisionNames maps each precision to its canonical name.
+var precisionNames = map[Precision]string{
+	PrecisionFloat32:  "float32",
+	PrecisionFloat16:  "float16",
+	PrecisionBFloat16: "bfloat16",
+}
+
+// String returns the canonical name of the precision, as accepted by ParsePrecision.
+func (p Precision) String() string {
+	if name, ok := precisionNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("Precision(%d)", int(p))
+}
+
+// Valid reports whether p is one of the defined precisions.
+func (p Precision) Valid() bool {
+	_, ok := precisionNames[p]
+	return ok
+}
+
+// Size returns the number of bytes of an encoded component.
+func (p Precision) Size() int {
+	if p == PrecisionFloat32 {
+		return 4
+	}
+	return 2
+}
+
+// Round returns v rounded to the precision, written to dst, growing it if
+// needed. Float32 vectors are copied unchanged.
+func (p Precision) Round(dst, v []float32) []float32 {
+	if cap(dst) < len(v) {
+		dst = make([]float32, len(v))
+	}
+	dst = dst[:len(v)]
+	for i, x := range v {
+		switch p {
+		case PrecisionFloat16:
+			x = ToFloat16(x).Float32()
+		case PrecisionBFloat16:
+			x = ToBFloat16(x).Float32()
+		}
+		dst[i] = x
+	}
+	return dst
+}
+
+// ParsePrecision returns the precision with the given name.
+// It accepts the canonical names as well as the aliases "f32", "fp32", "f16",
+// "fp16", "half", "bf16" and "brain". Matching is case-insensitive.
+func ParsePrecision(name string) (Precision, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "float32", "f32", "fp32":
+		return PrecisionFloat32, nil
+	case "float16", "f16", "fp16", "half":
+		return PrecisionFloat16, nil
+	case "bfloat16", "bf16", "brain":
+		return PrecisionBFloat16, nil
+	default:
+		return 0, fmt.Errorf("vector: unknown precision %q", name)
+	}
+}
+
+// Float16 is an IEEE 754 half-precision value: a sign bit, 5 exponent bits
+// and 10 mantissa bits.
+type Float16 uint16
+
+// ToFloat16 returns f rounded to the nearest half-precision value, ties to
+// even. Values beyond the half-precision range become infinities, values too
+// small for it become signed zeros, and NaNs stay NaNs.
+func ToFloat16(f float32) Float16 {
+	b := math.Float32bits(f)
+	sign := uint16(b>>16) & 0x8000
+	exp := int(b>>23) & 0xff
+	mant := b & 0x7fffff
+
+	if exp == 0xff {
+		if mant != 0 {
+			return Float16(sign | 0x7e00)
+		}
+		return Float16(sign | 0x7c00)
+	}
+	e := exp - 127 + 15
+	if e >= 0x1f {
+		return Float16(sign | 0x7c00)
+	}
+	if e <= 0 {
+		// Subnormal: the implicit leading bit becomes explicit and the
+		// mantissa is shifted to an exponent of -14.
+		if e < -10 {
+			return Float16(sign)
+		}
+		return Float16(sign | uint16(roundShift(mant|0x800000, uint(14-e))))
+	}
+	// Rounding may carry into the exponent, up to infinity, as it should.
+	return Float16(sign | uint16(roundShift(uint32(e)<<23|mant, 13)))
+}
+
+// roundShift returns x >> s rounded to the nearest integer, ties to even.
+func roundShift(x uint32, s uint) uint32 {
+	q := x >> s
+	rem := x & (1<<s - 1)
+	half := uint32(1) << (s - 1)
+	if rem > half || rem == half && q&1 == 1 {
+		q++
+	}
+	return q
+}
+
+// Float32 returns h as a float32, which represents every half-precision value exactly.
+func (h Float16) Float32() float32 {
+	// The exponent and mantissa are moved into place and rebiased by a
+	// multiplication, which also normalizes subnormal values. Exponents that
+	// were all ones then map to at least 2^16 and become infinities or NaNs.
+	f := math.Float32frombits(uint32(h&0x7fff)<<13) * 0x1p112
+	b := math.Float32bits(f)
+	if f >= 0x1p16 {
+		b |= 0x7f800000
+	}
+	return math.Float32frombits(b | uint32(h&0x8000)<<16)
+}
+
+// BFloat16 is a bfloat16 value: the upper 16 bits of a float32.
+type BFloat16 uint16
+
+// ToBFloat16 returns f rounded to the nearest bfloat16 value, ties to even.
+// NaNs stay NaNs.
+func ToBFloat16(f float32) BFloat16 {
+	b := math.Float32bits(f)
+	if b&0x7fffffff > 0x7f800000 {
+		return BFloat16(b>>16 | 0x40)
+	}
+	b += 0x7fff + (b>>16)&1
+	return BFloat16(b >> 16)
+}
+
+// Float32 returns h as a float32, which represents every bfloat16 value exactly.
+func (h BFloat16) Float32() float32 {
+	return math.Float32frombits(uint32(h) << 16)
+}
+
+// EncodeFloat16 rounds v to half precision into dst, growing it if needed, and returns it.
+func EncodeFloat16(dst []Float16, v []float32) []Float16 {
+	if cap(dst) < len(v) {
+		dst = make([]Float16, len(v))
+	}
+	dst = dst[:len(v)]
+	for i, x := range v {
+		dst[i] = ToFloat16(x)
+	}
+	return dst
+}
+
+// DecodeFloat16 converts h to float32 into dst, growing it if needed, and returns it.
+func DecodeFloat16(dst []float32, h []Float16) []float32 {
+	if cap(dst) < len(h) {
+		dst = make([]float32, len(h))
+	}
+	dst = dst[:len(h)]
+	for i, x := range h {
+		dst[i] = x.Float32()
+	}
+	return dst
+}
+
+// EncodeBFloat16 rounds v to bfloat16 into dst, growing it if needed, and returns it.
+func EncodeBFloat16(dst []BFloat16, v []float32) []BFloat16 {
+	if cap(dst) < len(v) {
+		dst = make([]BFloat16, len(v))
+	}
+	dst = dst[:len(v)]
+	for i, x := range v {
+		dst[i] = ToBFloat16(x)
+	}
+	return dst
+}
+
+// DecodeBFloat16 converts h to float32 into dst, growing it if needed, and returns it.
+func DecodeBFloat16(dst []float32, h []BFloat16) []float32 {
+	if cap(dst) < len(h) {
+		dst = make([]float32, len(h))
+	}
+	dst = dst[:len(h)]
+	for i, x := range h {
+		dst[i] = x.Float32()
+	}
+	return dst
+}
+
+// DotFloat16 returns the dot product of a float32 vector and a half-precision
+// vector, accumulated in float32. It scores stored half-precision vectors
+// against a full-precision query.
+//
+// This function will panic if the vectors have different lengths.
+func DotFloat16(a []float32, b []Float16) float32 {
+	if len(a) != len(b) {
+		panic("vector: DotFloat16 requires vectors of equal length")
+	}
+	var s0, s1, s2, s3 float32
+	i := 0
+	for ; i+4 <= len(a); i += 4 {
+		s0 += a[i] * b[i].Float32()
+		s1 += a[i+1] * b[i+1].Float32()
+		s2 += a[i+2] * b[i+2].Float32()
+		s3 += a[i+3] * b[i+3].Float32()
+	}
+	for ; i < len(a); i++ {
+		s0 += a[i] * b[i].Float32()
+	}
+	return s0 + s1 + s2 + s3
+}
+
+// DotBFloat16 returns the dot product of a float32 vector and a bfloat16
+// vector, accumulated in float32. It scores stored bfloat16 vectors against
+// a full-precision query.
+//
+// This function will panic if the vectors have different lengths.
+func DotBFloat16(a []float32, b []BFloat16) float32 {
+	if len(a) != len(b) {
+		panic("vector: DotBFloat16 requires vectors of equal length")
+	}
+	var s0, s1, s2, s3 float32
+	i := 0
+	for ; i+4 <= len(a); i += 4 {
+		s0 += a[i] * math.Float32frombits(uint32(b[i])<<16)
+		s1 += a[i+1] * math.Float32frombits(uint32(b[i+1])<<16)
+		s2 += a[i+2] * math.Float32frombits(uint32(b[i+2])<<16)
+		s3 += a[i+3] * math.Float32frombits(uint32(b[i+3])<<16)
+	}
+	for ; i < len(a); i++ {
+		s0 += a[i] * math.Float32frombits(uint32(b[i])<<16)
+	}
+	return s0 + s1 + s2 + s3
+}
@@ -0,0 +1,171 @@
+package vector
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestFloat16Conversion(t *testing.T) {
+	tests := []struct {
+		f    float32
+		want Float16
+	}{
+		{0, 0x0000},
+		{float32(math.Copysign(0, -1)), 0x8000},
+		{1, 0x3c00},
+		{-2, 0xc000},
+		{65504, 0x7bff},
+		// Halfway between 65504 and 65536 rounds to even, which overflows.
+		{65520, 0x7c00},
+		{1e6, 0x7c00},
+		{float32(math.Inf(-1)), 0xfc00},
+		// The smallest subnormal, and the ties on either side of it.
+		{0x1p-24, 0x0001},
+		{0x1p-25, 0x0000},
+		{0x3p-25, 0x0002},
+		{0x1p-14, 0x0400},
+		// 1 + 2^-11 is a tie that rounds down to even, 1 + 3*2^-11 rounds up.
+		{1 + 0x1p-11, 0x3c00},
+		{1 + 0x3p-11, 0x3c02},
+	}
+	for _, tt := range tests {
+		if got := ToFloat16(tt.f); got != tt.want {
+			t.Errorf("ToFloat16(%v) = %#04x, want %#04x", tt.f, got, tt.want)
+		}
+	}
+	if h := ToFloat16(float32(math.NaN())); !math.IsNaN(float64(h.Float32())) {
+		t.Errorf("Expected NaN to stay NaN, got %#04x", h)
+	}
+
+	// Every half-precision value survives a round trip through float32.
+	for i := 0; i <= 0xffff; i++ {
+		h := Float16(i)
+		if f := h.Float32(); !math.IsNaN(float64(f)) && ToFloat16(f) != h {
+			t.Fatalf("Round trip of %#04x gave %#04x", h, ToFloat16(f))
+		}
+	}
+
+	// Conversions round to the nearest value.
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		f := float32(r.NormFloat64() * math.Pow(2, float64(r.Intn(40)-25)))
+		h := ToFloat16(f)
+		if math.IsInf(float64(h.Float32()), 0) {
+			continue
+		}
+		d := math.Abs(float64(f - h.Float32()))
+		for _, n := range []Float16{h + 1, h - 1} {
+			if nf := n.Float32(); !math.IsInf(float64(nf), 0) && math.Abs(float64(f-nf)) < d {
+				t.Fatalf("ToFloat16(%v) = %v, but %v is closer", f, h.Float32(), nf)
+			}
+		}
+	}
+}
+
+func TestBFloat16Conversion(t *testing.T) {
+	tests := []struct {
+		f    float32
+		want BFloat16
+	}{
+		{0, 0x0000},
+		{1, 0x3f80},
+		{-2, 0xc000},
+		{float32(math.MaxFloat32), 0x7f80},
+		// 1 + 2^-8 is a tie that rounds down to even, 1 + 3*2^-8 rounds up.
+		{1 + 0x1p-8, 0x3f80},
+		{1 + 0x3p-8, 0x3f82},
+	}
+	for _, tt := range tests {
+		if got := ToBFloat16(tt.f); got != tt.want {
+			t.Errorf("ToBFloat16(%v) = %#04x, want %#04x", tt.f, got, tt.want)
+		}
+	}
+	if h := ToBFloat16(float32(math.NaN())); !math.IsNaN(float64(h.Float32())) {
+		t.Errorf("Expected NaN to stay NaN, got %#04x", h)
+	}
+	for i := 0; i <= 0xffff; i++ {
+		h := BFloat16(i)
+		if f := h.Float32(); !math.IsNaN(float64(f)) && ToBFloat16(f) != h {
+			t.Fatalf("Round trip of %#04x gave %#04x", h, ToBFloat16(f))
+		}
+	}
+}
+
+func TestPrecision(t *testing.T) {
+	for _, name := range []string{"float32", "fp16", "BF16"} {
+		p, err := ParsePrecision(name)
+		if err != nil || !p.Valid() {
+			t.Fatalf("ParsePrecision(%q) failed: %v", name, err)
+		}
+		if q, _ := ParsePrecision(p.String()); q != p {
+			t.Errorf("Expected %s to parse back to itself, got %s", p, q)
+		}
+	}
+	if _, err := ParsePrecision("int4"); err == nil {
+		t.Error("Expected error for an unknown precision, got nil")
+	}
+	if PrecisionFloat32.Size() != 4 || PrecisionFloat16.Size() != 2 || PrecisionBFloat16.Size() != 2 {
+		t.Error("Unexpected component sizes")
+	}
+
+	v := []float32{1 + 0x3p-11, -0.1}
+	if got := PrecisionFloat32.Round(nil, v); !equalFloats(got, v) {
+		t.Errorf("Expected float32 rounding to copy the vector, got %v", got)
+	}
+	if got := PrecisionFloat16.Round(nil, v); got[0] != 1+0x2p-10 || got[1] != ToFloat16(-0.1).Float32() {
+		t.Errorf("Unexpected float16 rounding: %v", got)
+	}
+	if got := PrecisionBFloat16.Round(nil, v); got[0] != 1 || got[1] != ToBFloat16(-0.1).Float32() {
+		t.Errorf("Unexpected bfloat16 rounding: %v", got)
+	}
+}
+
+func TestHalfKernels(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	a, b := make([]float32, 67), make([]float32, 67)
+	for i := range a {
+		a[i], b[i] = r.Float32()*2-1, r.Float32()*2-1
+	}
+
+	h := EncodeFloat16(nil, b)
+	if got, want := DotFloat16(a, h), Dot(a, DecodeFloat16(nil, h)); math.Abs(float64(got-want)) > 1e-5 {
+		t.Errorf("DotFloat16 = %v, want %v", got, want)
+	}
+	if got, want := DotFloat16(a, h), Dot(a, b); math.Abs(float64(got-want)) > 1e-2 {
+		t.Errorf("DotFloat16 = %v, too far from the float32 dot product %v", got, want)
+	}
+
+	bh := EncodeBFloat16(nil, b)
+	if got, want := DotBFloat16(a, bh), Dot(a, DecodeBFloat16(nil, bh)); math.Abs(float64(got-want)) > 1e-5 {
+		t.Errorf("DotBFloat16 = %v, want %v", got, want)
+	}
+	if got, want := DotBFloat16(a, bh), Dot(a, b); math.Abs(float64(got-want)) > 5e-2 {
+		t.Errorf("DotBFloat16 = %v, too far from the float32 dot product %v", got, want)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic for mismatched lengths")
+		}
+	}()
+	DotFloat16(a, h[:2])
+}
+
+func BenchmarkDotFloat16(b *testing.B) {
+	q := makeVec(1536)
+	h := EncodeFloat16(nil, makeVec(1536))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = DotFloat16(q, h)
+	}
+}
+
+func BenchmarkDotBFloat16(b *testing.B) {
+	q := makeVec(1536)
+	h := EncodeBFloat16(nil, makeVec(1536))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = DotBFloat16(q, h)
+	}
+}
@@ -12,3 +12,14 @@ func DotGeneric(a, b []float32) float32 {
 	}
 	return sum
 }
+
+// L2SquaredGeneric computes the squared Euclidean distance between two
+// vectors with a simple loop. It is the fallback of the SIMD L2 kernels.
+func L2SquaredGeneric(a, b []float32) float32 {
+	var sum float32
+	for i := range a {
+		d := a[i] - b[i]
+		sum += d * d
+	}
+	return sum
+}
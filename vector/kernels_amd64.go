@@ -0,0 +1,40 @@
+//go:build !purego
+
+package vector
+
+// The AVX2 kernels process 32 components per iteration in four 8-lane FMA
+// accumulators, then 8 at a time, and the last few one by one. The AVX-512
+// kernels do the same with 16-lane registers. Each kernel family sums in
+// the same order, so sumSquares(a) equals dot(a, a) exactly.
+// The callers guarantee that a and b have the same length.
+
+//go:noescape
+func dotAVX2(a, b []float32) float32
+
+//go:noescape
+func l2SquaredAVX2(a, b []float32) float32
+
+//go:noescape
+func sumSquaresAVX2(a []float32) float32
+
+//go:noescape
+func dotAVX512(a, b []float32) float32
+
+//go:noescape
+func l2SquaredAVX512(a, b []float32) float32
+
+//go:noescape
+func sumSquaresAVX512(a []float32) float32
+
+// simdKernels returns the assembly kernels the CPU supports, widest first:
+// AVX-512, then AVX2 and FMA.
+func simdKernels() []kernels {
+	var ks []kernels
+	if hasAVX512() {
+		ks = append(ks, kernels{name: "avx512", dot: dotAVX512, l2Squared: l2SquaredAVX512, sumSquares: sumSquaresAVX512})
+	}
+	if hasAVX2() {
+		ks = append(ks, kernels{name: "avx2", dot: dotAVX2, l2Squared: l2SquaredAVX2, sumSquares: sumSquaresAVX2})
+	}
+	return ks
+}
@@ -0,0 +1,26 @@
+//go:build !purego
+
+package vector
+
+// The NEON kernels process 16 components per iteration in four 4-lane FMA
+// accumulators, then 4 at a time, and the last few one by one. dotNEON and
+// sumSquaresNEON sum in the same order, so sumSquares(a) equals dot(a, a)
+// exactly. The callers guarantee that a and b have the same length.
+
+//go:noescape
+func dotNEON(a, b []float32) float32
+
+//go:noescape
+func l2SquaredNEON(a, b []float32) float32
+
+//go:noescape
+func sumSquaresNEON(a []float32) float32
+
+// simdKernels returns the NEON kernels, or nothing if the CPU lacks Advanced
+// SIMD.
+func simdKernels() []kernels {
+	if !hasNEON() {
+		return nil
+	}
+	return []kernels{{name: "neon", dot: dotNEON, l2Squared: l2SquaredNEON, sumSquares: sumSquaresNEON}}
+}
@@ -0,0 +1,160 @@
+//go:build !purego
+
+#include "textflag.h"
+
+// func dotNEON(a, b []float32) float32
+TEXT ·dotNEON(SB), NOSPLIT, $0-52
+	MOVD a_base+0(FP), R0
+	MOVD b_base+24(FP), R1
+	MOVD a_len+8(FP), R2
+	VEOR V0.B16, V0.B16, V0.B16
+	VEOR V1.B16, V1.B16, V1.B16
+	VEOR V2.B16, V2.B16, V2.B16
+	VEOR V3.B16, V3.B16, V3.B16
+
+loop16:
+	CMP  $16, R2
+	BLT  loop4
+	VLD1.P 64(R0), [V4.S4, V5.S4, V6.S4, V7.S4]
+	VLD1.P 64(R1), [V8.S4, V9.S4, V10.S4, V11.S4]
+	VFMLA  V8.S4, V4.S4, V0.S4
+	VFMLA  V9.S4, V5.S4, V1.S4
+	VFMLA  V10.S4, V6.S4, V2.S4
+	VFMLA  V11.S4, V7.S4, V3.S4
+	SUB    $16, R2
+	B      loop16
+
+loop4:
+	CMP  $4, R2
+	BLT  reduce
+	VLD1.P 16(R0), [V4.S4]
+	VLD1.P 16(R1), [V8.S4]
+	VFMLA  V8.S4, V4.S4, V0.S4
+	SUB    $4, R2
+	B      loop4
+
+reduce:
+	VFADD  V1.S4, V0.S4, V0.S4
+	VFADD  V3.S4, V2.S4, V2.S4
+	VFADD  V2.S4, V0.S4, V0.S4
+	VFADDP V0.S4, V0.S4, V0.S4
+	VFADDP V0.S4, V0.S4, V0.S4
+
+tail:
+	CBZ    R2, done
+	FMOVS  (R0), F4
+	FMOVS  (R1), F5
+	FMADDS F4, F0, F5, F0
+	ADD    $4, R0
+	ADD    $4, R1
+	SUB    $1, R2
+	B      tail
+
+done:
+	FMOVS F0, ret+48(FP)
+	RET
+
+// func l2SquaredNEON(a, b []float32) float32
+TEXT ·l2SquaredNEON(SB), NOSPLIT, $0-52
+	MOVD a_base+0(FP), R0
+	MOVD b_base+24(FP), R1
+	MOVD a_len+8(FP), R2
+	VEOR V0.B16, V0.B16, V0.B16
+	VEOR V1.B16, V1.B16, V1.B16
+	VEOR V2.B16, V2.B16, V2.B16
+	VEOR V3.B16, V3.B16, V3.B16
+
+loop16:
+	CMP  $16, R2
+	BLT  loop4
+	VLD1.P 64(R0), [V4.S4, V5.S4, V6.S4, V7.S4]
+	VLD1.P 64(R1), [V8.S4, V9.S4, V10.S4, V11.S4]
+	VFSUB  V8.S4, V4.S4, V4.S4
+	VFSUB  V9.S4, V5.S4, V5.S4
+	VFSUB  V10.S4, V6.S4, V6.S4
+	VFSUB  V11.S4, V7.S4, V7.S4
+	VFMLA  V4.S4, V4.S4, V0.S4
+	VFMLA  V5.S4, V5.S4, V1.S4
+	VFMLA  V6.S4, V6.S4, V2.S4
+	VFMLA  V7.S4, V7.S4, V3.S4
+	SUB    $16, R2
+	B      loop16
+
+loop4:
+	CMP  $4, R2
+	BLT  reduce
+	VLD1.P 16(R0), [V4.S4]
+	VLD1.P 16(R1), [V8.S4]
+	VFSUB  V8.S4, V4.S4, V4.S4
+	VFMLA  V4.S4, V4.S4, V0.S4
+	SUB    $4, R2
+	B      loop4
+
+reduce:
+	VFADD  V1.S4, V0.S4, V0.S4
+	VFADD  V3.S4, V2.S4, V2.S4
+	VFADD  V2.S4, V0.S4, V0.S4
+	VFADDP V0.S4, V0.S4, V0.S4
+	VFADDP V0.S4, V0.S4, V0.S4
+
+tail:
+	CBZ    R2, done
+	FMOVS  (R0), F4
+	FMOVS  (R1), F5
+	FSUBS  F5, F4, F4
+	FMADDS F4, F0, F4, F0
+	ADD    $4, R0
+	ADD    $4, R1
+	SUB    $1, R2
+	B      tail
+
+done:
+	FMOVS F0, ret+48(FP)
+	RET
+
+// func sumSquaresNEON(a []float32) float32
+TEXT ·sumSquaresNEON(SB), NOSPLIT, $0-28
+	MOVD a_base+0(FP), R0
+	MOVD a_len+8(FP), R2
+	VEOR V0.B16, V0.B16, V0.B16
+	VEOR V1.B16, V1.B16, V1.B16
+	VEOR V2.B16, V2.B16, V2.B16
+	VEOR V3.B16, V3.B16, V3.B16
+
+loop16:
+	CMP  $16, R2
+	BLT  loop4
+	VLD1.P 64(R0), [V4.S4, V5.S4, V6.S4, V7.S4]
+	VFMLA  V4.S4, V4.S4, V0.S4
+	VFMLA  V5.S4, V5.S4, V1.S4
+	VFMLA  V6.S4, V6.S4, V2.S4
+	VFMLA  V7.S4, V7.S4, V3.S4
+	SUB    $16, R2
+	B      loop16
+
+loop4:
+	CMP  $4, R2
+	BLT  reduce
+	VLD1.P 16(R0), [V4.S4]
+	VFMLA  V4.S4, V4.S4, V0.S4
+	SUB    $4, R2
+	B      loop4
+
+reduce:
+	VFADD  V1.S4, V0.S4, V0.S4
+	VFADD  V3.S4, V2.S4, V2.S4
+	VFADD  V2.S4, V0.S4, V0.S4
+	VFADDP V0.S4, V0.S4, V0.S4
+	VFADDP V0.S4, V0.S4, V0.S4
+
+tail:
+	CBZ    R2, done
+	FMOVS  (R0), F4
+	FMADDS F4, F0, F4, F0
+	ADD    $4, R0
+	SUB    $1, R2
+	B      tail
+
+done:
+	FMOVS F0, ret+24(FP)
+	RET
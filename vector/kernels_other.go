@@ -0,0 +1,7 @@
+//go:build purego || !(amd64 || arm64)
+
+package vector
+
+// simdKernels returns no kernels: there are no assembly kernels for this
+// architecture, or they were disabled with the purego build tag.
+func simdKernels() []kernels { return nil }
@@ -0,0 +1,144 @@
+package vector
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/ldaidone/goembedx/vector/internal"
+)
+
+// allKernels returns the generic kernels and every SIMD kernel set the CPU
+// supports, so that each is tested, not only the one Dot dispatches to.
+func allKernels() []kernels {
+	return append([]kernels{genericKernels}, simdKernels()...)
+}
+
+// kernelTolerance returns how far two sums of the n products p[i] may differ
+// when each is rounded in a different order: twice the worst-case float32
+// error of a sum of n terms whose magnitudes add up to sumAbs.
+func kernelTolerance(n int, sumAbs float64) float64 {
+	const u = 1.0 / (1 << 24)
+	return 2*float64(n+1)*u*sumAbs + 1e-30
+}
+
+// fuzzVectors returns two vectors of n components in [-scale, scale) drawn
+// from seed, with n capped at 4096.
+func fuzzVectors(seed int64, n uint16, scale float32) (a, b []float32) {
+	rng := rand.New(rand.NewSource(seed))
+	size := int(n % 4097)
+	a, b = make([]float32, size), make([]float32, size)
+	for i := range a {
+		a[i] = (rng.Float32()*2 - 1) * scale
+		b[i] = (rng.Float32()*2 - 1) * scale
+	}
+	return a, b
+}
+
+// addKernelSeeds adds lengths around every loop boundary of the kernels.
+func addKernelSeeds(f *testing.F) {
+	for _, n := range []uint16{0, 1, 3, 4, 7, 8, 15, 16, 17, 31, 32, 33, 63, 64, 65, 100, 127, 513, 1000, 4096} {
+		f.Add(int64(n), n, float32(1))
+	}
+	f.Add(int64(1), uint16(300), float32(1e-3))
+	f.Add(int64(2), uint16(300), float32(1e4))
+}
+
+func FuzzDot(f *testing.F) {
+	addKernelSeeds(f)
+	f.Fuzz(func(t *testing.T, seed int64, n uint16, scale float32) {
+		if !(scale > 0 && scale < 1e15) {
+			t.Skip()
+		}
+		a, b := fuzzVectors(seed, n, scale)
+		want := internal.DotGeneric(a, b)
+		var sumAbs float64
+		for i := range a {
+			sumAbs += math.Abs(float64(a[i]) * float64(b[i]))
+		}
+		tol := kernelTolerance(len(a), sumAbs)
+		for _, k := range allKernels() {
+			if got := k.dot(a, b); math.Abs(float64(got)-float64(want)) > tol {
+				t.Fatalf("%s: dot of %d components: expected %v within %v, got %v", k.name, len(a), want, tol, got)
+			}
+		}
+		if got := Dot(a, b); math.Abs(float64(got)-float64(want)) > tol {
+			t.Fatalf("Dot of %d components: expected %v within %v, got %v", len(a), want, tol, got)
+		}
+	})
+}
+
+func FuzzL2Squared(f *testing.F) {
+	addKernelSeeds(f)
+	f.Fuzz(func(t *testing.T, seed int64, n uint16, scale float32) {
+		if !(scale > 0 && scale < 1e15) {
+			t.Skip()
+		}
+		a, b := fuzzVectors(seed, n, scale)
+		want := internal.L2SquaredGeneric(a, b)
+		var sumAbs float64
+		for i := range a {
+			d := float64(a[i] - b[i])
+			sumAbs += d * d
+		}
+		tol := kernelTolerance(len(a), sumAbs)
+		for _, k := range allKernels() {
+			if got := k.l2Squared(a, b); math.Abs(float64(got)-float64(want)) > tol {
+				t.Fatalf("%s: squared L2 of %d components: expected %v within %v, got %v", k.name, len(a), want, tol, got)
+			}
+		}
+	})
+}
+
+func FuzzNorm(f *testing.F) {
+	addKernelSeeds(f)
+	f.Fuzz(func(t *testing.T, seed int64, n uint16, scale float32) {
+		if !(scale > 0 && scale < 1e15) {
+			t.Skip()
+		}
+		a, _ := fuzzVectors(seed, n, scale)
+		want := internal.DotGeneric(a, a)
+		tol := kernelTolerance(len(a), float64(want))
+		for _, k := range allKernels() {
+			got := k.sumSquares(a)
+			if math.Abs(float64(got)-float64(want)) > tol {
+				t.Fatalf("%s: sum of squares of %d components: expected %v within %v, got %v", k.name, len(a), want, tol, got)
+			}
+			// Norm(a)² and Dot(a, a) must agree exactly, so that a vector
+			// always has a cosine of exactly 1 with itself.
+			if dot := k.dot(a, a); got != dot {
+				t.Fatalf("%s: sum of squares %v differs from the dot product with itself %v", k.name, got, dot)
+			}
+		}
+		if got, dot := Norm(a), Dot(a, a); got != float32(math.Sqrt(float64(dot))) {
+			t.Fatalf("Norm of %d components: expected sqrt(%v), got %v", len(a), dot, got)
+		}
+	})
+}
+
+func TestKernels(t *testing.T) {
+	want := "generic"
+	if ks := simdKernels(); len(ks) > 0 {
+		want = ks[0].name
+	}
+	if got := Kernels(); got != want {
+		t.Errorf("Expected the %s kernels, got %s", want, got)
+	}
+	for _, k := range simdKernels() {
+		switch k.name {
+		case "avx512", "avx2", "neon":
+		default:
+			t.Errorf("Unexpected kernels %q", k.name)
+		}
+	}
+	t.Logf("kernels: %s", Kernels())
+}
+
+func TestDotPanicsOnLengthMismatch(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic for vectors of different lengths")
+		}
+	}()
+	Dot([]float32{1, 2}, []float32{1})
+}
@@ -1,19 +1,18 @@
 // Package vector provides optimized mathematical operations for float32 vectors.
 // It includes SIMD-optimized implementations for common vector operations like dot
 // products, cosine similarity, and L2 norms. The package automatically selects
-// the best implementation based on CPU capabilities (AVX2, NEON, or generic).
+// the best implementation based on CPU capabilities (AVX-512, AVX2, NEON, or generic).
 package vector
 
 import "math"
 
 // Norm returns the L2 norm (Euclidean length) of a vector.
 // The L2 norm is calculated as the square root of the sum of the squares of its elements.
+// The squares are summed by the same kernel as Dot, so Norm(a) is the square
+// root of Dot(a, a).
 func Norm(a []float32) float32 {
-	var sum float32
-	// Sum the squares of the elements.
-	for i := 0; i < len(a); i++ {
-		sum += a[i] * a[i]
-	}
+	once.Do(initKernels)
+	sum := impl.sumSquares(a)
 
 	// The L2 norm is the square root of the sum of squares.
 	// We cast to float64 for math.Sqrt and then back to float32.
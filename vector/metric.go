@@ -0,0 +1,170 @@
+package vector
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Metric selects the similarity function used to rank vectors.
+// Every metric produces a score where higher values mean more similar vectors,
+// so results can always be sorted in descending order of score.
+type Metric int
+
+const (
+	// MetricCosine scores vectors by the cosine of the angle between them, in [-1, 1].
+	// It is the zero value and the default metric.
+	MetricCosine Metric = iota
+	// MetricDot scores vectors by their inner product.
+	// It suits models trained for maximum inner product search.
+	MetricDot
+	// MetricEuclidean scores vectors by 1 / (1 + d), where d is their L2 distance.
+	// Scores lie in (0, 1], with 1 meaning identical vectors.
+	MetricEuclidean
+	// MetricManhattan scores vectors by 1 / (1 + d), where d is their L1 distance.
+	// Scores lie in (0, 1], with 1 meaning identical vectors.
+	MetricManhattan
+	// MetricHamming scores vectors by 1 - h/n, where h is the number of differing
+	// components and n the dimension. Scores lie in [0, 1].
+	MetricHamming
+)
+
+// metricNames maps each metric to its canonical name.
+var metricNames = map[Metric]string{
+	MetricCosine:    "cosine",
+	MetricDot:       "dot",
+	MetricEuclidean: "euclidean",
+	MetricManhattan: "manhattan",
+	MetricHamming:   "hamming",
+}
+
+// String returns the canonical name of the metric, as accepted by ParseMetric.
+func (m Metric) String() string {
+	if name, ok := metricNames[m]; ok {
+		return name
+	}
+	return fmt.Sprintf("Metric(%d)", int(m))
+}
+
+// Valid reports whether m is one of the defined metrics.
+func (m Metric) Valid() bool {
+	_, ok := metricNames[m]
+	return ok
+}
+
+// ParseMetric returns the metric with the given name.
+// It accepts the canonical names as well as the aliases "ip", "inner", "l2" and "l1".
+// Matching is case-insensitive.
+func ParseMetric(name string) (Metric, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "cosine":
+		return MetricCosine, nil
+	case "dot", "ip", "inner":
+		return MetricDot, nil
+	case "euclidean", "l2":
+		return MetricEuclidean, nil
+	case "manhattan", "l1":
+		return MetricManhattan, nil
+	case "hamming":
+		return MetricHamming, nil
+	default:
+		return 0, fmt.Errorf("vector: unknown metric %q", name)
+	}
+}
+
+// Score returns the similarity of a and b under m. Higher values mean more similar vectors.
+// Cosine scores involving a zero-magnitude vector are 0.
+//
+// This function will panic if the vectors have different lengths or m is not a valid metric.
+func (m Metric) Score(a, b []float32) float32 {
+	if m == MetricCosine {
+		return m.ScoreNorms(a, b, Norm(a), Norm(b))
+	}
+	return m.ScoreNorms(a, b, 0, 0)
+}
+
+// ScoreNorms is like Score but takes the precomputed L2 norms of a and b.
+// The norms are only used by MetricCosine, so stores that keep norms alongside
+// their vectors avoid recomputing them on every comparison.
+//
+// This function will panic if the vectors have different lengths or m is not a valid metric.
+func (m Metric) ScoreNorms(a, b []float32, na, nb float32) float32 {
+	if len(a) != len(b) {
+		panic("vector: Score requires vectors of equal length")
+	}
+	switch m {
+	case MetricCosine:
+		if na == 0 || nb == 0 {
+			return 0
+		}
+		return Dot(a, b) / (na * nb)
+	case MetricDot:
+		return Dot(a, b)
+	case MetricEuclidean:
+		return 1 / (1 + Euclidean(a, b))
+	case MetricManhattan:
+		return 1 / (1 + Manhattan(a, b))
+	case MetricHamming:
+		if len(a) == 0 {
+			return 1
+		}
+		return 1 - float32(Hamming(a, b))/float32(len(a))
+	default:
+		panic(fmt.Sprintf("vector: unknown metric %d", int(m)))
+	}
+}
+
+// L2Squared returns the squared Euclidean distance between two vectors.
+// It avoids the square root of Euclidean when only the ordering of distances matters.
+//
+// This function will panic if the vectors have different lengths.
+func L2Squared(a, b []float32) float32 {
+	if len(a) != len(b) {
+		panic("vector: L2Squared requires vectors of equal length")
+	}
+	once.Do(initKernels)
+	return impl.l2Squared(a, b)
+}
+
+// Euclidean returns the Euclidean (L2) distance between two vectors.
+//
+// This function will panic if the vectors have different lengths.
+func Euclidean(a, b []float32) float32 {
+	return float32(math.Sqrt(float64(L2Squared(a, b))))
+}
+
+// Manhattan returns the Manhattan (L1) distance between two vectors,
+// the sum of the absolute differences of their components.
+//
+// This function will panic if the vectors have different lengths.
+func Manhattan(a, b []float32) float32 {
+	if len(a) != len(b) {
+		panic("vector: Manhattan requires vectors of equal length")
+	}
+	var sum float32
+	for i := range a {
+		d := a[i] - b[i]
+		if d < 0 {
+			d = -d
+		}
+		sum += d
+	}
+	return sum
+}
+
+// Hamming returns the number of components in which two vectors differ.
+// It is intended for binary features stored as 0/1 values.
+//
+// This function will panic if the vectors have different lengths.
+func Hamming(a, b []float32) int {
+	if len(a) != len(b) {
+		panic("vector: Hamming requires vectors of equal length")
+	}
+	n := 0
+	for i := range a {
+		if a[i] != b[i] {
+			n++
+		}
+	}
+	return n
+}
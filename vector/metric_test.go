@@ -0,0 +1,110 @@
+package vector
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDistanceKernels(t *testing.T) {
+	a := []float32{1, 2, 3}
+	b := []float32{4, -2, 3}
+
+	// diff = (-3, 4, 0)
+	if got := L2Squared(a, b); got != 25 {
+		t.Fatalf("L2Squared expected 25, got %v", got)
+	}
+	if got := Euclidean(a, b); got != 5 {
+		t.Fatalf("Euclidean expected 5, got %v", got)
+	}
+	if got := Manhattan(a, b); got != 7 {
+		t.Fatalf("Manhattan expected 7, got %v", got)
+	}
+	if got := Hamming(a, b); got != 2 {
+		t.Fatalf("Hamming expected 2, got %v", got)
+	}
+}
+
+func TestMetricScore(t *testing.T) {
+	a := []float32{1, 0, 1, 0}
+	b := []float32{1, 1, 0, 0}
+	zero := []float32{0, 0, 0, 0}
+
+	tests := []struct {
+		name   string
+		metric Metric
+		a, b   []float32
+		want   float32
+	}{
+		{"cosine identical", MetricCosine, a, a, 1},
+		{"cosine partial", MetricCosine, a, b, 0.5},
+		{"cosine zero vector", MetricCosine, a, zero, 0},
+		{"dot", MetricDot, a, b, 1},
+		{"euclidean identical", MetricEuclidean, a, a, 1},
+		{"euclidean", MetricEuclidean, []float32{0, 0}, []float32{3, 4}, 1.0 / 6},
+		{"manhattan identical", MetricManhattan, a, a, 1},
+		{"manhattan", MetricManhattan, []float32{0, 0}, []float32{3, -4}, 1.0 / 8},
+		{"hamming identical", MetricHamming, a, a, 1},
+		{"hamming", MetricHamming, a, b, 0.5},
+		{"hamming empty", MetricHamming, nil, nil, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.metric.Score(tt.a, tt.b)
+			if math.Abs(float64(got-tt.want)) > 1e-6 {
+				t.Errorf("Score() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetricScoreOrdering(t *testing.T) {
+	// Higher scores must always mean more similar vectors.
+	tests := []struct {
+		metric           Metric
+		query, near, far []float32
+	}{
+		{MetricCosine, []float32{1, 1}, []float32{1, 0.9}, []float32{-1, 3}},
+		{MetricDot, []float32{1, 1}, []float32{2, 2}, []float32{0.1, 0.1}},
+		{MetricEuclidean, []float32{1, 1}, []float32{1, 0.9}, []float32{-1, 3}},
+		{MetricManhattan, []float32{1, 1}, []float32{1, 0.9}, []float32{-1, 3}},
+		{MetricHamming, []float32{1, 0, 1}, []float32{1, 0, 0}, []float32{0, 1, 0}},
+	}
+
+	for _, tt := range tests {
+		if tt.metric.Score(tt.query, tt.near) <= tt.metric.Score(tt.query, tt.far) {
+			t.Errorf("%s: expected near vector to score higher", tt.metric)
+		}
+	}
+}
+
+func TestParseMetric(t *testing.T) {
+	for _, m := range []Metric{MetricCosine, MetricDot, MetricEuclidean, MetricManhattan, MetricHamming} {
+		got, err := ParseMetric(m.String())
+		if err != nil || got != m {
+			t.Errorf("ParseMetric(%q) = %v, %v", m.String(), got, err)
+		}
+		if !m.Valid() {
+			t.Errorf("%s should be valid", m)
+		}
+	}
+
+	if got, err := ParseMetric(" L2 "); err != nil || got != MetricEuclidean {
+		t.Errorf("ParseMetric alias failed: %v, %v", got, err)
+	}
+	if _, err := ParseMetric("chebyshev"); err == nil {
+		t.Error("Expected error for unknown metric, got nil")
+	}
+	if Metric(42).Valid() || Metric(42).String() != "Metric(42)" {
+		t.Errorf("unexpected behavior for invalid metric: %s", Metric(42))
+	}
+}
+
+func TestMetricScorePanicsOnLengthMismatch(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic for mismatched lengths")
+		}
+	}()
+	MetricDot.Score([]float32{1}, []float32{1, 2})
+}
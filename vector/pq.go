@@ -0,0 +1,427 @@
+package vector
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// PQConfig holds the training parameters of a ProductQuantizer.
+type PQConfig struct {
+	// Subspaces is the number of sub-vectors each vector is split into,
+	// and the size in bytes of its code. It must divide the dimension.
+	Subspaces int
+	// Centroids is the number of centroids of each sub-vector codebook,
+	// at most 256. Fewer are trained when the sample is smaller.
+	Centroids int
+	// Iterations bounds the number of k-means iterations per codebook.
+	Iterations int
+	// Seed initializes the random generator used to pick initial centroids.
+	Seed int64
+}
+
+// DefaultPQConfig provides reasonable default values for general-purpose embeddings.
+// Subspaces should be raised for higher-dimensional vectors to preserve recall.
+var DefaultPQConfig = PQConfig{
+	Subspaces:  8,
+	Centroids:  256,
+	Iterations: 25,
+	Seed:       1,
+}
+
+// pqVersion is the encoding version written by ProductQuantizer.MarshalBinary.
+const pqVersion = 1
+
+// ProductQuantizer compresses vectors by splitting them into Subspaces
+// sub-vectors and replacing each with the index of its nearest centroid in
+// a per-subspace codebook trained with k-means. Codes are one byte per
+// subspace. Queries are scored against codes with asymmetric distance
+// computation: the query stays in full precision and its distance to every
+// centroid is tabulated once per query by Table.
+type ProductQuantizer struct {
+	// subspaces is the number of sub-vectors.
+	subspaces int
+	// centroids is the number of centroids per codebook.
+	centroids int
+	// dsub is the dimension of each sub-vector.
+	dsub int
+	// codebooks holds the centroids of subspace m at
+	// codebooks[(m*centroids+c)*dsub:], one after the other.
+	codebooks []float32
+}
+
+// TrainProductQuantizer trains the codebooks of a product quantizer on sample.
+// Returns an error if sample is empty, the vectors have different or zero
+// dimensions, or cfg is invalid for the dimension.
+func TrainProductQuantizer(sample [][]float32, cfg PQConfig) (*ProductQuantizer, error) {
+	if len(sample) == 0 {
+		return nil, errors.New("vector: cannot train a quantizer without vectors")
+	}
+	dim := len(sample[0])
+	switch {
+	case dim == 0:
+		return nil, errors.New("vector: cannot train a quantizer on empty vectors")
+	case cfg.Subspaces <= 0 || dim%cfg.Subspaces != 0:
+		return nil, fmt.Errorf("vector: %d subspaces do not divide dimension %d", cfg.Subspaces, dim)
+	case cfg.Centroids <= 0 || cfg.Centroids > 256:
+		return nil, fmt.Errorf("vector: centroids must be in [1, 256], got %d", cfg.Centroids)
+	case cfg.Iterations <= 0:
+		return nil, errors.New("vector: iterations must be positive")
+	}
+	for _, v := range sample {
+		if len(v) != dim {
+			return nil, errors.New("vector: cannot train a quantizer on vectors of different lengths")
+		}
+	}
+
+	pq := &ProductQuantizer{
+		subspaces: cfg.Subspaces,
+		centroids: min(cfg.Centroids, len(sample)),
+		dsub:      dim / cfg.Subspaces,
+	}
+	pq.codebooks = make([]float32, pq.subspaces*pq.centroids*pq.dsub)
+	rng := rand.New(rand.NewSource(cfg.Seed))
+	points := make([][]float32, len(sample))
+	for m := 0; m < pq.subspaces; m++ {
+		for i, v := range sample {
+			points[i] = v[m*pq.dsub : (m+1)*pq.dsub]
+		}
+		kmeans(pq.codebook(m), points, pq.centroids, pq.dsub, cfg.Iterations, rng)
+	}
+	return pq, nil
+}
+
+// KMeans clusters points into k centroids with Lloyd's algorithm, starting
+// from k-means++ seeding with a random generator initialized with seed,
+// and returns the centroids. At most iterations rounds of assignment are run.
+//
+// This function will panic if k is not in [1, len(points)] or the points
+// have different dimensions.
+func KMeans(points [][]float32, k, iterations int, seed int64) [][]float32 {
+	if k <= 0 || k > len(points) {
+		panic("vector: KMeans requires 1 <= k <= len(points)")
+	}
+	d := len(points[0])
+	flat := make([]float32, k*d)
+	kmeans(flat, points, k, d, iterations, rand.New(rand.NewSource(seed)))
+	return splitCentroids(flat, k, d)
+}
+
+// RefineKMeans runs up to iterations rounds of Lloyd's algorithm on points
+// starting from centroids, which are updated in place. Centroids left without
+// points are reseeded with a point of the largest cluster, chosen with a
+// random generator initialized with seed.
+//
+// This function will panic if centroids is empty or the centroids and points
+// have different dimensions.
+func RefineKMeans(centroids, points [][]float32, iterations int, seed int64) {
+	if len(centroids) == 0 {
+		panic("vector: RefineKMeans requires at least one centroid")
+	}
+	k, d := len(centroids), len(centroids[0])
+	flat := make([]float32, 0, k*d)
+	for _, c := range centroids {
+		if len(c) != d {
+			panic("vector: RefineKMeans requires centroids of equal length")
+		}
+		flat = append(flat, c...)
+	}
+	lloyd(flat, points, k, d, iterations, rand.New(rand.NewSource(seed)))
+	for i, c := range splitCentroids(flat, k, d) {
+		copy(centroids[i], c)
+	}
+}
+
+// splitCentroids returns the k centroids of dimension d stored one after the
+// other in flat, as subslices of flat.
+func splitCentroids(flat []float32, k, d int) [][]float32 {
+	out := make([][]float32, k)
+	for c := range out {
+		out[c] = flat[c*d : (c+1)*d : (c+1)*d]
+	}
+	return out
+}
+
+// kmeans clusters points into k centroids of dimension d, written to centroids,
+// with Lloyd's algorithm starting from k points chosen by k-means++ seeding:
+// each point is picked with a probability proportional to its squared
+// distance to the nearest centroid already chosen.
+func kmeans(centroids []float32, points [][]float32, k, d, iterations int, rng *rand.Rand) {
+	copy(centroids[:d], points[rng.Intn(len(points))])
+	dist := make([]float64, len(points))
+	for i, p := range points {
+		dist[i] = float64(L2Squared(p, centroids[:d]))
+	}
+	for c := 1; c < k; c++ {
+		var total float64
+		for _, x := range dist {
+			total += x
+		}
+		pick := rng.Intn(len(points))
+		if total > 0 {
+			r := rng.Float64() * total
+			for i, x := range dist {
+				if r -= x; r < 0 {
+					pick = i
+					break
+				}
+			}
+		}
+		centroid := centroids[c*d : (c+1)*d]
+		copy(centroid, points[pick])
+		for i, p := range points {
+			dist[i] = min(dist[i], float64(L2Squared(p, centroid)))
+		}
+	}
+	lloyd(centroids, points, k, d, iterations, rng)
+}
+
+// lloyd refines the k centroids of dimension d in centroids with up to
+// iterations rounds of Lloyd's algorithm on points.
+func lloyd(centroids []float32, points [][]float32, k, d, iterations int, rng *rand.Rand) {
+	assign := make([]int, len(points))
+	for i := range assign {
+		assign[i] = -1
+	}
+	sums := make([]float32, k*d)
+	counts := make([]int, k)
+	for it := 0; it < iterations; it++ {
+		changed := false
+		for i, p := range points {
+			if len(p) != d {
+				panic("vector: k-means requires points of the centroids' dimension")
+			}
+			if c := nearest(centroids, p, k, d); c != assign[i] {
+				assign[i], changed = c, true
+			}
+		}
+		if !changed {
+			return
+		}
+
+		clear(sums)
+		clear(counts)
+		for i, p := range points {
+			c := assign[i]
+			counts[c]++
+			for j, x := range p {
+				sums[c*d+j] += x
+			}
+		}
+		largest := 0
+		for c := range counts {
+			if counts[c] > counts[largest] {
+				largest = c
+			}
+		}
+		for c := 0; c < k; c++ {
+			if counts[c] == 0 {
+				// Reseed an empty cluster with a point of the largest one,
+				// splitting it on the next iteration.
+				copy(centroids[c*d:(c+1)*d], points[randomMember(assign, largest, counts[largest], rng)])
+				continue
+			}
+			for j := 0; j < d; j++ {
+				centroids[c*d+j] = sums[c*d+j] / float32(counts[c])
+			}
+		}
+	}
+}
+
+// randomMember returns the index of a random point assigned to cluster c,
+// which has n points.
+func randomMember(assign []int, c, n int, rng *rand.Rand) int {
+	target := rng.Intn(n)
+	for i, a := range assign {
+		if a != c {
+			continue
+		}
+		if target == 0 {
+			return i
+		}
+		target--
+	}
+	return 0
+}
+
+// nearest returns the index of the centroid closest to p in L2 distance.
+func nearest(centroids, p []float32, k, d int) int {
+	best, bestDist := 0, float32(math.Inf(1))
+	for c := 0; c < k; c++ {
+		if dist := L2Squared(p, centroids[c*d:(c+1)*d]); dist < bestDist {
+			best, bestDist = c, dist
+		}
+	}
+	return best
+}
+
+// codebook returns the centroids of subspace m.
+func (pq *ProductQuantizer) codebook(m int) []float32 {
+	n := pq.centroids * pq.dsub
+	return pq.codebooks[m*n : (m+1)*n]
+}
+
+// Dim returns the dimension of the vectors the quantizer was trained on.
+func (pq *ProductQuantizer) Dim() int { return pq.subspaces * pq.dsub }
+
+// Subspaces returns the number of subspaces, which is the size of a code in bytes.
+func (pq *ProductQuantizer) Subspaces() int { return pq.subspaces }
+
+// Centroids returns the number of centroids per codebook.
+func (pq *ProductQuantizer) Centroids() int { return pq.centroids }
+
+// Encode quantizes v into dst, growing it if needed, and returns it.
+//
+// This function will panic if v does not have the quantizer's dimension.
+func (pq *ProductQuantizer) Encode(dst []byte, v []float32) []byte {
+	if len(v) != pq.Dim() {
+		panic("vector: Encode requires a vector of the quantizer's dimension")
+	}
+	if cap(dst) < pq.subspaces {
+		dst = make([]byte, pq.subspaces)
+	}
+	dst = dst[:pq.subspaces]
+	for m := range dst {
+		dst[m] = byte(nearest(pq.codebook(m), v[m*pq.dsub:(m+1)*pq.dsub], pq.centroids, pq.dsub))
+	}
+	return dst
+}
+
+// Decode reconstructs the vector of code into dst, growing it if needed, and returns it.
+//
+// This function will panic if code does not have one byte per subspace.
+func (pq *ProductQuantizer) Decode(dst []float32, code []byte) []float32 {
+	if len(code) != pq.subspaces {
+		panic("vector: Decode requires one code byte per subspace")
+	}
+	if cap(dst) < pq.Dim() {
+		dst = make([]float32, pq.Dim())
+	}
+	dst = dst[:pq.Dim()]
+	for m, c := range code {
+		copy(dst[m*pq.dsub:], pq.codebook(m)[int(c)*pq.dsub:(int(c)+1)*pq.dsub])
+	}
+	return dst
+}
+
+// MarshalBinary encodes the quantizer in a versioned little-endian layout.
+func (pq *ProductQuantizer) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 0, 13+4*len(pq.codebooks))
+	b = append(b, pqVersion)
+	b = binary.LittleEndian.AppendUint32(b, uint32(pq.subspaces))
+	b = binary.LittleEndian.AppendUint32(b, uint32(pq.centroids))
+	b = binary.LittleEndian.AppendUint32(b, uint32(pq.dsub))
+	for _, x := range pq.codebooks {
+		b = binary.LittleEndian.AppendUint32(b, math.Float32bits(x))
+	}
+	return b, nil
+}
+
+// UnmarshalBinary decodes a quantizer encoded by MarshalBinary.
+func (pq *ProductQuantizer) UnmarshalBinary(b []byte) error {
+	if len(b) < 13 {
+		return errors.New("vector: quantizer encoding is truncated")
+	}
+	if b[0] != pqVersion {
+		return fmt.Errorf("vector: unsupported quantizer version %d", b[0])
+	}
+	subspaces := binary.LittleEndian.Uint32(b[1:])
+	centroids := binary.LittleEndian.Uint32(b[5:])
+	dsub := binary.LittleEndian.Uint32(b[9:])
+	if centroids == 0 || centroids > 256 {
+		return fmt.Errorf("vector: invalid centroid count %d", centroids)
+	}
+	if n := uint64(subspaces) * uint64(centroids) * uint64(dsub); uint64(len(b)-13) != 4*n {
+		return errors.New("vector: quantizer encoding is truncated")
+	}
+
+	pq.subspaces, pq.centroids, pq.dsub = int(subspaces), int(centroids), int(dsub)
+	pq.codebooks = make([]float32, (len(b)-13)/4)
+	for i := range pq.codebooks {
+		pq.codebooks[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[13+4*i:]))
+	}
+	return nil
+}
+
+// ADCTable scores product-quantized codes against a full-precision query.
+// It holds the contribution of every centroid of every subspace to the
+// score, so that scoring a code takes one lookup per subspace.
+// Cosine scores use the full-precision norms kept next to the codes.
+type ADCTable struct {
+	metric Metric
+	// dim is the dimension of the vectors, for Hamming scores.
+	dim       int
+	centroids int
+	// norm is the L2 norm of the query.
+	norm float32
+	// table holds the partial score of centroid c of subspace m at m*centroids+c:
+	// the dot product for cosine and dot, the squared L2 distance for
+	// Euclidean, the L1 distance for Manhattan, and the number of
+	// differing components for Hamming.
+	table []float32
+}
+
+// Table builds the lookup table of query under m.
+//
+// This function will panic if query does not have the quantizer's dimension.
+func (pq *ProductQuantizer) Table(m Metric, query []float32) *ADCTable {
+	if len(query) != pq.Dim() {
+		panic("vector: Table requires a query of the quantizer's dimension")
+	}
+	t := &ADCTable{
+		metric:    m,
+		dim:       len(query),
+		centroids: pq.centroids,
+		norm:      Norm(query),
+		table:     make([]float32, pq.subspaces*pq.centroids),
+	}
+	for s := 0; s < pq.subspaces; s++ {
+		q := query[s*pq.dsub : (s+1)*pq.dsub]
+		book := pq.codebook(s)
+		for c := 0; c < pq.centroids; c++ {
+			centroid := book[c*pq.dsub : (c+1)*pq.dsub]
+			var partial float32
+			switch m {
+			case MetricCosine, MetricDot:
+				partial = Dot(q, centroid)
+			case MetricEuclidean:
+				partial = L2Squared(q, centroid)
+			case MetricManhattan:
+				partial = Manhattan(q, centroid)
+			case MetricHamming:
+				partial = float32(Hamming(q, centroid))
+			default:
+				panic(fmt.Sprintf("vector: unknown metric %d", int(m)))
+			}
+			t.table[s*pq.centroids+c] = partial
+		}
+	}
+	return t
+}
+
+// Score returns the approximate similarity of the query and the vector
+// quantized as code, whose full-precision L2 norm is norm.
+// Cosine scores involving a zero-magnitude vector are 0.
+func (t *ADCTable) Score(code []byte, norm float32) float32 {
+	var sum float32
+	for m, c := range code {
+		sum += t.table[m*t.centroids+int(c)]
+	}
+	switch t.metric {
+	case MetricCosine:
+		if t.norm == 0 || norm == 0 {
+			return 0
+		}
+		return sum / (t.norm * norm)
+	case MetricDot:
+		return sum
+	case MetricEuclidean:
+		return 1 / (1 + float32(math.Sqrt(float64(sum))))
+	case MetricManhattan:
+		return 1 / (1 + sum)
+	default:
+		return 1 - sum/float32(t.dim)
+	}
+}
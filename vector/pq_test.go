@@ -0,0 +1,174 @@
+package vector
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// clusteredVecs returns n vectors of dimension dim drawn around a few centers.
+func clusteredVecs(rng *rand.Rand, n, dim int) [][]float32 {
+	centers := make([][]float32, 4)
+	for i := range centers {
+		centers[i] = make([]float32, dim)
+		for j := range centers[i] {
+			centers[i][j] = float32(rng.NormFloat64() * 3)
+		}
+	}
+	vecs := make([][]float32, n)
+	for i := range vecs {
+		c := centers[i%len(centers)]
+		vecs[i] = make([]float32, dim)
+		for j := range vecs[i] {
+			vecs[i][j] = c[j] + float32(rng.NormFloat64()*0.1)
+		}
+	}
+	return vecs
+}
+
+func TestProductQuantizerRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	vecs := clusteredVecs(rng, 200, 16)
+
+	pq, err := TrainProductQuantizer(vecs, PQConfig{Subspaces: 4, Centroids: 16, Iterations: 10, Seed: 1})
+	if err != nil {
+		t.Fatalf("TrainProductQuantizer failed: %v", err)
+	}
+	if pq.Dim() != 16 || pq.Subspaces() != 4 || pq.Centroids() != 16 {
+		t.Fatalf("Unexpected quantizer shape: dim %d, %d x %d", pq.Dim(), pq.Subspaces(), pq.Centroids())
+	}
+
+	code := pq.Encode(nil, vecs[5])
+	if len(code) != 4 {
+		t.Fatalf("Expected a 4-byte code, got %d bytes", len(code))
+	}
+	// Clustered vectors reconstruct close to their cluster.
+	if d := Euclidean(pq.Decode(nil, code), vecs[5]); d > 1 {
+		t.Errorf("Expected reconstruction within 1 of the vector, got distance %v", d)
+	}
+}
+
+func TestTrainProductQuantizerErrors(t *testing.T) {
+	vecs := [][]float32{{1, 2, 3, 4}, {4, 3, 2, 1}}
+	tests := []PQConfig{
+		{Subspaces: 3, Centroids: 2, Iterations: 1},
+		{Subspaces: 0, Centroids: 2, Iterations: 1},
+		{Subspaces: 2, Centroids: 257, Iterations: 1},
+		{Subspaces: 2, Centroids: 2, Iterations: 0},
+	}
+	for _, cfg := range tests {
+		if _, err := TrainProductQuantizer(vecs, cfg); err == nil {
+			t.Errorf("%+v: expected error, got nil", cfg)
+		}
+	}
+	if _, err := TrainProductQuantizer(nil, DefaultPQConfig); err == nil {
+		t.Error("Expected error for no vectors, got nil")
+	}
+
+	// Fewer vectors than centroids train one centroid per vector.
+	pq, err := TrainProductQuantizer(vecs, PQConfig{Subspaces: 2, Centroids: 256, Iterations: 5})
+	if err != nil || pq.Centroids() != 2 {
+		t.Fatalf("Expected 2 centroids, got %v, %v", pq, err)
+	}
+	if got := pq.Decode(nil, pq.Encode(nil, vecs[1])); !equalFloats(got, vecs[1]) {
+		t.Errorf("Expected exact reconstruction, got %v", got)
+	}
+}
+
+func TestProductQuantizerMarshal(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	vecs := clusteredVecs(rng, 50, 8)
+	pq, _ := TrainProductQuantizer(vecs, PQConfig{Subspaces: 2, Centroids: 8, Iterations: 5})
+
+	b, err := pq.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+	var got ProductQuantizer
+	if err := got.UnmarshalBinary(b); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+	if !equalFloats(got.Decode(nil, got.Encode(nil, vecs[3])), pq.Decode(nil, pq.Encode(nil, vecs[3]))) {
+		t.Error("Expected the unmarshaled quantizer to match the original")
+	}
+	if err := got.UnmarshalBinary(b[:len(b)-4]); err == nil {
+		t.Error("Expected error for truncated encoding, got nil")
+	}
+}
+
+func TestADCTable(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	vecs := clusteredVecs(rng, 100, 12)
+	pq, _ := TrainProductQuantizer(vecs, PQConfig{Subspaces: 3, Centroids: 32, Iterations: 10})
+	query := vecs[10]
+
+	// ADC scores match the exact scores of the reconstructed vectors.
+	for m := range metricNames {
+		table := pq.Table(m, query)
+		for _, v := range vecs[:10] {
+			code := pq.Encode(nil, v)
+			decoded := pq.Decode(nil, code)
+			want := m.ScoreNorms(query, decoded, Norm(query), Norm(decoded))
+			if got := table.Score(code, Norm(decoded)); math.Abs(float64(got-want)) > 1e-3*max(1, math.Abs(float64(want))) {
+				t.Errorf("%s: expected %v, got %v", m, want, got)
+			}
+		}
+	}
+}
+
+func TestKMeans(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	points := clusteredVecs(rng, 400, 8)
+
+	// Four well-separated clusters are recovered: every point is close to
+	// its nearest centroid.
+	centroids := KMeans(points, 4, 25, 1)
+	if len(centroids) != 4 {
+		t.Fatalf("Expected 4 centroids, got %d", len(centroids))
+	}
+	for i, p := range points {
+		best := float32(math.Inf(1))
+		for _, c := range centroids {
+			best = min(best, L2Squared(p, c))
+		}
+		if best > 1 {
+			t.Fatalf("point %d is %v from its nearest centroid", i, best)
+		}
+	}
+
+	// Refining from a degenerate start reseeds the empty clusters, so that
+	// every centroid ends up with points.
+	start := make([][]float32, 4)
+	for i := range start {
+		start[i] = append([]float32(nil), points[0]...)
+	}
+	RefineKMeans(start, points, 25, 1)
+	counts := make([]int, len(start))
+	for _, p := range points {
+		best, bestDist := 0, float32(math.Inf(1))
+		for c, centroid := range start {
+			if dist := L2Squared(p, centroid); dist < bestDist {
+				best, bestDist = c, dist
+			}
+		}
+		counts[best]++
+	}
+	for c, n := range counts {
+		if n == 0 {
+			t.Errorf("centroid %d has no points after refining: %v", c, counts)
+		}
+	}
+}
+
+func BenchmarkADCTableScore(b *testing.B) {
+	rng := rand.New(rand.NewSource(4))
+	vecs := clusteredVecs(rng, 1000, 768)
+	pq, _ := TrainProductQuantizer(vecs, PQConfig{Subspaces: 96, Centroids: 256, Iterations: 5})
+	table := pq.Table(MetricCosine, vecs[0])
+	code := pq.Encode(nil, vecs[1])
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = table.Score(code, 1)
+	}
+}
@@ -0,0 +1,317 @@
+package vector
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// Calibration selects how a ScalarQuantizer derives the value range it maps onto int8 codes.
+type Calibration int
+
+const (
+	// CalibratePerDimension uses the minimum and maximum of each dimension.
+	// It preserves more precision when dimensions have different ranges and
+	// is the zero value and the default.
+	CalibratePerDimension Calibration = iota
+	// CalibrateGlobal uses a single minimum and maximum for every dimension.
+	CalibrateGlobal
+)
+
+// String returns the name of the calibration.
+func (c Calibration) String() string {
+	switch c {
+	case CalibratePerDimension:
+		return "per-dimension"
+	case CalibrateGlobal:
+		return "global"
+	default:
+		return fmt.Sprintf("Calibration(%d)", int(c))
+	}
+}
+
+// quantizerVersion is the encoding version written by ScalarQuantizer.MarshalBinary.
+const quantizerVersion = 1
+
+// ScalarQuantizer maps float32 vectors onto int8 codes, one byte per component.
+// Component i is stored as the code c in [-128, 127] for which
+// offset[i] + scale[i]*c is closest to its value; values outside the
+// calibrated range are clamped. Quantized vectors take a quarter of the memory
+// of float32 vectors, at the cost of a small error in every score.
+type ScalarQuantizer struct {
+	// calibration records how the ranges were derived.
+	calibration Calibration
+	// offset is the value of code 0 for each dimension.
+	offset []float32
+	// scale is the value step between consecutive codes for each dimension.
+	scale []float32
+}
+
+// TrainScalarQuantizer calibrates a quantizer on the value ranges of vecs.
+// Returns an error if vecs is empty or the vectors have different or zero dimensions.
+func TrainScalarQuantizer(vecs [][]float32, c Calibration) (*ScalarQuantizer, error) {
+	if len(vecs) == 0 {
+		return nil, errors.New("vector: cannot train a quantizer without vectors")
+	}
+	dim := len(vecs[0])
+	if dim == 0 {
+		return nil, errors.New("vector: cannot train a quantizer on empty vectors")
+	}
+	if c != CalibratePerDimension && c != CalibrateGlobal {
+		return nil, fmt.Errorf("vector: unknown calibration %d", int(c))
+	}
+
+	lo := make([]float32, dim)
+	hi := make([]float32, dim)
+	copy(lo, vecs[0])
+	copy(hi, vecs[0])
+	for _, v := range vecs {
+		if len(v) != dim {
+			return nil, errors.New("vector: cannot train a quantizer on vectors of different lengths")
+		}
+		for i, x := range v {
+			lo[i] = min(lo[i], x)
+			hi[i] = max(hi[i], x)
+		}
+	}
+	if c == CalibrateGlobal {
+		gl, gh := lo[0], hi[0]
+		for i := range lo {
+			gl, gh = min(gl, lo[i]), max(gh, hi[i])
+		}
+		for i := range lo {
+			lo[i], hi[i] = gl, gh
+		}
+	}
+
+	q := &ScalarQuantizer{calibration: c, offset: lo, scale: hi}
+	for i := range lo {
+		// Code -128 maps to the minimum and 127 to the maximum.
+		q.scale[i] = (hi[i] - lo[i]) / 255
+		q.offset[i] = lo[i] + 128*q.scale[i]
+	}
+	return q, nil
+}
+
+// Dim returns the dimension of the vectors the quantizer was trained on.
+func (q *ScalarQuantizer) Dim() int { return len(q.scale) }
+
+// Calibration returns how the quantizer was calibrated.
+func (q *ScalarQuantizer) Calibration() Calibration { return q.calibration }
+
+// Encode quantizes v into dst, growing it if needed, and returns it.
+//
+// This function will panic if v does not have the quantizer's dimension.
+func (q *ScalarQuantizer) Encode(dst []int8, v []float32) []int8 {
+	if len(v) != q.Dim() {
+		panic("vector: Encode requires a vector of the quantizer's dimension")
+	}
+	if cap(dst) < len(v) {
+		dst = make([]int8, len(v))
+	}
+	dst = dst[:len(v)]
+	for i, x := range v {
+		var c float32
+		if q.scale[i] > 0 {
+			c = float32(math.Round(float64((x - q.offset[i]) / q.scale[i])))
+		}
+		dst[i] = int8(min(max(c, -128), 127))
+	}
+	return dst
+}
+
+// Decode reconstructs the vector of the codes c into dst, growing it if needed, and returns it.
+//
+// This function will panic if c does not have the quantizer's dimension.
+func (q *ScalarQuantizer) Decode(dst []float32, c []int8) []float32 {
+	if len(c) != q.Dim() {
+		panic("vector: Decode requires codes of the quantizer's dimension")
+	}
+	if cap(dst) < len(c) {
+		dst = make([]float32, len(c))
+	}
+	dst = dst[:len(c)]
+	for i, x := range c {
+		dst[i] = q.offset[i] + q.scale[i]*float32(x)
+	}
+	return dst
+}
+
+// MarshalBinary encodes the quantizer in a versioned little-endian layout.
+func (q *ScalarQuantizer) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 0, 6+8*q.Dim())
+	b = append(b, quantizerVersion, byte(q.calibration))
+	b = binary.LittleEndian.AppendUint32(b, uint32(q.Dim()))
+	for i := range q.scale {
+		b = binary.LittleEndian.AppendUint32(b, math.Float32bits(q.offset[i]))
+		b = binary.LittleEndian.AppendUint32(b, math.Float32bits(q.scale[i]))
+	}
+	return b, nil
+}
+
+// UnmarshalBinary decodes a quantizer encoded by MarshalBinary.
+func (q *ScalarQuantizer) UnmarshalBinary(b []byte) error {
+	if len(b) < 6 {
+		return errors.New("vector: quantizer encoding is truncated")
+	}
+	if b[0] != quantizerVersion {
+		return fmt.Errorf("vector: unsupported quantizer version %d", b[0])
+	}
+	dim := binary.LittleEndian.Uint32(b[2:])
+	if uint64(len(b)-6) != 8*uint64(dim) {
+		return errors.New("vector: quantizer encoding is truncated")
+	}
+
+	q.calibration = Calibration(b[1])
+	q.offset = make([]float32, dim)
+	q.scale = make([]float32, dim)
+	for i, p := 0, b[6:]; i < int(dim); i, p = i+1, p[8:] {
+		q.offset[i] = math.Float32frombits(binary.LittleEndian.Uint32(p))
+		q.scale[i] = math.Float32frombits(binary.LittleEndian.Uint32(p[4:]))
+	}
+	return nil
+}
+
+// QuantizedScorer scores int8 codes against a float32 query under a metric
+// without reconstructing the stored vectors. Dot products are computed as
+// DotFloat32Int8 of the query, pre-multiplied by the scales, with the codes.
+// Cosine scores use the full-precision norms kept next to the codes, and
+// Euclidean scores are derived from the dot product and both norms.
+// Manhattan and Hamming scores decode each vector before scoring it.
+//
+// A QuantizedScorer reuses an internal buffer and must not be used concurrently.
+type QuantizedScorer struct {
+	q      *ScalarQuantizer
+	metric Metric
+	query  []float32
+	norm   float32
+	// weights holds query[i] * scale[i].
+	weights []float32
+	// bias is the dot product of the query with the offsets.
+	bias float32
+	buf  []float32
+}
+
+// Scorer returns a scorer of codes against query under m.
+//
+// This function will panic if query does not have the quantizer's dimension.
+func (q *ScalarQuantizer) Scorer(m Metric, query []float32) *QuantizedScorer {
+	if len(query) != q.Dim() {
+		panic("vector: Scorer requires a query of the quantizer's dimension")
+	}
+	s := &QuantizedScorer{
+		q:       q,
+		metric:  m,
+		query:   query,
+		norm:    Norm(query),
+		weights: make([]float32, len(query)),
+	}
+	for i, x := range query {
+		s.weights[i] = x * q.scale[i]
+		s.bias += x * q.offset[i]
+	}
+	return s
+}
+
+// Score returns the approximate similarity of the query and the vector
+// quantized as c, whose full-precision L2 norm is norm.
+// Cosine scores involving a zero-magnitude vector are 0.
+func (s *QuantizedScorer) Score(c []int8, norm float32) float32 {
+	switch s.metric {
+	case MetricCosine:
+		if s.norm == 0 || norm == 0 {
+			return 0
+		}
+		return s.dot(c) / (s.norm * norm)
+	case MetricDot:
+		return s.dot(c)
+	case MetricEuclidean:
+		d := s.norm*s.norm + norm*norm - 2*s.dot(c)
+		return 1 / (1 + float32(math.Sqrt(float64(max(d, 0)))))
+	default:
+		s.buf = s.q.Decode(s.buf, c)
+		return s.metric.ScoreNorms(s.query, s.buf, s.norm, norm)
+	}
+}
+
+// dot returns the approximate dot product of the query and the vector quantized as c.
+func (s *QuantizedScorer) dot(c []int8) float32 {
+	return DotFloat32Int8(s.weights, c) + s.bias
+}
+
+// DotInt8 returns the dot product of two int8 vectors, accumulated in int32.
+// The result cannot overflow for vectors of up to 2^16 components.
+//
+// This function will panic if the vectors have different lengths.
+func DotInt8(a, b []int8) int32 {
+	if len(a) != len(b) {
+		panic("vector: DotInt8 requires vectors of equal length")
+	}
+	var s0, s1, s2, s3 int32
+	i := 0
+	for ; i+4 <= len(a); i += 4 {
+		s0 += int32(a[i]) * int32(b[i])
+		s1 += int32(a[i+1]) * int32(b[i+1])
+		s2 += int32(a[i+2]) * int32(b[i+2])
+		s3 += int32(a[i+3]) * int32(b[i+3])
+	}
+	for ; i < len(a); i++ {
+		s0 += int32(a[i]) * int32(b[i])
+	}
+	return s0 + s1 + s2 + s3
+}
+
+// DotFloat32Int8 returns the dot product of a float32 vector and an int8 vector.
+// It scores quantized vectors against a full-precision query.
+//
+// This function will panic if the vectors have different lengths.
+func DotFloat32Int8(a []float32, b []int8) float32 {
+	if len(a) != len(b) {
+		panic("vector: DotFloat32Int8 requires vectors of equal length")
+	}
+	var s0, s1, s2, s3 float32
+	i := 0
+	for ; i+4 <= len(a); i += 4 {
+		s0 += a[i] * float32(b[i])
+		s1 += a[i+1] * float32(b[i+1])
+		s2 += a[i+2] * float32(b[i+2])
+		s3 += a[i+3] * float32(b[i+3])
+	}
+	for ; i < len(a); i++ {
+		s0 += a[i] * float32(b[i])
+	}
+	return s0 + s1 + s2 + s3
+}
+
+// Reservoir draws a uniform random sample of bounded size from a stream of
+// vectors, for training quantizers on large stores without loading every
+// vector into memory.
+type Reservoir struct {
+	n    int
+	seen int
+	rng  *rand.Rand
+	vecs [][]float32
+}
+
+// NewReservoir returns a reservoir that keeps up to n vectors, or every vector
+// if n <= 0, choosing them with a random generator initialized with seed.
+func NewReservoir(n int, seed int64) *Reservoir {
+	return &Reservoir{n: n, rng: rand.New(rand.NewSource(seed))}
+}
+
+// Add offers v to the sample. The reservoir keeps a reference to v.
+func (r *Reservoir) Add(v []float32) {
+	r.seen++
+	if r.n <= 0 || len(r.vecs) < r.n {
+		r.vecs = append(r.vecs, v)
+		return
+	}
+	if i := r.rng.Intn(r.seen); i < r.n {
+		r.vecs[i] = v
+	}
+}
+
+// Sample returns the sampled vectors.
+func (r *Reservoir) Sample() [][]float32 { return r.vecs }
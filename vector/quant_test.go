@@ -0,0 +1,186 @@
+package vector
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestScalarQuantizerRoundTrip(t *testing.T) {
+	vecs := [][]float32{{-1, 0, 10}, {1, 0.5, 20}, {0, 1, 15}}
+
+	for _, c := range []Calibration{CalibratePerDimension, CalibrateGlobal} {
+		q, err := TrainScalarQuantizer(vecs, c)
+		if err != nil {
+			t.Fatalf("%s: TrainScalarQuantizer failed: %v", c, err)
+		}
+		if q.Dim() != 3 || q.Calibration() != c {
+			t.Fatalf("%s: unexpected quantizer dim %d, calibration %s", c, q.Dim(), q.Calibration())
+		}
+
+		// The error of each component is at most half a step of its range.
+		step := []float32{2.0 / 255, 1.0 / 255, 10.0 / 255}
+		if c == CalibrateGlobal {
+			step = []float32{21.0 / 255, 21.0 / 255, 21.0 / 255}
+		}
+		for _, v := range vecs {
+			got := q.Decode(nil, q.Encode(nil, v))
+			for i := range v {
+				if d := math.Abs(float64(got[i] - v[i])); d > float64(step[i])/2+1e-5 {
+					t.Errorf("%s: component %d of %v decoded as %v", c, i, v, got[i])
+				}
+			}
+		}
+	}
+}
+
+func TestScalarQuantizerClampsAndReuses(t *testing.T) {
+	q, _ := TrainScalarQuantizer([][]float32{{0, 5}, {1, 5}}, CalibratePerDimension)
+
+	buf := make([]int8, 0, 4)
+	codes := q.Encode(buf, []float32{-3, 7})
+	if &codes[0] != &buf[:1][0] {
+		t.Error("Expected Encode to reuse dst")
+	}
+	if codes[0] != -128 {
+		t.Errorf("Expected values below the range to clamp to -128, got %d", codes[0])
+	}
+	// A constant dimension decodes to its only value.
+	if got := q.Decode(nil, codes); got[1] != 5 {
+		t.Errorf("Expected constant dimension to decode to 5, got %v", got[1])
+	}
+}
+
+func TestTrainScalarQuantizerErrors(t *testing.T) {
+	if _, err := TrainScalarQuantizer(nil, CalibratePerDimension); err == nil {
+		t.Error("Expected error for no vectors, got nil")
+	}
+	if _, err := TrainScalarQuantizer([][]float32{{}}, CalibratePerDimension); err == nil {
+		t.Error("Expected error for empty vectors, got nil")
+	}
+	if _, err := TrainScalarQuantizer([][]float32{{1, 2}, {1}}, CalibratePerDimension); err == nil {
+		t.Error("Expected error for mismatched lengths, got nil")
+	}
+	if _, err := TrainScalarQuantizer([][]float32{{1}}, Calibration(9)); err == nil {
+		t.Error("Expected error for unknown calibration, got nil")
+	}
+}
+
+func TestScalarQuantizerMarshal(t *testing.T) {
+	q, _ := TrainScalarQuantizer([][]float32{{-1, 2}, {3, 4}}, CalibrateGlobal)
+	b, err := q.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+
+	var got ScalarQuantizer
+	if err := got.UnmarshalBinary(b); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+	v := []float32{0.5, 3}
+	if got.Calibration() != CalibrateGlobal || !equalFloats(got.Decode(nil, got.Encode(nil, v)), q.Decode(nil, q.Encode(nil, v))) {
+		t.Error("Expected the unmarshaled quantizer to match the original")
+	}
+	if err := got.UnmarshalBinary(b[:len(b)-1]); err == nil {
+		t.Error("Expected error for truncated encoding, got nil")
+	}
+}
+
+func TestQuantizedScorer(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	vecs := make([][]float32, 50)
+	for i := range vecs {
+		vecs[i] = make([]float32, 32)
+		for j := range vecs[i] {
+			vecs[i][j] = float32(rng.NormFloat64())
+		}
+	}
+	q, _ := TrainScalarQuantizer(vecs, CalibratePerDimension)
+	query := vecs[7]
+
+	for m := range metricNames {
+		s := q.Scorer(m, query)
+		for _, v := range vecs[:10] {
+			want := m.ScoreNorms(query, v, Norm(query), Norm(v))
+			if m == MetricManhattan || m == MetricHamming {
+				// These metrics score the decoded vector exactly.
+				want = m.ScoreNorms(query, q.Decode(nil, q.Encode(nil, v)), Norm(query), Norm(v))
+			}
+			got := s.Score(q.Encode(nil, v), Norm(v))
+			tol := 0.05 * max(1, float32(math.Abs(float64(want))))
+			if math.Abs(float64(got-want)) > float64(tol) {
+				t.Errorf("%s: expected score near %v, got %v", m, want, got)
+			}
+		}
+	}
+}
+
+func TestInt8Kernels(t *testing.T) {
+	a := []int8{1, -2, 3, 127, -128}
+	b := []int8{4, 5, -6, 127, -128}
+	// 4 - 10 - 18 + 16129 + 16384
+	if got := DotInt8(a, b); got != 32489 {
+		t.Errorf("DotInt8 expected 32489, got %d", got)
+	}
+	if got := DotFloat32Int8([]float32{0.5, 1, -1, 2, 0}, b); got != 2+5+6+254 {
+		t.Errorf("DotFloat32Int8 expected 267, got %v", got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic for mismatched lengths")
+		}
+	}()
+	DotInt8(a, b[:2])
+}
+
+func equalFloats(a, b []float32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func BenchmarkDotFloat32Int8(b *testing.B) {
+	q := makeVec(1536)
+	c := make([]int8, 1536)
+	for i := range c {
+		c[i] = int8(i)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = DotFloat32Int8(q, c)
+	}
+}
+
+func TestReservoir(t *testing.T) {
+	r := NewReservoir(10, 1)
+	for i := 0; i < 1000; i++ {
+		r.Add([]float32{float32(i)})
+	}
+	sample := r.Sample()
+	if len(sample) != 10 {
+		t.Fatalf("Expected 10 sampled vectors, got %d", len(sample))
+	}
+	late := 0
+	for _, v := range sample {
+		if v[0] >= 500 {
+			late++
+		}
+	}
+	if late == 0 || late == 10 {
+		t.Errorf("Expected the sample to span the stream, got %v", sample)
+	}
+
+	all := NewReservoir(0, 1)
+	all.Add([]float32{1})
+	all.Add([]float32{2})
+	if len(all.Sample()) != 2 {
+		t.Errorf("Expected an unbounded reservoir to keep every vector, got %v", all.Sample())
+	}
+}
@@ -0,0 +1,160 @@
+package vector
+
+import "fmt"
+
+const (
+	// batchRows is the number of rows a QueryBatch buffers before scoring
+	// them against every query.
+	batchRows = 1024
+	// batchQueries is the number of queries a QueryBatch scores against a
+	// block of rows at a time, which bounds its buffer of dot products.
+	batchQueries = 256
+)
+
+// QueryBatch selects the top k rows for each of many queries of one
+// dimension in a single pass over the rows. Rows are buffered in blocks, and
+// under MetricCosine and MetricDot each block is scored against the queries
+// with DotMatrixFlat, so that a row is read from memory once per block of
+// queries rather than once per query. Other metrics score each pair of query
+// and row with Metric.ScoreNorms.
+//
+// Scores are exactly those of Arena.Search and Metric.ScoreNorms, and, as
+// there, under MetricCosine rows with a zero norm are skipped and a zero
+// query gets no results. A QueryBatch is not safe for concurrent use.
+type QueryBatch struct {
+	dim     int
+	metric  Metric
+	queries []float32
+	qnorms  []float32
+	tops    []*TopK
+	n       int
+
+	// rows, norms and ids hold the buffered block, the first row of which
+	// was the pos-th row pushed.
+	rows  []float32
+	norms []float32
+	ids   []string
+	pos   int
+	dots  []float32
+}
+
+// NewQueryBatch returns a QueryBatch that keeps the top k rows for each query
+// under metric m. If k <= 0, every row is kept. The queries are copied.
+//
+// This function will panic if the queries are empty, do not all have the
+// same dimension, or m is not a valid metric.
+func NewQueryBatch(queries [][]float32, k int, m Metric) *QueryBatch {
+	if len(queries) == 0 || len(queries[0]) == 0 {
+		panic("vector: NewQueryBatch requires non-empty queries")
+	}
+	if !m.Valid() {
+		panic(fmt.Sprintf("vector: unknown metric %d", int(m)))
+	}
+	dim := len(queries[0])
+	b := &QueryBatch{
+		dim:     dim,
+		metric:  m,
+		queries: make([]float32, 0, len(queries)*dim),
+		qnorms:  make([]float32, len(queries)),
+		tops:    make([]*TopK, len(queries)),
+	}
+	for i, q := range queries {
+		if len(q) != dim {
+			panic(fmt.Sprintf("vector: NewQueryBatch requires queries of dimension %d, got %d", dim, len(q)))
+		}
+		b.queries = append(b.queries, q...)
+		b.qnorms[i] = Norm(q)
+		b.tops[i] = NewTopK(k)
+	}
+	return b
+}
+
+// Dim returns the dimension of the queries.
+func (b *QueryBatch) Dim() int { return b.dim }
+
+// Push adds a copy of the row vec, stored under id with L2 norm norm, to the
+// candidates of every query. The candidates it makes have as Pos the number
+// of rows pushed before it.
+//
+// This function will panic if vec does not have the dimension of the queries.
+func (b *QueryBatch) Push(id string, vec []float32, norm float32) {
+	if len(vec) != b.dim {
+		panic(fmt.Sprintf("vector: QueryBatch.Push requires a vector of dimension %d, got %d", b.dim, len(vec)))
+	}
+	if b.rows == nil {
+		b.rows = make([]float32, 0, batchRows*b.dim)
+	}
+	b.rows = append(b.rows, vec...)
+	b.norms = append(b.norms, norm)
+	b.ids = append(b.ids, id)
+	b.n++
+	if len(b.ids) == batchRows {
+		b.flush()
+	}
+}
+
+// Results scores the rows still buffered and returns the top k candidates of
+// each query, in query order, ranked like TopK. It resets the QueryBatch.
+func (b *QueryBatch) Results() [][]Candidate {
+	b.flush()
+	out := make([][]Candidate, len(b.tops))
+	for i, top := range b.tops {
+		out[i] = top.Results()
+	}
+	b.n, b.pos = 0, 0
+	return out
+}
+
+// flush scores the buffered rows and empties the buffer.
+func (b *QueryBatch) flush() {
+	b.score(b.ids, b.rows, b.norms, b.pos)
+	clear(b.ids)
+	b.rows, b.norms, b.ids = b.rows[:0], b.norms[:0], b.ids[:0]
+	b.pos = b.n
+}
+
+// score scores the contiguous rows, stored under ids with the given norms,
+// against every query and pushes them to the queries' TopKs with Pos
+// starting at pos.
+func (b *QueryBatch) score(ids []string, rows, norms []float32, pos int) {
+	n := len(ids)
+	if n == 0 {
+		return
+	}
+	if b.metric != MetricCosine && b.metric != MetricDot {
+		for q, top := range b.tops {
+			query := b.queries[q*b.dim : (q+1)*b.dim]
+			for i := 0; i < n; i++ {
+				score := b.metric.ScoreNorms(query, rows[i*b.dim:(i+1)*b.dim], b.qnorms[q], norms[i])
+				if top.Admits(score) {
+					top.Push(Candidate{ID: ids[i], Score: score, Pos: pos + i})
+				}
+			}
+		}
+		return
+	}
+
+	for qlo := 0; qlo < len(b.tops); qlo += batchQueries {
+		qhi := min(qlo+batchQueries, len(b.tops))
+		b.dots = DotMatrixFlat(b.dots, b.queries[qlo*b.dim:qhi*b.dim], rows, b.dim)
+		for q := qlo; q < qhi; q++ {
+			qn, top := b.qnorms[q], b.tops[q]
+			if b.metric == MetricCosine && qn == 0 {
+				continue
+			}
+			dots := b.dots[(q-qlo)*n : (q-qlo+1)*n]
+			for i, dot := range dots {
+				score := dot
+				if b.metric == MetricCosine {
+					if norms[i] == 0 {
+						continue
+					}
+					score = dot / (qn * norms[i])
+				}
+				if top.Admits(score) {
+					top.Push(Candidate{ID: ids[i], Score: score, Pos: pos + i})
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package vector
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func TestQueryBatch(t *testing.T) {
+	rng := rand.New(rand.NewSource(4))
+	const n, dim = batchRows + 300, 8
+	a := NewArena(dim)
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("v%05d", i)
+		a.Set(ids[i], randomVec(rng, dim))
+	}
+	// More queries than are scored against a block at a time.
+	queries := make([][]float32, batchQueries+3)
+	for i := range queries {
+		queries[i] = randomVec(rng, dim)
+	}
+
+	for _, m := range []Metric{MetricCosine, MetricDot, MetricEuclidean, MetricHamming} {
+		b := NewQueryBatch(queries, 5, m)
+		for _, id := range ids {
+			vec, norm, _ := a.Get(id)
+			b.Push(id, vec, norm)
+		}
+		got := b.Results()
+		for q, query := range queries {
+			want, _ := a.Search(context.Background(), query, 5, m, nil)
+			// Pos is the push order, which is the row order of the arena.
+			if !slices.Equal(got[q], want) {
+				t.Fatalf("%s: query %d: expected %v, got %v", m, q, want, got[q])
+			}
+		}
+		if again := b.Results(); len(again[0]) != 0 {
+			t.Errorf("%s: expected Results to reset the batch, got %v", m, again[0])
+		}
+	}
+
+	b := NewQueryBatch([][]float32{{1, 0}, {0, 0}}, 0, MetricCosine)
+	b.Push("x", []float32{2, 0}, 2)
+	b.Push("zero", []float32{0, 0}, 0)
+	got := b.Results()
+	if len(got[0]) != 1 || got[0][0].ID != "x" || got[0][0].Score != 1 || len(got[1]) != 0 {
+		t.Errorf("Expected zero vectors and queries to be skipped under cosine, got %v", got)
+	}
+}
+
+func TestQueryBatchPanics(t *testing.T) {
+	for name, fn := range map[string]func(){
+		"no queries":     func() { NewQueryBatch(nil, 1, MetricCosine) },
+		"mixed queries":  func() { NewQueryBatch([][]float32{{1, 2}, {1}}, 1, MetricCosine) },
+		"invalid metric": func() { NewQueryBatch([][]float32{{1}}, 1, Metric(99)) },
+		"push dimension": func() { NewQueryBatch([][]float32{{1}}, 1, MetricCosine).Push("a", []float32{1, 2}, 1) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected a panic", name)
+				}
+			}()
+			fn()
+		}()
+	}
+}
+
+// BenchmarkArenaSearchBatch compares a batch of queries against as many
+// calls to Search, over 100k vectors of dimension 768.
+func BenchmarkArenaSearchBatch(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	const dim = 768
+	a := NewArena(dim)
+	for i := 0; i < 100_000; i++ {
+		a.Set(fmt.Sprint(i), randomVec(rng, dim))
+	}
+	queries := make([][]float32, 64)
+	for i := range queries {
+		queries[i] = randomVec(rng, dim)
+	}
+	b.Run("batch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, _ = a.SearchBatch(context.Background(), queries, 10, MetricCosine)
+		}
+	})
+	b.Run("loop", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, q := range queries {
+				_, _ = a.Search(context.Background(), q, 10, MetricCosine, nil)
+			}
+		}
+	})
+}
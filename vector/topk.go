@@ -0,0 +1,151 @@
+package vector
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Candidate is a scored item offered to a TopK.
+type Candidate struct {
+	// ID identifies the item and breaks ties between equal scores.
+	ID string
+	// Score ranks the item; higher is better.
+	Score float32
+	// Pos is a caller-defined position of the item, such as its index in a
+	// slice, so that the caller can find the item again without a lookup by ID.
+	Pos int
+}
+
+// better reports whether a ranks before b: it has a higher score, or the same
+// score and a smaller ID.
+func better(a, b Candidate) bool {
+	if a.Score != b.Score {
+		return a.Score > b.Score
+	}
+	return a.ID < b.ID
+}
+
+// compareCandidates orders candidates best first for slices.SortFunc.
+func compareCandidates(a, b Candidate) int {
+	if c := cmp.Compare(b.Score, a.Score); c != 0 {
+		return c
+	}
+	return strings.Compare(a.ID, b.ID)
+}
+
+// TopK selects the k best candidates from a stream in O(n log k) time and
+// O(k) memory, keeping them in a bounded min-heap whose root is the worst
+// candidate kept. Candidates rank by descending score, and candidates with
+// equal scores by ascending ID, so the selection does not depend on the order
+// of the stream. Candidates with a NaN score are ignored.
+//
+// A TopK with k <= 0 keeps every candidate.
+type TopK struct {
+	k    int
+	heap []Candidate
+}
+
+// NewTopK returns a TopK that keeps the k best candidates, or every candidate
+// if k <= 0.
+func NewTopK(k int) *TopK {
+	t := &TopK{k: k}
+	if k > 0 {
+		t.heap = make([]Candidate, 0, min(k, 1024))
+	}
+	return t
+}
+
+// Len returns the number of candidates kept.
+func (t *TopK) Len() int { return len(t.heap) }
+
+// Admits reports whether a candidate with the given score could be kept.
+// It lets callers skip the work of building a candidate, such as decoding
+// its ID, when its score is too low; a candidate whose score equals the
+// worst kept score may still be rejected by Push on its ID.
+func (t *TopK) Admits(score float32) bool {
+	if math.IsNaN(float64(score)) {
+		return false
+	}
+	return t.k <= 0 || len(t.heap) < t.k || score >= t.heap[0].Score
+}
+
+// Push offers c and reports whether it was kept. A kept candidate may be
+// evicted by a better one later.
+func (t *TopK) Push(c Candidate) bool {
+	if math.IsNaN(float64(c.Score)) {
+		return false
+	}
+	if t.k <= 0 {
+		t.heap = append(t.heap, c)
+		return true
+	}
+	if len(t.heap) < t.k {
+		t.heap = append(t.heap, c)
+		t.up(len(t.heap) - 1)
+		return true
+	}
+	if !better(c, t.heap[0]) {
+		return false
+	}
+	t.heap[0] = c
+	t.down(0)
+	return true
+}
+
+// Results returns the kept candidates, best first, and resets t.
+// The returned slice is owned by the caller.
+func (t *TopK) Results() []Candidate {
+	out := t.heap
+	t.heap = nil
+	if t.k > 0 {
+		// Pop the worst candidate into the end of the slice until the heap
+		// is empty, leaving the slice sorted best first.
+		for n := len(out) - 1; n > 0; n-- {
+			out[0], out[n] = out[n], out[0]
+			t.heap = out[:n]
+			t.down(0)
+		}
+		t.heap = nil
+	} else {
+		slices.SortFunc(out, compareCandidates)
+	}
+	if out == nil {
+		out = []Candidate{}
+	}
+	return out
+}
+
+// up restores the heap order after the candidate at i was added.
+func (t *TopK) up(i int) {
+	h := t.heap
+	for i > 0 {
+		parent := (i - 1) / 2
+		if !better(h[parent], h[i]) {
+			break
+		}
+		h[parent], h[i] = h[i], h[parent]
+		i = parent
+	}
+}
+
+// down restores the heap order after the candidate at i was replaced.
+func (t *TopK) down(i int) {
+	h := t.heap
+	n := len(h)
+	for {
+		worst := i
+		if l := 2*i + 1; l < n && better(h[worst], h[l]) {
+			worst = l
+		}
+		if r := 2*i + 2; r < n && better(h[worst], h[r]) {
+			worst = r
+		}
+		if worst == i {
+			return
+		}
+		h[i], h[worst] = h[worst], h[i]
+		i = worst
+	}
+}
@@ -0,0 +1,143 @@
+package vector
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+	"sort"
+	"testing"
+)
+
+// sortedCandidates returns the candidates sorted best first with a full sort.
+func sortedCandidates(cands []Candidate) []Candidate {
+	out := slices.Clone(cands)
+	sort.Slice(out, func(i, j int) bool { return better(out[i], out[j]) })
+	return out
+}
+
+func TestTopK(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	cands := make([]Candidate, 1000)
+	for i := range cands {
+		// Few distinct scores, so that many candidates tie.
+		cands[i] = Candidate{ID: fmt.Sprintf("id%04d", rng.Intn(100000)), Score: float32(rng.Intn(20)), Pos: i}
+	}
+	want := sortedCandidates(cands)
+
+	for _, k := range []int{1, 5, 100, 999, 1000, 5000} {
+		topk := NewTopK(k)
+		for _, c := range cands {
+			topk.Push(c)
+		}
+		got := topk.Results()
+		n := min(k, len(cands))
+		if !slices.Equal(got, want[:n]) {
+			t.Errorf("k=%d: results differ from a full sort", k)
+		}
+		if topk.Len() != 0 {
+			t.Errorf("k=%d: expected Results to reset the TopK, got %d kept", k, topk.Len())
+		}
+	}
+
+	// k <= 0 keeps every candidate.
+	for _, k := range []int{0, -1} {
+		topk := NewTopK(k)
+		for _, c := range cands {
+			topk.Push(c)
+		}
+		if got := topk.Results(); !slices.Equal(got, want) {
+			t.Errorf("k=%d: expected every candidate sorted", k)
+		}
+	}
+}
+
+func TestTopKTiesAreDeterministic(t *testing.T) {
+	cands := []Candidate{{ID: "d", Score: 1}, {ID: "b", Score: 1}, {ID: "c", Score: 2}, {ID: "a", Score: 1}, {ID: "e", Score: 1}}
+	for seed := int64(0); seed < 10; seed++ {
+		rng := rand.New(rand.NewSource(seed))
+		rng.Shuffle(len(cands), func(i, j int) { cands[i], cands[j] = cands[j], cands[i] })
+
+		topk := NewTopK(3)
+		for _, c := range cands {
+			topk.Push(c)
+		}
+		var ids []string
+		for _, c := range topk.Results() {
+			ids = append(ids, c.ID)
+		}
+		if fmt.Sprint(ids) != "[c a b]" {
+			t.Fatalf("Expected [c a b] in any stream order, got %v", ids)
+		}
+	}
+}
+
+func TestTopKAdmitsAndNaN(t *testing.T) {
+	topk := NewTopK(2)
+	if !topk.Admits(-100) {
+		t.Error("Expected a TopK that is not full to admit any score")
+	}
+	nan := float32(math.NaN())
+	if topk.Admits(nan) || topk.Push(Candidate{ID: "nan", Score: nan}) {
+		t.Error("Expected NaN scores to be rejected")
+	}
+	topk.Push(Candidate{ID: "b", Score: 1})
+	topk.Push(Candidate{ID: "c", Score: 2})
+	if topk.Admits(0.5) {
+		t.Error("Expected a score below the worst kept score to be rejected")
+	}
+	if !topk.Admits(1) {
+		t.Error("Expected a score equal to the worst kept score to be admitted")
+	}
+	if topk.Push(Candidate{ID: "z", Score: 1}) {
+		t.Error("Expected a tie with a larger ID to be rejected")
+	}
+	if !topk.Push(Candidate{ID: "a", Score: 1}) {
+		t.Error("Expected a tie with a smaller ID to be kept")
+	}
+	if got := topk.Results(); len(got) != 2 || got[0].ID != "c" || got[1].ID != "a" {
+		t.Errorf("Expected [c a], got %v", got)
+	}
+	if got := NewTopK(3).Results(); got == nil || len(got) != 0 {
+		t.Errorf("Expected an empty non-nil slice, got %#v", got)
+	}
+}
+
+// topKCandidates returns n candidates with random scores.
+func topKCandidates(n int) []Candidate {
+	rng := rand.New(rand.NewSource(1))
+	cands := make([]Candidate, n)
+	for i := range cands {
+		cands[i] = Candidate{ID: fmt.Sprint(i), Score: rng.Float32(), Pos: i}
+	}
+	return cands
+}
+
+func BenchmarkTopK1M(b *testing.B) {
+	cands := topKCandidates(1_000_000)
+	for _, k := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprintf("k=%d", k), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				topk := NewTopK(k)
+				for _, c := range cands {
+					if topk.Admits(c.Score) {
+						topk.Push(c)
+					}
+				}
+				topk.Results()
+			}
+		})
+	}
+}
+
+// BenchmarkSortTopK1M measures the full sort that TopK replaces.
+func BenchmarkSortTopK1M(b *testing.B) {
+	cands := topKCandidates(1_000_000)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		all := append([]Candidate(nil), cands...)
+		sort.Slice(all, func(i, j int) bool { return all[i].Score > all[j].Score })
+		_ = all[:10]
+	}
+}